@@ -0,0 +1,218 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"github.com/getupio-undistro/meta"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LabelAccessPolicy is set in the bindings created by an AccessPolicy with the policy name
+const LabelAccessPolicy = "undistro.io/access-policy"
+
+// AccessSubject is a user or a group of the identity provider.
+type AccessSubject struct {
+	// +kubebuilder:validation:Enum=User;Group
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// AccessRule binds the subjects to a ClusterRole.
+type AccessRule struct {
+	Subjects []AccessSubject `json:"subjects"`
+	// ClusterRole is the name of the ClusterRole bound to the subjects, e.g. view, edit or cluster-admin.
+	ClusterRole string `json:"clusterRole"`
+	// Namespaces where the ClusterRole is bound with RoleBindings.
+	// When empty, the ClusterRole is bound in the whole cluster with a ClusterRoleBinding.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// AccessPolicySpec defines the desired state of AccessPolicy
+type AccessPolicySpec struct {
+	Paused bool `json:"paused,omitempty"`
+	// ClusterSelector selects the clusters in the AccessPolicy namespace where the bindings are created.
+	// An empty selector selects all clusters.
+	ClusterSelector metav1.LabelSelector `json:"clusterSelector,omitempty"`
+	Rules           []AccessRule         `json:"rules,omitempty"`
+}
+
+// ClusterAccessPolicyStatus is the set of bindings created in a cluster
+type ClusterAccessPolicyStatus struct {
+	ClusterName     string                   `json:"clusterName"`
+	AppliedBindings []corev1.ObjectReference `json:"appliedBindings,omitempty"`
+	// Message is the last error applying the bindings to the cluster.
+	Message string `json:"message,omitempty"`
+}
+
+// AccessPolicyStatus defines the observed state of AccessPolicy
+type AccessPolicyStatus struct {
+	// ObservedGeneration is the last observed generation.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition          `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+	Clusters   []ClusterAccessPolicyStatus `json:"clusters,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
+// +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].message",description=""
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
+
+// AccessPolicy is the Schema for the accesspolicies API
+type AccessPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AccessPolicySpec   `json:"spec,omitempty"`
+	Status AccessPolicyStatus `json:"status,omitempty"`
+}
+
+// Bindings returns the ClusterRoleBindings and RoleBindings of the policy rules.
+// Subjects of rules with the same ClusterRole and namespace are merged in one binding,
+// named after the policy and the ClusterRole, so the role of a binding never changes.
+// The name carries a short hash of the policy namespace because policies with the
+// same name in different namespaces may target the same cluster.
+func (p *AccessPolicy) Bindings() []client.Object {
+	type bindingKey struct {
+		clusterRole string
+		namespace   string
+	}
+	subjects := make(map[bindingKey][]rbacv1.Subject)
+	keys := make([]bindingKey, 0)
+	for _, rule := range p.Spec.Rules {
+		namespaces := rule.Namespaces
+		if len(namespaces) == 0 {
+			namespaces = []string{""}
+		}
+		for _, ns := range namespaces {
+			key := bindingKey{clusterRole: rule.ClusterRole, namespace: ns}
+			if _, ok := subjects[key]; !ok {
+				keys = append(keys, key)
+			}
+			for _, s := range rule.Subjects {
+				subject := rbacv1.Subject{
+					APIGroup: rbacv1.GroupName,
+					Kind:     s.Kind,
+					Name:     s.Name,
+				}
+				if !containsSubject(subjects[key], subject) {
+					subjects[key] = append(subjects[key], subject)
+				}
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].namespace != keys[j].namespace {
+			return keys[i].namespace < keys[j].namespace
+		}
+		return keys[i].clusterRole < keys[j].clusterRole
+	})
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(p.Namespace)))[:8]
+	objs := make([]client.Object, 0, len(keys))
+	for _, key := range keys {
+		objMeta := metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s-%s", p.Name, hash, key.clusterRole),
+			Namespace: key.namespace,
+			Labels: map[string]string{
+				meta.LabelUndistro: "",
+				LabelAccessPolicy:  p.Name,
+			},
+		}
+		roleRef := rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     key.clusterRole,
+		}
+		if key.namespace == "" {
+			objs = append(objs, &rbacv1.ClusterRoleBinding{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "ClusterRoleBinding",
+					APIVersion: rbacv1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: objMeta,
+				Subjects:   subjects[key],
+				RoleRef:    roleRef,
+			})
+			continue
+		}
+		objs = append(objs, &rbacv1.RoleBinding{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "RoleBinding",
+				APIVersion: rbacv1.SchemeGroupVersion.String(),
+			},
+			ObjectMeta: objMeta,
+			Subjects:   subjects[key],
+			RoleRef:    roleRef,
+		})
+	}
+	return objs
+}
+
+func containsSubject(subjects []rbacv1.Subject, s rbacv1.Subject) bool {
+	for _, subject := range subjects {
+		if subject == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *AccessPolicy) GetStatusConditions() *[]metav1.Condition {
+	return &p.Status.Conditions
+}
+
+func AccessPolicyNotReady(p AccessPolicy, reason, message string) AccessPolicy {
+	meta.SetResourceCondition(&p, meta.ReadyCondition, metav1.ConditionFalse, reason, message)
+	return p
+}
+
+func AccessPolicyPaused(p AccessPolicy) AccessPolicy {
+	meta.SetResourceCondition(&p, meta.ReadyCondition, metav1.ConditionTrue, meta.ReconciliationPausedReason, meta.ReconciliationPausedReason)
+	return p
+}
+
+func AccessPolicyReady(p AccessPolicy) AccessPolicy {
+	msg := "Access policy reconciliation succeeded"
+	meta.SetResourceCondition(&p, meta.ReadyCondition, metav1.ConditionTrue, meta.ReconciliationSucceededReason, msg)
+	return p
+}
+
+//+kubebuilder:object:root=true
+
+// AccessPolicyList contains a list of AccessPolicy
+type AccessPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AccessPolicy{}, &AccessPolicyList{})
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestAccessPolicy_Bindings(t *testing.T) {
+	p := AccessPolicy{}
+	p.Name = "platform"
+	p.Namespace = "default"
+	p.Spec.Rules = []AccessRule{
+		{
+			Subjects:    []AccessSubject{{Kind: "Group", Name: "sre"}},
+			ClusterRole: "cluster-admin",
+		},
+		{
+			Subjects:    []AccessSubject{{Kind: "Group", Name: "developers"}, {Kind: "User", Name: "jane@example.com"}},
+			ClusterRole: "edit",
+			Namespaces:  []string{"web", "api"},
+		},
+		{
+			Subjects:    []AccessSubject{{Kind: "Group", Name: "developers"}, {Kind: "Group", Name: "qa"}},
+			ClusterRole: "edit",
+			Namespaces:  []string{"web"},
+		},
+	}
+	objs := p.Bindings()
+	if len(objs) != 3 {
+		t.Fatalf("Bindings() returned %d objects, want 3", len(objs))
+	}
+	crb, ok := objs[0].(*rbacv1.ClusterRoleBinding)
+	if !ok || crb.Name != "platform-37a8eec1-cluster-admin" || crb.RoleRef.Name != "cluster-admin" {
+		t.Errorf("Bindings()[0] = %v, want the cluster-admin ClusterRoleBinding", objs[0])
+	}
+	if crb != nil && crb.Labels[LabelAccessPolicy] != "platform" {
+		t.Errorf("Bindings()[0] labels = %v", crb.Labels)
+	}
+	names := make([]string, 0)
+	for _, o := range objs[1:] {
+		rb, ok := o.(*rbacv1.RoleBinding)
+		if !ok {
+			t.Fatalf("Bindings() = %v, want RoleBinding", o)
+		}
+		names = append(names, rb.Namespace+"/"+rb.Name)
+		if rb.Namespace != "web" {
+			continue
+		}
+		subjects := make([]string, 0, len(rb.Subjects))
+		for _, s := range rb.Subjects {
+			subjects = append(subjects, s.Kind+":"+s.Name)
+		}
+		want := []string{"Group:developers", "User:jane@example.com", "Group:qa"}
+		if !reflect.DeepEqual(subjects, want) {
+			t.Errorf("web subjects = %v, want %v", subjects, want)
+		}
+	}
+	if want := []string{"api/platform-37a8eec1-edit", "web/platform-37a8eec1-edit"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("RoleBindings = %v, want %v", names, want)
+	}
+	other := p.DeepCopy()
+	other.Namespace = "team"
+	if got := other.Bindings()[0].GetName(); got == objs[0].GetName() {
+		t.Errorf("Bindings() of policies in different namespaces share the name %q", got)
+	}
+}
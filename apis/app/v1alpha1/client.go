@@ -24,3 +24,9 @@ var (
 	// k8sClient as variable to use in webhooks https://github.com/kubernetes-sigs/kubebuilder/issues/1216#issuecomment-559570858
 	k8sClient client.Client
 )
+
+// SetClient sets the client used by the webhooks to read other objects,
+// so the validation can run outside the manager, like in the CLI before submitting objects.
+func SetClient(c client.Client) {
+	k8sClient = c
+}
@@ -22,6 +22,7 @@ import (
 	"strings"
 
 	"github.com/getupio-undistro/meta"
+	"github.com/getupio-undistro/undistro/pkg/util"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -87,6 +88,63 @@ type WorkerNode struct {
 	Autoscale               Autoscaling             `json:"autoscaling,omitempty"`
 	InfraNode               bool                    `json:"infraNode,omitempty"`
 	LaunchTemplateReference LaunchTemplateReference `json:"launchTemplateReference,omitempty"`
+	// Spot configures the purchase of spot instances for this pool.
+	// Only supported in AWS.
+	Spot *Spot `json:"spot,omitempty"`
+	// Instance types used in addition to machineType to launch the pool instances.
+	// Only supported in AWS.
+	AlternativeMachineTypes []string `json:"alternativeMachineTypes,omitempty"`
+	// Accelerator marks the pool as an accelerator pool. The nodes are labeled and tainted
+	// and the drivers and device plugin of the accelerator are installed in the cluster.
+	// The label and taint are removed when the accelerator is removed.
+	Accelerator *Accelerator `json:"accelerator,omitempty"`
+}
+
+// MixedInstances returns true when the pool can launch more than one
+// purchase option or instance type.
+func (w WorkerNode) MixedInstances() bool {
+	return (w.Spot != nil && w.Spot.Enabled) || len(w.AlternativeMachineTypes) > 0
+}
+
+// MachineTypes returns machineType followed by the alternative machine types.
+func (w WorkerNode) MachineTypes() []string {
+	return append([]string{w.MachineType}, w.AlternativeMachineTypes...)
+}
+
+type Spot struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// The minimum amount of the pool capacity that must be fulfilled by on-demand instances.
+	// +kubebuilder:validation:Minimum=0
+	OnDemandBaseCapacity *int64 `json:"onDemandBaseCapacity,omitempty"`
+	// The percentage of on-demand instances for the capacity above onDemandBaseCapacity.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	OnDemandPercentageAboveBaseCapacity *int64 `json:"onDemandPercentageAboveBaseCapacity,omitempty"`
+	// How spot instances are allocated across the instance types of the pool.
+	// +kubebuilder:validation:Enum=lowest-price;capacity-optimized
+	AllocationStrategy string `json:"allocationStrategy,omitempty"`
+	// The maximum hourly price in USD paid for a spot instance of the pool.
+	// Not supported by AWS machine pools yet, spot instances are capped at the on-demand price.
+	// +kubebuilder:validation:Pattern=`^[0-9]*\.?[0-9]+$`
+	MaxPrice string `json:"maxPrice,omitempty"`
+}
+
+// LabelAccelerator is the node label set with the accelerator type of the pool.
+const LabelAccelerator = "undistro.io/accelerator"
+
+var acceleratorResources = map[string]string{
+	"nvidia": "nvidia.com/gpu",
+}
+
+type Accelerator struct {
+	// Manufacturer of the accelerators attached to the machine types of the pool.
+	// +kubebuilder:validation:Enum=nvidia
+	Type string `json:"type"`
+}
+
+// ResourceName returns the extended resource advertised by the accelerator device plugin.
+func (a Accelerator) ResourceName() string {
+	return acceleratorResources[a.Type]
 }
 
 type Autoscaling struct {
@@ -164,6 +222,66 @@ type Bastion struct {
 	InstanceType        string   `json:"instanceType,omitempty"`
 }
 
+// ClusterAutoscaler configures the cluster-autoscaler installed in the workload cluster.
+type ClusterAutoscaler struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// How long after scale up that scale down evaluation resumes.
+	ScaleDownDelayAfterAdd *metav1.Duration `json:"scaleDownDelayAfterAdd,omitempty"`
+	// Node utilization level, defined as sum of requested resources divided by capacity,
+	// below which a node can be considered for scale down. Must be a decimal between 0 and 1.
+	ScaleDownUtilizationThreshold string `json:"scaleDownUtilizationThreshold,omitempty"`
+	// Type of node group expander to be used in scale up.
+	// +kubebuilder:validation:Enum=random;most-pods;least-waste;price;priority
+	Expander string `json:"expander,omitempty"`
+	// Detect similar node groups and balance the number of nodes between them.
+	BalanceSimilarNodeGroups bool `json:"balanceSimilarNodeGroups,omitempty"`
+	// Maximum time the autoscaler waits for a node to be provisioned.
+	MaxNodeProvisionTime *metav1.Duration `json:"maxNodeProvisionTime,omitempty"`
+}
+
+// ExtraArgs returns the cluster-autoscaler command line arguments
+// in the format expected by the chart extraArgs value.
+func (a ClusterAutoscaler) ExtraArgs() map[string]interface{} {
+	args := map[string]interface{}{
+		"write-status-configmap":      true,
+		"balance-similar-node-groups": a.BalanceSimilarNodeGroups,
+	}
+	if a.ScaleDownDelayAfterAdd != nil {
+		args["scale-down-delay-after-add"] = a.ScaleDownDelayAfterAdd.Duration.String()
+	}
+	if a.ScaleDownUtilizationThreshold != "" {
+		args["scale-down-utilization-threshold"] = a.ScaleDownUtilizationThreshold
+	}
+	if a.Expander != "" {
+		args["expander"] = a.Expander
+	}
+	if a.MaxNodeProvisionTime != nil {
+		args["max-node-provision-time"] = a.MaxNodeProvisionTime.Duration.String()
+	}
+	return args
+}
+
+// ClusterAutoscalerStatus reflects the cluster-wide section of the
+// cluster-autoscaler-status ConfigMap of the workload cluster.
+type ClusterAutoscalerStatus struct {
+	Health      string `json:"health,omitempty"`
+	ScaleUp     string `json:"scaleUp,omitempty"`
+	ScaleDown   string `json:"scaleDown,omitempty"`
+	LastUpdated string `json:"lastUpdated,omitempty"`
+}
+
+// EstimatedCost of the cluster infrastructure using on-demand prices.
+// The minimum and maximum values differ when worker pools have autoscaling enabled.
+type EstimatedCost struct {
+	Currency       string `json:"currency,omitempty"`
+	MinHourlyCost  string `json:"minHourlyCost,omitempty"`
+	MaxHourlyCost  string `json:"maxHourlyCost,omitempty"`
+	MinMonthlyCost string `json:"minMonthlyCost,omitempty"`
+	MaxMonthlyCost string `json:"maxMonthlyCost,omitempty"`
+	// Region of the prices used, they may differ from the prices of the cluster region.
+	PriceRegion string `json:"priceRegion,omitempty"`
+}
+
 type ConciergeInfo struct {
 	Endpoint string `json:"endpoint,omitempty"`
 	CABundle string `json:"caBundle,omitempty"`
@@ -178,6 +296,7 @@ type ClusterSpec struct {
 	Bastion                *Bastion               `json:"bastion,omitempty"`
 	ControlPlane           *ControlPlaneNode      `json:"controlPlane,omitempty"`
 	Workers                []WorkerNode           `json:"workers,omitempty"`
+	Autoscaler             *ClusterAutoscaler     `json:"autoscaler,omitempty"`
 }
 
 // ClusterStatus defines the observed state of Cluster
@@ -189,16 +308,18 @@ type ClusterStatus struct {
 	// +patchStrategy=merge
 	// +listType=map
 	// +listMapKey=type
-	Conditions          []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
-	TotalWorkerReplicas int32              `json:"totalWorkerReplicas,omitempty"`
-	TotalWorkerPools    int32              `json:"totalWorkerPools,omitempty"`
-	BastionPublicIP     string             `json:"bastionPublicIP,omitempty"`
-	LastUsedUID         string             `json:"lastUsedUID,omitempty"`
-	BastionConfig       *Bastion           `json:"bastionConfig,omitempty"`
-	KubernetesVersion   string             `json:"kubernetesVersion,omitempty"`
-	ControlPlane        ControlPlaneNode   `json:"controlPlane,omitempty"`
-	Workers             []WorkerNode       `json:"workers,omitempty"`
-	ConciergeInfo       *ConciergeInfo     `json:"conciergeInfo,omitempty"`
+	Conditions          []metav1.Condition       `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+	TotalWorkerReplicas int32                    `json:"totalWorkerReplicas,omitempty"`
+	TotalWorkerPools    int32                    `json:"totalWorkerPools,omitempty"`
+	BastionPublicIP     string                   `json:"bastionPublicIP,omitempty"`
+	LastUsedUID         string                   `json:"lastUsedUID,omitempty"`
+	BastionConfig       *Bastion                 `json:"bastionConfig,omitempty"`
+	KubernetesVersion   string                   `json:"kubernetesVersion,omitempty"`
+	ControlPlane        ControlPlaneNode         `json:"controlPlane,omitempty"`
+	Workers             []WorkerNode             `json:"workers,omitempty"`
+	ConciergeInfo       *ConciergeInfo           `json:"conciergeInfo,omitempty"`
+	Autoscaler          *ClusterAutoscalerStatus `json:"autoscaler,omitempty"`
+	EstimatedCost       *EstimatedCost           `json:"estimatedCost,omitempty"`
 }
 
 // +genclient
@@ -233,6 +354,27 @@ func (c Cluster) HasInfraNodes() bool {
 	return false
 }
 
+// AutoscalerEnabled reports if the cluster-autoscaler must be installed,
+// either through the spec or the legacy annotation.
+func (c Cluster) AutoscalerEnabled() bool {
+	if c.Spec.Autoscaler != nil && c.Spec.Autoscaler.Enabled {
+		return true
+	}
+	_, ok := c.Annotations[meta.EnableClusterAutoscaler]
+	return ok
+}
+
+// Accelerators returns the accelerator types used by the worker pools.
+func (c Cluster) Accelerators() []string {
+	types := make([]string, 0)
+	for _, w := range c.Spec.Workers {
+		if w.Accelerator != nil && !util.ContainsStringInSlice(types, w.Accelerator.Type) {
+			types = append(types, w.Accelerator.Type)
+		}
+	}
+	return types
+}
+
 func (c Cluster) GetTemplate() string {
 	return fmt.Sprintf("%s/%s", c.Spec.InfrastructureProvider.Name, c.Spec.InfrastructureProvider.Flavor)
 }
@@ -20,10 +20,12 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"unicode"
 
 	"github.com/getupio-undistro/meta"
+	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
 	"github.com/getupio-undistro/undistro/pkg/util"
 	"github.com/getupio-undistro/undistro/pkg/version"
 	corev1 "k8s.io/api/core/v1"
@@ -96,6 +98,25 @@ func (r *Cluster) Default() {
 				Effect: corev1.TaintEffectNoSchedule,
 			}}
 		}
+		defaultAccelerator(&r.Spec.Workers[i])
+		spot := r.Spec.Workers[i].Spot
+		if spot != nil && spot.Enabled {
+			var zero int64
+			if spot.OnDemandBaseCapacity == nil {
+				spot.OnDemandBaseCapacity = &zero
+			}
+			if spot.OnDemandPercentageAboveBaseCapacity == nil {
+				spot.OnDemandPercentageAboveBaseCapacity = &zero
+			}
+			if spot.AllocationStrategy == "" {
+				spot.AllocationStrategy = "lowest-price"
+			}
+		}
+	}
+	if r.Spec.Autoscaler == nil && r.AutoscalerEnabled() {
+		r.Spec.Autoscaler = &ClusterAutoscaler{
+			Enabled: true,
+		}
 	}
 	calicoPodNetwork := "192.168.0.0/16"
 	if r.Spec.Network.Pods == nil && r.Spec.InfrastructureProvider.Name == OpenStack.String() {
@@ -180,6 +201,7 @@ func (r *Cluster) validate(old *Cluster) error {
 			ImmutableField,
 		))
 	}
+	allErrs = r.validateAutoscaling(allErrs)
 	switch r.Spec.InfrastructureProvider.Name {
 	case Amazon.String():
 		allErrs = r.validateAWS(allErrs)
@@ -227,6 +249,194 @@ func (r *Cluster) validateAWS(allErrs field.ErrorList) field.ErrorList {
 			InvalidClusterNameInAws,
 		))
 	}
+	allErrs = r.validateSpot(allErrs)
+	needsMetadata := false
+	for _, w := range r.Spec.Workers {
+		needsMetadata = needsMetadata || w.MixedInstances() || w.Accelerator != nil
+	}
+	if needsMetadata {
+		machines := metadatav1alpha1.AWSMachineList{}
+		err := k8sClient.List(context.TODO(), &machines)
+		if err != nil {
+			return append(allErrs, field.InternalError(field.NewPath("spec", "workers"), err))
+		}
+		allErrs = r.validateMachineTypes(allErrs, machines.Items)
+		allErrs = r.validateAccelerators(allErrs, machines.Items)
+	}
+	return allErrs
+}
+
+// validateSpot rejects the spot max price of the pools. Spot pools are AWSMachinePools with a
+// mixed instances policy, whose launch template can't have market options and whose instances
+// distribution has no max price in the provider API, so the price can't be applied.
+func (r *Cluster) validateSpot(allErrs field.ErrorList) field.ErrorList {
+	for i, w := range r.Spec.Workers {
+		if w.Spot == nil || w.Spot.MaxPrice == "" {
+			continue
+		}
+		path := field.NewPath("spec", "workers").Index(i).Child("spot", "maxPrice")
+		price, err := strconv.ParseFloat(w.Spot.MaxPrice, 64)
+		if err != nil || price <= 0 {
+			allErrs = append(allErrs, field.Invalid(path, w.Spot.MaxPrice, InvalidSpotMaxPrice))
+			continue
+		}
+		allErrs = append(allErrs, field.Forbidden(path, SpotMaxPriceUnsupported))
+	}
+	return allErrs
+}
+
+// defaultAccelerator labels and taints the nodes of an accelerator pool with its accelerator type,
+// and removes the accelerator label and taints of the other types, so they are gone when the
+// accelerator is changed or dropped.
+func defaultAccelerator(w *WorkerNode) {
+	resource := ""
+	if w.Accelerator != nil {
+		resource = w.Accelerator.ResourceName()
+	}
+	var taints []corev1.Taint
+	hasTaint := false
+	for _, t := range w.Taints {
+		if t.Key == resource {
+			hasTaint = true
+		} else if isAcceleratorResource(t.Key) {
+			continue
+		}
+		taints = append(taints, t)
+	}
+	w.Taints = taints
+	if w.Accelerator == nil {
+		delete(w.Labels, LabelAccelerator)
+		return
+	}
+	if w.Labels == nil {
+		w.Labels = make(map[string]string)
+	}
+	w.Labels[LabelAccelerator] = w.Accelerator.Type
+	if !hasTaint {
+		w.Taints = append(w.Taints, corev1.Taint{
+			Key:    resource,
+			Value:  "true",
+			Effect: corev1.TaintEffectNoSchedule,
+		})
+	}
+}
+
+func isAcceleratorResource(name string) bool {
+	for _, r := range acceleratorResources {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAccelerators checks that all machine types of the accelerator pools have
+// accelerators of the requested type. It is a no-op when the machine metadata was not
+// populated yet.
+func (r *Cluster) validateAccelerators(allErrs field.ErrorList, machines []metadatav1alpha1.AWSMachine) field.ErrorList {
+	if len(machines) == 0 {
+		return allErrs
+	}
+	manufacturers := make(map[string]string)
+	for _, m := range machines {
+		manufacturers[m.Spec.InstanceType] = m.Spec.AcceleratorManufacturer
+	}
+	for i, w := range r.Spec.Workers {
+		if w.Accelerator == nil {
+			continue
+		}
+		path := field.NewPath("spec", "workers").Index(i)
+		for j, t := range w.MachineTypes() {
+			if manufacturers[t] == w.Accelerator.Type {
+				continue
+			}
+			p := path.Child("machineType")
+			if j > 0 {
+				p = path.Child("alternativeMachineTypes").Index(j - 1)
+			}
+			allErrs = append(allErrs, field.Invalid(
+				p,
+				t,
+				fmt.Sprintf("%s %s", AcceleratorNotAvailable, w.Accelerator.Type),
+			))
+		}
+	}
+	return allErrs
+}
+
+// validateMachineTypes checks that the instance types of the pools with mixed instances
+// are available in the cluster region. It is a no-op when the machine metadata was not
+// populated yet.
+func (r *Cluster) validateMachineTypes(allErrs field.ErrorList, machines []metadatav1alpha1.AWSMachine) field.ErrorList {
+	if len(machines) == 0 {
+		return allErrs
+	}
+	available := make(map[string]bool)
+	for _, m := range machines {
+		for _, zone := range m.Spec.AvailabilityZones {
+			if strings.HasPrefix(zone, r.Spec.InfrastructureProvider.Region) {
+				available[m.Spec.InstanceType] = true
+				break
+			}
+		}
+	}
+	for i, w := range r.Spec.Workers {
+		if !w.MixedInstances() {
+			continue
+		}
+		path := field.NewPath("spec", "workers").Index(i)
+		if !available[w.MachineType] {
+			allErrs = append(allErrs, field.Invalid(
+				path.Child("machineType"),
+				w.MachineType,
+				MachineTypeNotInRegion,
+			))
+		}
+		for j, t := range w.AlternativeMachineTypes {
+			if !available[t] {
+				allErrs = append(allErrs, field.Invalid(
+					path.Child("alternativeMachineTypes").Index(j),
+					t,
+					MachineTypeNotInRegion,
+				))
+			}
+		}
+	}
+	return allErrs
+}
+
+func (r *Cluster) validateAutoscaling(allErrs field.ErrorList) field.ErrorList {
+	for i, w := range r.Spec.Workers {
+		if !w.Autoscale.Enabled {
+			continue
+		}
+		path := field.NewPath("spec", "workers").Index(i)
+		if w.Autoscale.MinSize > w.Autoscale.MaxSize {
+			allErrs = append(allErrs, field.Invalid(
+				path.Child("autoscaling", "minSize"),
+				w.Autoscale.MinSize,
+				AutoscaleMinGreaterMax,
+			))
+			continue
+		}
+		if w.Replicas != nil && (*w.Replicas < w.Autoscale.MinSize || *w.Replicas > w.Autoscale.MaxSize) {
+			allErrs = append(allErrs, field.Invalid(
+				path.Child("replicas"),
+				*w.Replicas,
+				AutoscaleOutOfRange,
+			))
+		}
+	}
+	if r.Spec.Autoscaler != nil && r.Spec.Autoscaler.ScaleDownUtilizationThreshold != "" {
+		threshold, err := strconv.ParseFloat(r.Spec.Autoscaler.ScaleDownUtilizationThreshold, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			allErrs = append(allErrs, field.Invalid(
+				field.NewPath("spec", "autoscaler", "scaleDownUtilizationThreshold"),
+				r.Spec.Autoscaler.ScaleDownUtilizationThreshold,
+				InvalidUtilization,
+			))
+		}
+	}
 	return allErrs
 }
 
@@ -17,7 +17,11 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"reflect"
 	"testing"
+
+	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 )
 
 func Test_isValidNameForAWS(t *testing.T) {
@@ -73,3 +77,335 @@ func Test_isValidNameForAWS(t *testing.T) {
 		})
 	}
 }
+
+func TestCluster_validateAutoscaling(t *testing.T) {
+	replicas := func(n int32) *int32 {
+		return &n
+	}
+	tests := []struct {
+		name       string
+		workers    []WorkerNode
+		autoscaler *ClusterAutoscaler
+		wantErrs   int
+	}{
+		{
+			name: "replicas inside the range",
+			workers: []WorkerNode{
+				{
+					Node:      Node{Replicas: replicas(2)},
+					Autoscale: Autoscaling{Enabled: true, MinSize: 1, MaxSize: 3},
+				},
+			},
+			wantErrs: 0,
+		},
+		{
+			name: "replicas outside the range",
+			workers: []WorkerNode{
+				{
+					Node:      Node{Replicas: replicas(5)},
+					Autoscale: Autoscaling{Enabled: true, MinSize: 1, MaxSize: 3},
+				},
+			},
+			wantErrs: 1,
+		},
+		{
+			name: "min greater than max",
+			workers: []WorkerNode{
+				{
+					Node:      Node{Replicas: replicas(2)},
+					Autoscale: Autoscaling{Enabled: true, MinSize: 4, MaxSize: 3},
+				},
+			},
+			wantErrs: 1,
+		},
+		{
+			name: "autoscaling disabled",
+			workers: []WorkerNode{
+				{
+					Node:      Node{Replicas: replicas(5)},
+					Autoscale: Autoscaling{MinSize: 1, MaxSize: 3},
+				},
+			},
+			wantErrs: 0,
+		},
+		{
+			name:       "invalid utilization threshold",
+			autoscaler: &ClusterAutoscaler{Enabled: true, ScaleDownUtilizationThreshold: "1.5"},
+			wantErrs:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Cluster{
+				Spec: ClusterSpec{
+					Workers:    tt.workers,
+					Autoscaler: tt.autoscaler,
+				},
+			}
+			if got := r.validateAutoscaling(nil); len(got) != tt.wantErrs {
+				t.Errorf("validateAutoscaling() = %v, want %d errors", got, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestCluster_validateMachineTypes(t *testing.T) {
+	machines := []metadatav1alpha1.AWSMachine{
+		{Spec: metadatav1alpha1.AWSMachineSpec{InstanceType: "t3.medium", AvailabilityZones: []string{"us-east-1a", "sa-east-1a"}}},
+		{Spec: metadatav1alpha1.AWSMachineSpec{InstanceType: "t3a.medium", AvailabilityZones: []string{"us-east-1b"}}},
+	}
+	tests := []struct {
+		name     string
+		region   string
+		workers  []WorkerNode
+		machines []metadatav1alpha1.AWSMachine
+		wantErrs int
+	}{
+		{
+			name:   "all types available",
+			region: "us-east-1",
+			workers: []WorkerNode{
+				{
+					Node:                    Node{MachineType: "t3.medium"},
+					AlternativeMachineTypes: []string{"t3a.medium"},
+				},
+			},
+			machines: machines,
+			wantErrs: 0,
+		},
+		{
+			name:   "alternative type not in region",
+			region: "sa-east-1",
+			workers: []WorkerNode{
+				{
+					Node:                    Node{MachineType: "t3.medium"},
+					AlternativeMachineTypes: []string{"t3a.medium"},
+				},
+			},
+			machines: machines,
+			wantErrs: 1,
+		},
+		{
+			name:   "unknown spot type",
+			region: "us-east-1",
+			workers: []WorkerNode{
+				{
+					Node: Node{MachineType: "x9.huge"},
+					Spot: &Spot{Enabled: true},
+				},
+			},
+			machines: machines,
+			wantErrs: 1,
+		},
+		{
+			name:   "pool without mixed instances",
+			region: "us-east-1",
+			workers: []WorkerNode{
+				{
+					Node: Node{MachineType: "x9.huge"},
+				},
+			},
+			machines: machines,
+			wantErrs: 0,
+		},
+		{
+			name:   "metadata not populated",
+			region: "us-east-1",
+			workers: []WorkerNode{
+				{
+					Node:                    Node{MachineType: "x9.huge"},
+					AlternativeMachineTypes: []string{"x9.large"},
+				},
+			},
+			wantErrs: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Cluster{
+				Spec: ClusterSpec{
+					InfrastructureProvider: InfrastructureProvider{Region: tt.region},
+					Workers:                tt.workers,
+				},
+			}
+			if got := r.validateMachineTypes(nil, tt.machines); len(got) != tt.wantErrs {
+				t.Errorf("validateMachineTypes() = %v, want %d errors", got, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestCluster_validateAccelerators(t *testing.T) {
+	machines := []metadatav1alpha1.AWSMachine{
+		{Spec: metadatav1alpha1.AWSMachineSpec{InstanceType: "g4dn.xlarge", Accelerators: "1", AcceleratorManufacturer: "nvidia"}},
+		{Spec: metadatav1alpha1.AWSMachineSpec{InstanceType: "g4ad.4xlarge", Accelerators: "1", AcceleratorManufacturer: "amd"}},
+		{Spec: metadatav1alpha1.AWSMachineSpec{InstanceType: "t3.medium"}},
+	}
+	tests := []struct {
+		name     string
+		workers  []WorkerNode
+		machines []metadatav1alpha1.AWSMachine
+		wantErrs int
+	}{
+		{
+			name: "nvidia instance type",
+			workers: []WorkerNode{
+				{
+					Node:        Node{MachineType: "g4dn.xlarge"},
+					Accelerator: &Accelerator{Type: "nvidia"},
+				},
+			},
+			machines: machines,
+			wantErrs: 0,
+		},
+		{
+			name: "instance type without accelerators",
+			workers: []WorkerNode{
+				{
+					Node:        Node{MachineType: "t3.medium"},
+					Accelerator: &Accelerator{Type: "nvidia"},
+				},
+			},
+			machines: machines,
+			wantErrs: 1,
+		},
+		{
+			name: "alternative type from another manufacturer",
+			workers: []WorkerNode{
+				{
+					Node:                    Node{MachineType: "g4dn.xlarge"},
+					AlternativeMachineTypes: []string{"g4ad.4xlarge"},
+					Accelerator:             &Accelerator{Type: "nvidia"},
+				},
+			},
+			machines: machines,
+			wantErrs: 1,
+		},
+		{
+			name: "metadata not populated",
+			workers: []WorkerNode{
+				{
+					Node:        Node{MachineType: "t3.medium"},
+					Accelerator: &Accelerator{Type: "nvidia"},
+				},
+			},
+			wantErrs: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Cluster{
+				Spec: ClusterSpec{
+					Workers: tt.workers,
+				},
+			}
+			if got := r.validateAccelerators(nil, tt.machines); len(got) != tt.wantErrs {
+				t.Errorf("validateAccelerators() = %v, want %d errors", got, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func Test_defaultAccelerator(t *testing.T) {
+	gpuTaint := corev1.Taint{Key: "nvidia.com/gpu", Value: "true", Effect: corev1.TaintEffectNoSchedule}
+	userTaint := corev1.Taint{Key: "dedicated", Value: "batch", Effect: corev1.TaintEffectNoSchedule}
+	tests := []struct {
+		name       string
+		worker     WorkerNode
+		wantLabels map[string]string
+		wantTaints []corev1.Taint
+	}{
+		{
+			name:       "accelerator pool",
+			worker:     WorkerNode{Accelerator: &Accelerator{Type: "nvidia"}, Taints: []corev1.Taint{userTaint}},
+			wantLabels: map[string]string{LabelAccelerator: "nvidia"},
+			wantTaints: []corev1.Taint{userTaint, gpuTaint},
+		},
+		{
+			name: "already defaulted",
+			worker: WorkerNode{
+				Accelerator: &Accelerator{Type: "nvidia"},
+				Labels:      map[string]string{LabelAccelerator: "nvidia"},
+				Taints:      []corev1.Taint{gpuTaint},
+			},
+			wantLabels: map[string]string{LabelAccelerator: "nvidia"},
+			wantTaints: []corev1.Taint{gpuTaint},
+		},
+		{
+			name: "accelerator dropped",
+			worker: WorkerNode{
+				Labels: map[string]string{LabelAccelerator: "nvidia", "team": "ml"},
+				Taints: []corev1.Taint{gpuTaint, userTaint},
+			},
+			wantLabels: map[string]string{"team": "ml"},
+			wantTaints: []corev1.Taint{userTaint},
+		},
+		{
+			name: "not an accelerator pool",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.worker
+			defaultAccelerator(&w)
+			if len(w.Labels) != 0 || len(tt.wantLabels) != 0 {
+				if !reflect.DeepEqual(w.Labels, tt.wantLabels) {
+					t.Errorf("defaultAccelerator() labels = %v, want %v", w.Labels, tt.wantLabels)
+				}
+			}
+			if !reflect.DeepEqual(w.Taints, tt.wantTaints) {
+				t.Errorf("defaultAccelerator() taints = %v, want %v", w.Taints, tt.wantTaints)
+			}
+		})
+	}
+}
+
+func TestCluster_validateSpot(t *testing.T) {
+	tests := []struct {
+		name     string
+		spot     *Spot
+		wantErrs int
+	}{
+		{
+			name:     "without spot",
+			wantErrs: 0,
+		},
+		{
+			name:     "without max price",
+			spot:     &Spot{Enabled: true},
+			wantErrs: 0,
+		},
+		{
+			name:     "unsupported max price",
+			spot:     &Spot{Enabled: true, MaxPrice: "0.045"},
+			wantErrs: 1,
+		},
+		{
+			name:     "zero max price",
+			spot:     &Spot{Enabled: true, MaxPrice: "0"},
+			wantErrs: 1,
+		},
+		{
+			name:     "negative max price",
+			spot:     &Spot{Enabled: true, MaxPrice: "-0.1"},
+			wantErrs: 1,
+		},
+		{
+			name:     "not a number",
+			spot:     &Spot{Enabled: true, MaxPrice: "cheap"},
+			wantErrs: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Cluster{
+				Spec: ClusterSpec{
+					Workers: []WorkerNode{{Spot: tt.spot}},
+				},
+			}
+			if got := r.validateSpot(nil); len(got) != tt.wantErrs {
+				t.Errorf("validateSpot() = %v, want %d errors", got, tt.wantErrs)
+			}
+		})
+	}
+}
@@ -21,11 +21,54 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// LabelDefaultPolicies is set in the policies applied by a DefaultPolicies with its name
+const LabelDefaultPolicies = "undistro.io/default-policies"
+
+// +kubebuilder:validation:Enum=audit;enforce
+type ValidationFailureAction string
+
+const (
+	AuditAction   ValidationFailureAction = "audit"
+	EnforceAction ValidationFailureAction = "enforce"
+)
+
+// PolicyConfig overrides the settings of a default policy
+type PolicyConfig struct {
+	Name string `json:"name"`
+	// ValidationFailureAction of the policy. Overrides the DefaultPolicies action.
+	ValidationFailureAction ValidationFailureAction `json:"validationFailureAction,omitempty"`
+	// ExcludeNamespaces where the policy is not applied, in addition to the DefaultPolicies ones.
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+}
+
 // DefaultPoliciesSpec defines the desired state of DefaultPolicies
 type DefaultPoliciesSpec struct {
 	Paused          bool     `json:"paused,omitempty"`
 	ClusterName     string   `json:"clusterName,omitempty"`
 	ExcludePolicies []string `json:"excludePolicies,omitempty"`
+	// Parameters of the default policies, e.g. allowedRegistries for disallow-latest-tag.
+	Parameters []PolicyParameters `json:"parameters,omitempty"`
+	// ValidationFailureAction of all default policies. When empty each policy keeps its own action.
+	ValidationFailureAction ValidationFailureAction `json:"validationFailureAction,omitempty"`
+	// ExcludeNamespaces where the default policies are not applied.
+	ExcludeNamespaces []string       `json:"excludeNamespaces,omitempty"`
+	Policies          []PolicyConfig `json:"policies,omitempty"`
+}
+
+// PolicySettings returns the validation failure action and excluded namespaces of the policy
+func (s DefaultPoliciesSpec) PolicySettings(name string) (ValidationFailureAction, []string) {
+	action := s.ValidationFailureAction
+	namespaces := append([]string{}, s.ExcludeNamespaces...)
+	for _, p := range s.Policies {
+		if p.Name != name {
+			continue
+		}
+		if p.ValidationFailureAction != "" {
+			action = p.ValidationFailureAction
+		}
+		namespaces = append(namespaces, p.ExcludeNamespaces...)
+	}
+	return action, namespaces
 }
 
 // DefaultPoliciesStatus defines the observed state of DefaultPolicies
@@ -40,6 +83,31 @@ type DefaultPoliciesStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
 
 	AppliedPolicies []string `json:"appliedPolicies,omitempty"`
+	// Revision is the checksum of the policy set active in the cluster.
+	Revision string `json:"revision,omitempty"`
+	// PolicyReports summarizes the results of the Kyverno policy reports per policy.
+	PolicyReports []PolicyReportSummary `json:"policyReports,omitempty"`
+	// TopOffenders are the resources with most policy failures.
+	TopOffenders []PolicyOffender `json:"topOffenders,omitempty"`
+}
+
+// PolicyReportSummary counts the results of a policy in the policy reports
+type PolicyReportSummary struct {
+	Policy string `json:"policy"`
+	Pass   int    `json:"pass"`
+	Fail   int    `json:"fail"`
+	Warn   int    `json:"warn"`
+	Error  int    `json:"error"`
+	Skip   int    `json:"skip"`
+}
+
+// PolicyOffender is a resource failing policies
+type PolicyOffender struct {
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace,omitempty"`
+	Name      string   `json:"name"`
+	Failures  int      `json:"failures"`
+	Policies  []string `json:"policies,omitempty"`
 }
 
 // +genclient
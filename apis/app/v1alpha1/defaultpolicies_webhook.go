@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"github.com/getupio-undistro/meta"
+	"github.com/getupio-undistro/undistro/pkg/policy"
 	"github.com/getupio-undistro/undistro/pkg/util"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -92,12 +93,36 @@ func (r *DefaultPolicies) validate(old *DefaultPolicies) error {
 			))
 		}
 	}
+	allErrs = r.validatePolicyNames(allErrs)
 	if len(allErrs) == 0 {
 		return nil
 	}
 	return apierrors.NewInvalid(GroupVersion.WithKind("DefaultPolicies").GroupKind(), r.Name, allErrs)
 }
 
+func (r *DefaultPolicies) validatePolicyNames(allErrs field.ErrorList) field.ErrorList {
+	names, err := policy.DefaultNames()
+	if err != nil {
+		return append(allErrs, field.InternalError(field.NewPath("spec"), err))
+	}
+	for i, name := range r.Spec.ExcludePolicies {
+		if !util.ContainsStringInSlice(names, name) {
+			allErrs = append(allErrs, field.NotSupported(field.NewPath("spec", "excludePolicies").Index(i), name, names))
+		}
+	}
+	for i, p := range r.Spec.Policies {
+		if !util.ContainsStringInSlice(names, p.Name) {
+			allErrs = append(allErrs, field.NotSupported(field.NewPath("spec", "policies").Index(i).Child("name"), p.Name, names))
+		}
+	}
+	for i, p := range r.Spec.Parameters {
+		if !util.ContainsStringInSlice(names, p.Policy) {
+			allErrs = append(allErrs, field.NotSupported(field.NewPath("spec", "parameters").Index(i).Child("policy"), p.Policy, names))
+		}
+	}
+	return allErrs
+}
+
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *DefaultPolicies) ValidateCreate() error {
 	defaultpolicieslog.Info("validate create", "name", r.Name)
@@ -0,0 +1,73 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+)
+
+func TestDefaultPolicies_validatePolicyNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     DefaultPoliciesSpec
+		wantErrs int
+	}{
+		{
+			name: "known policies",
+			spec: DefaultPoliciesSpec{
+				ExcludePolicies: []string{"traffic-deny"},
+				Policies:        []PolicyConfig{{Name: "disallow-host-path", ValidationFailureAction: AuditAction}},
+				Parameters:      []PolicyParameters{{Policy: "disallow-latest-tag"}},
+			},
+		},
+		{
+			name: "unknown policies",
+			spec: DefaultPoliciesSpec{
+				ExcludePolicies: []string{"network-policy"},
+				Policies:        []PolicyConfig{{Name: "disallow-host-paths"}},
+				Parameters:      []PolicyParameters{{Policy: "disallow-latest-tag.yaml"}},
+			},
+			wantErrs: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &DefaultPolicies{Spec: tt.spec}
+			if got := r.validatePolicyNames(nil); len(got) != tt.wantErrs {
+				t.Errorf("validatePolicyNames() = %v, want %d errors", got, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestDefaultPoliciesSpec_PolicySettings(t *testing.T) {
+	spec := DefaultPoliciesSpec{
+		ValidationFailureAction: EnforceAction,
+		ExcludeNamespaces:       []string{"monitoring"},
+		Policies: []PolicyConfig{
+			{Name: "disallow-host-path", ValidationFailureAction: AuditAction, ExcludeNamespaces: []string{"storage"}},
+		},
+	}
+	action, namespaces := spec.PolicySettings("disallow-host-path")
+	if action != AuditAction || len(namespaces) != 2 {
+		t.Errorf("PolicySettings() = %s, %v, want audit, [monitoring storage]", action, namespaces)
+	}
+	action, namespaces = spec.PolicySettings("traffic-deny")
+	if action != EnforceAction || len(namespaces) != 1 {
+		t.Errorf("PolicySettings() = %s, %v, want enforce, [monitoring]", action, namespaces)
+	}
+}
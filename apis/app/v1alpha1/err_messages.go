@@ -12,4 +12,11 @@ const (
 	ImmutableField          = "The target field is immutable"
 	NetAddrConflict         = "ID or CIDRBlock must be set to avoid network conflicts with others clusters"
 	InvalidClusterNameInAws = "Invalid cluster name for AWS" // Add valid ones
+	AutoscaleMinGreaterMax  = "The 'minSize' field can't be greater than 'maxSize'"
+	AutoscaleOutOfRange     = "The 'replicas' field must be between 'minSize' and 'maxSize' when autoscaling is enabled"
+	InvalidUtilization      = "The 'scaleDownUtilizationThreshold' field must be a decimal between 0 and 1"
+	MachineTypeNotInRegion  = "The instance type is not available in the cluster region"
+	AcceleratorNotAvailable = "The instance type has no accelerators of type"
+	InvalidSpotMaxPrice     = "The 'maxPrice' field must be a positive decimal"
+	SpotMaxPriceUnsupported = "The 'maxPrice' field is not supported by AWS machine pools, spot instances are capped at the on-demand price"
 )
@@ -162,6 +162,11 @@ type HelmReleaseSpec struct {
 	// ValuesFrom holds references to resources containing Helm values for this HelmRelease,
 	// and information about how they should be merged.
 	ValuesFrom []ValuesReference `json:"valuesFrom,omitempty"`
+	// ImageRegistry moves the images of the rendered manifests
+	// to this registry keeping their repository paths, e.g.
+	// quay.io/jetstack/cert-manager-controller becomes
+	// <ImageRegistry>/jetstack/cert-manager-controller.
+	ImageRegistry string `json:"imageRegistry,omitempty"`
 	// BeforeApplyObjects holds the objects that will be applied
 	// before this helm release installation
 	BeforeApplyObjects []apiextensionsv1.JSON `json:"beforeApplyObjects,omitempty"`
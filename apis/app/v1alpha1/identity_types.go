@@ -17,22 +17,51 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"strings"
+
 	"github.com/getupio-undistro/meta"
+	"github.com/pkg/errors"
 	supervisoridpv1aplha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
 )
 
+// IdentityConfigMapName is the ConfigMap holding the identity settings before they were moved to the Identity spec
+const IdentityConfigMapName = "identity-config"
+
+// FederationDomain is the Pinniped Supervisor issuer.
 type FederationDomain struct {
-	Issuer        string `json:"issuer,omitempty"`
+	// Issuer is the https URL of the Supervisor, e.g. https://undistro.example.com/auth.
+	Issuer string `json:"issuer"`
+	// TLSSecretName is the name of the Secret in the UnDistro namespace with the certificate
+	// served by the issuer in local clusters.
+	// +optional
 	TLSSecretName string `json:"tlsSecretName,omitempty"`
 }
 
+// OIDCProviderConfig configures an upstream OIDC identity provider from a preset.
+type OIDCProviderConfig struct {
+	// Name of the provider preset.
+	// +kubebuilder:validation:Enum=gitlab;google;github
+	Name OIDCProviderName `json:"name"`
+	// URL of the issuer, overriding the preset one. It's required by the github preset.
+	// +optional
+	URL string `json:"url,omitempty"`
+	// AdditionalScopes requested besides the preset scopes.
+	// +optional
+	AdditionalScopes []string `json:"additionalScopes,omitempty"`
+	// ClientSecretName is the name of a Secret in the Identity namespace with the clientID and clientSecret keys.
+	ClientSecretName string `json:"clientSecretName"`
+}
+
 type OIDCProviderName string
 
 const (
 	Gitlab OIDCProviderName = "gitlab"
 	Google OIDCProviderName = "google"
 	Azure  OIDCProviderName = "azure"
+	Github OIDCProviderName = "github"
 )
 
 // IdentitySpec defines the desired state of Identity
@@ -40,9 +69,24 @@ type IdentitySpec struct {
 	// Pause Identity reconciliation
 	Paused bool `json:"paused,omitempty"`
 
+	// FederationDomain is the Pinniped Supervisor issuer. It's required in the management cluster identity
+	// and the workload cluster identities use the management cluster one.
+	FederationDomain *FederationDomain `json:"federationDomain,omitempty"`
+
+	// OIDC configures an upstream OpenID Connect identity provider from a preset.
+	OIDC *OIDCProviderConfig `json:"oidc,omitempty"`
+
 	// OIDCIdentityProvider describes the configuration of an upstream OpenID Connect identity provider.
 	OIDCIdentityProvider supervisoridpv1aplha1.OIDCIdentityProvider `json:"oidcProvider,omitempty"`
 
+	// LDAPIdentityProvider describes the configuration of an upstream LDAP identity provider.
+	// The bind secret is read from the Identity namespace and must have the username and password keys.
+	LDAPIdentityProvider *supervisoridpv1aplha1.LDAPIdentityProviderSpec `json:"ldapProvider,omitempty"`
+
+	// ActiveDirectoryIdentityProvider describes the configuration of an upstream Microsoft Active Directory identity provider.
+	// The bind secret is read from the Identity namespace and must have the username and password keys.
+	ActiveDirectoryIdentityProvider *supervisoridpv1aplha1.ActiveDirectoryIdentityProviderSpec `json:"activeDirectoryProvider,omitempty"`
+
 	// ClusterName is the name of the cluster to which this identity belongs
 	ClusterName string `json:"clusterName,omitempty"`
 
@@ -88,6 +132,59 @@ func IdentityPaused(i Identity) *Identity {
 	return &i
 }
 
+// MigrateConfigMap sets the federation domain and the OIDC provider of the spec from the
+// identity ConfigMap used by previous versions. Fields already set aren't changed.
+func (s *IdentitySpec) MigrateConfigMap(cm corev1.ConfigMap) error {
+	// previous versions stripped the block scalar indicators left by the chart
+	data := func(key string) []byte {
+		return []byte(strings.ReplaceAll(cm.Data[key], "|", ""))
+	}
+	if s.FederationDomain == nil && cm.Data["federationdomain.yaml"] != "" {
+		fedo := FederationDomain{}
+		err := yaml.Unmarshal(data("federationdomain.yaml"), &fedo)
+		if err != nil {
+			return errors.Wrap(err, "invalid federationdomain.yaml")
+		}
+		s.FederationDomain = &fedo
+	}
+	if s.OIDC == nil && cm.Data["oidcprovider.yaml"] != "" {
+		provider := struct {
+			Issuer struct {
+				Name             OIDCProviderName `json:"name,omitempty"`
+				URL              string           `json:"url,omitempty"`
+				AdditionalScopes []string         `json:"aditionalScopes,omitempty"`
+			} `json:"issuer,omitempty"`
+		}{}
+		err := yaml.Unmarshal(data("oidcprovider.yaml"), &provider)
+		if err != nil {
+			return errors.Wrap(err, "invalid oidcprovider.yaml")
+		}
+		if provider.Issuer.Name != "" {
+			s.OIDC = &OIDCProviderConfig{
+				Name:             provider.Issuer.Name,
+				URL:              provider.Issuer.URL,
+				AdditionalScopes: provider.Issuer.AdditionalScopes,
+				// created by the chart of previous versions
+				ClientSecretName: "idp-credentials",
+			}
+		}
+	}
+	return nil
+}
+
+// IdentityNotReady registers a failed reconciliation of the given Identity.
+func IdentityNotReady(i Identity, reason, message string) Identity {
+	meta.SetResourceCondition(&i, meta.ReadyCondition, metav1.ConditionFalse, reason, message)
+	return i
+}
+
+// IdentityReady registers a successful reconciliation of the given Identity.
+func IdentityReady(i Identity) Identity {
+	msg := "Identity reconciliation succeeded"
+	meta.SetResourceCondition(&i, meta.ReadyCondition, metav1.ConditionTrue, meta.ReconciliationSucceededReason, msg)
+	return i
+}
+
 func (i *Identity) GetStatusConditions() *[]metav1.Condition {
 	return &i.Status.Conditions
 }
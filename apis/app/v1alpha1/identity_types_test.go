@@ -0,0 +1,100 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestIdentitySpec_MigrateConfigMap(t *testing.T) {
+	cm := corev1.ConfigMap{
+		Data: map[string]string{
+			"federationdomain.yaml": "|\nissuer: https://supervisor.example.com\ntlsSecretName: undistro-ingress-cert\n",
+			"oidcprovider.yaml":     "|\nissuer:\n  name: gitlab\n  aditionalScopes:\n  - read_api\nclaims: {}\n",
+		},
+	}
+	tests := []struct {
+		name    string
+		spec    IdentitySpec
+		cm      corev1.ConfigMap
+		want    IdentitySpec
+		wantErr bool
+	}{
+		{
+			name: "empty spec",
+			cm:   cm,
+			want: IdentitySpec{
+				FederationDomain: &FederationDomain{
+					Issuer:        "https://supervisor.example.com",
+					TLSSecretName: "undistro-ingress-cert",
+				},
+				OIDC: &OIDCProviderConfig{
+					Name:             Gitlab,
+					AdditionalScopes: []string{"read_api"},
+					ClientSecretName: "idp-credentials",
+				},
+			},
+		},
+		{
+			name: "fields already set",
+			spec: IdentitySpec{
+				FederationDomain: &FederationDomain{Issuer: "https://new.example.com"},
+			},
+			cm: corev1.ConfigMap{
+				Data: map[string]string{
+					"federationdomain.yaml": cm.Data["federationdomain.yaml"],
+				},
+			},
+			want: IdentitySpec{
+				FederationDomain: &FederationDomain{Issuer: "https://new.example.com"},
+			},
+		},
+		{
+			name: "no oidc provider",
+			cm: corev1.ConfigMap{
+				Data: map[string]string{
+					"oidcprovider.yaml": "issuer:\n  name: \"\"\n",
+				},
+			},
+			want: IdentitySpec{},
+		},
+		{
+			name: "invalid federation domain",
+			cm: corev1.ConfigMap{
+				Data: map[string]string{
+					"federationdomain.yaml": "issuer: [",
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec
+			err := spec.MigrateConfigMap(tt.cm)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MigrateConfigMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(spec, tt.want) {
+				t.Errorf("MigrateConfigMap() = %+v, want %+v", spec, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/getupio-undistro/undistro/pkg/util"
+	supervisoridpv1aplha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var identitylog = logf.Log.WithName("identity-resource")
+
+func (r *Identity) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if k8sClient == nil {
+		k8sClient = mgr.GetClient()
+	}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-app-undistro-io-v1alpha1-identity,mutating=false,failurePolicy=fail,sideEffects=None,groups=app.undistro.io,resources=identities,verbs=create;update,versions=v1alpha1,name=videntity.undistro.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &Identity{}
+
+func (r *Identity) validate(old *Identity) error {
+	var allErrs field.ErrorList
+	if old != nil && old.Spec.ClusterName != r.Spec.ClusterName {
+		allErrs = append(allErrs, field.Invalid(
+			field.NewPath("spec", "clusterName"),
+			r.Spec.ClusterName,
+			"field is immutable",
+		))
+	}
+	allErrs = r.validateFederationDomain(allErrs, old)
+	allErrs = r.validateProviders(allErrs)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Identity").GroupKind(), r.Name, allErrs)
+}
+
+func (r *Identity) validateProviders(allErrs field.ErrorList) field.ErrorList {
+	var providers []*field.Path
+	oidc := r.Spec.OIDCIdentityProvider.Spec
+	if oidc.Issuer != "" || oidc.Client.SecretName != "" {
+		p := field.NewPath("spec", "oidcProvider", "spec")
+		providers = append(providers, p)
+		allErrs = validateOIDCProvider(allErrs, p, oidc)
+	}
+	if r.Spec.OIDC != nil {
+		p := field.NewPath("spec", "oidc")
+		providers = append(providers, p)
+		allErrs = validateOIDCPreset(allErrs, p, *r.Spec.OIDC)
+	}
+	if ldap := r.Spec.LDAPIdentityProvider; ldap != nil {
+		p := field.NewPath("spec", "ldapProvider")
+		providers = append(providers, p)
+		allErrs = validateDirectoryProvider(allErrs, p, ldap.Host, ldap.Bind.SecretName, ldap.TLS, ldap.UserSearch.Filter, ldap.GroupSearch.Filter)
+		if ldap.UserSearch.Base == "" {
+			allErrs = append(allErrs, field.Required(p.Child("userSearch", "base"), "LDAP user search needs a base dn"))
+		}
+	}
+	if ad := r.Spec.ActiveDirectoryIdentityProvider; ad != nil {
+		p := field.NewPath("spec", "activeDirectoryProvider")
+		providers = append(providers, p)
+		allErrs = validateDirectoryProvider(allErrs, p, ad.Host, ad.Bind.SecretName, ad.TLS, ad.UserSearch.Filter, ad.GroupSearch.Filter)
+	}
+	for i, p := range providers {
+		if !util.IsMgmtCluster(r.Spec.ClusterName) {
+			allErrs = append(allErrs, field.Forbidden(p, "identity providers can only be set in the management cluster identity"))
+		} else if i > 0 {
+			allErrs = append(allErrs, field.Forbidden(p, fmt.Sprintf("only one identity provider can be set, %s is already set", providers[0])))
+		}
+	}
+	return allErrs
+}
+
+func (r *Identity) validateFederationDomain(allErrs field.ErrorList, old *Identity) field.ErrorList {
+	p := field.NewPath("spec", "federationDomain")
+	fedo := r.Spec.FederationDomain
+	if !util.IsMgmtCluster(r.Spec.ClusterName) {
+		if fedo != nil {
+			allErrs = append(allErrs, field.Forbidden(p, "workload cluster identities use the management cluster federation domain"))
+		}
+		return allErrs
+	}
+	if fedo == nil {
+		// identities created before the federation domain was moved to the spec
+		// are updated by the controller with the identity ConfigMap settings
+		if old == nil || old.Spec.FederationDomain != nil {
+			allErrs = append(allErrs, field.Required(p, "federation domain is required in the management cluster identity"))
+		}
+		return allErrs
+	}
+	if !isHTTPSURL(fedo.Issuer) {
+		allErrs = append(allErrs, field.Invalid(p.Child("issuer"), fedo.Issuer, "issuer must be an https URL"))
+	}
+	return allErrs
+}
+
+func validateOIDCPreset(allErrs field.ErrorList, p *field.Path, cfg OIDCProviderConfig) field.ErrorList {
+	supported := []string{string(Gitlab), string(Google), string(Github)}
+	if !util.ContainsStringInSlice(supported, string(cfg.Name)) {
+		allErrs = append(allErrs, field.NotSupported(p.Child("name"), cfg.Name, supported))
+	}
+	if cfg.URL != "" && !isHTTPSURL(cfg.URL) {
+		allErrs = append(allErrs, field.Invalid(p.Child("url"), cfg.URL, "issuer must be an https URL"))
+	}
+	if cfg.URL == "" && cfg.Name == Github {
+		allErrs = append(allErrs, field.Required(p.Child("url"), "github preset needs the URL of an OIDC bridge"))
+	}
+	if cfg.ClientSecretName == "" {
+		allErrs = append(allErrs, field.Required(p.Child("clientSecretName"), "OIDC client credentials are required"))
+	}
+	return allErrs
+}
+
+func isHTTPSURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
+
+func validateOIDCProvider(allErrs field.ErrorList, p *field.Path, spec supervisoridpv1aplha1.OIDCIdentityProviderSpec) field.ErrorList {
+	if !isHTTPSURL(spec.Issuer) {
+		allErrs = append(allErrs, field.Invalid(p.Child("issuer"), spec.Issuer, "issuer must be an https URL"))
+	}
+	if spec.Client.SecretName == "" {
+		allErrs = append(allErrs, field.Required(p.Child("client", "secretName"), "OIDC client credentials are required"))
+	}
+	if spec.TLS != nil {
+		allErrs = validateCertificateAuthority(allErrs, p.Child("tls", "certificateAuthorityData"), spec.TLS.CertificateAuthorityData)
+	}
+	return allErrs
+}
+
+// validateDirectoryProvider validates the fields shared by the LDAP and Active Directory providers.
+func validateDirectoryProvider(allErrs field.ErrorList, p *field.Path, host, secretName string, tls *supervisoridpv1aplha1.TLSSpec, userFilter, groupFilter string) field.ErrorList {
+	if host == "" {
+		allErrs = append(allErrs, field.Required(p.Child("host"), "host is required"))
+	} else if strings.Contains(host, "://") {
+		allErrs = append(allErrs, field.Invalid(p.Child("host"), host, "host must be in the host:port form, without a scheme"))
+	}
+	if secretName == "" {
+		allErrs = append(allErrs, field.Required(p.Child("bind", "secretName"), "bind credentials are required"))
+	}
+	if tls != nil {
+		allErrs = validateCertificateAuthority(allErrs, p.Child("tls", "certificateAuthorityData"), tls.CertificateAuthorityData)
+	}
+	if userFilter != "" && !strings.Contains(userFilter, "{}") {
+		allErrs = append(allErrs, field.Invalid(p.Child("userSearch", "filter"), userFilter, `filter must contain the "{}" placeholder`))
+	}
+	if groupFilter != "" && !strings.Contains(groupFilter, "{}") {
+		allErrs = append(allErrs, field.Invalid(p.Child("groupSearch", "filter"), groupFilter, `filter must contain the "{}" placeholder`))
+	}
+	return allErrs
+}
+
+func validateCertificateAuthority(allErrs field.ErrorList, p *field.Path, data string) field.ErrorList {
+	if data == "" {
+		return allErrs
+	}
+	if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+		allErrs = append(allErrs, field.Invalid(p, data, "certificate authority must be a base64 encoded PEM bundle"))
+	}
+	return allErrs
+}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Identity) ValidateCreate() error {
+	identitylog.Info("validate create", "name", r.Name)
+	return r.validate(nil)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Identity) ValidateUpdate(old runtime.Object) error {
+	identitylog.Info("validate update", "name", r.Name)
+	oldIdentity, ok := old.(*Identity)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected an Identity but got a %T", old))
+	}
+	return r.validate(oldIdentity)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Identity) ValidateDelete() error {
+	identitylog.Info("validate delete", "name", r.Name)
+	return nil
+}
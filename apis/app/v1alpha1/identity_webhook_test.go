@@ -0,0 +1,161 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	supervisoridpv1aplha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+)
+
+func TestIdentity_validateProviders(t *testing.T) {
+	ldap := &supervisoridpv1aplha1.LDAPIdentityProviderSpec{
+		Host: "ldap.example.com:636",
+		Bind: supervisoridpv1aplha1.LDAPIdentityProviderBind{SecretName: "ldap-bind"},
+		UserSearch: supervisoridpv1aplha1.LDAPIdentityProviderUserSearch{
+			Base:   "ou=users,dc=example,dc=com",
+			Filter: "&(objectClass=person)(uid={})",
+		},
+	}
+	ad := &supervisoridpv1aplha1.ActiveDirectoryIdentityProviderSpec{
+		Host: "ad.example.com",
+		Bind: supervisoridpv1aplha1.ActiveDirectoryIdentityProviderBind{SecretName: "ad-bind"},
+	}
+	tests := []struct {
+		name     string
+		spec     IdentitySpec
+		wantErrs int
+	}{
+		{
+			name: "local only",
+			spec: IdentitySpec{Local: true},
+		},
+		{
+			name: "ldap",
+			spec: IdentitySpec{LDAPIdentityProvider: ldap},
+		},
+		{
+			name: "active directory",
+			spec: IdentitySpec{ActiveDirectoryIdentityProvider: ad},
+		},
+		{
+			name: "invalid ldap",
+			spec: IdentitySpec{
+				LDAPIdentityProvider: &supervisoridpv1aplha1.LDAPIdentityProviderSpec{
+					Host:       "ldaps://ldap.example.com",
+					TLS:        &supervisoridpv1aplha1.TLSSpec{CertificateAuthorityData: "not base64"},
+					UserSearch: supervisoridpv1aplha1.LDAPIdentityProviderUserSearch{Filter: "uid=user"},
+				},
+			},
+			wantErrs: 5,
+		},
+		{
+			name: "invalid oidc",
+			spec: IdentitySpec{
+				OIDCIdentityProvider: supervisoridpv1aplha1.OIDCIdentityProvider{
+					Spec: supervisoridpv1aplha1.OIDCIdentityProviderSpec{Issuer: "http://accounts.google.com"},
+				},
+			},
+			wantErrs: 2,
+		},
+		{
+			name: "oidc preset",
+			spec: IdentitySpec{OIDC: &OIDCProviderConfig{Name: Google, ClientSecretName: "idp-credentials"}},
+		},
+		{
+			name:     "github preset without url",
+			spec:     IdentitySpec{OIDC: &OIDCProviderConfig{Name: Github, ClientSecretName: "idp-credentials"}},
+			wantErrs: 1,
+		},
+		{
+			name:     "unsupported oidc preset",
+			spec:     IdentitySpec{OIDC: &OIDCProviderConfig{Name: "okta", URL: "http://okta.example.com"}},
+			wantErrs: 3,
+		},
+		{
+			name:     "more than one provider",
+			spec:     IdentitySpec{LDAPIdentityProvider: ldap, ActiveDirectoryIdentityProvider: ad},
+			wantErrs: 1,
+		},
+		{
+			name:     "workload cluster",
+			spec:     IdentitySpec{ClusterName: "cool-cluster", ActiveDirectoryIdentityProvider: ad},
+			wantErrs: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Identity{Spec: tt.spec}
+			if got := r.validateProviders(nil); len(got) != tt.wantErrs {
+				t.Errorf("validateProviders() = %v, want %d errors", got, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestIdentity_validateFederationDomain(t *testing.T) {
+	fedo := &FederationDomain{Issuer: "https://supervisor.example.com"}
+	tests := []struct {
+		name     string
+		spec     IdentitySpec
+		old      *Identity
+		wantErrs int
+	}{
+		{
+			name: "management cluster",
+			spec: IdentitySpec{FederationDomain: fedo},
+		},
+		{
+			name:     "missing on create",
+			spec:     IdentitySpec{},
+			wantErrs: 1,
+		},
+		{
+			name: "missing in identities to be migrated",
+			spec: IdentitySpec{Paused: true},
+			old:  &Identity{},
+		},
+		{
+			name:     "removed",
+			spec:     IdentitySpec{},
+			old:      &Identity{Spec: IdentitySpec{FederationDomain: fedo}},
+			wantErrs: 1,
+		},
+		{
+			name:     "invalid issuer",
+			spec:     IdentitySpec{FederationDomain: &FederationDomain{Issuer: "supervisor.example.com"}},
+			wantErrs: 1,
+		},
+		{
+			name: "workload cluster",
+			spec: IdentitySpec{ClusterName: "cool-cluster"},
+		},
+		{
+			name:     "workload cluster with federation domain",
+			spec:     IdentitySpec{ClusterName: "cool-cluster", FederationDomain: fedo},
+			wantErrs: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Identity{Spec: tt.spec}
+			if got := r.validateFederationDomain(nil, tt.old); len(got) != tt.wantErrs {
+				t.Errorf("validateFederationDomain() = %v, want %d errors", got, tt.wantErrs)
+			}
+		})
+	}
+}
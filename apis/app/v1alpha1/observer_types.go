@@ -18,9 +18,182 @@ package v1alpha1
 
 import (
 	"github.com/getupio-undistro/meta"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// MetricsReadyCondition reports the state of the Observer metrics stack.
+	MetricsReadyCondition = "MetricsReady"
+	// LogsReadyCondition reports the state of the Observer logs stack.
+	LogsReadyCondition = "LogsReady"
+	// ElasticsearchReadyCondition reports the health of the Elasticsearch cluster of the logs stack.
+	ElasticsearchReadyCondition = "ElasticsearchReady"
+	// ComponentDisabledReason is set in the condition of a disabled stack.
+	ComponentDisabledReason = "ComponentDisabledReason"
+)
+
+type MetricsBackend string
+
+const (
+	PrometheusBackend      MetricsBackend = "prometheus"
+	VictoriaMetricsBackend MetricsBackend = "victoriametrics"
+)
+
+type LogsBackend string
+
+const (
+	ElasticsearchBackend LogsBackend = "elasticsearch"
+	LokiBackend          LogsBackend = "loki"
+	ExternalLogsBackend  LogsBackend = "external"
+)
+
+// MetricsFederation configures the remote write of the workload cluster metrics to a central
+// store in the management cluster.
+type MetricsFederation struct {
+	// Enabled exposes the metrics backend of the management cluster as the central store
+	// in the management cluster Observer, and remote-writes the metrics to it in workload
+	// cluster Observers. The metrics are labeled with the cluster name, namespace and provider.
+	Enabled bool `json:"enabled,omitempty"`
+	// Host of the central store endpoint, set in the management cluster Observer.
+	// Defaults to the address of the management cluster ingress.
+	// +optional
+	Host string `json:"host,omitempty"`
+}
+
+// MetricsSpec configures the metrics stack of the cluster.
+type MetricsSpec struct {
+	// Enabled installs the metrics stack. Defaults to true.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// Backend storing the metrics. Defaults to prometheus.
+	// +kubebuilder:validation:Enum=prometheus;victoriametrics
+	// +optional
+	Backend MetricsBackend `json:"backend,omitempty"`
+	// Retention of the metrics, rounded up to days.
+	// +optional
+	Retention *metav1.Duration `json:"retention,omitempty"`
+	// StorageSize of the persistent volume storing the metrics.
+	// When empty, the metrics are lost when the backend pod is recreated.
+	// +optional
+	StorageSize *resource.Quantity `json:"storageSize,omitempty"`
+	// +optional
+	Federation MetricsFederation `json:"federation,omitempty"`
+}
+
+// IsEnabled returns true unless the metrics stack was disabled.
+func (m MetricsSpec) IsEnabled() bool {
+	return m.Enabled == nil || *m.Enabled
+}
+
+// GetBackend returns the metrics backend, Prometheus by default.
+func (m MetricsSpec) GetBackend() MetricsBackend {
+	if m.Backend == "" {
+		return PrometheusBackend
+	}
+	return m.Backend
+}
+
+// ExternalLogsSpec is an endpoint outside the cluster receiving the logs.
+type ExternalLogsSpec struct {
+	// Type of the endpoint API.
+	// +kubebuilder:validation:Enum=elasticsearch;loki
+	Type LogsBackend `json:"type"`
+	// URL of the endpoint, e.g. https://logs.example.com:9200.
+	URL string `json:"url"`
+	// SecretName is the name of a Secret in the Observer namespace with the username and password keys
+	// used to authenticate in the endpoint.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// ElasticsearchNodeSet configures a group of Elasticsearch nodes.
+type ElasticsearchNodeSet struct {
+	// Count of nodes in the group.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Count *int32 `json:"count,omitempty"`
+	// Resources of the Elasticsearch container.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// StorageSize of the data volume of each node. Defaults to the logs storage size.
+	// +optional
+	StorageSize *resource.Quantity `json:"storageSize,omitempty"`
+}
+
+// KibanaSpec configures the Kibana connected to the Elasticsearch cluster.
+type KibanaSpec struct {
+	// Enabled installs Kibana. Defaults to true.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// Count of Kibana instances. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Count *int32 `json:"count,omitempty"`
+	// Resources of the Kibana container.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// IsEnabled returns true unless Kibana was disabled.
+func (k KibanaSpec) IsEnabled() bool {
+	return k.Enabled == nil || *k.Enabled
+}
+
+// ElasticsearchSpec configures the Elasticsearch cluster of the elasticsearch logs backend.
+type ElasticsearchSpec struct {
+	// Version of Elasticsearch and Kibana. Defaults to 7.15.1.
+	// +optional
+	Version string `json:"version,omitempty"`
+	// Master nodes, which store data too. Defaults to one node.
+	// +optional
+	Master ElasticsearchNodeSet `json:"master,omitempty"`
+	// Data nodes. Defaults to one node per infra node, or per worker node in clusters without infra nodes.
+	// +optional
+	Data ElasticsearchNodeSet `json:"data,omitempty"`
+	// +optional
+	Kibana KibanaSpec `json:"kibana,omitempty"`
+}
+
+// LogsSpec configures the logs stack of the cluster.
+type LogsSpec struct {
+	// Enabled installs the logs stack. Defaults to true.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// Backend storing the logs. Defaults to elasticsearch.
+	// With the external backend, the logs are only shipped to the external endpoint.
+	// +kubebuilder:validation:Enum=elasticsearch;loki;external
+	// +optional
+	Backend LogsBackend `json:"backend,omitempty"`
+	// Retention of the logs, rounded up to days. Elasticsearch applies it with an
+	// index lifecycle policy that deletes the log indices.
+	// +optional
+	Retention *metav1.Duration `json:"retention,omitempty"`
+	// StorageSize of the persistent volumes storing the logs.
+	// +optional
+	StorageSize *resource.Quantity `json:"storageSize,omitempty"`
+	// External is the endpoint receiving the logs with the external backend.
+	// +optional
+	External *ExternalLogsSpec `json:"external,omitempty"`
+	// Elasticsearch configures the cluster of the elasticsearch backend.
+	// +optional
+	Elasticsearch ElasticsearchSpec `json:"elasticsearch,omitempty"`
+}
+
+// IsEnabled returns true unless the logs stack was disabled.
+func (l LogsSpec) IsEnabled() bool {
+	return l.Enabled == nil || *l.Enabled
+}
+
+// GetBackend returns the logs backend, Elasticsearch by default.
+func (l LogsSpec) GetBackend() LogsBackend {
+	if l.Backend == "" {
+		return ElasticsearchBackend
+	}
+	return l.Backend
+}
+
 // ObserverSpec defines the desired state of Observer
 type ObserverSpec struct {
 	// Pause Observer reconciliation.
@@ -28,6 +201,14 @@ type ObserverSpec struct {
 
 	// ClusterName is the name of the cluster to which this Observer belongs.
 	ClusterName string `json:"clusterName,omitempty"`
+
+	// Metrics configures the metrics stack.
+	// +optional
+	Metrics MetricsSpec `json:"metrics,omitempty"`
+
+	// Logs configures the logs stack.
+	// +optional
+	Logs LogsSpec `json:"logs,omitempty"`
 }
 
 // ObserverStatus defines the observed state of Observer
@@ -40,10 +221,18 @@ type ObserverStatus struct {
 	// +listType=map
 	// +listMapKey=type
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// FederationEndpoint is the remote write endpoint of the central metrics store.
+	FederationEndpoint string `json:"federationEndpoint,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.clusterName",description=""
+//+kubebuilder:printcolumn:name="Metrics",type="string",JSONPath=".status.conditions[?(@.type==\"MetricsReady\")].status",description=""
+//+kubebuilder:printcolumn:name="Logs",type="string",JSONPath=".status.conditions[?(@.type==\"LogsReady\")].status",description=""
+//+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
 
 // Observer is the Schema for the observers API
 type Observer struct {
@@ -60,6 +249,39 @@ func ObserverPaused(i Observer) *Observer {
 	return &i
 }
 
+// ObserverComponentReady registers the state of a stack of the given Observer.
+// Disabled stacks are reported as ready.
+func ObserverComponentReady(o Observer, conditionType string, enabled bool, err error) Observer {
+	switch {
+	case !enabled:
+		meta.SetResourceCondition(&o, conditionType, metav1.ConditionTrue, ComponentDisabledReason, "Component is disabled")
+	case err != nil:
+		meta.SetResourceCondition(&o, conditionType, metav1.ConditionFalse, meta.InstallFailedReason, err.Error())
+	default:
+		meta.SetResourceCondition(&o, conditionType, metav1.ConditionTrue, meta.InstallSucceededReason, "Component is installed")
+	}
+	return o
+}
+
+// ObserverComponentProgressing registers a stack of the given Observer waiting to be ready.
+func ObserverComponentProgressing(o Observer, conditionType, message string) Observer {
+	meta.SetResourceCondition(&o, conditionType, metav1.ConditionFalse, meta.WaitProvisionReason, message)
+	return o
+}
+
+// ObserverNotReady registers a failed reconciliation of the given Observer.
+func ObserverNotReady(o Observer, reason, message string) Observer {
+	meta.SetResourceCondition(&o, meta.ReadyCondition, metav1.ConditionFalse, reason, message)
+	return o
+}
+
+// ObserverReady registers a successful reconciliation of the given Observer.
+func ObserverReady(o Observer) Observer {
+	msg := "Observer reconciliation succeeded"
+	meta.SetResourceCondition(&o, meta.ReadyCondition, metav1.ConditionTrue, meta.ReconciliationSucceededReason, msg)
+	return o
+}
+
 func (i *Observer) GetStatusConditions() *[]metav1.Condition {
 	return &i.Status.Conditions
 }
@@ -0,0 +1,152 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	"github.com/getupio-undistro/meta"
+	"github.com/getupio-undistro/undistro/pkg/util"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LabelPolicyBundle is set in the policies applied by a PolicyBundle with the bundle name
+const LabelPolicyBundle = "undistro.io/policy-bundle"
+
+// PolicyParameters holds the values used to render a parameterized policy.
+// Parameters are referenced in the policy with the [[ ]] delimiters, e.g. [[ .allowedRegistries ]],
+// because Kyverno variables already use {{ }}.
+type PolicyParameters struct {
+	// Policy is the policy name.
+	Policy string `json:"policy"`
+	// Values used to render the policy.
+	Values *apiextensionsv1.JSON `json:"values,omitempty"`
+}
+
+// PolicyValues returns the parameter values of the policies
+func PolicyValues(params []PolicyParameters, policies ...string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for _, p := range params {
+		if p.Values == nil || !util.ContainsStringInSlice(policies, p.Policy) {
+			continue
+		}
+		err := json.Unmarshal(p.Values.Raw, &values)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid parameters for policy %s", p.Policy)
+		}
+	}
+	return values, nil
+}
+
+// PolicySourceReference references a ConfigMap in the PolicyBundle namespace.
+// Each key of the ConfigMap holds the YAML of one or more Kyverno policies.
+type PolicySourceReference struct {
+	Name string `json:"name"`
+	// Optional marks this reference as optional. When set, a not found error
+	// for the ConfigMap is ignored.
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// PolicyBundleSpec defines the desired state of PolicyBundle
+type PolicyBundleSpec struct {
+	Paused bool `json:"paused,omitempty"`
+	// ClusterSelector selects the clusters in the PolicyBundle namespace where the policies are applied.
+	// An empty selector selects all clusters.
+	ClusterSelector metav1.LabelSelector `json:"clusterSelector,omitempty"`
+	// PoliciesFrom holds references to ConfigMaps containing the policies.
+	PoliciesFrom []PolicySourceReference `json:"policiesFrom,omitempty"`
+	Parameters   []PolicyParameters      `json:"parameters,omitempty"`
+}
+
+// ClusterPolicyBundleStatus is the policy set applied to a cluster
+type ClusterPolicyBundleStatus struct {
+	ClusterName string `json:"clusterName"`
+	// Revision is the checksum of the policy set active in the cluster.
+	Revision        string                   `json:"revision,omitempty"`
+	AppliedPolicies []corev1.ObjectReference `json:"appliedPolicies,omitempty"`
+	// Message is the last error applying the policies to the cluster.
+	Message string `json:"message,omitempty"`
+}
+
+// PolicyBundleStatus defines the observed state of PolicyBundle
+type PolicyBundleStatus struct {
+	// ObservedGeneration is the last observed generation.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// Revision is the checksum of the desired policy set.
+	Revision string                      `json:"revision,omitempty"`
+	Clusters []ClusterPolicyBundleStatus `json:"clusters,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Revision",type="string",JSONPath=".status.revision",description=""
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
+// +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].message",description=""
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
+
+// PolicyBundle is the Schema for the policybundles API
+type PolicyBundle struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PolicyBundleSpec   `json:"spec,omitempty"`
+	Status PolicyBundleStatus `json:"status,omitempty"`
+}
+
+func (p *PolicyBundle) GetStatusConditions() *[]metav1.Condition {
+	return &p.Status.Conditions
+}
+
+func PolicyBundleNotReady(p PolicyBundle, reason, message string) PolicyBundle {
+	meta.SetResourceCondition(&p, meta.ReadyCondition, metav1.ConditionFalse, reason, message)
+	return p
+}
+
+func PolicyBundlePaused(p PolicyBundle) PolicyBundle {
+	meta.SetResourceCondition(&p, meta.ReadyCondition, metav1.ConditionTrue, meta.ReconciliationPausedReason, meta.ReconciliationPausedReason)
+	return p
+}
+
+func PolicyBundleReady(p PolicyBundle) PolicyBundle {
+	msg := "Policy bundle reconciliation succeeded"
+	meta.SetResourceCondition(&p, meta.ReadyCondition, metav1.ConditionTrue, meta.ReconciliationSucceededReason, msg)
+	return p
+}
+
+//+kubebuilder:object:root=true
+
+// PolicyBundleList contains a list of PolicyBundle
+type PolicyBundleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PolicyBundle `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PolicyBundle{}, &PolicyBundleList{})
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RecommendationSpec defines the desired state of Recommendation
+type RecommendationSpec struct {
+	// ClusterName is the name of the cluster to which this Recommendation belongs.
+	ClusterName string `json:"clusterName,omitempty"`
+}
+
+// WorkerRecommendation is the right-sizing recommendation for a worker pool
+// based on the resources requested in its nodes.
+type WorkerRecommendation struct {
+	// Index of the worker pool in the cluster spec.
+	Pool        int32  `json:"pool"`
+	MachineType string `json:"machineType,omitempty"`
+	Replicas    int32  `json:"replicas,omitempty"`
+	// Ratio between the CPU requested by pods and the allocatable CPU of the pool nodes.
+	CPURequestsRatio string `json:"cpuRequestsRatio,omitempty"`
+	// Ratio between the memory requested by pods and the allocatable memory of the pool nodes.
+	MemoryRequestsRatio string `json:"memoryRequestsRatio,omitempty"`
+	// Machine type that fits the requested resources keeping the number of replicas.
+	RecommendedMachineType string `json:"recommendedMachineType,omitempty"`
+	// Number of replicas that fits the requested resources keeping the machine type.
+	RecommendedReplicas int32  `json:"recommendedReplicas,omitempty"`
+	Reason              string `json:"reason,omitempty"`
+}
+
+// RecommendationStatus defines the observed state of Recommendation
+type RecommendationStatus struct {
+	// ObservedGeneration is the last observed generation.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition     `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+	Workers    []WorkerRecommendation `json:"workers,omitempty"`
+	// LastUpdated is the last time the recommended workers changed.
+	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".spec.clusterName",description=""
+//+kubebuilder:printcolumn:name="Last Updated",type="date",JSONPath=".status.lastUpdated",description=""
+
+// Recommendation is the Schema for the recommendations API
+type Recommendation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RecommendationSpec   `json:"spec,omitempty"`
+	Status RecommendationStatus `json:"status,omitempty"`
+}
+
+func (r *Recommendation) GetStatusConditions() *[]metav1.Condition {
+	return &r.Status.Conditions
+}
+
+//+kubebuilder:object:root=true
+
+// RecommendationList contains a list of Recommendation
+type RecommendationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Recommendation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Recommendation{}, &RecommendationList{})
+}
@@ -23,11 +23,14 @@ import (
 
 // AWSMachineSpec defines the desired state of AWSMachine
 type AWSMachineSpec struct {
-	InstanceType      string                  `json:"instanceType,omitempty"`
-	AvailabilityZones []string                `json:"availabilityZones,omitempty"`
-	Vcpus             string                  `json:"vcpus,omitempty"`
-	Memory            string                  `json:"memory,omitempty"`
-	ProviderRef       *corev1.ObjectReference `json:"providerRef,omitempty"`
+	InstanceType            string                  `json:"instanceType,omitempty"`
+	AvailabilityZones       []string                `json:"availabilityZones,omitempty"`
+	Vcpus                   string                  `json:"vcpus,omitempty"`
+	Memory                  string                  `json:"memory,omitempty"`
+	Accelerators            string                  `json:"accelerators,omitempty"`
+	AcceleratorManufacturer string                  `json:"acceleratorManufacturer,omitempty"`
+	PricePerHour            string                  `json:"pricePerHour,omitempty"`
+	ProviderRef             *corev1.ObjectReference `json:"providerRef,omitempty"`
 }
 
 // AWSMachineStatus defines the observed state of AWSMachine
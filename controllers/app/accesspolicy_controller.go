@@ -0,0 +1,253 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/getupio-undistro/controllerlib"
+	"github.com/getupio-undistro/meta"
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/kube"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// AccessPolicyReconciler reconciles a AccessPolicy object
+type AccessPolicyReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=app.undistro.io,resources=accesspolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=app.undistro.io,resources=accesspolicies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=app.undistro.io,resources=accesspolicies/finalizers,verbs=update
+
+func (r *AccessPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	start := time.Now()
+
+	p := appv1alpha1.AccessPolicy{}
+	if err := r.Get(ctx, req.NamespacedName, &p); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+	log.WithValues("AccessPolicy", req.NamespacedName)
+
+	// Initialize the patch helper.
+	patchHelper, err := patch.NewHelper(&p, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	defer controllerlib.PatchInstance(ctx, controllerlib.InstanceOpts{
+		Controller: "AccessPolicyController",
+		Request:    req.String(),
+		Object:     &p,
+		Error:      err,
+		Helper:     patchHelper,
+	})
+
+	if !p.ObjectMeta.DeletionTimestamp.IsZero() {
+		p, err = r.reconcileDelete(ctx, p)
+		return ctrl.Result{}, err
+	}
+
+	// Add our finalizer if it does not exist
+	if !controllerutil.ContainsFinalizer(&p, meta.Finalizer) {
+		log.Info("Adding finalizer")
+		controllerutil.AddFinalizer(&p, meta.Finalizer)
+		return ctrl.Result{}, nil
+	}
+
+	if p.Spec.Paused {
+		log.Info("Reconciliation is paused for this object")
+		p = appv1alpha1.AccessPolicyPaused(p)
+		return ctrl.Result{}, nil
+	}
+
+	if p.Generation < p.Status.ObservedGeneration {
+		log.Info("skipping this old version of reconciled object")
+		return ctrl.Result{}, nil
+	}
+
+	p, result, err := r.reconcile(ctx, p)
+	durationMsg := fmt.Sprintf("Reconcilation finished in %s", time.Since(start).String())
+	if result.RequeueAfter > 0 {
+		durationMsg = fmt.Sprintf("%s, next run in %s", durationMsg, result.RequeueAfter.String())
+	}
+	log.Info(durationMsg)
+	return result, err
+}
+
+func (r *AccessPolicyReconciler) reconcile(ctx context.Context, p appv1alpha1.AccessPolicy) (appv1alpha1.AccessPolicy, ctrl.Result, error) {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&p.Spec.ClusterSelector)
+	if err != nil {
+		return appv1alpha1.AccessPolicyNotReady(p, meta.ArtifactFailedReason, err.Error()), ctrl.Result{}, err
+	}
+	clusters := appv1alpha1.ClusterList{}
+	err = r.List(ctx, &clusters, client.InNamespace(p.GetNamespace()), client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return p, ctrl.Result{}, err
+	}
+
+	objs := p.Bindings()
+	previous := make(map[string]appv1alpha1.ClusterAccessPolicyStatus)
+	for _, st := range p.Status.Clusters {
+		previous[st.ClusterName] = st
+	}
+	statuses := make([]appv1alpha1.ClusterAccessPolicyStatus, 0, len(clusters.Items))
+	failed := 0
+	for _, cl := range clusters.Items {
+		if !cl.DeletionTimestamp.IsZero() {
+			continue
+		}
+		st, ok := previous[cl.Name]
+		if !ok {
+			st = appv1alpha1.ClusterAccessPolicyStatus{ClusterName: cl.Name}
+		}
+		delete(previous, cl.Name)
+		log.Info("Applying access policy", "cluster", cl.Name)
+		st, err = r.applyBindings(ctx, p, st, objs)
+		if err != nil {
+			log.Info("failed to apply access policy", "cluster", cl.Name, "err", err)
+			failed++
+		}
+		statuses = append(statuses, st)
+	}
+	// remove the bindings from clusters not selected anymore
+	for _, st := range previous {
+		log.Info("Removing access policy", "cluster", st.ClusterName)
+		st, err = r.removeBindings(ctx, p, st)
+		if err != nil {
+			log.Info("failed to remove access policy", "cluster", st.ClusterName, "err", err)
+			failed++
+			statuses = append(statuses, st)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ClusterName < statuses[j].ClusterName
+	})
+	p.Status.Clusters = statuses
+	if failed > 0 {
+		msg := fmt.Sprintf("Failed to reconcile bindings in %d clusters", failed)
+		return appv1alpha1.AccessPolicyNotReady(p, meta.ObjectsApliedFailedReason, msg), ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+	return appv1alpha1.AccessPolicyReady(p), ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+// applyBindings creates the bindings in the cluster and deletes the ones applied before and
+// no longer desired. RoleBindings in namespaces that don't exist in the cluster are skipped
+// until the namespace is created.
+func (r *AccessPolicyReconciler) applyBindings(
+	ctx context.Context, p appv1alpha1.AccessPolicy, st appv1alpha1.ClusterAccessPolicyStatus, objs []client.Object) (appv1alpha1.ClusterAccessPolicyStatus, error) {
+	clusterClient, err := kube.NewClusterClient(ctx, r.Client, st.ClusterName, p.GetNamespace())
+	if err != nil {
+		st.Message = err.Error()
+		return st, err
+	}
+	st.AppliedBindings, err = applyClusterObjects(ctx, clusterClient, objs, st.AppliedBindings, true)
+	if err != nil {
+		st.Message = err.Error()
+		return st, err
+	}
+	st.Message = ""
+	return st, nil
+}
+
+func (r *AccessPolicyReconciler) removeBindings(ctx context.Context, p appv1alpha1.AccessPolicy, st appv1alpha1.ClusterAccessPolicyStatus) (appv1alpha1.ClusterAccessPolicyStatus, error) {
+	key := client.ObjectKey{
+		Name:      st.ClusterName,
+		Namespace: p.GetNamespace(),
+	}
+	err := removeClusterObjects(ctx, r.Client, key, st.AppliedBindings)
+	if err != nil {
+		st.Message = err.Error()
+	}
+	return st, err
+}
+
+func (r *AccessPolicyReconciler) reconcileDelete(ctx context.Context, p appv1alpha1.AccessPolicy) (appv1alpha1.AccessPolicy, error) {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+	for i, st := range p.Status.Clusters {
+		log.Info("Removing access policy", "cluster", st.ClusterName)
+		p.Status.Clusters[i], err = r.removeBindings(ctx, p, st)
+		if err != nil {
+			return appv1alpha1.AccessPolicyNotReady(p, meta.ReconciliationDeletingReason, err.Error()), err
+		}
+	}
+	controllerutil.RemoveFinalizer(&p, meta.Finalizer)
+	return p, nil
+}
+
+// clusterToAccessPolicies enqueues the policies selecting the cluster or already applied in it.
+func (r *AccessPolicyReconciler) clusterToAccessPolicies(o client.Object) []ctrl.Request {
+	policies := appv1alpha1.AccessPolicyList{}
+	err := r.List(context.Background(), &policies, client.InNamespace(o.GetNamespace()))
+	if err != nil {
+		ctrl.Log.Info("failed to list access policies", "err", err)
+		return nil
+	}
+	reqs := make([]ctrl.Request, 0)
+	for _, p := range policies.Items {
+		applied := false
+		for _, st := range p.Status.Clusters {
+			applied = applied || st.ClusterName == o.GetName()
+		}
+		if applied || selectsCluster(p.Spec.ClusterSelector, o) {
+			reqs = append(reqs, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&p)})
+		}
+	}
+	return reqs
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AccessPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&appv1alpha1.AccessPolicy{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 10}).
+		Watches(
+			&source.Kind{
+				Type: &appv1alpha1.Cluster{},
+			},
+			handler.EnqueueRequestsFromMapFunc(r.clusterToAccessPolicies),
+			builder.WithPredicates(clusterSelectionChanged),
+		).
+		Complete(r)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package app
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestClusterToAccessPolicies(t *testing.T) {
+	prod := &appv1alpha1.AccessPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec: appv1alpha1.AccessPolicySpec{
+			ClusterSelector: metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+		},
+		Status: appv1alpha1.AccessPolicyStatus{
+			Clusters: []appv1alpha1.ClusterAccessPolicyStatus{{ClusterName: "relabeled"}},
+		},
+	}
+	all := &appv1alpha1.AccessPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "all", Namespace: "default"},
+	}
+	other := &appv1alpha1.AccessPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "all", Namespace: "other"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(prod, all, other).Build()
+	r := &AccessPolicyReconciler{Client: c, Scheme: scheme.Scheme}
+	tests := []struct {
+		name    string
+		cluster *appv1alpha1.Cluster
+		want    []ctrl.Request
+	}{
+		{
+			name:    "cluster selected by two policies",
+			cluster: &appv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cool-cluster", Namespace: "default", Labels: map[string]string{"env": "prod"}}},
+			want:    policyBundleRequests("all", "prod"),
+		},
+		{
+			name:    "cluster selected by the empty selector",
+			cluster: &appv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "dev-cluster", Namespace: "default"}},
+			want:    policyBundleRequests("all"),
+		},
+		{
+			name:    "cluster relabeled out of a policy",
+			cluster: &appv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "relabeled", Namespace: "default", Labels: map[string]string{"env": "dev"}}},
+			want:    policyBundleRequests("all", "prod"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.clusterToAccessPolicies(tt.cluster)
+			sort.Slice(got, func(i, j int) bool {
+				return got[i].Name < got[j].Name
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("clusterToAccessPolicies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/getupio-undistro/undistro/pkg/fs"
 	"github.com/getupio-undistro/undistro/pkg/hr"
 	"github.com/getupio-undistro/undistro/pkg/kube"
+	"github.com/getupio-undistro/undistro/pkg/registry"
 	"github.com/getupio-undistro/undistro/pkg/retry"
 	"github.com/getupio-undistro/undistro/pkg/scheme"
 	"github.com/getupio-undistro/undistro/pkg/template"
@@ -55,6 +56,9 @@ import (
 type ClusterReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// ImageRegistry moves the kubeadm images of the cluster templates
+	// to a local registry on disconnected installations.
+	ImageRegistry string
 }
 
 // +kubebuilder:rbac:groups=*,resources=*,verbs=*
@@ -150,6 +154,11 @@ func (r *ClusterReconciler) reconcile(ctx context.Context, cl appv1alpha1.Cluste
 	}
 	log.Info("Cluster capabilities", "totalWorkerPools", cl.Status.TotalWorkerPools, "totalWorkerReplicas", cl.Status.TotalWorkerReplicas)
 
+	cl.Status.EstimatedCost, err = cloud.EstimateCost(&cl)
+	if err != nil {
+		log.Info("Unable to estimate cluster cost", "err", err.Error())
+	}
+
 	// we need to install calico in managed flavors too for network policy support
 	err = r.reconcileCNI(ctx, &cl)
 	if err != nil {
@@ -157,17 +166,19 @@ func (r *ClusterReconciler) reconcile(ctx context.Context, cl appv1alpha1.Cluste
 		return cl, ctrl.Result{}, err
 	}
 
-	if cl.Annotations != nil {
-		_, autoscalerEnabled := cl.Annotations[meta.EnableClusterAutoscaler]
-		if autoscalerEnabled {
-			err := r.reconcileClusterAutoscaler(ctx, &cl)
-			if err != nil {
-				meta.SetResourceCondition(&cl, meta.ClusterAutoscalerInstalledCondition, metav1.ConditionFalse, meta.ClusterAutoscalerInstalledFailedReason, err.Error())
-				return cl, ctrl.Result{}, err
-			}
+	if cl.AutoscalerEnabled() {
+		err := r.reconcileClusterAutoscaler(ctx, &cl)
+		if err != nil {
+			meta.SetResourceCondition(&cl, meta.ClusterAutoscalerInstalledCondition, metav1.ConditionFalse, meta.ClusterAutoscalerInstalledFailedReason, err.Error())
+			return cl, ctrl.Result{}, err
 		}
 	}
 
+	err = r.reconcileAccelerators(ctx, &cl)
+	if err != nil {
+		return appv1alpha1.ClusterNotReady(cl, meta.InstallFailedReason, err.Error()), ctrl.Result{}, err
+	}
+
 	err = cloud.ReconcileIntegration(ctx, r.Client, log, &cl, &capiCluster)
 	if err != nil {
 		meta.SetResourceCondition(&cl, meta.CloudProviderInstalledCondition, metav1.ConditionFalse, meta.CloudProvideInstalledFailedReason, err.Error())
@@ -209,6 +220,9 @@ func (r *ClusterReconciler) reconcile(ctx context.Context, cl appv1alpha1.Cluste
 			return appv1alpha1.ClusterNotReady(cl, meta.TemplateAppliedFailed, err.Error()), ctrl.Result{}, err
 		}
 		for _, o := range objs {
+			if r.ImageRegistry != "" {
+				relocateImageRepositories(o.Object, r.ImageRegistry)
+			}
 			if o.GetAPIVersion() == capi.GroupVersion.String() && o.GetKind() == "Cluster" {
 				err = ctrl.SetControllerReference(&cl, &o, scheme.Scheme)
 				if err != nil {
@@ -252,6 +266,13 @@ func (r *ClusterReconciler) reconcile(ctx context.Context, cl appv1alpha1.Cluste
 				return cl, ctrl.Result{}, err
 			}
 		}
+		if cl.AutoscalerEnabled() {
+			cl.Status.Autoscaler, err = kube.AutoscalerStatus(ctx, r.Client, &cl)
+			if err != nil {
+				// the status ConfigMap is written only after the autoscaler first loop
+				log.Info("Unable to retrieve cluster autoscaler status", "err", err.Error())
+			}
+		}
 		return cl, ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
 	}
 	return appv1alpha1.ClusterNotReady(cl, meta.WaitProvisionReason, "wait cluster to be provisioned"), ctrl.Result{RequeueAfter: 30 * time.Second}, nil
@@ -509,8 +530,13 @@ func (r *ClusterReconciler) reconcileClusterAutoscaler(ctx context.Context, cl *
 		meta.SetResourceCondition(cl, meta.ClusterAutoscalerInstalledCondition, metav1.ConditionTrue, meta.ClusterAutoscalerInstalledSuccessReason, "cluster-autoscaler installed")
 	}
 
+	autoscaler := appv1alpha1.ClusterAutoscaler{}
+	if cl.Spec.Autoscaler != nil {
+		autoscaler = *cl.Spec.Autoscaler
+	}
 	values := map[string]interface{}{
 		"clusterName": cl.Name,
+		"extraArgs":   autoscaler.ExtraArgs(),
 	}
 	release, err = hr.Prepare(chartName, cl.GetNamespace(), cl.GetNamespace(), chartVersion, cl.Name, values)
 	if err != nil {
@@ -533,6 +559,49 @@ func (r *ClusterReconciler) reconcileClusterAutoscaler(ctx context.Context, cl *
 	return nil
 }
 
+func (r *ClusterReconciler) reconcileAccelerators(ctx context.Context, cl *appv1alpha1.Cluster) error {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+	for _, accType := range cl.Accelerators() {
+		log.Info("Reconciling accelerator", "type", accType)
+		release, err := hr.PrepareAccelerator(cl, accType)
+		if err != nil {
+			return err
+		}
+		if release.Labels == nil {
+			release.Labels = make(map[string]string)
+		}
+		release.Labels[meta.LabelUndistroMove] = ""
+		if release.Annotations == nil {
+			release.Annotations = make(map[string]string)
+		}
+		release.Annotations[meta.HelmReleaseLocation] = ""
+		err = hr.Install(ctx, r.Client, log, release, cl)
+		if err != nil {
+			return err
+		}
+	}
+	// the drivers of accelerators the pools don't request anymore are uninstalled
+	for _, name := range hr.UnusedAcceleratorReleases(cl) {
+		release := appv1alpha1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      hr.GetObjectName(name, cl.Name),
+				Namespace: cl.GetNamespace(),
+			},
+		}
+		err = r.Delete(ctx, &release)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		if err == nil {
+			log.Info("Uninstalling unused accelerator release", "release", release.Name)
+		}
+	}
+	return nil
+}
+
 func (r *ClusterReconciler) reconcileDelete(ctx context.Context, undistroCluster *appv1alpha1.Cluster) (ctrl.Result, error) {
 	log, err := logr.FromContext(ctx)
 	if err != nil {
@@ -629,3 +698,27 @@ func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		).
 		Complete(r)
 }
+
+// relocateImageRepositories moves the image repositories of a rendered
+// template object to reg, see registry.Relocate.
+func relocateImageRepositories(obj map[string]interface{}, reg string) {
+	for k, v := range obj {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			relocateImageRepositories(v, reg)
+		case []interface{}:
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					relocateImageRepositories(m, reg)
+				}
+			}
+		case string:
+			if k != "imageRepository" {
+				continue
+			}
+			if repo, err := registry.Relocate(v, reg); err == nil {
+				obj[k] = repo
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package app
+
+import (
+	"context"
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/fs"
+	"github.com/getupio-undistro/undistro/pkg/hr"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	"github.com/getupio-undistro/undistro/pkg/template"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSpotTemplate(t *testing.T) {
+	tests := []struct {
+		name   string
+		flavor string
+		spot   *appv1alpha1.Spot
+		want   interface{}
+	}{
+		{name: "ec2 spot", flavor: appv1alpha1.EC2.String(), spot: &appv1alpha1.Spot{Enabled: true, AllocationStrategy: "lowest-price"}, want: "lowest-price"},
+		{name: "eks spot", flavor: appv1alpha1.EKS.String(), spot: &appv1alpha1.Spot{Enabled: true, AllocationStrategy: "lowest-price"}, want: "lowest-price"},
+		{name: "ec2 spot disabled", flavor: appv1alpha1.EC2.String(), spot: &appv1alpha1.Spot{AllocationStrategy: "lowest-price"}},
+		{name: "eks spot disabled", flavor: appv1alpha1.EKS.String(), spot: &appv1alpha1.Spot{AllocationStrategy: "lowest-price"}},
+		{name: "ec2 on-demand", flavor: appv1alpha1.EC2.String()},
+		{name: "eks on-demand", flavor: appv1alpha1.EKS.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replicas := int32(1)
+			cl := &appv1alpha1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "spot", Namespace: "default"},
+			}
+			cl.Spec.KubernetesVersion = "v1.21.3"
+			cl.Spec.InfrastructureProvider = appv1alpha1.InfrastructureProvider{
+				Name:   appv1alpha1.Amazon.String(),
+				Flavor: tt.flavor,
+				Region: "us-east-1",
+			}
+			cl.Spec.ControlPlane = &appv1alpha1.ControlPlaneNode{
+				Node: appv1alpha1.Node{Replicas: &replicas, MachineType: "t3.medium"},
+			}
+			cl.Spec.Workers = []appv1alpha1.WorkerNode{
+				{
+					Node:                    appv1alpha1.Node{Replicas: &replicas, MachineType: "t3.medium"},
+					AlternativeMachineTypes: []string{"t3a.medium"},
+					Spot:                    tt.spot,
+				},
+			}
+			vars := map[string]interface{}{
+				"Cluster":        cl,
+				"ENV":            map[string]interface{}{"ROLE_NAME": ""},
+				"CPID":           "cp",
+				"WorkersChanged": []int{0},
+				"OldID":          "",
+			}
+			objs, err := template.GetObjs(fs.FS, "clustertemplates", cl.GetTemplate(), vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pools := 0
+			for _, o := range objs {
+				if o.GetKind() != "AWSMachinePool" {
+					continue
+				}
+				pools++
+				got := nestedValue(t, o.Object, "spec", "mixedInstancesPolicy", "instancesDistribution", "spotAllocationStrategy")
+				if got != tt.want {
+					t.Errorf("instancesDistribution.spotAllocationStrategy = %v, want %v", got, tt.want)
+				}
+				// market options in the launch template are rejected in mixed instances groups
+				if got := nestedValue(t, o.Object, "spec", "awsLaunchTemplate", "spotMarketOptions"); got != nil {
+					t.Errorf("awsLaunchTemplate.spotMarketOptions = %v, want none", got)
+				}
+			}
+			if pools != 1 {
+				t.Errorf("rendered %d AWSMachinePools, want 1", pools)
+			}
+		})
+	}
+}
+
+func TestReconcileAcceleratorsAddThenRemove(t *testing.T) {
+	replicas := int32(1)
+	cl := &appv1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu", Namespace: "default", UID: "gpu-uid"},
+	}
+	cl.Spec.InfrastructureProvider = appv1alpha1.InfrastructureProvider{
+		Name:   appv1alpha1.Amazon.String(),
+		Flavor: appv1alpha1.EC2.String(),
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	r := &ClusterReconciler{Client: c, Scheme: scheme.Scheme}
+	key := client.ObjectKey{Name: hr.GetObjectName("gpu-operator", cl.Name), Namespace: cl.Namespace}
+	steps := []struct {
+		name        string
+		accelerator *appv1alpha1.Accelerator
+		wantRelease bool
+	}{
+		{name: "accelerator pool added", accelerator: &appv1alpha1.Accelerator{Type: "nvidia"}, wantRelease: true},
+		{name: "accelerator pool removed"},
+		{name: "no accelerator pool"},
+	}
+	for _, step := range steps {
+		cl.Spec.Workers = []appv1alpha1.WorkerNode{
+			{
+				Node:        appv1alpha1.Node{Replicas: &replicas, MachineType: "g4dn.xlarge"},
+				Accelerator: step.accelerator,
+			},
+		}
+		err := r.reconcileAccelerators(context.Background(), cl)
+		if err != nil {
+			t.Fatalf("%s: reconcileAccelerators() error = %v", step.name, err)
+		}
+		release := appv1alpha1.HelmRelease{}
+		err = c.Get(context.Background(), key, &release)
+		if client.IgnoreNotFound(err) != nil {
+			t.Fatal(err)
+		}
+		if got := !apierrors.IsNotFound(err); got != step.wantRelease {
+			t.Errorf("%s: gpu-operator release exists = %v, want %v", step.name, got, step.wantRelease)
+		}
+	}
+}
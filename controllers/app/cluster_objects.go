@@ -0,0 +1,139 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package app
+
+import (
+	"context"
+	"reflect"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/kube"
+	"github.com/getupio-undistro/undistro/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// applyClusterObjects creates or updates the objects selected clusters receive, like PolicyBundle
+// policies and AccessPolicy bindings, and deletes the applied ones that are no longer desired. It returns the references of the objects in the cluster. With
+// skipMissingNamespaces, objects in namespaces that don't exist in the cluster are skipped
+// until the namespace is created. On errors the references of the objects applied so far are
+// kept with the previous ones, so the next run prunes them.
+func applyClusterObjects(
+	ctx context.Context, c client.Client, objs []client.Object, applied []corev1.ObjectReference, skipMissingNamespaces bool) ([]corev1.ObjectReference, error) {
+	refs := make([]corev1.ObjectReference, 0, len(objs))
+	desired := make(map[corev1.ObjectReference]bool, len(objs))
+	for _, o := range objs {
+		o = o.DeepCopyObject().(client.Object)
+		_, err := util.CreateOrUpdate(ctx, c, o)
+		if err != nil {
+			if skipMissingNamespaces && apierrors.IsNotFound(err) && o.GetNamespace() != "" {
+				continue
+			}
+			return mergeRefs(refs, applied), err
+		}
+		ref := objectRef(o)
+		refs = append(refs, ref)
+		desired[ref] = true
+	}
+	for _, ref := range applied {
+		if desired[ref] {
+			continue
+		}
+		err := deleteObjectRef(ctx, c, ref)
+		if err != nil {
+			return mergeRefs(refs, applied), err
+		}
+	}
+	return refs, nil
+}
+
+// mergeRefs returns the references of a followed by the ones of b not in a.
+func mergeRefs(a, b []corev1.ObjectReference) []corev1.ObjectReference {
+	merged := append([]corev1.ObjectReference{}, a...)
+	seen := make(map[corev1.ObjectReference]bool, len(a))
+	for _, ref := range a {
+		seen[ref] = true
+	}
+	for _, ref := range b {
+		if !seen[ref] {
+			merged = append(merged, ref)
+		}
+	}
+	return merged
+}
+
+// removeClusterObjects deletes the applied objects from the cluster of the key.
+func removeClusterObjects(ctx context.Context, c client.Client, key client.ObjectKey, applied []corev1.ObjectReference) error {
+	cl := appv1alpha1.Cluster{}
+	err := c.Get(ctx, key, &cl)
+	if err != nil {
+		// the objects are gone with the cluster
+		return client.IgnoreNotFound(err)
+	}
+	clusterClient, err := kube.NewClusterClient(ctx, c, key.Name, key.Namespace)
+	if err != nil {
+		return err
+	}
+	for _, ref := range applied {
+		err = deleteObjectRef(ctx, clusterClient, ref)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func objectRef(o client.Object) corev1.ObjectReference {
+	gvk := o.GetObjectKind().GroupVersionKind()
+	return corev1.ObjectReference{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  o.GetNamespace(),
+		Name:       o.GetName(),
+	}
+}
+
+func deleteObjectRef(ctx context.Context, c client.Client, ref corev1.ObjectReference) error {
+	u := unstructured.Unstructured{}
+	u.SetAPIVersion(ref.APIVersion)
+	u.SetKind(ref.Kind)
+	u.SetNamespace(ref.Namespace)
+	u.SetName(ref.Name)
+	return client.IgnoreNotFound(c.Delete(ctx, &u))
+}
+
+// selectsCluster returns true when the selector matches the labels of the cluster.
+func selectsCluster(selector metav1.LabelSelector, cl client.Object) bool {
+	s, err := metav1.LabelSelectorAsSelector(&selector)
+	return err == nil && s.Matches(labels.Set(cl.GetLabels()))
+}
+
+// clusterSelectionChanged filters the Cluster events that change the clusters selected by a
+// cluster selector, created clusters and label changes. Status updates are ignored, and
+// deleted clusters take the applied objects with them.
+var clusterSelectionChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return !reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
+	},
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package app
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func roleBinding(namespace, name string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "RoleBinding",
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"},
+	}
+}
+
+func TestApplyClusterObjects(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	applied, err := applyClusterObjects(ctx, c, []client.Object{roleBinding("web", "old"), roleBinding("web", "kept")}, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err = applyClusterObjects(ctx, c, []client.Object{roleBinding("web", "kept"), roleBinding("api", "new")}, applied, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []corev1.ObjectReference{objectRef(roleBinding("web", "kept")), objectRef(roleBinding("api", "new"))}
+	if !reflect.DeepEqual(applied, want) {
+		t.Errorf("applyClusterObjects() = %v, want %v", applied, want)
+	}
+	err = c.Get(ctx, client.ObjectKey{Name: "old", Namespace: "web"}, &rbacv1.RoleBinding{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("binding no longer desired was not pruned, err = %v", err)
+	}
+	err = c.Get(ctx, client.ObjectKey{Name: "kept", Namespace: "web"}, &rbacv1.RoleBinding{})
+	if err != nil {
+		t.Errorf("desired binding was pruned, err = %v", err)
+	}
+}
+
+// failingClient fails to create the objects of a name.
+type failingClient struct {
+	client.Client
+	name string
+}
+
+func (c failingClient) Create(ctx context.Context, o client.Object, opts ...client.CreateOption) error {
+	if o.GetName() == c.name {
+		return errors.New("create failed")
+	}
+	return c.Client.Create(ctx, o, opts...)
+}
+
+func TestApplyClusterObjectsKeepsAppliedOnError(t *testing.T) {
+	ctx := context.Background()
+	c := failingClient{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), name: "broken"}
+	previous := []corev1.ObjectReference{objectRef(roleBinding("web", "old"))}
+	applied, err := applyClusterObjects(ctx, c, []client.Object{roleBinding("web", "new"), roleBinding("web", "broken")}, previous, false)
+	if err == nil {
+		t.Fatal("applyClusterObjects() expected error")
+	}
+	want := []corev1.ObjectReference{objectRef(roleBinding("web", "new")), objectRef(roleBinding("web", "old"))}
+	if !reflect.DeepEqual(applied, want) {
+		t.Errorf("applyClusterObjects() = %v, want %v", applied, want)
+	}
+}
+
+func TestClusterSelectionChanged(t *testing.T) {
+	cluster := func(labels map[string]string) *metav1.PartialObjectMetadata {
+		return &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: labels}}
+	}
+	prod := map[string]string{"env": "prod"}
+	tests := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{name: "created", got: clusterSelectionChanged.Create(event.CreateEvent{Object: cluster(prod)}), want: true},
+		{name: "status updated", got: clusterSelectionChanged.Update(event.UpdateEvent{ObjectOld: cluster(prod), ObjectNew: cluster(prod)}), want: false},
+		{name: "relabeled", got: clusterSelectionChanged.Update(event.UpdateEvent{ObjectOld: cluster(prod), ObjectNew: cluster(map[string]string{"env": "dev"})}), want: true},
+		{name: "deleted", got: clusterSelectionChanged.Delete(event.DeleteEvent{Object: cluster(prod)}), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("clusterSelectionChanged = %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -28,11 +28,12 @@ import (
 	"github.com/getupio-undistro/undistro/pkg/fs"
 	"github.com/getupio-undistro/undistro/pkg/hr"
 	"github.com/getupio-undistro/undistro/pkg/kube"
+	"github.com/getupio-undistro/undistro/pkg/policy"
+	"github.com/getupio-undistro/undistro/pkg/policy/report"
 	"github.com/getupio-undistro/undistro/pkg/util"
 	"github.com/go-logr/logr"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/cluster-api/util/patch"
@@ -205,6 +206,7 @@ func (r *DefaultPoliciesReconciler) reconcile(ctx context.Context, p appv1alpha1
 	if err != nil {
 		appv1alpha1.DefaultPoliciesNotReady(p, meta.ArtifactFailedReason, err.Error())
 	}
+	p = r.reconcileReports(ctx, clusterClient, p)
 	return appv1alpha1.DefaultPoliciesReady(p), ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
 }
 
@@ -225,6 +227,8 @@ func (r *DefaultPoliciesReconciler) applyPolicies(ctx context.Context, clusterCl
 	if err != nil {
 		return p, err
 	}
+	rendered := make([][]byte, 0, len(dir))
+	desired := sets.NewString()
 	for _, f := range dir {
 		if f.IsDir() {
 			continue
@@ -234,41 +238,82 @@ func (r *DefaultPoliciesReconciler) applyPolicies(ctx context.Context, clusterCl
 		if err != nil {
 			return p, err
 		}
+		byt, err = renderPolicy(f.Name(), byt, p.Spec.Parameters)
+		if err != nil {
+			return p, err
+		}
+		rendered = append(rendered, byt)
 
 		objs, err := util.ToUnstructured(byt)
 		if err != nil {
 			return p, err
 		}
 		for _, o := range objs {
+			// excluded policies are removed by the prune below
 			if util.ContainsStringInSlice(p.Spec.ExcludePolicies, o.GetName()) {
-				// delete policy if exists
-				u := unstructured.Unstructured{}
-				u.SetGroupVersionKind(o.GroupVersionKind())
-				key := client.ObjectKey{
-					Name: o.GetName(),
-				}
-				err = clusterClient.Get(ctx, key, &u)
-				if !apierrors.IsNotFound(err) {
-					err = clusterClient.Delete(ctx, &u)
-					if err != nil {
-						log.V(2).Error(err, "can't exclude policy", "name", u.GetName())
-					}
-				}
 				continue
 			}
+			labels := o.GetLabels()
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			labels[appv1alpha1.LabelDefaultPolicies] = p.Name
+			o.SetLabels(labels)
+			action, namespaces := p.Spec.PolicySettings(o.GetName())
+			err = policy.Configure(&o, string(action), namespaces)
+			if err != nil {
+				return p, err
+			}
 			_, err = util.CreateOrUpdate(ctx, clusterClient, &o)
 			if err != nil {
 				log.Info("failed to apply policy", "name", o.GetName(), "err", err)
 				return p, err
 			}
-			p.Status.AppliedPolicies = append(p.Status.AppliedPolicies, o.GetName())
-			set := sets.NewString(p.Status.AppliedPolicies...)
-			p.Status.AppliedPolicies = set.UnsortedList()
+			desired.Insert(o.GetName())
 		}
 	}
+	owner := client.MatchingLabels{appv1alpha1.LabelDefaultPolicies: p.Name}
+	pruned, err := policy.Prune(ctx, clusterClient, owner, p.Status.AppliedPolicies, desired)
+	if err != nil {
+		return p, err
+	}
+	if len(pruned) > 0 {
+		log.Info("Removed policies", "names", pruned)
+	}
+	p.Status.AppliedPolicies = desired.List()
+	p.Status.Revision = policy.Revision(rendered)
 	return p, nil
 }
 
+// reconcileReports summarizes the Kyverno policy reports of the cluster in the DefaultPolicies status.
+// Reports are informational, so failures to read them are only logged.
+func (r *DefaultPoliciesReconciler) reconcileReports(ctx context.Context, clusterClient client.Client, p appv1alpha1.DefaultPolicies) appv1alpha1.DefaultPolicies {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+	reports, err := report.List(ctx, clusterClient)
+	if err != nil {
+		log.Info("failed to list policy reports", "err", err)
+		return p
+	}
+	p.Status.PolicyReports, p.Status.TopOffenders = report.Summarize(reports, report.MaxOffenders)
+	return p
+}
+
+// renderPolicy renders the policy template with the parameters of the policies it defines
+func renderPolicy(name string, byt []byte, params []appv1alpha1.PolicyParameters) ([]byte, error) {
+	names, err := policy.Names(name, byt)
+	if err != nil {
+		return nil, err
+	}
+	values, err := appv1alpha1.PolicyValues(params, names...)
+	if err != nil {
+		return nil, err
+	}
+	return policy.Render(name, byt, values)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *DefaultPoliciesReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
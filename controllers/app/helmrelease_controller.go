@@ -65,6 +65,10 @@ var (
 			Schemes: []string{"http", "https"},
 			New:     getter.NewHTTPGetter,
 		},
+		getter.Provider{
+			Schemes: []string{"oci"},
+			New:     helm.NewOCIGetter,
+		},
 	}
 )
 
@@ -21,6 +21,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/getupio-undistro/controllerlib"
@@ -31,10 +32,13 @@ import (
 	"github.com/getupio-undistro/undistro/pkg/undistro"
 	"github.com/getupio-undistro/undistro/pkg/util"
 	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
 	conciergev1aplha1 "go.pinniped.dev/generated/latest/apis/concierge/authentication/v1alpha1"
 	supervisorconfigv1aplha1 "go.pinniped.dev/generated/latest/apis/supervisor/config/v1alpha1"
 	supervisoridpv1aplha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/cluster-api/util/patch"
@@ -113,6 +117,15 @@ func (r *IdentityReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, nil
 	}
 
+	if util.IsMgmtCluster(instance.Spec.ClusterName) && instance.Spec.FederationDomain == nil {
+		log.Info("Migrating identity ConfigMap settings to the spec")
+		err = r.migrateConfigMap(ctx, instance)
+		if err != nil {
+			*instance = appv1alpha1.IdentityNotReady(*instance, meta.ArtifactFailedReason, err.Error())
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Add our finalizer if it does not exist
 	if !controllerutil.ContainsFinalizer(instance, meta.Finalizer) {
 		controllerutil.AddFinalizer(instance, meta.Finalizer)
@@ -123,7 +136,8 @@ func (r *IdentityReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return r.reconcileDelete(ctx, *instance)
 	}
 	log.Info("Checking if the Pinniped components are installed")
-	result, err := r.reconcile(ctx, *instance)
+	var result ctrl.Result
+	*instance, result, err = r.reconcile(ctx, *instance)
 
 	durationMsg := fmt.Sprintf("Reconcilation finished in %s", time.Since(start).String())
 	if result.RequeueAfter > 0 {
@@ -133,13 +147,54 @@ func (r *IdentityReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	return result, err
 }
 
+// migrateConfigMap moves the settings of the identity ConfigMap used by previous versions to the spec.
+func (r *IdentityReconciler) migrateConfigMap(ctx context.Context, instance *appv1alpha1.Identity) error {
+	cm := corev1.ConfigMap{}
+	key := client.ObjectKey{
+		Name:      appv1alpha1.IdentityConfigMapName,
+		Namespace: undistro.Namespace,
+	}
+	err := r.Get(ctx, key, &cm)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	return instance.Spec.MigrateConfigMap(cm)
+}
+
+// federationDomain returns the federation domain of the management cluster identity.
+func (r *IdentityReconciler) federationDomain(ctx context.Context, instance appv1alpha1.Identity) (*appv1alpha1.FederationDomain, error) {
+	if util.IsMgmtCluster(instance.Spec.ClusterName) {
+		if instance.Spec.FederationDomain == nil || instance.Spec.FederationDomain.Issuer == "" {
+			return nil, errors.New("federation domain issuer is not set")
+		}
+		return instance.Spec.FederationDomain, nil
+	}
+	identities := appv1alpha1.IdentityList{}
+	err := r.List(ctx, &identities)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range identities.Items {
+		if util.IsMgmtCluster(i.Spec.ClusterName) && i.Spec.FederationDomain != nil && i.Spec.FederationDomain.Issuer != "" {
+			return i.Spec.FederationDomain, nil
+		}
+	}
+	return nil, errors.New("management cluster identity has no federation domain issuer")
+}
+
 // reconcile ensures that, if identity is enabled, pinniped is installed in clusters
-func (r *IdentityReconciler) reconcile(ctx context.Context, instance appv1alpha1.Identity) (ctrl.Result, error) {
+func (r *IdentityReconciler) reconcile(ctx context.Context, instance appv1alpha1.Identity) (appv1alpha1.Identity, ctrl.Result, error) {
 	log, err := logr.FromContext(ctx)
 	if err != nil {
 		log = ctrl.Log
 	}
 
+	fedo, err := r.federationDomain(ctx, instance)
+	if err != nil {
+		log.Info(err.Error())
+		instance = appv1alpha1.IdentityNotReady(instance, meta.DependencyNotReadyReason, err.Error())
+		return instance, ctrl.Result{RequeueAfter: identityRequeueAfter}, nil
+	}
 	cl := &appv1alpha1.Cluster{}
 	clusterClient := r.Client
 	key := client.ObjectKey{
@@ -149,7 +204,8 @@ func (r *IdentityReconciler) reconcile(ctx context.Context, instance appv1alpha1
 	err = r.Get(ctx, key, cl)
 	if client.IgnoreNotFound(err) != nil {
 		log.Error(err, err.Error())
-		return ctrl.Result{}, err
+		instance = appv1alpha1.IdentityNotReady(instance, meta.GetClusterFailed, err.Error())
+		return instance, ctrl.Result{}, err
 	}
 	if util.IsMgmtCluster(instance.Spec.ClusterName) {
 		cl.Name = "management"
@@ -163,52 +219,49 @@ func (r *IdentityReconciler) reconcile(ctx context.Context, instance appv1alpha1
 	err = r.reconcileComponentInstallation(ctx, cl, instance, concierge, undistro.Namespace, "0.10.0", values)
 	if err != nil {
 		log.Error(err, err.Error())
-		return ctrl.Result{}, err
+		instance = appv1alpha1.IdentityNotReady(instance, meta.InstallFailedReason, err.Error())
+		return instance, ctrl.Result{}, err
 	}
-	fedo := make(map[string]interface{})
-	o, err := util.GetFromConfigMap(
-		ctx, clusterClient, "identity-config", undistro.Namespace, "federationdomain.yaml", fedo)
-	fedo = o.(map[string]interface{})
-	if err != nil {
-		log.Error(err, err.Error())
-		return ctrl.Result{}, err
-	}
-	issuer := fedo["issuer"].(string)
 	if util.IsMgmtCluster(instance.Spec.ClusterName) {
 		log.Info("Installing Pinniped components in cluster ", "cluster-name", instance.Spec.ClusterName)
 		// regex to get ip or dns names
-		callbackURL := fmt.Sprintf("https://%s/uapi/callback", hostFromURL(issuer))
+		callbackURL := fmt.Sprintf("https://%s/uapi/callback", hostFromURL(fedo.Issuer))
 		values["config"] = map[string]interface{}{
 			"callbackURL": callbackURL,
 		}
 		err = r.reconcileComponentInstallation(ctx, cl, instance, supervisor, undistro.Namespace, "0.10.0", values)
 		if err != nil {
 			log.Error(err, err.Error())
-			return ctrl.Result{}, err
+			instance = appv1alpha1.IdentityNotReady(instance, meta.InstallFailedReason, err.Error())
+			return instance, ctrl.Result{}, err
 		}
-		err = r.reconcileFederationDomain(ctx, fedo)
+		err = r.reconcileFederationDomain(ctx, *fedo)
 		if err != nil {
 			log.Error(err, err.Error())
-			return ctrl.Result{}, err
+			instance = appv1alpha1.IdentityNotReady(instance, meta.ObjectsApliedFailedReason, err.Error())
+			return instance, ctrl.Result{}, err
 		}
-		err = r.reconcileOIDCProvider(ctx)
+		err = r.reconcileIdentityProvider(ctx, instance)
 		if err != nil {
 			log.Error(err, err.Error())
-			return ctrl.Result{}, err
+			instance = appv1alpha1.IdentityNotReady(instance, meta.ObjectsApliedFailedReason, err.Error())
+			return instance, ctrl.Result{}, err
 		}
 	} else {
 		clusterClient, err = kube.NewClusterClient(ctx, r.Client, instance.Spec.ClusterName, cl.GetNamespace())
 		if err != nil {
 			log.Error(err, err.Error())
-			return ctrl.Result{}, err
+			instance = appv1alpha1.IdentityNotReady(instance, meta.GetClusterFailed, err.Error())
+			return instance, ctrl.Result{}, err
 		}
 	}
-	err = r.reconcileJWTAuthenticator(ctx, clusterClient, issuer)
+	err = r.reconcileJWTAuthenticator(ctx, clusterClient, fedo.Issuer)
 	if err != nil {
 		log.Error(err, err.Error())
-		return ctrl.Result{}, err
+		instance = appv1alpha1.IdentityNotReady(instance, meta.ObjectsApliedFailedReason, err.Error())
+		return instance, ctrl.Result{}, err
 	}
-	return ctrl.Result{RequeueAfter: identityRequeueAfter}, err
+	return appv1alpha1.IdentityReady(instance), ctrl.Result{RequeueAfter: identityRequeueAfter}, nil
 }
 
 func (r *IdentityReconciler) reconcileDelete(ctx context.Context, instance appv1alpha1.Identity) (res ctrl.Result, err error) {
@@ -236,7 +289,7 @@ func hostFromURL(input string) string {
 	return u.Host
 }
 
-func (r *IdentityReconciler) reconcileFederationDomain(ctx context.Context, federationDomainCfg map[string]interface{}) error {
+func (r *IdentityReconciler) reconcileFederationDomain(ctx context.Context, federationDomain appv1alpha1.FederationDomain) error {
 	log, err := logr.FromContext(ctx)
 	if err != nil {
 		log = ctrl.Log
@@ -244,14 +297,14 @@ func (r *IdentityReconciler) reconcileFederationDomain(ctx context.Context, fede
 
 	log.Info("Reconciling Federation Domain")
 	spec := supervisorconfigv1aplha1.FederationDomainSpec{}
-	spec.Issuer = federationDomainCfg["issuer"].(string)
+	spec.Issuer = federationDomain.Issuer
 	localClus, err := util.IsLocalCluster(ctx, r.Client)
 	if err != nil {
 		return err
 	}
-	if localClus != util.NonLocal {
+	if localClus != util.NonLocal && federationDomain.TLSSecretName != "" {
 		spec.TLS = &supervisorconfigv1aplha1.FederationDomainTLSSpec{
-			SecretName: federationDomainCfg["tlsSecretName"].(string),
+			SecretName: federationDomain.TLSSecretName,
 		}
 	}
 	fedo := &supervisorconfigv1aplha1.FederationDomain{
@@ -272,24 +325,6 @@ func (r *IdentityReconciler) reconcileFederationDomain(ctx context.Context, fede
 	return nil
 }
 
-func (r *IdentityReconciler) getIdentityConfigMap(ctx context.Context) (map[string]interface{}, error) {
-	log, err := logr.FromContext(ctx)
-	if err != nil {
-		log = ctrl.Log
-	}
-
-	log.Info("Retrieving Identity Config")
-	// get oidc related configmap
-	tmp := make(map[string]interface{})
-	o, err := util.GetFromConfigMap(
-		ctx, r.Client, "identity-config", undistro.Namespace, "oidcprovider.yaml", tmp)
-	if err != nil {
-		return nil, err
-	}
-	tmp = o.(map[string]interface{})
-	return tmp, nil
-}
-
 var providersOIDCProviderCfg = map[string]supervisoridpv1aplha1.OIDCIdentityProviderSpec{
 	string(appv1alpha1.Google): {
 		Issuer: "https://accounts.google.com",
@@ -310,33 +345,234 @@ var providersOIDCProviderCfg = map[string]supervisoridpv1aplha1.OIDCIdentityProv
 			Groups:   "groups",
 		},
 	},
+	// GitHub doesn't serve OpenID Connect discovery, so the issuer is a bridge with
+	// a GitHub connector, such as Dex, which must be set in the provider URL.
+	string(appv1alpha1.Github): {
+		AuthorizationConfig: supervisoridpv1aplha1.OIDCAuthorizationConfig{
+			AdditionalScopes: []string{"email", "profile", "groups"},
+		},
+		Claims: supervisoridpv1aplha1.OIDCClaims{
+			Username: "email",
+			Groups:   "groups",
+		},
+	},
+}
+
+const (
+	ldapProviderName            = "undistro-ldap-idp"
+	activeDirectoryProviderName = "undistro-activedirectory-idp"
+)
+
+func oidcProviderName(name appv1alpha1.OIDCProviderName) string {
+	return fmt.Sprintf("undistro-%s-idp", name)
+}
+
+// reconcileIdentityProvider configures the upstream identity provider set in the Identity,
+// falling back to the OIDC provider preset, and removes the other providers along with
+// their secrets, as the Pinniped Supervisor supports a single upstream provider.
+func (r *IdentityReconciler) reconcileIdentityProvider(ctx context.Context, instance appv1alpha1.Identity) error {
+	var err error
+	keep := ""
+	switch {
+	case instance.Spec.LDAPIdentityProvider != nil:
+		keep = ldapProviderName
+		err = r.reconcileLDAPProvider(ctx, instance)
+	case instance.Spec.ActiveDirectoryIdentityProvider != nil:
+		keep = activeDirectoryProviderName
+		err = r.reconcileActiveDirectoryProvider(ctx, instance)
+	default:
+		if instance.Spec.OIDC != nil {
+			keep = oidcProviderName(instance.Spec.OIDC.Name)
+		}
+		err = r.reconcileOIDCProvider(ctx, instance)
+	}
+	if err != nil {
+		return err
+	}
+	return r.pruneIdentityProviders(ctx, keep)
 }
 
-func (r *IdentityReconciler) reconcileOIDCProvider(ctx context.Context) error {
+// pruneIdentityProviders deletes the upstream providers created by UnDistro but the one named keep,
+// along with the client or bind secret copied for each of them.
+func (r *IdentityReconciler) pruneIdentityProviders(ctx context.Context, keep string) error {
+	providers := []struct {
+		list         client.ObjectList
+		secretSuffix string
+	}{
+		{list: &supervisoridpv1aplha1.OIDCIdentityProviderList{}, secretSuffix: "-client"},
+		{list: &supervisoridpv1aplha1.LDAPIdentityProviderList{}, secretSuffix: "-bind"},
+		{list: &supervisoridpv1aplha1.ActiveDirectoryIdentityProviderList{}, secretSuffix: "-bind"},
+	}
+	for _, p := range providers {
+		err := r.List(ctx, p.list, client.InNamespace(undistro.Namespace))
+		if apimeta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		secretSuffix := p.secretSuffix
+		err = apimeta.EachListItem(p.list, func(o runtime.Object) error {
+			provider, ok := o.(client.Object)
+			if !ok {
+				return nil
+			}
+			name := provider.GetName()
+			if name == keep || !strings.HasPrefix(name, "undistro-") || !strings.HasSuffix(name, "-idp") {
+				return nil
+			}
+			secret := &corev1.Secret{}
+			secret.SetName(name + secretSuffix)
+			secret.SetNamespace(undistro.Namespace)
+			for _, obj := range []client.Object{provider, secret} {
+				err := r.Delete(ctx, obj)
+				if client.IgnoreNotFound(err) != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pinnipedOIDCClientSecretType is the Secret type of OIDC client credentials read by the Pinniped Supervisor
+const pinnipedOIDCClientSecretType corev1.SecretType = "secrets.pinniped.dev/oidc-client"
+
+// reconcileProviderSecret copies the keys of a provider Secret from the Identity namespace
+// to the Pinniped Supervisor namespace with the Secret type expected by Pinniped.
+func (r *IdentityReconciler) reconcileProviderSecret(
+	ctx context.Context, instance appv1alpha1.Identity, secretName, targetName string, secretType corev1.SecretType, keys ...string) error {
+	secret := corev1.Secret{}
+	key := client.ObjectKey{
+		Name:      secretName,
+		Namespace: instance.GetNamespace(),
+	}
+	err := r.Get(ctx, key, &secret)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get secret %s", key)
+	}
+	target := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Secret",
+			APIVersion: corev1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      targetName,
+			Namespace: undistro.Namespace,
+		},
+		Type: secretType,
+		Data: make(map[string][]byte, len(keys)),
+	}
+	for _, k := range keys {
+		if len(secret.Data[k]) == 0 {
+			return errors.Errorf("secret %s has no %s", key, k)
+		}
+		target.Data[k] = secret.Data[k]
+	}
+	_, err = util.CreateOrUpdate(ctx, r.Client, target)
+	return err
+}
+
+func (r *IdentityReconciler) reconcileLDAPProvider(ctx context.Context, instance appv1alpha1.Identity) error {
 	log, err := logr.FromContext(ctx)
 	if err != nil {
 		log = ctrl.Log
 	}
 
-	log.Info("Reconciling OIDC provider")
-	cfgMap, err := r.getIdentityConfigMap(ctx)
+	log.Info("Reconciling LDAP provider")
+	spec := *instance.Spec.LDAPIdentityProvider
+	spec.Bind.SecretName = fmt.Sprintf("%s-bind", ldapProviderName)
+	err = r.reconcileProviderSecret(ctx, instance, instance.Spec.LDAPIdentityProvider.Bind.SecretName, spec.Bind.SecretName,
+		corev1.SecretTypeBasicAuth, corev1.BasicAuthUsernameKey, corev1.BasicAuthPasswordKey)
+	if err != nil {
+		return err
+	}
+	ldapProvider := &supervisoridpv1aplha1.LDAPIdentityProvider{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "LDAPIdentityProvider",
+			APIVersion: supervisoridpv1aplha1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ldapProviderName,
+			Namespace: undistro.Namespace,
+		},
+		Spec: spec,
+	}
+	_, err = util.CreateOrUpdate(ctx, r.Client, ldapProvider)
+	return err
+}
+
+func (r *IdentityReconciler) reconcileActiveDirectoryProvider(ctx context.Context, instance appv1alpha1.Identity) error {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+
+	log.Info("Reconciling Active Directory provider")
+	spec := *instance.Spec.ActiveDirectoryIdentityProvider
+	spec.Bind.SecretName = fmt.Sprintf("%s-bind", activeDirectoryProviderName)
+	err = r.reconcileProviderSecret(ctx, instance, instance.Spec.ActiveDirectoryIdentityProvider.Bind.SecretName, spec.Bind.SecretName,
+		corev1.SecretTypeBasicAuth, corev1.BasicAuthUsernameKey, corev1.BasicAuthPasswordKey)
 	if err != nil {
-		log.Info(err.Error())
 		return err
 	}
+	adProvider := &supervisoridpv1aplha1.ActiveDirectoryIdentityProvider{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ActiveDirectoryIdentityProvider",
+			APIVersion: supervisoridpv1aplha1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      activeDirectoryProviderName,
+			Namespace: undistro.Namespace,
+		},
+		Spec: spec,
+	}
+	_, err = util.CreateOrUpdate(ctx, r.Client, adProvider)
+	return err
+}
+
+func (r *IdentityReconciler) reconcileOIDCProvider(ctx context.Context, instance appv1alpha1.Identity) error {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
 
-	log.Info("Retrieving info about the OIDC Identity Provider configured")
-	name := cfgMap["issuer"].(map[string]interface{})["name"].(string)
-	fmtName := fmt.Sprintf("undistro-%s-idp", name)
+	cfg := instance.Spec.OIDC
+	if cfg == nil {
+		log.Info("No upstream identity provider set")
+		return nil
+	}
+	log.Info("Reconciling OIDC provider")
+	preset, ok := providersOIDCProviderCfg[string(cfg.Name)]
+	if !ok {
+		return errors.Errorf("OIDC provider %s is not supported", cfg.Name)
+	}
+	fmtName := oidcProviderName(cfg.Name)
 	spec := supervisoridpv1aplha1.OIDCIdentityProviderSpec{}
 	spec.Client = supervisoridpv1aplha1.OIDCClient{
-		SecretName: "idp-credentials",
+		SecretName: fmt.Sprintf("%s-client", fmtName),
+	}
+	err = r.reconcileProviderSecret(ctx, instance, cfg.ClientSecretName, spec.Client.SecretName,
+		pinnipedOIDCClientSecretType, "clientID", "clientSecret")
+	if err != nil {
+		return err
+	}
+	spec.Issuer = preset.Issuer
+	if cfg.URL != "" {
+		spec.Issuer = cfg.URL
+	}
+	if spec.Issuer == "" {
+		return errors.Errorf("issuer url is required for the %s provider", cfg.Name)
 	}
-	spec.Issuer = providersOIDCProviderCfg[name].Issuer
-	spec.AuthorizationConfig = providersOIDCProviderCfg[name].AuthorizationConfig
-	spec.Claims = providersOIDCProviderCfg[name].Claims
+	spec.AuthorizationConfig = preset.AuthorizationConfig
+	spec.AuthorizationConfig.AdditionalScopes = append(append([]string{}, preset.AuthorizationConfig.AdditionalScopes...), cfg.AdditionalScopes...)
+	spec.Claims = preset.Claims
 
-	log.Info("Mounting the OIDC Identity Provider", "provider", name, "providerName", fmtName)
+	log.Info("Mounting the OIDC Identity Provider", "provider", cfg.Name, "providerName", fmtName)
 	oidcProvider := &supervisoridpv1aplha1.OIDCIdentityProvider{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "OIDCIdentityProvider",
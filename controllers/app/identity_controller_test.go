@@ -0,0 +1,110 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package app
+
+import (
+	"context"
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	"github.com/getupio-undistro/undistro/pkg/undistro"
+	supervisoridpv1aplha1 "go.pinniped.dev/generated/latest/apis/supervisor/idp/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileIdentityProviderSwitch(t *testing.T) {
+	secrets := []client.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "google", Namespace: "default"},
+			Data:       map[string][]byte{"clientID": []byte("id"), "clientSecret": []byte("secret")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "ldap", Namespace: "default"},
+			Data:       map[string][]byte{corev1.BasicAuthUsernameKey: []byte("cn=admin"), corev1.BasicAuthPasswordKey: []byte("admin")},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secrets...).Build()
+	r := &IdentityReconciler{Client: c, Scheme: scheme.Scheme}
+
+	oidc := appv1alpha1.IdentitySpec{
+		OIDC: &appv1alpha1.OIDCProviderConfig{Name: appv1alpha1.Google, ClientSecretName: "google"},
+	}
+	ldap := appv1alpha1.IdentitySpec{
+		LDAPIdentityProvider: &supervisoridpv1aplha1.LDAPIdentityProviderSpec{
+			Host: "ldap.example.com:636",
+			Bind: supervisoridpv1aplha1.LDAPIdentityProviderBind{SecretName: "ldap"},
+		},
+	}
+	steps := []struct {
+		name       string
+		spec       appv1alpha1.IdentitySpec
+		want       string
+		wantSecret string
+	}{
+		{name: "oidc", spec: oidc, want: "undistro-google-idp", wantSecret: "undistro-google-idp-client"},
+		{name: "ldap", spec: ldap, want: ldapProviderName, wantSecret: ldapProviderName + "-bind"},
+		{name: "back to oidc", spec: oidc, want: "undistro-google-idp", wantSecret: "undistro-google-idp-client"},
+	}
+	for _, step := range steps {
+		identity := appv1alpha1.Identity{
+			ObjectMeta: metav1.ObjectMeta{Name: "management", Namespace: "default"},
+			Spec:       step.spec,
+		}
+		err := r.reconcileIdentityProvider(context.Background(), identity)
+		if err != nil {
+			t.Fatalf("%s: reconcileIdentityProvider() error = %v", step.name, err)
+		}
+		names := identityProviderNames(t, c)
+		if len(names) != 1 || names[0] != step.want {
+			t.Errorf("%s: identity providers = %v, want [%s]", step.name, names, step.want)
+		}
+		secretList := corev1.SecretList{}
+		err = c.List(context.Background(), &secretList, client.InNamespace(undistro.Namespace))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(secretList.Items) != 1 || secretList.Items[0].Name != step.wantSecret {
+			t.Errorf("%s: secrets = %v, want [%s]", step.name, secretList.Items, step.wantSecret)
+		}
+	}
+}
+
+func identityProviderNames(t *testing.T, c client.Client) []string {
+	oidcList := supervisoridpv1aplha1.OIDCIdentityProviderList{}
+	ldapList := supervisoridpv1aplha1.LDAPIdentityProviderList{}
+	adList := supervisoridpv1aplha1.ActiveDirectoryIdentityProviderList{}
+	for _, l := range []client.ObjectList{&oidcList, &ldapList, &adList} {
+		err := c.List(context.Background(), l, client.InNamespace(undistro.Namespace))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := make([]string, 0)
+	for _, p := range oidcList.Items {
+		names = append(names, p.Name)
+	}
+	for _, p := range ldapList.Items {
+		names = append(names, p.Name)
+	}
+	for _, p := range adList.Items {
+		names = append(names, p.Name)
+	}
+	return names
+}
@@ -18,22 +18,39 @@ package app
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"math"
+	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/getupio-undistro/controllerlib"
 	"github.com/getupio-undistro/meta"
 	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
 	"github.com/getupio-undistro/undistro/pkg/hr"
 	"github.com/getupio-undistro/undistro/pkg/kube"
+	"github.com/getupio-undistro/undistro/pkg/recommender"
 	"github.com/getupio-undistro/undistro/pkg/undistro"
 	"github.com/getupio-undistro/undistro/pkg/util"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -42,22 +59,73 @@ import (
 )
 
 const (
-	observerRequeueAfter   = time.Minute * 5
-	monitoringNs           = "monitoring"
-	kubeStackVersion       = "18.0.4"
-	kubeStackReleaseName   = "kube-prometheus-stack"
-	eckOperatorVersion     = "1.8.0"
-	eckOperatorReleaseName = "eck-operator"
-	fluentBitVersion       = "0.18.0"
-	fluentBitReleaseName   = "fluent-bit"
-	fluentdVersion         = "0.2.12"
-	fluentdReleaseName     = "fluentd"
+	observerRequeueAfter                 = time.Minute * 5
+	observerWaitRequeueAfter             = time.Minute
+	monitoringNs                         = "monitoring"
+	kubeStackVersion                     = "18.0.4"
+	kubeStackReleaseName                 = "kube-prometheus-stack"
+	victoriaMetricsVersion               = "0.5.9"
+	victoriaMetricsReleaseName           = "victoria-metrics-k8s-stack"
+	eckOperatorVersion                   = "1.8.0"
+	eckOperatorReleaseName               = "eck-operator"
+	lokiVersion                          = "2.8.1"
+	lokiReleaseName                      = "loki"
+	fluentBitVersion                     = "0.18.0"
+	fluentBitReleaseName                 = "fluent-bit"
+	fluentdVersion                       = "0.2.12"
+	fluentdReleaseName                   = "fluentd"
+	externalLogsSecretName               = "undistro-logs-credentials"
+	elasticsearchAPIVersion              = "elasticsearch.k8s.elastic.co/v1"
+	elasticsearchName                    = "elasticsearch"
+	elasticsearchVersion                 = "7.15.1"
+	elasticsearchDesiredHealth           = "green"
+	elasticsearchVolumeClaimName         = "elasticsearch-data"
+	elasticsearchLifecyclePolicy         = "undistro-logs"
+	elasticsearchLogIndices              = "logstash-*,dapr-*"
+	labelIndexLifecycle                  = "undistro.io/index-lifecycle"
+	indexLifecycleRetryAfter             = time.Minute * 10
+	curlImage                            = "curlimages/curl:7.80.0"
+	kibanaAPIVersion                     = "kibana.k8s.elastic.co/v1"
+	kibanaName                           = "kibana"
+	kubeStackPrometheusService           = "kube-prometheus-stack-prometheus"
+	kubeStackPrometheusPort        int32 = 9090
+	victoriaMetricsService               = "vmsingle-victoria-metrics-k8s-stack"
+	victoriaMetricsPort            int32 = 8429
+	federationCredentialsLabel           = "undistro.io/metrics-federation"
+	federationSecretName                 = "undistro-metrics-federation"
+	federationAuthSecretName             = "undistro-metrics-federation-auth"
+	federationCertSecretName             = "undistro-metrics-federation-cert"
+	federationIngressName                = "undistro-metrics-federation"
+	federationPath                       = "/metrics-federation"
+	federationWritePath                  = "/api/v1/write"
+	ingressCertSecretName                = "undistro-ingress-cert"
+	undistroMetricsMonitorName           = "undistro-controller-manager-metrics-monitor"
+	undistroMetricsMonitorSelector       = "controller-manager"
+)
+
+var (
+	metricsReleases = map[appv1alpha1.MetricsBackend][]string{
+		appv1alpha1.PrometheusBackend:      {kubeStackReleaseName},
+		appv1alpha1.VictoriaMetricsBackend: {victoriaMetricsReleaseName},
+	}
+	logsReleases = map[appv1alpha1.LogsBackend][]string{
+		appv1alpha1.ElasticsearchBackend: {eckOperatorReleaseName, fluentBitReleaseName, fluentdReleaseName},
+		appv1alpha1.LokiBackend:          {lokiReleaseName, fluentBitReleaseName},
+		appv1alpha1.ExternalLogsBackend:  {fluentBitReleaseName},
+	}
+	allMetricsReleases = []string{kubeStackReleaseName, victoriaMetricsReleaseName}
+	allLogsReleases    = []string{eckOperatorReleaseName, lokiReleaseName, fluentBitReleaseName, fluentdReleaseName}
 )
 
 // ObserverReconciler reconciles a Observer object
 type ObserverReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// recommendedAt is when the recommendations of each cluster were last computed,
+	// they query the cluster metrics, so they are computed once per observerRequeueAfter.
+	recommendedAt   map[client.ObjectKey]time.Time
+	recommendedAtMu sync.Mutex
 }
 
 //+kubebuilder:rbac:groups=app.undistro.io,resources=observers,verbs=get;list;watch;create;update;patch;delete
@@ -130,7 +198,8 @@ func (r *ObserverReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return r.reconcileDelete(ctx, instance)
 	}
 
-	result, err := r.reconcile(ctx, *instance)
+	var result ctrl.Result
+	*instance, result, err = r.reconcile(ctx, *instance)
 
 	durationMsg := fmt.Sprintf("Reconcilation finished in %s", time.Since(start).String())
 	if result.RequeueAfter > 0 {
@@ -140,447 +209,1677 @@ func (r *ObserverReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	return result, err
 }
 
-func (r *ObserverReconciler) reconcile(ctx context.Context, observer appv1alpha1.Observer) (ctrl.Result, error) {
-	res, err := r.reconcileMetrics(ctx, observer)
-	if err != nil {
-		return ctrl.Result{}, err
-	}
-	res, err = r.reconcileLog(ctx, observer)
+// reconcile installs the metrics and logs stacks independently, so a failure in one of them
+// is reported in its condition without blocking the other. Stacks that aren't ready yet are
+// checked again in the next run.
+func (r *ObserverReconciler) reconcile(ctx context.Context, observer appv1alpha1.Observer) (appv1alpha1.Observer, ctrl.Result, error) {
+	cl, err := r.getCluster(ctx, observer)
 	if err != nil {
-		return ctrl.Result{}, err
+		return appv1alpha1.ObserverNotReady(observer, meta.GetClusterFailed, err.Error()), ctrl.Result{}, err
 	}
-	res.RequeueAfter = observerRequeueAfter
-	return res, err
-}
-
-func (r *ObserverReconciler) reconcileLog(ctx context.Context, observer appv1alpha1.Observer) (ctrl.Result, error) {
-	log, err := logr.FromContext(ctx)
-	if err != nil {
-		log = ctrl.Log
+	observer, metricsWaiting, metricsErr := r.reconcileMetrics(ctx, observer, cl)
+	observer = componentCondition(observer, appv1alpha1.MetricsReadyCondition, observer.Spec.Metrics.IsEnabled(), metricsWaiting, metricsErr)
+	r.reconcileRecommendation(ctx, observer)
+	observer, logsWaiting, logsErr := r.reconcileLog(ctx, observer, cl)
+	observer = componentCondition(observer, appv1alpha1.LogsReadyCondition, observer.Spec.Logs.IsEnabled(), logsWaiting, logsErr)
+	if err = kerrors.NewAggregate([]error{metricsErr, logsErr}); err != nil {
+		return appv1alpha1.ObserverNotReady(observer, meta.InstallFailedReason, err.Error()), ctrl.Result{}, err
 	}
-
-	log.Info("Reconciling log stack")
-	res, err := r.reconcileElasticStack(ctx, observer)
-	if err != nil {
-		log.Info("error reconciling elastic eck operator", "error", err.Error())
-		return res, err
+	if metricsWaiting != "" || logsWaiting != "" {
+		msg := "Waiting observability stacks to be ready"
+		return appv1alpha1.ObserverNotReady(observer, meta.WaitProvisionReason, msg), ctrl.Result{RequeueAfter: observerWaitRequeueAfter}, nil
 	}
-	return r.reconcileFluentStack(ctx, observer)
+	return appv1alpha1.ObserverReady(observer), ctrl.Result{RequeueAfter: observerRequeueAfter}, nil
 }
 
-func (r *ObserverReconciler) reconcileFluentStack(ctx context.Context, observer appv1alpha1.Observer) (ctrl.Result, error) {
-	log, err := logr.FromContext(ctx)
-	if err != nil {
-		log = ctrl.Log
+// componentCondition sets the condition of a stack. waiting is the component the stack is
+// waiting for, empty when the stack is ready.
+func componentCondition(o appv1alpha1.Observer, conditionType string, enabled bool, waiting string, err error) appv1alpha1.Observer {
+	if enabled && err == nil && waiting != "" {
+		return appv1alpha1.ObserverComponentProgressing(o, conditionType, fmt.Sprintf("Waiting %s to be ready", waiting))
 	}
+	return appv1alpha1.ObserverComponentReady(o, conditionType, enabled, err)
+}
 
-	log.Info("Reconciling fluent stack")
-
-	values := map[string]interface{}{}
+// getCluster returns the observed cluster. The management cluster isn't a Cluster object,
+// so a Cluster named management in the UnDistro namespace represents it.
+func (r *ObserverReconciler) getCluster(ctx context.Context, observer appv1alpha1.Observer) (*appv1alpha1.Cluster, error) {
 	cl := &appv1alpha1.Cluster{}
 	if util.IsMgmtCluster(observer.Spec.ClusterName) {
 		cl.Name = "management"
 		cl.Namespace = undistro.Namespace
-	} else {
-		key := client.ObjectKey{
-			Name:      observer.Spec.ClusterName,
-			Namespace: observer.GetNamespace(),
-		}
-		err := r.Get(ctx, key, cl)
-		if client.IgnoreNotFound(err) != nil {
-			return ctrl.Result{}, err
-		}
-		if cl.HasInfraNodes() {
-			values = map[string]interface{}{
-				"nodeSelector": map[string]interface{}{
-					meta.LabelUndistroInfra: "true",
-				},
-				"tolerations": []map[string]interface{}{
-					{
-						"effect": "NoSchedule",
-						"key":    "dedicated",
-						"value":  "infra",
-					},
-				},
-			}
-		}
-	}
-
-	if err := r.installRelease(ctx, fluentBitReleaseName, fluentBitVersion, values, &observer, cl); err != nil {
-		return ctrl.Result{}, err
+		return cl, nil
 	}
-	if err := r.installRelease(ctx, fluentdReleaseName, fluentdVersion, values, &observer, cl); err != nil {
-		return ctrl.Result{}, err
+	key := client.ObjectKey{
+		Name:      observer.Spec.ClusterName,
+		Namespace: observer.GetNamespace(),
 	}
-	return ctrl.Result{}, nil
+	err := r.Get(ctx, key, cl)
+	return cl, err
 }
 
-func (r *ObserverReconciler) reconcileElasticStack(ctx context.Context, observer appv1alpha1.Observer) (ctrl.Result, error) {
-	log, err := logr.FromContext(ctx)
-	if err != nil {
-		log = ctrl.Log
+func (r *ObserverReconciler) clusterClient(ctx context.Context, observer appv1alpha1.Observer) (client.Client, error) {
+	if util.IsMgmtCluster(observer.Spec.ClusterName) {
+		return r.Client, nil
 	}
+	return kube.NewClusterClient(ctx, r.Client, observer.Spec.ClusterName, observer.GetNamespace())
+}
 
-	log.Info("Reconciling elastic stack")
-	values := map[string]interface{}{}
-	cl := &appv1alpha1.Cluster{}
-	if util.IsMgmtCluster(observer.Spec.ClusterName) {
-		cl.Name = "management"
-		cl.Namespace = undistro.Namespace
-	} else {
-		key := client.ObjectKey{
-			Name:      observer.Spec.ClusterName,
-			Namespace: observer.GetNamespace(),
-		}
-		err := r.Get(ctx, key, cl)
-		if client.IgnoreNotFound(err) != nil {
-			return ctrl.Result{Requeue: true}, err
-		}
-		if cl.HasInfraNodes() {
-			values = map[string]interface{}{
-				"nodeSelector": map[string]interface{}{
-					meta.LabelUndistroInfra: "true",
-				},
-				"tolerations": []map[string]interface{}{
-					{
-						"effect": "NoSchedule",
-						"key":    "dedicated",
-						"value":  "infra",
-					},
-				},
-			}
-		}
+// infraScheduling returns the values scheduling the pods of a chart in the infra nodes.
+func infraScheduling() map[string]interface{} {
+	return map[string]interface{}{
+		"nodeSelector": map[string]interface{}{
+			meta.LabelUndistroInfra: "true",
+		},
+		"tolerations": []map[string]interface{}{
+			{
+				"effect": "NoSchedule",
+				"key":    "dedicated",
+				"value":  "infra",
+			},
+		},
 	}
-	if err := r.installRelease(ctx, eckOperatorReleaseName, eckOperatorVersion, values, &observer, cl); err != nil {
-		return ctrl.Result{}, err
+}
+
+// retentionDays rounds the retention up to days, the unit accepted by all backends.
+func retentionDays(d *metav1.Duration) int {
+	days := int(math.Ceil(d.Hours() / 24))
+	if days < 1 {
+		return 1
 	}
-	return r.createElasticsearchCluster(ctx, &observer, cl)
+	return days
 }
 
-func (r *ObserverReconciler) createElasticsearchCluster(ctx context.Context, obs *appv1alpha1.Observer, cl *appv1alpha1.Cluster) (ctrl.Result, error) {
+// reconcileMetrics installs the metrics backend and returns the component it is waiting for.
+// With federation enabled the management cluster exposes its backend as the central store
+// and workload clusters remote-write their metrics to it.
+func (r *ObserverReconciler) reconcileMetrics(
+	ctx context.Context, observer appv1alpha1.Observer, cl *appv1alpha1.Cluster) (appv1alpha1.Observer, string, error) {
 	log, err := logr.FromContext(ctx)
 	if err != nil {
 		log = ctrl.Log
 	}
 
-	replicaCount := 0
-	if cl.HasInfraNodes() {
-		for _, w := range cl.Spec.Workers {
-			if w.InfraNode {
-				replicaCount = int(*w.Replicas)
-				log.Info("Replicas incremented", "replicaCount", replicaCount)
-				break
-			}
-		}
-	} else {
-		replicaCount = int(cl.Status.TotalWorkerReplicas)
-	}
-	elasticsearch := fmt.Sprintf(`
---- 
-apiVersion: elasticsearch.k8s.elastic.co/v1
-kind: Elasticsearch
-metadata: 
-  name: elasticsearch
-  namespace: monitoring
-spec: 
-  nodeSets: 
-    - 
-      config: 
-        node.attr.attr_name: attr_value
-        node.roles: 
-          - master
-          - data
-        node.store.allow_mmap: false
-      count: 1
-      name: master
-      podTemplate: 
-        spec: 
-          containers: 
-            - 
-              name: elasticsearch
-    - 
-      config: 
-        node.data: true
-      count: %d
-      name: worker
-  version: "7.15.1"
-`, replicaCount)
-	objs, err := util.ToUnstructured([]byte(elasticsearch))
+	metrics := observer.Spec.Metrics
+	var desired []string
+	if metrics.IsEnabled() {
+		desired = metricsReleases[metrics.GetBackend()]
+	}
+	err = r.pruneReleases(ctx, observer, allMetricsReleases, desired)
 	if err != nil {
-		return ctrl.Result{}, err
+		return observer, "", err
 	}
-	u := unstructured.Unstructured{}
-	k := client.ObjectKey{}
-	clusterClient := r.Client
-	if !util.IsMgmtCluster(obs.Spec.ClusterName) {
-		clusterClient, err = kube.NewClusterClient(ctx, r.Client, obs.Spec.ClusterName, obs.GetNamespace())
+	mgmt := util.IsMgmtCluster(observer.Spec.ClusterName)
+	federated := metrics.IsEnabled() && metrics.Federation.Enabled
+	if !federated && observer.Status.FederationEndpoint != "" {
+		err = r.deleteFederationObjects(ctx, observer)
 		if err != nil {
-			return ctrl.Result{}, err
+			return observer, "", err
 		}
+		observer.Status.FederationEndpoint = ""
 	}
-	for _, o := range objs {
-		_, err = util.CreateOrUpdate(ctx, clusterClient, &o)
+	if !metrics.IsEnabled() {
+		log.Info("Metrics stack is disabled")
+		return observer, "", nil
+	}
+
+	log.Info("Reconciling metrics stack", "backend", metrics.GetBackend())
+	var release, version string
+	var values map[string]interface{}
+	switch metrics.GetBackend() {
+	case appv1alpha1.PrometheusBackend:
+		release, version = kubeStackReleaseName, kubeStackVersion
+		values = prometheusValues(metrics, cl)
+	case appv1alpha1.VictoriaMetricsBackend:
+		release, version = victoriaMetricsReleaseName, victoriaMetricsVersion
+		values = victoriaMetricsValues(metrics, cl)
+	default:
+		return observer, "", errors.Errorf("unsupported metrics backend %q", metrics.GetBackend())
+	}
+	waitingFederation := false
+	switch {
+	case federated && mgmt && metrics.GetBackend() == appv1alpha1.PrometheusBackend:
+		values = util.MergeMaps(values, map[string]interface{}{
+			"prometheus": map[string]interface{}{
+				"prometheusSpec": map[string]interface{}{
+					"enableFeatures": []string{"remote-write-receiver"},
+				},
+			},
+		})
+	case federated && !mgmt:
+		var endpoint string
+		endpoint, err = r.federationEndpoint(ctx)
 		if err != nil {
-			return ctrl.Result{}, err
+			return observer, "", err
+		}
+		if endpoint == "" {
+			// the management cluster turned federation off, so the copied credentials are stale
+			if observer.Status.FederationEndpoint != "" {
+				err = r.deleteFederationObjects(ctx, observer)
+				if err != nil {
+					return observer, "", err
+				}
+				observer.Status.FederationEndpoint = ""
+			}
+			// the metrics are kept in the cluster until the central store is exposed
+			log.Info("Waiting management cluster metrics federation")
+			waitingFederation = true
+			break
+		}
+		observer.Status.FederationEndpoint = endpoint
+		hasCA, err := r.reconcileFederationCredentials(ctx, observer)
+		if err != nil {
+			return observer, "", err
 		}
-		u.SetGroupVersionKind(o.GroupVersionKind())
-		k.Namespace = o.GetNamespace()
-		k.Name = o.GetName()
+		values = util.MergeMaps(values, remoteWriteValues(metrics.GetBackend(), observer.Status.FederationEndpoint, hasCA, cl))
 	}
-	err = clusterClient.Get(ctx, k, &u)
+	ready, err := r.installRelease(ctx, release, version, values, &observer, cl)
 	if err != nil {
-		return ctrl.Result{}, err
+		return observer, "", err
+	}
+	if !ready {
+		return observer, release, nil
+	}
+	if waitingFederation {
+		return observer, "management cluster metrics federation", nil
 	}
-	health, ok, err := unstructured.NestedString(u.Object, "status", "health")
+	if !mgmt {
+		return observer, "", nil
+	}
+	// after the monitoring crds install
+	err = r.enableUnDistroMetrics(ctx, metrics.GetBackend())
+	if err != nil || !federated {
+		return observer, "", err
+	}
+	observer.Status.FederationEndpoint, err = r.exposeCentralStore(ctx, metrics)
 	if err != nil {
-		return ctrl.Result{}, err
+		return observer, "", err
 	}
-	if !ok {
-		return ctrl.Result{}, errors.New("health field not present")
+	if observer.Status.FederationEndpoint == "" {
+		return observer, "metrics federation ingress", nil
 	}
-	desiredHealth := "green"
-	// maybe this will need refactoring
-	for strings.ToLower(health) != desiredHealth {
-		log.Info("Waiting elasticsearch", "health", health, "desiredHealth", desiredHealth)
-		err = clusterClient.Get(ctx, k, &u)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
-		health, ok, err = unstructured.NestedString(u.Object, "status", "health")
-		if err != nil {
-			return ctrl.Result{}, err
+	return observer, "", nil
+}
+
+func prometheusValues(metrics appv1alpha1.MetricsSpec, cl *appv1alpha1.Cluster) map[string]interface{} {
+	prometheusSpec := map[string]interface{}{}
+	if metrics.Retention != nil {
+		prometheusSpec["retention"] = fmt.Sprintf("%dd", retentionDays(metrics.Retention))
+	}
+	if metrics.StorageSize != nil {
+		prometheusSpec["storageSpec"] = map[string]interface{}{
+			"volumeClaimTemplate": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"accessModes": []string{"ReadWriteOnce"},
+					"resources": map[string]interface{}{
+						"requests": map[string]interface{}{
+							"storage": metrics.StorageSize.String(),
+						},
+					},
+				},
+			},
 		}
-		if !ok {
-			return ctrl.Result{}, errors.New("health field not present")
+	}
+	values := map[string]interface{}{
+		"namespaceOverride": monitoringNs,
+		"grafana": map[string]interface{}{
+			"enabled": false,
+		},
+		"prometheus": map[string]interface{}{
+			"prometheusSpec": prometheusSpec,
+		},
+	}
+	if cl.HasInfraNodes() {
+		infraValues := map[string]interface{}{
+			"prometheusOperator": util.MergeMaps(map[string]interface{}{
+				"admissionWebhooks": map[string]interface{}{
+					"patch": infraScheduling(),
+				},
+				"prometheusSpec": infraScheduling(),
+			}, infraScheduling()),
+			"alertmanager": map[string]interface{}{
+				"alertmanagerSpec": infraScheduling(),
+			},
+			"kube-state-metrics": infraScheduling(),
+			"prometheus": map[string]interface{}{
+				"prometheusSpec": util.MergeMaps(prometheusSpec, infraScheduling()),
+			},
 		}
-		<-time.After(time.Minute * 1)
+		values = util.MergeMaps(values, infraValues)
 	}
-	return ctrl.Result{}, nil
+	return values
 }
 
-func (r *ObserverReconciler) reconcileMetrics(ctx context.Context, observer appv1alpha1.Observer) (ctrl.Result, error) {
-	log, err := logr.FromContext(ctx)
-	if err != nil {
-		log = ctrl.Log
+func victoriaMetricsValues(metrics appv1alpha1.MetricsSpec, cl *appv1alpha1.Cluster) map[string]interface{} {
+	vmsingleSpec := map[string]interface{}{}
+	if metrics.Retention != nil {
+		vmsingleSpec["retentionPeriod"] = fmt.Sprintf("%dd", retentionDays(metrics.Retention))
+	}
+	if metrics.StorageSize != nil {
+		vmsingleSpec["storage"] = map[string]interface{}{
+			"accessModes": []string{"ReadWriteOnce"},
+			"resources": map[string]interface{}{
+				"requests": map[string]interface{}{
+					"storage": metrics.StorageSize.String(),
+				},
+			},
+		}
 	}
-
-	log.Info("Reconciling metrics stack")
 	values := map[string]interface{}{
-		"namespaceOverride": monitoringNs,
 		"grafana": map[string]interface{}{
 			"enabled": false,
 		},
+		"vmsingle": map[string]interface{}{
+			"spec": vmsingleSpec,
+		},
 	}
-	cl := &appv1alpha1.Cluster{}
-	if util.IsMgmtCluster(observer.Spec.ClusterName) {
-		cl.Name = "management"
-		cl.Namespace = undistro.Namespace
-		log.Info("Cluster is a management cluster", "name", cl.Name, "namespace", undistro.Namespace)
-	} else {
-		log.Info("Cluster is a managed cluster", "name", cl.Name, "namespace", undistro.Namespace)
-		key := client.ObjectKey{
-			Name:      observer.Spec.ClusterName,
-			Namespace: observer.GetNamespace(),
-		}
-		err := r.Get(ctx, key, cl)
-		if client.IgnoreNotFound(err) != nil {
-			return ctrl.Result{}, err
+	if cl.HasInfraNodes() {
+		infraValues := map[string]interface{}{
+			"victoria-metrics-operator": infraScheduling(),
+			"kube-state-metrics":        infraScheduling(),
+			"alertmanager": map[string]interface{}{
+				"spec": infraScheduling(),
+			},
+			"vmagent": map[string]interface{}{
+				"spec": infraScheduling(),
+			},
+			"vmalert": map[string]interface{}{
+				"spec": infraScheduling(),
+			},
+			"vmsingle": map[string]interface{}{
+				"spec": util.MergeMaps(vmsingleSpec, infraScheduling()),
+			},
 		}
-		if cl.HasInfraNodes() {
-			infraValues := map[string]interface{}{
-				"prometheusOperator": map[string]interface{}{
-					"admissionWebhooks": map[string]interface{}{
-						"patch": map[string]interface{}{
-							"nodeSelector": map[string]interface{}{
-								meta.LabelUndistroInfra: "true",
-							},
-							"tolerations": []map[string]interface{}{
-								{
-									"effect": "NoSchedule",
-									"key":    "dedicated",
-									"value":  "infra",
-								},
-							},
-						},
-					},
-					"prometheusSpec": map[string]interface{}{
-						"nodeSelector": map[string]interface{}{
-							meta.LabelUndistroInfra: "true",
-						},
-						"tolerations": []map[string]interface{}{
-							{
-								"effect": "NoSchedule",
-								"key":    "dedicated",
-								"value":  "infra",
-							},
-						},
-					},
-					"nodeSelector": map[string]interface{}{
-						meta.LabelUndistroInfra: "true",
-					},
-					"tolerations": []map[string]interface{}{
-						{
-							"effect": "NoSchedule",
-							"key":    "dedicated",
-							"value":  "infra",
-						},
-					},
-				},
-				"alertmanager": map[string]interface{}{
-					"alertmanagerSpec": map[string]interface{}{
-						"nodeSelector": map[string]interface{}{
-							meta.LabelUndistroInfra: "true",
-						},
-						"tolerations": []map[string]interface{}{
-							{
-								"effect": "NoSchedule",
-								"key":    "dedicated",
-								"value":  "infra",
-							},
-						},
-					},
-				},
-				"kube-state-metrics": map[string]interface{}{
-					"nodeSelector": map[string]interface{}{
-						meta.LabelUndistroInfra: "true",
-					},
-					"tolerations": []map[string]interface{}{
-						{
-							"effect": "NoSchedule",
-							"key":    "dedicated",
-							"value":  "infra",
-						},
-					},
-				},
-				"prometheus": map[string]interface{}{
-					"prometheusSpec": map[string]interface{}{
-						"nodeSelector": map[string]interface{}{
-							meta.LabelUndistroInfra: "true",
-						},
-						"tolerations": []map[string]interface{}{
-							{
-								"effect": "NoSchedule",
-								"key":    "dedicated",
-								"value":  "infra",
-							},
-						},
-					},
-				},
-			}
-			values = util.MergeMaps(values, infraValues)
+		values = util.MergeMaps(values, infraValues)
+	}
+	return values
+}
+
+// federationLabels returns the external labels identifying the cluster in the central store.
+func federationLabels(cl *appv1alpha1.Cluster) map[string]interface{} {
+	labels := map[string]interface{}{
+		"cluster":           cl.Name,
+		"cluster_namespace": cl.GetNamespace(),
+	}
+	if cl.Spec.InfrastructureProvider.Name != "" {
+		labels["provider"] = cl.Spec.InfrastructureProvider.Name
+	}
+	if cl.Spec.InfrastructureProvider.Flavor != "" {
+		labels["flavor"] = cl.Spec.InfrastructureProvider.Flavor
+	}
+	return labels
+}
+
+// remoteWriteValues returns the values remote-writing the metrics to the central store
+// with the credentials copied by reconcileFederationCredentials.
+func remoteWriteValues(backend appv1alpha1.MetricsBackend, endpoint string, hasCA bool, cl *appv1alpha1.Cluster) map[string]interface{} {
+	secretKey := func(key string) map[string]interface{} {
+		return map[string]interface{}{
+			"name": federationSecretName,
+			"key":  key,
 		}
 	}
-	if err := r.installRelease(ctx, kubeStackReleaseName, kubeStackVersion, values, &observer, cl); err != nil {
-		return ctrl.Result{}, err
+	remoteWrite := map[string]interface{}{
+		"url": endpoint,
+		"basicAuth": map[string]interface{}{
+			"username": secretKey("username"),
+			"password": secretKey("password"),
+		},
 	}
-	// after the kube-prometheus-stack crds install
-	if util.IsMgmtCluster(observer.Spec.ClusterName) {
-		err := r.enableUnDistroMetrics(ctx)
-		if err != nil {
-			return ctrl.Result{}, err
+	if hasCA {
+		remoteWrite["tlsConfig"] = map[string]interface{}{
+			"ca": map[string]interface{}{
+				"secret": secretKey(corev1.ServiceAccountRootCAKey),
+			},
 		}
 	}
-	return ctrl.Result{}, nil
+	if backend == appv1alpha1.VictoriaMetricsBackend {
+		return map[string]interface{}{
+			"vmagent": map[string]interface{}{
+				"additionalRemoteWrites": []interface{}{remoteWrite},
+				"spec": map[string]interface{}{
+					"externalLabels": federationLabels(cl),
+				},
+			},
+		}
+	}
+	return map[string]interface{}{
+		"prometheus": map[string]interface{}{
+			"prometheusSpec": map[string]interface{}{
+				"externalLabels": federationLabels(cl),
+				"remoteWrite":    []interface{}{remoteWrite},
+			},
+		},
+	}
 }
 
-func (r *ObserverReconciler) installRelease(
-	ctx context.Context, name, version string, values map[string]interface{}, observer *appv1alpha1.Observer, cl *appv1alpha1.Cluster) (err error) {
-	log, err := logr.FromContext(ctx)
+// federationEndpoint returns the central store endpoint exposed by the management cluster Observer.
+func (r *ObserverReconciler) federationEndpoint(ctx context.Context) (string, error) {
+	observers := appv1alpha1.ObserverList{}
+	err := r.List(ctx, &observers)
 	if err != nil {
-		log = ctrl.Log
+		return "", err
+	}
+	for _, o := range observers.Items {
+		if util.IsMgmtCluster(o.Spec.ClusterName) && o.Spec.Metrics.Federation.Enabled && o.Status.FederationEndpoint != "" {
+			return o.Status.FederationEndpoint, nil
+		}
 	}
+	return "", nil
+}
 
-	log.Info("Installing release", "releaseName", name)
-	key := client.ObjectKey{
-		Name:      hr.GetObjectName(name, observer.Spec.ClusterName),
+// federationCredentialsKey returns the key of the central store credentials of the workload cluster
+// in the management cluster.
+func federationCredentialsKey(observer appv1alpha1.Observer) client.ObjectKey {
+	return client.ObjectKey{
+		Name:      fmt.Sprintf("%s-%s", observer.Spec.ClusterName, federationSecretName),
 		Namespace: observer.GetNamespace(),
 	}
-	release := appv1alpha1.HelmRelease{}
-	err = r.Get(ctx, key, &release)
-	if err != nil {
-		if client.IgnoreNotFound(err) != nil {
-			return err
-		}
+}
+
+// federationCredentials returns the central store credentials of the workload cluster, creating
+// them the first time. Each cluster has its own credentials, owned by its Observer, so a cluster
+// is revoked by deleting its credentials without rotating the other ones.
+func (r *ObserverReconciler) federationCredentials(ctx context.Context, observer appv1alpha1.Observer) (corev1.Secret, error) {
+	creds := corev1.Secret{}
+	err := r.Get(ctx, federationCredentialsKey(observer), &creds)
+	if !apierrors.IsNotFound(err) {
+		return creds, err
 	}
-	release, err = hr.Prepare(name, monitoringNs, cl.GetNamespace(), version, observer.Spec.ClusterName, values)
+	username := fmt.Sprintf("%s/%s", observer.GetNamespace(), observer.Spec.ClusterName)
+	password := util.RandomString(32)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return err
+		return creds, err
 	}
-	if release.Labels == nil {
-		release.Labels = make(map[string]string)
+	key := federationCredentialsKey(observer)
+	creds = corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+			Labels: map[string]string{
+				meta.LabelUndistro:         "",
+				federationCredentialsLabel: "",
+			},
+		},
+		Data: map[string][]byte{
+			"username": []byte(username),
+			"password": []byte(password),
+			// htpasswd line read by the ingress
+			"auth": []byte(fmt.Sprintf("%s:%s\n", username, hash)),
+		},
 	}
-	release.Labels[meta.LabelUndistroMove] = ""
-	if err := hr.Install(ctx, r.Client, log, release, cl); err != nil {
-		return err
+	err = ctrl.SetControllerReference(&observer, &creds, r.Scheme)
+	if err != nil {
+		return creds, err
 	}
-	for !meta.InReadyCondition(release.Status.Conditions) {
-		err = r.Get(ctx, key, &release)
-		if err != nil {
-			return err
-		}
-		if meta.InReadyCondition(release.Status.Conditions) {
-			continue
-		} else {
-			log.Info("Waiting release is ready", "release", release.Name, "namespace", release.Namespace)
-			<-time.After(1 * time.Minute)
-		}
-		log.Info("HelmRelease status", "lastAppliedVersion", release.Status.LastAppliedRevision)
+	err = r.Create(ctx, &creds)
+	if err != nil {
+		return creds, err
 	}
-	return
+	return creds, r.updateFederationAuth(ctx)
 }
 
-func (r *ObserverReconciler) enableUnDistroMetrics(ctx context.Context) error {
-	undistroServiceMonitor := `
----
-apiVersion: monitoring.coreos.com/v1
-kind: ServiceMonitor
-metadata:
-  labels:
-    control-plane: controller-manager
-    undistro.io: undistro
-  name: undistro-controller-manager-metrics-monitor
-  namespace: undistro-system
-spec:
-  endpoints:
-  - bearerTokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token
-    path: /metrics
-    port: https
-    scheme: https
-    tlsConfig:
-      insecureSkipVerify: true
-  selector:
-    matchLabels:
-      control-plane: controller-manager
-`
-	objs, err := util.ToUnstructured([]byte(undistroServiceMonitor))
+// federationAuth returns the data of the central store ingress auth secret, an htpasswd file
+// with the credentials of every workload cluster.
+func (r *ObserverReconciler) federationAuth(ctx context.Context) (map[string][]byte, error) {
+	secrets := corev1.SecretList{}
+	err := r.List(ctx, &secrets, client.HasLabels{federationCredentialsLabel})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, o := range objs {
-		_, err = util.CreateOrUpdate(ctx, r.Client, &o)
-		if err != nil {
-			return err
-		}
+	sort.Slice(secrets.Items, func(i, j int) bool {
+		return client.ObjectKeyFromObject(&secrets.Items[i]).String() < client.ObjectKeyFromObject(&secrets.Items[j]).String()
+	})
+	auth := make([]byte, 0)
+	for _, s := range secrets.Items {
+		auth = append(auth, s.Data["auth"]...)
 	}
-	return nil
+	return map[string][]byte{"auth": auth}, nil
 }
 
-func (r *ObserverReconciler) reconcileDelete(ctx context.Context, instance *appv1alpha1.Observer) (res ctrl.Result, err error) {
-	log, err := logr.FromContext(ctx)
+// updateFederationAuth updates the htpasswd file of the central store ingress after the
+// credentials of a workload cluster are created or deleted. It is a no-op until the management
+// cluster exposes the central store.
+func (r *ObserverReconciler) updateFederationAuth(ctx context.Context) error {
+	auth := corev1.Secret{}
+	key := client.ObjectKey{
+		Name:      federationAuthSecretName,
+		Namespace: monitoringNs,
+	}
+	err := r.Get(ctx, key, &auth)
 	if err != nil {
-		log = ctrl.Log
+		return client.IgnoreNotFound(err)
+	}
+	auth.Data, err = r.federationAuth(ctx)
+	if err != nil {
+		return err
 	}
+	return r.Update(ctx, &auth)
+}
 
-	log.Info("Reconciling delete")
-	releases := []string{kubeStackReleaseName, eckOperatorReleaseName, fluentBitVersion, fluentdReleaseName}
-	for _, release := range releases {
+// reconcileFederationCredentials copies the credentials of the workload cluster, and the certificate
+// authority of the management cluster ingress in local clusters, to its monitoring namespace.
+func (r *ObserverReconciler) reconcileFederationCredentials(ctx context.Context, observer appv1alpha1.Observer) (bool, error) {
+	creds, err := r.federationCredentials(ctx, observer)
+	if err != nil {
+		return false, err
+	}
+	data := map[string][]byte{
+		"username": creds.Data["username"],
+		"password": creds.Data["password"],
+	}
+	cert := corev1.Secret{}
+	key := client.ObjectKey{
+		Name:      ingressCertSecretName,
+		Namespace: undistro.Namespace,
+	}
+	err = r.Get(ctx, key, &cert)
+	if client.IgnoreNotFound(err) != nil {
+		return false, err
+	}
+	ca := cert.Data[corev1.ServiceAccountRootCAKey]
+	if len(ca) > 0 {
+		data[corev1.ServiceAccountRootCAKey] = ca
+	}
+	err = r.copySecret(ctx, observer, federationSecretName, data)
+	return len(ca) > 0, err
+}
+
+// exposeCentralStore exposes only the remote write API of the management cluster metrics backend
+// in the management cluster ingress, with the basic authentication of the workload clusters
+// credentials, and returns its endpoint. The endpoint is empty until the ingress has an address.
+func (r *ObserverReconciler) exposeCentralStore(ctx context.Context, metrics appv1alpha1.MetricsSpec) (string, error) {
+	authData, err := r.federationAuth(ctx)
+	if err != nil {
+		return "", err
+	}
+	auth := corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      federationAuthSecretName,
+			Namespace: monitoringNs,
+			Labels: map[string]string{
+				meta.LabelUndistro: "",
+			},
+		},
+		Data: authData,
+	}
+	_, err = util.CreateOrUpdate(ctx, r.Client, &auth)
+	if err != nil {
+		return "", err
+	}
+	service, port := kubeStackPrometheusService, kubeStackPrometheusPort
+	if metrics.GetBackend() == appv1alpha1.VictoriaMetricsBackend {
+		service, port = victoriaMetricsService, victoriaMetricsPort
+	}
+	pathType := networkingv1.PathTypeImplementationSpecific
+	ingressClass := "nginx"
+	ing := networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: networkingv1.SchemeGroupVersion.String(),
+			Kind:       "Ingress",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      federationIngressName,
+			Namespace: monitoringNs,
+			Labels: map[string]string{
+				meta.LabelUndistro: "",
+			},
+			Annotations: map[string]string{
+				"nginx.ingress.kubernetes.io/use-regex":      "true",
+				"nginx.ingress.kubernetes.io/rewrite-target": federationWritePath,
+				"nginx.ingress.kubernetes.io/auth-type":      "basic",
+				"nginx.ingress.kubernetes.io/auth-secret":    federationAuthSecretName,
+				"nginx.ingress.kubernetes.io/auth-realm":     "UnDistro metrics federation",
+			},
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &ingressClass,
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: metrics.Federation.Host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									// the query API stays private, workload clusters only write
+									Path:     fmt.Sprintf("%s%s$", federationPath, federationWritePath),
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: service,
+											Port: networkingv1.ServiceBackendPort{
+												Number: port,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	// without a host the ingress serves its default certificate, the local UnDistro authority one in local clusters
+	if metrics.Federation.Host != "" {
+		ing.Annotations["cert-manager.io/cluster-issuer"] = "letsencrypt-prod"
+		ing.Spec.TLS = []networkingv1.IngressTLS{
+			{
+				Hosts:      []string{metrics.Federation.Host},
+				SecretName: federationCertSecretName,
+			},
+		}
+	}
+	_, err = util.CreateOrUpdate(ctx, r.Client, &ing)
+	if err != nil {
+		return "", err
+	}
+	err = r.Get(ctx, client.ObjectKeyFromObject(&ing), &ing)
+	if err != nil {
+		return "", err
+	}
+	host := metrics.Federation.Host
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		if host != "" {
+			break
+		}
+		host = lb.Hostname
+		if host == "" {
+			host = lb.IP
+		}
+	}
+	if host == "" {
+		return "", nil
+	}
+	return fmt.Sprintf("https://%s%s%s", host, federationPath, federationWritePath), nil
+}
+
+// deleteFederationObjects deletes the central store ingress in the management cluster, or
+// the central store credentials of workload clusters, revoking them in the ingress.
+func (r *ObserverReconciler) deleteFederationObjects(ctx context.Context, observer appv1alpha1.Observer) error {
+	clusterClient, err := r.clusterClient(ctx, observer)
+	if err != nil {
+		return err
+	}
+	if !util.IsMgmtCluster(observer.Spec.ClusterName) {
+		key := federationCredentialsKey(observer)
+		creds := corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+			},
+		}
+		err = r.Delete(ctx, &creds)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		err = r.updateFederationAuth(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	objs := []client.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      federationSecretName,
+				Namespace: monitoringNs,
+			},
+		},
+	}
+	if util.IsMgmtCluster(observer.Spec.ClusterName) {
+		objs = []client.Object{
+			&networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      federationIngressName,
+					Namespace: monitoringNs,
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      federationAuthSecretName,
+					Namespace: monitoringNs,
+				},
+			},
+		}
+	}
+	for _, o := range objs {
+		err = clusterClient.Delete(ctx, o)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ObserverReconciler) reconcileLog(
+	ctx context.Context, observer appv1alpha1.Observer, cl *appv1alpha1.Cluster) (appv1alpha1.Observer, string, error) {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+
+	logs := observer.Spec.Logs
+	var desired []string
+	if logs.IsEnabled() {
+		desired = logsReleases[logs.GetBackend()]
+	}
+	if !logs.IsEnabled() || logs.GetBackend() != appv1alpha1.ElasticsearchBackend {
+		apimeta.RemoveStatusCondition(&observer.Status.Conditions, appv1alpha1.ElasticsearchReadyCondition)
+		// the elastic objects are deleted before the operator managing them
+		err = r.deleteElasticObjects(ctx, observer)
+		if err != nil {
+			return observer, "", err
+		}
+	}
+	err = r.pruneReleases(ctx, observer, allLogsReleases, desired)
+	if err != nil {
+		return observer, "", err
+	}
+	if !logs.IsEnabled() {
+		log.Info("Logs stack is disabled")
+		return observer, "", nil
+	}
+
+	log.Info("Reconciling log stack", "backend", logs.GetBackend())
+	var waiting string
+	switch logs.GetBackend() {
+	case appv1alpha1.ElasticsearchBackend:
+		observer, waiting, err = r.reconcileElasticStack(ctx, observer, cl)
+	case appv1alpha1.LokiBackend:
+		waiting, err = r.reconcileLokiStack(ctx, observer, cl)
+	case appv1alpha1.ExternalLogsBackend:
+		waiting, err = r.reconcileExternalLogs(ctx, observer, cl)
+	default:
+		err = errors.Errorf("unsupported logs backend %q", logs.GetBackend())
+	}
+	if err != nil {
+		log.Info("error reconciling log stack", "error", err.Error())
+	}
+	return observer, waiting, err
+}
+
+// reconcileElasticStack installs the ECK operator, the Elasticsearch cluster and Kibana, and the
+// fluent charts shipping the logs to Elasticsearch. The cluster health is reported in the
+// ElasticsearchReady condition and the index lifecycle policy is applied once it's green.
+func (r *ObserverReconciler) reconcileElasticStack(
+	ctx context.Context, observer appv1alpha1.Observer, cl *appv1alpha1.Cluster) (appv1alpha1.Observer, string, error) {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+
+	log.Info("Reconciling elastic stack")
+	values := map[string]interface{}{}
+	if cl.HasInfraNodes() {
+		values = infraScheduling()
+	}
+	ready, err := r.installRelease(ctx, eckOperatorReleaseName, eckOperatorVersion, values, &observer, cl)
+	if err != nil {
+		return observer, "", err
+	}
+	if !ready {
+		return observer, eckOperatorReleaseName, nil
+	}
+	health, err := r.reconcileElasticsearchCluster(ctx, observer, cl)
+	if err != nil {
+		return observer, "", err
+	}
+	if health != elasticsearchDesiredHealth {
+		if health == "" {
+			health = "unknown"
+		}
+		log.Info("Waiting elasticsearch", "health", health, "desiredHealth", elasticsearchDesiredHealth)
+		msg := fmt.Sprintf("Elasticsearch health is %s", health)
+		meta.SetResourceCondition(&observer, appv1alpha1.ElasticsearchReadyCondition, metav1.ConditionFalse, meta.WaitProvisionReason, msg)
+	} else {
+		msg := fmt.Sprintf("Elasticsearch health is %s", health)
+		meta.SetResourceCondition(&observer, appv1alpha1.ElasticsearchReadyCondition, metav1.ConditionTrue, meta.InstallSucceededReason, msg)
+	}
+	// the fluent charts default values ship the logs to the elasticsearch cluster
+	fluentBitReady, err := r.installRelease(ctx, fluentBitReleaseName, fluentBitVersion, values, &observer, cl)
+	if err != nil {
+		return observer, "", err
+	}
+	fluentdReady, err := r.installRelease(ctx, fluentdReleaseName, fluentdVersion, values, &observer, cl)
+	if err != nil {
+		return observer, "", err
+	}
+	switch {
+	case health != elasticsearchDesiredHealth:
+		return observer, elasticsearchName, nil
+	case !fluentBitReady:
+		return observer, fluentBitReleaseName, nil
+	case !fluentdReady:
+		return observer, fluentdReleaseName, nil
+	}
+	return observer, "", r.reconcileIndexLifecycle(ctx, observer)
+}
+
+func (r *ObserverReconciler) reconcileLokiStack(ctx context.Context, observer appv1alpha1.Observer, cl *appv1alpha1.Cluster) (string, error) {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+
+	log.Info("Reconciling loki stack")
+	logs := observer.Spec.Logs
+	lokiValues := map[string]interface{}{}
+	if logs.Retention != nil {
+		lokiValues["config"] = map[string]interface{}{
+			"compactor": map[string]interface{}{
+				"retention_enabled": true,
+			},
+			"limits_config": map[string]interface{}{
+				"retention_period": fmt.Sprintf("%dh", retentionDays(logs.Retention)*24),
+			},
+		}
+	}
+	if logs.StorageSize != nil {
+		lokiValues["persistence"] = map[string]interface{}{
+			"enabled": true,
+			"size":    logs.StorageSize.String(),
+		}
+	}
+	if cl.HasInfraNodes() {
+		lokiValues = util.MergeMaps(lokiValues, infraScheduling())
+	}
+	lokiReady, err := r.installRelease(ctx, lokiReleaseName, lokiVersion, lokiValues, &observer, cl)
+	if err != nil {
+		return "", err
+	}
+	output := fmt.Sprintf(`[OUTPUT]
+    Name                   loki
+    Match                  *
+    Host                   %s.%s.svc
+    Port                   3100
+    Labels                 job=fluent-bit
+    Auto_Kubernetes_Labels On
+`, lokiReleaseName, monitoringNs)
+	fluentBitReady, err := r.installFluentBit(ctx, observer, cl, output, false)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case !lokiReady:
+		return lokiReleaseName, nil
+	case !fluentBitReady:
+		return fluentBitReleaseName, nil
+	}
+	return "", nil
+}
+
+func (r *ObserverReconciler) reconcileExternalLogs(ctx context.Context, observer appv1alpha1.Observer, cl *appv1alpha1.Cluster) (string, error) {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+
+	external := observer.Spec.Logs.External
+	if external == nil {
+		return "", errors.New("the external backend needs the logs external endpoint")
+	}
+	log.Info("Reconciling external logs", "type", external.Type, "url", external.URL)
+	output, err := fluentBitOutput(*external)
+	if err != nil {
+		return "", err
+	}
+	if external.SecretName != "" {
+		err = r.reconcileExternalLogsSecret(ctx, observer, external.SecretName)
+		if err != nil {
+			return "", err
+		}
+	}
+	ready, err := r.installFluentBit(ctx, observer, cl, output, external.SecretName != "")
+	if err != nil || ready {
+		return "", err
+	}
+	return fluentBitReleaseName, nil
+}
+
+// fluentBitOutput returns the fluent-bit output shipping the logs to an external endpoint.
+// Credentials are read from the environment variables set by installFluentBit.
+func fluentBitOutput(external appv1alpha1.ExternalLogsSpec) (string, error) {
+	u, err := url.Parse(external.URL)
+	if err != nil || u.Hostname() == "" {
+		return "", errors.Errorf("invalid logs endpoint URL %q", external.URL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	tls := "Off"
+	if u.Scheme == "https" {
+		tls = "On"
+	}
+	var b strings.Builder
+	b.WriteString("[OUTPUT]\n")
+	switch external.Type {
+	case appv1alpha1.ElasticsearchBackend:
+		fmt.Fprintf(&b, "    Name            es\n")
+		fmt.Fprintf(&b, "    Match           *\n")
+		fmt.Fprintf(&b, "    Host            %s\n", u.Hostname())
+		fmt.Fprintf(&b, "    Port            %s\n", port)
+		if path := strings.TrimSuffix(u.Path, "/"); path != "" {
+			fmt.Fprintf(&b, "    Path            %s\n", path)
+		}
+		fmt.Fprintf(&b, "    Logstash_Format On\n")
+		fmt.Fprintf(&b, "    Replace_Dots    On\n")
+		fmt.Fprintf(&b, "    Retry_Limit     False\n")
+		fmt.Fprintf(&b, "    tls             %s\n", tls)
+		if external.SecretName != "" {
+			fmt.Fprintf(&b, "    HTTP_User       ${LOGS_USERNAME}\n")
+			fmt.Fprintf(&b, "    HTTP_Passwd     ${LOGS_PASSWORD}\n")
+		}
+	case appv1alpha1.LokiBackend:
+		fmt.Fprintf(&b, "    Name                   loki\n")
+		fmt.Fprintf(&b, "    Match                  *\n")
+		fmt.Fprintf(&b, "    Host                   %s\n", u.Hostname())
+		fmt.Fprintf(&b, "    Port                   %s\n", port)
+		fmt.Fprintf(&b, "    Labels                 job=fluent-bit\n")
+		fmt.Fprintf(&b, "    Auto_Kubernetes_Labels On\n")
+		fmt.Fprintf(&b, "    tls                    %s\n", tls)
+		if external.SecretName != "" {
+			fmt.Fprintf(&b, "    HTTP_User              ${LOGS_USERNAME}\n")
+			fmt.Fprintf(&b, "    HTTP_Passwd            ${LOGS_PASSWORD}\n")
+		}
+	default:
+		return "", errors.Errorf("unsupported logs endpoint type %q", external.Type)
+	}
+	return b.String(), nil
+}
+
+func (r *ObserverReconciler) installFluentBit(
+	ctx context.Context, observer appv1alpha1.Observer, cl *appv1alpha1.Cluster, output string, credentials bool) (bool, error) {
+	env := make([]map[string]interface{}, 0)
+	if credentials {
+		for _, key := range []string{"username", "password"} {
+			env = append(env, map[string]interface{}{
+				"name": fmt.Sprintf("LOGS_%s", strings.ToUpper(key)),
+				"valueFrom": map[string]interface{}{
+					"secretKeyRef": map[string]interface{}{
+						"name": externalLogsSecretName,
+						"key":  key,
+					},
+				},
+			})
+		}
+	}
+	values := map[string]interface{}{
+		// replaces the elasticsearch settings of the default values
+		"env": env,
+		"config": map[string]interface{}{
+			"outputs": output,
+		},
+	}
+	if cl.HasInfraNodes() {
+		values = util.MergeMaps(values, infraScheduling())
+	}
+	return r.installRelease(ctx, fluentBitReleaseName, fluentBitVersion, values, &observer, cl)
+}
+
+// reconcileExternalLogsSecret copies the external endpoint credentials to the monitoring
+// namespace of the observed cluster, where fluent-bit reads them.
+func (r *ObserverReconciler) reconcileExternalLogsSecret(ctx context.Context, observer appv1alpha1.Observer, secretName string) error {
+	secret := corev1.Secret{}
+	key := client.ObjectKey{
+		Name:      secretName,
+		Namespace: observer.GetNamespace(),
+	}
+	err := r.Get(ctx, key, &secret)
+	if err != nil {
+		return err
+	}
+	data := map[string][]byte{
+		"username": secret.Data["username"],
+		"password": secret.Data["password"],
+	}
+	return r.copySecret(ctx, observer, externalLogsSecretName, data)
+}
+
+// copySecret creates or updates a Secret with the data in the monitoring namespace of the Observer cluster.
+func (r *ObserverReconciler) copySecret(ctx context.Context, observer appv1alpha1.Observer, name string, data map[string][]byte) error {
+	clusterClient, err := r.clusterClient(ctx, observer)
+	if err != nil {
+		return err
+	}
+	ns := corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Namespace",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: monitoringNs,
+		},
+	}
+	_, err = util.CreateOrUpdate(ctx, clusterClient, &ns)
+	if err != nil {
+		return err
+	}
+	copied := corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: monitoringNs,
+			Labels: map[string]string{
+				meta.LabelUndistro: "",
+			},
+		},
+		Data: data,
+	}
+	_, err = util.CreateOrUpdate(ctx, clusterClient, &copied)
+	return err
+}
+
+// reconcileElasticsearchCluster applies the Elasticsearch cluster and Kibana and returns the cluster health.
+func (r *ObserverReconciler) reconcileElasticsearchCluster(ctx context.Context, obs appv1alpha1.Observer, cl *appv1alpha1.Cluster) (string, error) {
+	clusterClient, err := r.clusterClient(ctx, obs)
+	if err != nil {
+		return "", err
+	}
+	es, err := elasticsearchCluster(obs, cl)
+	if err != nil {
+		return "", err
+	}
+	_, err = util.CreateOrUpdate(ctx, clusterClient, es)
+	if err != nil {
+		return "", err
+	}
+	if obs.Spec.Logs.Elasticsearch.Kibana.IsEnabled() {
+		kb, err := kibana(obs)
+		if err != nil {
+			return "", err
+		}
+		_, err = util.CreateOrUpdate(ctx, clusterClient, kb)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		err = deleteElasticObject(ctx, clusterClient, kibanaAPIVersion, "Kibana", kibanaName)
+		if err != nil {
+			return "", err
+		}
+	}
+	err = clusterClient.Get(ctx, client.ObjectKeyFromObject(es), es)
+	if err != nil {
+		return "", err
+	}
+	// the health isn't set until the operator observes the cluster
+	health, _, err := unstructured.NestedString(es.Object, "status", "health")
+	return strings.ToLower(health), err
+}
+
+// elasticsearchCluster returns the Elasticsearch cluster of the logs stack with a group of
+// master nodes, which store data too, and a group of data nodes.
+func elasticsearchCluster(obs appv1alpha1.Observer, cl *appv1alpha1.Cluster) (*unstructured.Unstructured, error) {
+	spec := obs.Spec.Logs.Elasticsearch
+	masterCount := int32(1)
+	if spec.Master.Count != nil {
+		masterCount = *spec.Master.Count
+	}
+	dataCount := cl.Status.TotalWorkerReplicas
+	if cl.HasInfraNodes() {
+		for _, w := range cl.Spec.Workers {
+			if w.InfraNode {
+				dataCount = *w.Replicas
+				break
+			}
+		}
+	}
+	if spec.Data.Count != nil {
+		dataCount = *spec.Data.Count
+	}
+	master, err := elasticsearchNodeSet("master", masterCount, map[string]interface{}{
+		"node.attr.attr_name":   "attr_value",
+		"node.roles":            []interface{}{"master", "data"},
+		"node.store.allow_mmap": false,
+	}, spec.Master, obs.Spec.Logs.StorageSize)
+	if err != nil {
+		return nil, err
+	}
+	data, err := elasticsearchNodeSet("worker", dataCount, map[string]interface{}{
+		"node.data": true,
+	}, spec.Data, obs.Spec.Logs.StorageSize)
+	if err != nil {
+		return nil, err
+	}
+	es := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"version":  elasticVersion(spec),
+				"nodeSets": []interface{}{master, data},
+			},
+		},
+	}
+	es.SetAPIVersion(elasticsearchAPIVersion)
+	es.SetKind("Elasticsearch")
+	es.SetName(elasticsearchName)
+	es.SetNamespace(monitoringNs)
+	return es, nil
+}
+
+func elasticsearchNodeSet(
+	name string, count int32, config map[string]interface{}, set appv1alpha1.ElasticsearchNodeSet, storageSize *resource.Quantity) (map[string]interface{}, error) {
+	container := map[string]interface{}{
+		"name": "elasticsearch",
+	}
+	resources, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&set.Resources)
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) > 0 {
+		container["resources"] = resources
+	}
+	nodeSet := map[string]interface{}{
+		"name":   name,
+		"count":  int64(count),
+		"config": config,
+		"podTemplate": map[string]interface{}{
+			"spec": map[string]interface{}{
+				"containers": []interface{}{container},
+			},
+		},
+	}
+	if set.StorageSize != nil {
+		storageSize = set.StorageSize
+	}
+	if storageSize != nil {
+		nodeSet["volumeClaimTemplates"] = []interface{}{
+			map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name": elasticsearchVolumeClaimName,
+				},
+				"spec": map[string]interface{}{
+					"accessModes": []interface{}{"ReadWriteOnce"},
+					"resources": map[string]interface{}{
+						"requests": map[string]interface{}{
+							"storage": storageSize.String(),
+						},
+					},
+				},
+			},
+		}
+	}
+	return nodeSet, nil
+}
+
+func kibana(obs appv1alpha1.Observer) (*unstructured.Unstructured, error) {
+	spec := obs.Spec.Logs.Elasticsearch
+	count := int32(1)
+	if spec.Kibana.Count != nil {
+		count = *spec.Kibana.Count
+	}
+	kbSpec := map[string]interface{}{
+		"version": elasticVersion(spec),
+		"count":   int64(count),
+		"elasticsearchRef": map[string]interface{}{
+			"name": elasticsearchName,
+		},
+	}
+	resources, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&spec.Kibana.Resources)
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) > 0 {
+		kbSpec["podTemplate"] = map[string]interface{}{
+			"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{
+						"name":      "kibana",
+						"resources": resources,
+					},
+				},
+			},
+		}
+	}
+	kb := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": kbSpec,
+		},
+	}
+	kb.SetAPIVersion(kibanaAPIVersion)
+	kb.SetKind("Kibana")
+	kb.SetName(kibanaName)
+	kb.SetNamespace(monitoringNs)
+	return kb, nil
+}
+
+func elasticVersion(spec appv1alpha1.ElasticsearchSpec) string {
+	if spec.Version == "" {
+		return elasticsearchVersion
+	}
+	return spec.Version
+}
+
+// reconcileIndexLifecycle provisions the index lifecycle policy deleting the log indices after
+// the logs retention, and detaches it from the log indices when the retention is removed. The
+// Elasticsearch API isn't reachable from the management cluster, so the policy is applied by a
+// Job in the cluster named after its script. A failed Job is recreated after indexLifecycleRetryAfter.
+func (r *ObserverReconciler) reconcileIndexLifecycle(ctx context.Context, observer appv1alpha1.Observer) error {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+
+	clusterClient, err := r.clusterClient(ctx, observer)
+	if err != nil {
+		return err
+	}
+	jobs := batchv1.JobList{}
+	err = clusterClient.List(ctx, &jobs, client.InNamespace(monitoringNs), client.HasLabels{labelIndexLifecycle})
+	if err != nil {
+		return err
+	}
+	var job *batchv1.Job
+	switch {
+	case observer.Spec.Logs.Retention != nil:
+		job = indexLifecycleJob(retentionDays(observer.Spec.Logs.Retention))
+	case len(jobs.Items) > 0:
+		// a policy was applied before, the removal Job is kept as the record it was detached
+		job = indexLifecycleRemovalJob()
+	}
+	for i := range jobs.Items {
+		if job != nil && jobs.Items[i].Name == job.Name {
+			continue
+		}
+		err = clusterClient.Delete(ctx, &jobs.Items[i], client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	if job == nil {
+		return nil
+	}
+	err = clusterClient.Get(ctx, client.ObjectKeyFromObject(job), job)
+	if apierrors.IsNotFound(err) {
+		log.Info("Applying index lifecycle policy", "job", job.Name)
+		return clusterClient.Create(ctx, job)
+	}
+	if err != nil {
+		return err
+	}
+	failed := jobFailed(job)
+	if failed == nil {
+		return nil
+	}
+	if job.DeletionTimestamp.IsZero() && time.Since(failed.LastTransitionTime.Time) >= indexLifecycleRetryAfter {
+		log.Info("Retrying index lifecycle policy", "job", job.Name)
+		err = clusterClient.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return errors.Errorf("index lifecycle policy job %s failed: %s", job.Name, failed.Message)
+}
+
+func jobFailed(job *batchv1.Job) *batchv1.JobCondition {
+	for i, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return &job.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func indexLifecycleJob(retentionDays int) *batchv1.Job {
+	policy := fmt.Sprintf(`{"policy":{"phases":{"hot":{"actions":{}},"delete":{"min_age":"%dd","actions":{"delete":{}}}}}}`, retentionDays)
+	patterns := `"` + strings.ReplaceAll(elasticsearchLogIndices, ",", `","`) + `"`
+	template := fmt.Sprintf(`{"index_patterns":[%s],"priority":200,"template":{"settings":{"index.lifecycle.name":"%s"}}}`,
+		patterns, elasticsearchLifecyclePolicy)
+	script := fmt.Sprintf(`set -e
+es=https://%[1]s-es-http:9200
+curl -sSfk -u "elastic:${ELASTIC_PASSWORD}" -H 'Content-Type: application/json' -X PUT "${es}/_ilm/policy/%[2]s" -d "${POLICY}"
+curl -sSfk -u "elastic:${ELASTIC_PASSWORD}" -H 'Content-Type: application/json' -X PUT "${es}/_index_template/%[2]s" -d "${TEMPLATE}"
+curl -sSfk -u "elastic:${ELASTIC_PASSWORD}" -H 'Content-Type: application/json' -X PUT "${es}/%[3]s/_settings?allow_no_indices=true" -d '{"index.lifecycle.name":"%[2]s"}'
+`, elasticsearchName, elasticsearchLifecyclePolicy, elasticsearchLogIndices)
+	return lifecycleJob(script, corev1.EnvVar{Name: "POLICY", Value: policy}, corev1.EnvVar{Name: "TEMPLATE", Value: template})
+}
+
+// indexLifecycleRemovalJob detaches the policy from the log indices and deletes the index template
+// and the policy. Missing ones are ignored, so the Job succeeds whatever was applied before.
+func indexLifecycleRemovalJob() *batchv1.Job {
+	script := fmt.Sprintf(`set -e
+es=https://%[1]s-es-http:9200
+request() {
+  code=$(curl -sSk -o /dev/null -w '%%{http_code}' -u "elastic:${ELASTIC_PASSWORD}" -X "$1" "$2")
+  [ "${code}" = 200 ] || [ "${code}" = 404 ] || { echo "$1 $2 returned ${code}"; exit 1; }
+}
+request POST "${es}/%[3]s/_ilm/remove"
+request DELETE "${es}/_index_template/%[2]s"
+request DELETE "${es}/_ilm/policy/%[2]s"
+`, elasticsearchName, elasticsearchLifecyclePolicy, elasticsearchLogIndices)
+	return lifecycleJob(script)
+}
+
+func lifecycleJob(script string, env ...corev1.EnvVar) *batchv1.Job {
+	h := sha256.New()
+	h.Write([]byte(script))
+	for _, e := range env {
+		h.Write([]byte(e.Value))
+	}
+	hash := h.Sum(nil)
+	backoffLimit := int32(6)
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: batchv1.SchemeGroupVersion.String(),
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-lifecycle-%x", elasticsearchName, hash[:4]),
+			Namespace: monitoringNs,
+			Labels: map[string]string{
+				meta.LabelUndistro:  "",
+				labelIndexLifecycle: elasticsearchLifecyclePolicy,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyOnFailure,
+					Containers: []corev1.Container{
+						{
+							Name:    "index-lifecycle",
+							Image:   curlImage,
+							Command: []string{"/bin/sh", "-c", script},
+							Env: append([]corev1.EnvVar{
+								{
+									Name: "ELASTIC_PASSWORD",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: fmt.Sprintf("%s-es-elastic-user", elasticsearchName),
+											},
+											Key: "elastic",
+										},
+									},
+								},
+							}, env...),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// deleteElasticObjects deletes Kibana and the Elasticsearch cluster of the logs stack.
+func (r *ObserverReconciler) deleteElasticObjects(ctx context.Context, observer appv1alpha1.Observer) error {
+	clusterClient, err := r.clusterClient(ctx, observer)
+	if err != nil {
+		return err
+	}
+	err = deleteElasticObject(ctx, clusterClient, kibanaAPIVersion, "Kibana", kibanaName)
+	if err != nil {
+		return err
+	}
+	return deleteElasticObject(ctx, clusterClient, elasticsearchAPIVersion, "Elasticsearch", elasticsearchName)
+}
+
+// elasticObjectsDeleted deletes Kibana and the Elasticsearch cluster of the logs stack
+// and returns if they are gone. They are gone with the cluster they were created in.
+func (r *ObserverReconciler) elasticObjectsDeleted(ctx context.Context, observer appv1alpha1.Observer) (bool, error) {
+	clusterClient, err := r.clusterClient(ctx, observer)
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	err = r.deleteElasticObjects(ctx, observer)
+	if err != nil {
+		return false, err
+	}
+	objs := []struct {
+		apiVersion, kind, name string
+	}{
+		{apiVersion: kibanaAPIVersion, kind: "Kibana", name: kibanaName},
+		{apiVersion: elasticsearchAPIVersion, kind: "Elasticsearch", name: elasticsearchName},
+	}
+	for _, o := range objs {
+		u := unstructured.Unstructured{}
+		u.SetAPIVersion(o.apiVersion)
+		u.SetKind(o.kind)
+		err = clusterClient.Get(ctx, client.ObjectKey{Name: o.name, Namespace: monitoringNs}, &u)
+		if apierrors.IsNotFound(err) || apimeta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func deleteElasticObject(ctx context.Context, c client.Client, apiVersion, kind, name string) error {
+	u := unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetName(name)
+	u.SetNamespace(monitoringNs)
+	err := c.Delete(ctx, &u)
+	// the crd is gone when the operator was never installed
+	if apierrors.IsNotFound(err) || apimeta.IsNoMatchError(err) {
+		return nil
+	}
+	return err
+}
+
+// recommendationDue returns true when the recommendations of the cluster weren't computed
+// in the last observerRequeueAfter, and records they are computed now.
+func (r *ObserverReconciler) recommendationDue(key client.ObjectKey, now time.Time) bool {
+	r.recommendedAtMu.Lock()
+	defer r.recommendedAtMu.Unlock()
+	if r.recommendedAt == nil {
+		r.recommendedAt = make(map[client.ObjectKey]time.Time)
+	}
+	last, ok := r.recommendedAt[key]
+	if ok && now.Sub(last) < observerRequeueAfter {
+		return false
+	}
+	r.recommendedAt[key] = now
+	return true
+}
+
+// reconcileRecommendation computes right-sizing recommendations for the worker pools
+// of AWS clusters based on the resources requested in their nodes. Recommendations are
+// advisory, so failures are recorded in the Recommendation status and never block the Observer.
+// The status is only written when the recommendations or their condition change.
+func (r *ObserverReconciler) reconcileRecommendation(ctx context.Context, observer appv1alpha1.Observer) {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+
+	if util.IsMgmtCluster(observer.Spec.ClusterName) || !observer.Spec.Metrics.IsEnabled() {
+		return
+	}
+	cl := &appv1alpha1.Cluster{}
+	key := client.ObjectKey{
+		Name:      observer.Spec.ClusterName,
+		Namespace: observer.GetNamespace(),
+	}
+	err = r.Get(ctx, key, cl)
+	if err != nil {
+		log.Info("unable to get cluster for recommendations", "error", err.Error())
+		return
+	}
+	if cl.Spec.InfrastructureProvider.Name != appv1alpha1.Amazon.String() {
+		return
+	}
+	if !r.recommendationDue(key, time.Now()) {
+		return
+	}
+
+	log.Info("Reconciling recommendations")
+	rec := &appv1alpha1.Recommendation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cl.Name,
+			Namespace: cl.GetNamespace(),
+		},
+	}
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, rec, func() error {
+		if rec.Labels == nil {
+			rec.Labels = make(map[string]string)
+		}
+		rec.Labels[meta.LabelUndistroMove] = ""
+		rec.Spec.ClusterName = cl.Name
+		return ctrl.SetControllerReference(&observer, rec, r.Scheme)
+	})
+	if err != nil {
+		log.Info("unable to create recommendation", "error", err.Error())
+		return
+	}
+	before := rec.Status.DeepCopy()
+	workers, err := r.recommendWorkers(ctx, cl, observer.Spec.Metrics.GetBackend())
+	if err != nil {
+		log.Info("unable to compute recommendations", "error", err.Error())
+		meta.SetResourceCondition(rec, meta.ReadyCondition, metav1.ConditionFalse, meta.ReconciliationFailedReason, err.Error())
+	} else {
+		if !equality.Semantic.DeepEqual(rec.Status.Workers, workers) {
+			rec.Status.Workers = workers
+			rec.Status.LastUpdated = metav1.Now()
+		}
+		meta.SetResourceCondition(rec, meta.ReadyCondition, metav1.ConditionTrue, meta.ReconciliationSucceededReason, "Recommendations updated")
+	}
+	rec.Status.ObservedGeneration = rec.Generation
+	if equality.Semantic.DeepEqual(before, &rec.Status) {
+		return
+	}
+	err = r.Status().Update(ctx, rec)
+	if err != nil {
+		log.Info("unable to update recommendation status", "error", err.Error())
+	}
+}
+
+func (r *ObserverReconciler) recommendWorkers(
+	ctx context.Context, cl *appv1alpha1.Cluster, backend appv1alpha1.MetricsBackend) ([]appv1alpha1.WorkerRecommendation, error) {
+	machines := metadatav1alpha1.AWSMachineList{}
+	err := r.List(ctx, &machines)
+	if err != nil {
+		return nil, err
+	}
+	catalog := make([]metadatav1alpha1.AWSMachineSpec, len(machines.Items))
+	for i, m := range machines.Items {
+		catalog[i] = m.Spec
+	}
+	cfg, err := kube.NewClusterConfig(ctx, r.Client, cl.Name, cl.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	newPrometheus := recommender.NewClusterPrometheus
+	if backend == appv1alpha1.VictoriaMetricsBackend {
+		newPrometheus = recommender.NewClusterVictoriaMetrics
+	}
+	prom, err := newPrometheus(cfg)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := prom.NodeResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	workers := make([]appv1alpha1.WorkerRecommendation, 0, len(cl.Spec.Workers))
+	for i, w := range cl.Spec.Workers {
+		mp := capiexp.MachinePool{}
+		key := client.ObjectKey{
+			Name:      fmt.Sprintf("%s-mp-%d", cl.Name, i),
+			Namespace: cl.GetNamespace(),
+		}
+		err = r.Get(ctx, key, &mp)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		pool := recommender.Pool{
+			Index:       int32(i),
+			MachineType: w.MachineType,
+			Replicas:    mp.Status.Replicas,
+		}
+		for _, ref := range mp.Status.NodeRefs {
+			pool.Nodes = append(pool.Nodes, ref.Name)
+		}
+		workers = append(workers, recommender.Recommend(pool, nodes, catalog, cl.Spec.InfrastructureProvider.Region))
+	}
+	return workers, nil
+}
+
+// installRelease creates or updates the HelmRelease of a chart and returns if it's ready.
+// The Observer is requeued while its releases aren't ready instead of waiting for them.
+func (r *ObserverReconciler) installRelease(
+	ctx context.Context, name, version string, values map[string]interface{}, observer *appv1alpha1.Observer, cl *appv1alpha1.Cluster) (bool, error) {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+
+	log.Info("Installing release", "releaseName", name)
+	key := client.ObjectKey{
+		Name:      hr.GetObjectName(name, observer.Spec.ClusterName),
+		Namespace: observer.GetNamespace(),
+	}
+	current := appv1alpha1.HelmRelease{}
+	err = r.Get(ctx, key, &current)
+	if client.IgnoreNotFound(err) != nil {
+		return false, err
+	}
+	release, err := hr.Prepare(name, monitoringNs, cl.GetNamespace(), version, observer.Spec.ClusterName, values)
+	if err != nil {
+		return false, err
+	}
+	if release.Labels == nil {
+		release.Labels = make(map[string]string)
+	}
+	release.Labels[meta.LabelUndistroMove] = ""
+	if err := hr.Install(ctx, r.Client, log, release, cl); err != nil {
+		return false, err
+	}
+	ready := meta.InReadyCondition(current.Status.Conditions)
+	if !ready {
+		log.Info("Waiting release is ready", "release", release.Name, "namespace", release.Namespace)
+	}
+	return ready, nil
+}
+
+// pruneReleases deletes the releases of a stack that are no longer desired, e.g. after the
+// backend of the stack is changed. The HelmRelease controller uninstalls the charts.
+func (r *ObserverReconciler) pruneReleases(ctx context.Context, observer appv1alpha1.Observer, releases, desired []string) error {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+
+	for _, name := range releases {
+		if util.ContainsStringInSlice(desired, name) {
+			continue
+		}
+		release := appv1alpha1.HelmRelease{}
+		key := client.ObjectKey{
+			Name:      hr.GetObjectName(name, observer.Spec.ClusterName),
+			Namespace: observer.GetNamespace(),
+		}
+		err = r.Get(ctx, key, &release)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		log.Info("Deleting release no longer desired", "releaseName", name)
+		err = r.Delete(ctx, &release)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ObserverReconciler) enableUnDistroMetrics(ctx context.Context, backend appv1alpha1.MetricsBackend) error {
+	undistroServiceMonitor := fmt.Sprintf(`
+---
+apiVersion: monitoring.coreos.com/v1
+kind: ServiceMonitor
+metadata:
+  labels:
+    control-plane: %[2]s
+    undistro.io: undistro
+  name: %[1]s
+  namespace: undistro-system
+spec:
+  endpoints:
+  - bearerTokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token
+    path: /metrics
+    port: https
+    scheme: https
+    tlsConfig:
+      insecureSkipVerify: true
+  selector:
+    matchLabels:
+      control-plane: %[2]s
+`, undistroMetricsMonitorName, undistroMetricsMonitorSelector)
+	if backend == appv1alpha1.VictoriaMetricsBackend {
+		undistroServiceMonitor = fmt.Sprintf(`
+---
+apiVersion: operator.victoriametrics.com/v1beta1
+kind: VMServiceScrape
+metadata:
+  labels:
+    control-plane: %[2]s
+    undistro.io: undistro
+  name: %[1]s
+  namespace: undistro-system
+spec:
+  endpoints:
+  - bearerTokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token
+    path: /metrics
+    port: https
+    scheme: https
+    tlsConfig:
+      insecureSkipVerify: true
+  selector:
+    matchLabels:
+      control-plane: %[2]s
+`, undistroMetricsMonitorName, undistroMetricsMonitorSelector)
+	}
+	objs, err := util.ToUnstructured([]byte(undistroServiceMonitor))
+	if err != nil {
+		return err
+	}
+	for _, o := range objs {
+		_, err = util.CreateOrUpdate(ctx, r.Client, &o)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ObserverReconciler) reconcileDelete(ctx context.Context, instance *appv1alpha1.Observer) (res ctrl.Result, err error) {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+
+	log.Info("Reconciling delete")
+	// the elastic objects are deleted before the operator managing them,
+	// their finalizers aren't processed once it's uninstalled
+	deleted, err := r.elasticObjectsDeleted(ctx, *instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !deleted {
+		log.Info("Waiting elastic objects to be deleted")
+		return ctrl.Result{RequeueAfter: observerWaitRequeueAfter}, nil
+	}
+	err = r.deleteFederationObjects(ctx, *instance)
+	// the objects are gone with the cluster they were created in
+	if client.IgnoreNotFound(err) != nil {
+		return ctrl.Result{}, err
+	}
+	releases := append(allMetricsReleases, allLogsReleases...)
+	for _, release := range releases {
 		log.Info("Deleting charts", "release", release, "namespace", instance.GetNamespace())
 		res, err = hr.Uninstall(ctx, r.Client, log, release, instance.Spec.ClusterName, instance.GetNamespace())
-		if err != nil {
+		// releases of disabled stacks and backends were never installed
+		if client.IgnoreNotFound(err) != nil {
 			return ctrl.Result{}, err
 		}
 	}
-	return res, nil
+	controllerutil.RemoveFinalizer(instance, meta.Finalizer)
+	return ctrl.Result{}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
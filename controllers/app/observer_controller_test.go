@@ -0,0 +1,711 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package app
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getupio-undistro/meta"
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func infraCluster() *appv1alpha1.Cluster {
+	replicas := int32(3)
+	cl := &appv1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+	}
+	cl.Spec.Workers = []appv1alpha1.WorkerNode{
+		{Node: appv1alpha1.Node{Replicas: &replicas}, InfraNode: true},
+	}
+	return cl
+}
+
+func nestedValue(t *testing.T, values map[string]interface{}, fields ...string) interface{} {
+	t.Helper()
+	v, found, err := unstructured.NestedFieldNoCopy(values, fields...)
+	if err != nil {
+		t.Fatalf("%s: %v", strings.Join(fields, "."), err)
+	}
+	if !found {
+		return nil
+	}
+	return v
+}
+
+func TestRetentionDays(t *testing.T) {
+	tests := []struct {
+		name string
+		d    time.Duration
+		want int
+	}{
+		{name: "days", d: 15 * 24 * time.Hour, want: 15},
+		{name: "rounded up", d: 36 * time.Hour, want: 2},
+		{name: "less than a day", d: time.Hour, want: 1},
+		{name: "zero", d: 0, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retentionDays(&metav1.Duration{Duration: tt.d}); got != tt.want {
+				t.Errorf("retentionDays() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecommendationDue(t *testing.T) {
+	r := &ObserverReconciler{}
+	prod := client.ObjectKey{Name: "prod", Namespace: "default"}
+	dev := client.ObjectKey{Name: "dev", Namespace: "default"}
+	now := time.Now()
+	if !r.recommendationDue(prod, now) {
+		t.Error("recommendationDue() = false the first time, want true")
+	}
+	if r.recommendationDue(prod, now.Add(time.Minute)) {
+		t.Error("recommendationDue() = true before observerRequeueAfter, want false")
+	}
+	if !r.recommendationDue(dev, now.Add(time.Minute)) {
+		t.Error("recommendationDue() = false for another cluster, want true")
+	}
+	if !r.recommendationDue(prod, now.Add(observerRequeueAfter)) {
+		t.Error("recommendationDue() = false after observerRequeueAfter, want true")
+	}
+}
+
+func TestPrometheusValues(t *testing.T) {
+	size := resource.MustParse("50Gi")
+	tests := []struct {
+		name    string
+		metrics appv1alpha1.MetricsSpec
+		cl      *appv1alpha1.Cluster
+		field   []string
+		want    interface{}
+	}{
+		{
+			name:  "grafana disabled",
+			cl:    &appv1alpha1.Cluster{},
+			field: []string{"grafana", "enabled"},
+			want:  false,
+		},
+		{
+			name:    "retention",
+			metrics: appv1alpha1.MetricsSpec{Retention: &metav1.Duration{Duration: 36 * time.Hour}},
+			cl:      &appv1alpha1.Cluster{},
+			field:   []string{"prometheus", "prometheusSpec", "retention"},
+			want:    "2d",
+		},
+		{
+			name:    "storage size",
+			metrics: appv1alpha1.MetricsSpec{StorageSize: &size},
+			cl:      &appv1alpha1.Cluster{},
+			field:   []string{"prometheus", "prometheusSpec", "storageSpec", "volumeClaimTemplate", "spec", "resources", "requests", "storage"},
+			want:    "50Gi",
+		},
+		{
+			name:  "no infra nodes",
+			cl:    &appv1alpha1.Cluster{},
+			field: []string{"prometheus", "prometheusSpec", "nodeSelector"},
+		},
+		{
+			name:    "infra nodes keep the retention",
+			metrics: appv1alpha1.MetricsSpec{Retention: &metav1.Duration{Duration: 24 * time.Hour}},
+			cl:      infraCluster(),
+			field:   []string{"prometheus", "prometheusSpec", "retention"},
+			want:    "1d",
+		},
+		{
+			name:  "infra nodes",
+			cl:    infraCluster(),
+			field: []string{"prometheus", "prometheusSpec", "nodeSelector", meta.LabelUndistroInfra},
+			want:  "true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nestedValue(t, prometheusValues(tt.metrics, tt.cl), tt.field...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prometheusValues() %s = %v, want %v", strings.Join(tt.field, "."), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVictoriaMetricsValues(t *testing.T) {
+	size := resource.MustParse("20Gi")
+	tests := []struct {
+		name    string
+		metrics appv1alpha1.MetricsSpec
+		cl      *appv1alpha1.Cluster
+		field   []string
+		want    interface{}
+	}{
+		{
+			name:    "retention",
+			metrics: appv1alpha1.MetricsSpec{Retention: &metav1.Duration{Duration: 7 * 24 * time.Hour}},
+			cl:      &appv1alpha1.Cluster{},
+			field:   []string{"vmsingle", "spec", "retentionPeriod"},
+			want:    "7d",
+		},
+		{
+			name:    "storage size",
+			metrics: appv1alpha1.MetricsSpec{StorageSize: &size},
+			cl:      &appv1alpha1.Cluster{},
+			field:   []string{"vmsingle", "spec", "storage", "resources", "requests", "storage"},
+			want:    "20Gi",
+		},
+		{
+			name:  "no infra nodes",
+			cl:    &appv1alpha1.Cluster{},
+			field: []string{"vmagent"},
+		},
+		{
+			name:  "infra nodes",
+			cl:    infraCluster(),
+			field: []string{"vmagent", "spec", "nodeSelector", meta.LabelUndistroInfra},
+			want:  "true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nestedValue(t, victoriaMetricsValues(tt.metrics, tt.cl), tt.field...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("victoriaMetricsValues() %s = %v, want %v", strings.Join(tt.field, "."), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFluentBitOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		external appv1alpha1.ExternalLogsSpec
+		want     []string
+		notWant  []string
+		wantErr  bool
+	}{
+		{
+			name:     "elasticsearch https",
+			external: appv1alpha1.ExternalLogsSpec{Type: appv1alpha1.ElasticsearchBackend, URL: "https://es.example.com/logs/"},
+			want:     []string{"Name            es", "Host            es.example.com", "Port            443", "Path            /logs", "tls             On"},
+			notWant:  []string{"HTTP_User"},
+		},
+		{
+			name:     "elasticsearch with credentials",
+			external: appv1alpha1.ExternalLogsSpec{Type: appv1alpha1.ElasticsearchBackend, URL: "http://es.example.com:9200", SecretName: "es"},
+			want:     []string{"Port            9200", "tls             Off", "HTTP_User       ${LOGS_USERNAME}", "HTTP_Passwd     ${LOGS_PASSWORD}"},
+			notWant:  []string{"Path"},
+		},
+		{
+			name:     "loki",
+			external: appv1alpha1.ExternalLogsSpec{Type: appv1alpha1.LokiBackend, URL: "http://loki.example.com"},
+			want:     []string{"Name                   loki", "Host                   loki.example.com", "Port                   80"},
+		},
+		{
+			name:     "invalid url",
+			external: appv1alpha1.ExternalLogsSpec{Type: appv1alpha1.LokiBackend, URL: "loki"},
+			wantErr:  true,
+		},
+		{
+			name:     "unsupported type",
+			external: appv1alpha1.ExternalLogsSpec{Type: "splunk", URL: "https://splunk.example.com"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fluentBitOutput(tt.external)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fluentBitOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("fluentBitOutput() = %s, want %q", got, w)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(got, w) {
+					t.Errorf("fluentBitOutput() = %s, don't want %q", got, w)
+				}
+			}
+		})
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestElasticsearchCluster(t *testing.T) {
+	size := resource.MustParse("30Gi")
+	tests := []struct {
+		name        string
+		obs         appv1alpha1.Observer
+		cl          *appv1alpha1.Cluster
+		wantVersion string
+		wantMaster  int64
+		wantData    int64
+	}{
+		{
+			name:        "defaults to the worker replicas",
+			cl:          &appv1alpha1.Cluster{Status: appv1alpha1.ClusterStatus{TotalWorkerReplicas: 5}},
+			wantVersion: elasticsearchVersion,
+			wantMaster:  1,
+			wantData:    5,
+		},
+		{
+			name:        "infra nodes",
+			cl:          infraCluster(),
+			wantVersion: elasticsearchVersion,
+			wantMaster:  1,
+			wantData:    3,
+		},
+		{
+			name: "counts and version",
+			obs: appv1alpha1.Observer{Spec: appv1alpha1.ObserverSpec{Logs: appv1alpha1.LogsSpec{
+				StorageSize: &size,
+				Elasticsearch: appv1alpha1.ElasticsearchSpec{
+					Version: "7.16.0",
+					Master:  appv1alpha1.ElasticsearchNodeSet{Count: int32Ptr(3)},
+					Data:    appv1alpha1.ElasticsearchNodeSet{Count: int32Ptr(2)},
+				},
+			}}},
+			cl:          infraCluster(),
+			wantVersion: "7.16.0",
+			wantMaster:  3,
+			wantData:    2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es, err := elasticsearchCluster(tt.obs, tt.cl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if es.GetName() != elasticsearchName || es.GetNamespace() != monitoringNs || es.GetAPIVersion() != elasticsearchAPIVersion {
+				t.Errorf("elasticsearchCluster() = %s/%s %s", es.GetNamespace(), es.GetName(), es.GetAPIVersion())
+			}
+			version := nestedValue(t, es.Object, "spec", "version")
+			if version != tt.wantVersion {
+				t.Errorf("elasticsearchCluster() version = %v, want %s", version, tt.wantVersion)
+			}
+			nodeSets, _, _ := unstructured.NestedSlice(es.Object, "spec", "nodeSets")
+			if len(nodeSets) != 2 {
+				t.Fatalf("elasticsearchCluster() nodeSets = %v", nodeSets)
+			}
+			master := nodeSets[0].(map[string]interface{})
+			data := nodeSets[1].(map[string]interface{})
+			if master["name"] != "master" || master["count"] != tt.wantMaster {
+				t.Errorf("elasticsearchCluster() master = %s %v, want %d", master["name"], master["count"], tt.wantMaster)
+			}
+			if data["name"] != "worker" || data["count"] != tt.wantData {
+				t.Errorf("elasticsearchCluster() data = %s %v, want %d", data["name"], data["count"], tt.wantData)
+			}
+		})
+	}
+}
+
+func TestElasticsearchNodeSet(t *testing.T) {
+	logsSize := resource.MustParse("30Gi")
+	setSize := resource.MustParse("100Gi")
+	tests := []struct {
+		name          string
+		set           appv1alpha1.ElasticsearchNodeSet
+		storageSize   *resource.Quantity
+		wantStorage   interface{}
+		wantResources bool
+	}{
+		{
+			name: "defaults",
+		},
+		{
+			name:        "logs storage size",
+			storageSize: &logsSize,
+			wantStorage: "30Gi",
+		},
+		{
+			name:        "node set storage size overrides the logs one",
+			set:         appv1alpha1.ElasticsearchNodeSet{StorageSize: &setSize},
+			storageSize: &logsSize,
+			wantStorage: "100Gi",
+		},
+		{
+			name: "resources",
+			set: appv1alpha1.ElasticsearchNodeSet{Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+			}},
+			wantResources: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodeSet, err := elasticsearchNodeSet("worker", 2, map[string]interface{}{"node.data": true}, tt.set, tt.storageSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var storage interface{}
+			if claims, ok := nodeSet["volumeClaimTemplates"].([]interface{}); ok {
+				storage = nestedValue(t, claims[0].(map[string]interface{}), "spec", "resources", "requests", "storage")
+			}
+			if storage != tt.wantStorage {
+				t.Errorf("elasticsearchNodeSet() storage = %v, want %v", storage, tt.wantStorage)
+			}
+			containers, _, _ := unstructured.NestedSlice(nodeSet, "podTemplate", "spec", "containers")
+			_, hasResources := containers[0].(map[string]interface{})["resources"]
+			if hasResources != tt.wantResources {
+				t.Errorf("elasticsearchNodeSet() resources = %v, want %v", hasResources, tt.wantResources)
+			}
+		})
+	}
+}
+
+func TestKibana(t *testing.T) {
+	tests := []struct {
+		name          string
+		spec          appv1alpha1.ElasticsearchSpec
+		wantCount     int64
+		wantVersion   string
+		wantResources bool
+	}{
+		{
+			name:        "defaults",
+			wantCount:   1,
+			wantVersion: elasticsearchVersion,
+		},
+		{
+			name: "count, version and resources",
+			spec: appv1alpha1.ElasticsearchSpec{
+				Version: "7.16.0",
+				Kibana: appv1alpha1.KibanaSpec{
+					Count: int32Ptr(2),
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+					},
+				},
+			},
+			wantCount:     2,
+			wantVersion:   "7.16.0",
+			wantResources: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obs := appv1alpha1.Observer{Spec: appv1alpha1.ObserverSpec{Logs: appv1alpha1.LogsSpec{Elasticsearch: tt.spec}}}
+			kb, err := kibana(obs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kb.GetName() != kibanaName || kb.GetNamespace() != monitoringNs || kb.GetAPIVersion() != kibanaAPIVersion {
+				t.Errorf("kibana() = %s/%s %s", kb.GetNamespace(), kb.GetName(), kb.GetAPIVersion())
+			}
+			if got := nestedValue(t, kb.Object, "spec", "count"); got != tt.wantCount {
+				t.Errorf("kibana() count = %v, want %d", got, tt.wantCount)
+			}
+			if got := nestedValue(t, kb.Object, "spec", "version"); got != tt.wantVersion {
+				t.Errorf("kibana() version = %v, want %s", got, tt.wantVersion)
+			}
+			if got := nestedValue(t, kb.Object, "spec", "elasticsearchRef", "name"); got != elasticsearchName {
+				t.Errorf("kibana() elasticsearchRef = %v, want %s", got, elasticsearchName)
+			}
+			hasResources := nestedValue(t, kb.Object, "spec", "podTemplate") != nil
+			if hasResources != tt.wantResources {
+				t.Errorf("kibana() resources = %v, want %v", hasResources, tt.wantResources)
+			}
+		})
+	}
+}
+
+func TestIndexLifecycleJob(t *testing.T) {
+	job := indexLifecycleJob(7)
+	if job.Namespace != monitoringNs || job.Labels[labelIndexLifecycle] != elasticsearchLifecyclePolicy {
+		t.Errorf("indexLifecycleJob() = %s/%s %v", job.Namespace, job.Name, job.Labels)
+	}
+	env := map[string]string{}
+	container := job.Spec.Template.Spec.Containers[0]
+	for _, e := range container.Env {
+		env[e.Name] = e.Value
+	}
+	if !strings.Contains(env["POLICY"], `"min_age":"7d"`) {
+		t.Errorf("indexLifecycleJob() policy = %s", env["POLICY"])
+	}
+	if !strings.Contains(env["TEMPLATE"], `"index_patterns":["logstash-*","dapr-*"]`) {
+		t.Errorf("indexLifecycleJob() template = %s", env["TEMPLATE"])
+	}
+	if !strings.Contains(container.Command[2], "${es}/"+elasticsearchLogIndices+"/_settings") {
+		t.Errorf("indexLifecycleJob() script = %s", container.Command[2])
+	}
+	if job.Name == indexLifecycleJob(30).Name {
+		t.Error("indexLifecycleJob() name doesn't change with the retention")
+	}
+	if job.Name != indexLifecycleJob(7).Name {
+		t.Error("indexLifecycleJob() name changes with the same retention")
+	}
+	removal := indexLifecycleRemovalJob()
+	if removal.Name == job.Name || removal.Labels[labelIndexLifecycle] != elasticsearchLifecyclePolicy {
+		t.Errorf("indexLifecycleRemovalJob() = %s %v", removal.Name, removal.Labels)
+	}
+	for _, s := range []string{"/_ilm/remove", "DELETE \"${es}/_ilm/policy/" + elasticsearchLifecyclePolicy} {
+		if !strings.Contains(removal.Spec.Template.Spec.Containers[0].Command[2], s) {
+			t.Errorf("indexLifecycleRemovalJob() script doesn't contain %q", s)
+		}
+	}
+}
+
+func TestReconcileIndexLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	r := &ObserverReconciler{Client: c, Scheme: scheme.Scheme}
+	observer := appv1alpha1.Observer{
+		ObjectMeta: metav1.ObjectMeta{Name: "management", Namespace: "undistro-system"},
+	}
+	jobNames := func() []string {
+		jobs := batchv1.JobList{}
+		err := c.List(ctx, &jobs, client.InNamespace(monitoringNs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, 0)
+		for _, j := range jobs.Items {
+			names = append(names, j.Name)
+		}
+		return names
+	}
+
+	err := r.reconcileIndexLifecycle(ctx, observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := jobNames(); len(names) != 0 {
+		t.Fatalf("jobs without retention = %v", names)
+	}
+
+	observer.Spec.Logs.Retention = &metav1.Duration{Duration: 7 * 24 * time.Hour}
+	job := indexLifecycleJob(7)
+	err = r.reconcileIndexLifecycle(ctx, observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := jobNames(); !reflect.DeepEqual(names, []string{job.Name}) {
+		t.Fatalf("jobs with retention = %v, want %s", names, job.Name)
+	}
+
+	// a failed Job is kept until the retry backoff passes, then recreated
+	failed := func(since time.Duration) {
+		err = c.Get(ctx, client.ObjectKeyFromObject(job), job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.Status.Conditions = []batchv1.JobCondition{
+			{
+				Type:               batchv1.JobFailed,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(time.Now().Add(-since)),
+				Message:            "BackoffLimitExceeded",
+			},
+		}
+		err = c.Status().Update(ctx, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	failed(time.Minute)
+	err = r.reconcileIndexLifecycle(ctx, observer)
+	if err == nil {
+		t.Fatal("reconcileIndexLifecycle() of a failed job returned no error")
+	}
+	if names := jobNames(); len(names) != 1 {
+		t.Fatalf("jobs in the retry backoff = %v", names)
+	}
+	failed(indexLifecycleRetryAfter)
+	err = r.reconcileIndexLifecycle(ctx, observer)
+	if err == nil {
+		t.Fatal("reconcileIndexLifecycle() of a failed job returned no error")
+	}
+	if names := jobNames(); len(names) != 0 {
+		t.Fatalf("jobs after the retry backoff = %v", names)
+	}
+	err = r.reconcileIndexLifecycle(ctx, observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := jobNames(); !reflect.DeepEqual(names, []string{job.Name}) {
+		t.Fatalf("jobs after retry = %v, want %s", names, job.Name)
+	}
+
+	// removing the retention detaches the policy
+	observer.Spec.Logs.Retention = nil
+	err = r.reconcileIndexLifecycle(ctx, observer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names, want := jobNames(), indexLifecycleRemovalJob().Name; !reflect.DeepEqual(names, []string{want}) {
+		t.Fatalf("jobs without retention = %v, want %s", names, want)
+	}
+}
+
+func TestFederationLabels(t *testing.T) {
+	tests := []struct {
+		name string
+		cl   *appv1alpha1.Cluster
+		want map[string]interface{}
+	}{
+		{
+			name: "management",
+			cl:   &appv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "management", Namespace: "undistro-system"}},
+			want: map[string]interface{}{"cluster": "management", "cluster_namespace": "undistro-system"},
+		},
+		{
+			name: "provider and flavor",
+			cl: &appv1alpha1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+				Spec: appv1alpha1.ClusterSpec{
+					InfrastructureProvider: appv1alpha1.InfrastructureProvider{Name: "aws", Flavor: "eks"},
+				},
+			},
+			want: map[string]interface{}{"cluster": "prod", "cluster_namespace": "default", "provider": "aws", "flavor": "eks"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := federationLabels(tt.cl); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("federationLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteWriteValues(t *testing.T) {
+	const endpoint = "https://undistro.example.com/metrics-federation/api/v1/write"
+	cl := &appv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"}}
+	tests := []struct {
+		name         string
+		backend      appv1alpha1.MetricsBackend
+		hasCA        bool
+		writes       []string
+		labels       []string
+		wantCASecret interface{}
+	}{
+		{
+			name:    "prometheus",
+			backend: appv1alpha1.PrometheusBackend,
+			writes:  []string{"prometheus", "prometheusSpec", "remoteWrite"},
+			labels:  []string{"prometheus", "prometheusSpec", "externalLabels"},
+		},
+		{
+			name:         "prometheus with the local authority",
+			backend:      appv1alpha1.PrometheusBackend,
+			hasCA:        true,
+			writes:       []string{"prometheus", "prometheusSpec", "remoteWrite"},
+			labels:       []string{"prometheus", "prometheusSpec", "externalLabels"},
+			wantCASecret: federationSecretName,
+		},
+		{
+			name:    "victoria metrics",
+			backend: appv1alpha1.VictoriaMetricsBackend,
+			writes:  []string{"vmagent", "additionalRemoteWrites"},
+			labels:  []string{"vmagent", "spec", "externalLabels"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := remoteWriteValues(tt.backend, endpoint, tt.hasCA, cl)
+			writes, ok := nestedValue(t, values, tt.writes...).([]interface{})
+			if !ok || len(writes) != 1 {
+				t.Fatalf("remoteWriteValues() %s = %v", strings.Join(tt.writes, "."), writes)
+			}
+			write := writes[0].(map[string]interface{})
+			if write["url"] != endpoint {
+				t.Errorf("remoteWriteValues() url = %v, want %s", write["url"], endpoint)
+			}
+			if got := nestedValue(t, write, "basicAuth", "password", "name"); got != federationSecretName {
+				t.Errorf("remoteWriteValues() password secret = %v, want %s", got, federationSecretName)
+			}
+			if got := nestedValue(t, write, "tlsConfig", "ca", "secret", "name"); got != tt.wantCASecret {
+				t.Errorf("remoteWriteValues() ca secret = %v, want %v", got, tt.wantCASecret)
+			}
+			if got := nestedValue(t, values, tt.labels...); !reflect.DeepEqual(got, federationLabels(cl)) {
+				t.Errorf("remoteWriteValues() %s = %v", strings.Join(tt.labels, "."), got)
+			}
+		})
+	}
+}
+
+func TestFederationCredentials(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	r := &ObserverReconciler{Client: c, Scheme: scheme.Scheme}
+	metrics := appv1alpha1.MetricsSpec{
+		Federation: appv1alpha1.MetricsFederation{Enabled: true, Host: "metrics.example.com"},
+	}
+	endpoint, err := r.exposeCentralStore(ctx, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://metrics.example.com/metrics-federation/api/v1/write"; endpoint != want {
+		t.Errorf("exposeCentralStore() = %s, want %s", endpoint, want)
+	}
+	ing := networkingv1.Ingress{}
+	err = c.Get(ctx, client.ObjectKey{Name: federationIngressName, Namespace: monitoringNs}, &ing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ing.Spec.Rules[0].HTTP.Paths[0].Path; got != "/metrics-federation/api/v1/write$" {
+		t.Errorf("ingress path = %s, want only the remote write path", got)
+	}
+	passwords := make(map[string]string)
+	for _, name := range []string{"prod", "dev", "prod"} {
+		observer := appv1alpha1.Observer{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(name)},
+			Spec:       appv1alpha1.ObserverSpec{ClusterName: name},
+		}
+		creds, err := r.federationCredentials(ctx, observer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(creds.Data["username"]); got != "default/"+name {
+			t.Errorf("username = %s, want default/%s", got, name)
+		}
+		password := string(creds.Data["password"])
+		if previous, ok := passwords[name]; ok && previous != password {
+			t.Errorf("federationCredentials() rotated the %s password", name)
+		}
+		passwords[name] = password
+	}
+	if passwords["prod"] == passwords["dev"] {
+		t.Error("federationCredentials() shared the password between clusters")
+	}
+	auth := corev1.Secret{}
+	err = c.Get(ctx, client.ObjectKey{Name: federationAuthSecretName, Namespace: monitoringNs}, &auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(auth.Data["auth"])), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "default/dev:") || !strings.HasPrefix(lines[1], "default/prod:") {
+		t.Errorf("auth = %v, want a line for each cluster", lines)
+	}
+}
@@ -0,0 +1,340 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/getupio-undistro/controllerlib"
+	"github.com/getupio-undistro/meta"
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/kube"
+	"github.com/getupio-undistro/undistro/pkg/policy"
+	"github.com/getupio-undistro/undistro/pkg/util"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// PolicyBundleReconciler reconciles a PolicyBundle object
+type PolicyBundleReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=app.undistro.io,resources=policybundles,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=app.undistro.io,resources=policybundles/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=app.undistro.io,resources=policybundles/finalizers,verbs=update
+
+func (r *PolicyBundleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	start := time.Now()
+
+	p := appv1alpha1.PolicyBundle{}
+	if err := r.Get(ctx, req.NamespacedName, &p); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+	log.WithValues("PolicyBundle", req.NamespacedName)
+
+	// Initialize the patch helper.
+	patchHelper, err := patch.NewHelper(&p, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	defer controllerlib.PatchInstance(ctx, controllerlib.InstanceOpts{
+		Controller: "PolicyBundleController",
+		Request:    req.String(),
+		Object:     &p,
+		Error:      err,
+		Helper:     patchHelper,
+	})
+
+	if !p.ObjectMeta.DeletionTimestamp.IsZero() {
+		p, err = r.reconcileDelete(ctx, p)
+		return ctrl.Result{}, err
+	}
+
+	// Add our finalizer if it does not exist
+	if !controllerutil.ContainsFinalizer(&p, meta.Finalizer) {
+		log.Info("Adding finalizer")
+		controllerutil.AddFinalizer(&p, meta.Finalizer)
+		return ctrl.Result{}, nil
+	}
+
+	if p.Spec.Paused {
+		log.Info("Reconciliation is paused for this object")
+		p = appv1alpha1.PolicyBundlePaused(p)
+		return ctrl.Result{}, nil
+	}
+
+	if p.Generation < p.Status.ObservedGeneration {
+		log.Info("skipping this old version of reconciled object")
+		return ctrl.Result{}, nil
+	}
+
+	p, result, err := r.reconcile(ctx, p)
+	durationMsg := fmt.Sprintf("Reconcilation finished in %s", time.Since(start).String())
+	if result.RequeueAfter > 0 {
+		durationMsg = fmt.Sprintf("%s, next run in %s", durationMsg, result.RequeueAfter.String())
+	}
+	log.Info(durationMsg)
+	return result, err
+}
+
+func (r *PolicyBundleReconciler) reconcile(ctx context.Context, p appv1alpha1.PolicyBundle) (appv1alpha1.PolicyBundle, ctrl.Result, error) {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+
+	objs, revision, err := r.renderPolicies(ctx, p)
+	if err != nil {
+		return appv1alpha1.PolicyBundleNotReady(p, meta.ArtifactFailedReason, err.Error()), ctrl.Result{}, err
+	}
+	p.Status.Revision = revision
+
+	selector, err := metav1.LabelSelectorAsSelector(&p.Spec.ClusterSelector)
+	if err != nil {
+		return appv1alpha1.PolicyBundleNotReady(p, meta.ArtifactFailedReason, err.Error()), ctrl.Result{}, err
+	}
+	clusters := appv1alpha1.ClusterList{}
+	err = r.List(ctx, &clusters, client.InNamespace(p.GetNamespace()), client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return p, ctrl.Result{}, err
+	}
+
+	previous := make(map[string]appv1alpha1.ClusterPolicyBundleStatus)
+	for _, st := range p.Status.Clusters {
+		previous[st.ClusterName] = st
+	}
+	statuses := make([]appv1alpha1.ClusterPolicyBundleStatus, 0, len(clusters.Items))
+	failed := 0
+	for _, cl := range clusters.Items {
+		if !cl.DeletionTimestamp.IsZero() {
+			continue
+		}
+		st, ok := previous[cl.Name]
+		if !ok {
+			st = appv1alpha1.ClusterPolicyBundleStatus{ClusterName: cl.Name}
+		}
+		delete(previous, cl.Name)
+		log.Info("Applying policy bundle", "cluster", cl.Name, "revision", revision)
+		st, err = r.applyBundle(ctx, p, st, objs, revision)
+		if err != nil {
+			log.Info("failed to apply policy bundle", "cluster", cl.Name, "err", err)
+			failed++
+		}
+		statuses = append(statuses, st)
+	}
+	// remove the policies from clusters not selected anymore
+	for _, st := range previous {
+		log.Info("Removing policy bundle", "cluster", st.ClusterName)
+		st, err = r.removeBundle(ctx, p, st)
+		if err != nil {
+			log.Info("failed to remove policy bundle", "cluster", st.ClusterName, "err", err)
+			failed++
+			statuses = append(statuses, st)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ClusterName < statuses[j].ClusterName
+	})
+	p.Status.Clusters = statuses
+	if failed > 0 {
+		msg := fmt.Sprintf("Failed to reconcile policies in %d clusters", failed)
+		return appv1alpha1.PolicyBundleNotReady(p, meta.ArtifactFailedReason, msg), ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+	return appv1alpha1.PolicyBundleReady(p), ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+}
+
+// renderPolicies reads the policies of the bundle ConfigMaps, renders them with the bundle parameters
+// and returns the policy objects and the revision of the policy set.
+func (r *PolicyBundleReconciler) renderPolicies(ctx context.Context, p appv1alpha1.PolicyBundle) ([]unstructured.Unstructured, string, error) {
+	objs := make([]unstructured.Unstructured, 0)
+	rendered := make([][]byte, 0)
+	for _, ref := range p.Spec.PoliciesFrom {
+		cm := corev1.ConfigMap{}
+		key := client.ObjectKey{
+			Name:      ref.Name,
+			Namespace: p.GetNamespace(),
+		}
+		err := r.Get(ctx, key, &cm)
+		if err != nil {
+			if apierrors.IsNotFound(err) && ref.Optional {
+				continue
+			}
+			return nil, "", errors.Wrapf(err, "failed to get ConfigMap %s", ref.Name)
+		}
+		keys := make([]string, 0, len(cm.Data))
+		for k := range cm.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			byt, err := renderPolicy(k, []byte(cm.Data[k]), p.Spec.Parameters)
+			if err != nil {
+				return nil, "", err
+			}
+			rendered = append(rendered, byt)
+			o, err := util.ToUnstructured(byt)
+			if err != nil {
+				return nil, "", errors.Wrapf(err, "invalid policy %s in ConfigMap %s", k, ref.Name)
+			}
+			objs = append(objs, o...)
+		}
+	}
+	for i := range objs {
+		labels := objs[i].GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[appv1alpha1.LabelPolicyBundle] = p.Name
+		objs[i].SetLabels(labels)
+	}
+	return objs, policy.Revision(rendered), nil
+}
+
+func (r *PolicyBundleReconciler) applyBundle(
+	ctx context.Context, p appv1alpha1.PolicyBundle, st appv1alpha1.ClusterPolicyBundleStatus, objs []unstructured.Unstructured, revision string) (appv1alpha1.ClusterPolicyBundleStatus, error) {
+	clusterClient, err := kube.NewClusterClient(ctx, r.Client, st.ClusterName, p.GetNamespace())
+	if err != nil {
+		st.Message = err.Error()
+		return st, err
+	}
+	policies := make([]client.Object, len(objs))
+	for i := range objs {
+		policies[i] = &objs[i]
+	}
+	st.AppliedPolicies, err = applyClusterObjects(ctx, clusterClient, policies, st.AppliedPolicies, false)
+	if err != nil {
+		st.Message = err.Error()
+		return st, err
+	}
+	st.Revision = revision
+	st.Message = ""
+	return st, nil
+}
+
+func (r *PolicyBundleReconciler) removeBundle(ctx context.Context, p appv1alpha1.PolicyBundle, st appv1alpha1.ClusterPolicyBundleStatus) (appv1alpha1.ClusterPolicyBundleStatus, error) {
+	key := client.ObjectKey{
+		Name:      st.ClusterName,
+		Namespace: p.GetNamespace(),
+	}
+	err := removeClusterObjects(ctx, r.Client, key, st.AppliedPolicies)
+	if err != nil {
+		st.Message = err.Error()
+	}
+	return st, err
+}
+
+func (r *PolicyBundleReconciler) reconcileDelete(ctx context.Context, p appv1alpha1.PolicyBundle) (appv1alpha1.PolicyBundle, error) {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = ctrl.Log
+	}
+	for i, st := range p.Status.Clusters {
+		log.Info("Removing policy bundle", "cluster", st.ClusterName)
+		p.Status.Clusters[i], err = r.removeBundle(ctx, p, st)
+		if err != nil {
+			return appv1alpha1.PolicyBundleNotReady(p, meta.ReconciliationDeletingReason, err.Error()), err
+		}
+	}
+	controllerutil.RemoveFinalizer(&p, meta.Finalizer)
+	return p, nil
+}
+
+// configMapToPolicyBundles enqueues the bundles referencing the ConfigMap.
+func (r *PolicyBundleReconciler) configMapToPolicyBundles(o client.Object) []ctrl.Request {
+	bundles := appv1alpha1.PolicyBundleList{}
+	err := r.List(context.Background(), &bundles, client.InNamespace(o.GetNamespace()))
+	if err != nil {
+		ctrl.Log.Info("failed to list policy bundles", "err", err)
+		return nil
+	}
+	reqs := make([]ctrl.Request, 0)
+	for _, p := range bundles.Items {
+		for _, ref := range p.Spec.PoliciesFrom {
+			if ref.Name == o.GetName() {
+				reqs = append(reqs, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&p)})
+				break
+			}
+		}
+	}
+	return reqs
+}
+
+// clusterToPolicyBundles enqueues the bundles selecting the cluster, or applied
+// to it before its labels changed, so policies follow new and relabeled clusters.
+func (r *PolicyBundleReconciler) clusterToPolicyBundles(o client.Object) []ctrl.Request {
+	bundles := appv1alpha1.PolicyBundleList{}
+	err := r.List(context.Background(), &bundles, client.InNamespace(o.GetNamespace()))
+	if err != nil {
+		ctrl.Log.Info("failed to list policy bundles", "err", err)
+		return nil
+	}
+	reqs := make([]ctrl.Request, 0)
+	for _, p := range bundles.Items {
+		applied := false
+		for _, st := range p.Status.Clusters {
+			applied = applied || st.ClusterName == o.GetName()
+		}
+		if applied || selectsCluster(p.Spec.ClusterSelector, o) {
+			reqs = append(reqs, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&p)})
+		}
+	}
+	return reqs
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PolicyBundleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&appv1alpha1.PolicyBundle{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 10}).
+		Watches(
+			&source.Kind{
+				Type: &corev1.ConfigMap{},
+			},
+			handler.EnqueueRequestsFromMapFunc(r.configMapToPolicyBundles),
+		).
+		Watches(
+			&source.Kind{
+				Type: &appv1alpha1.Cluster{},
+			},
+			handler.EnqueueRequestsFromMapFunc(r.clusterToPolicyBundles),
+			builder.WithPredicates(clusterSelectionChanged),
+		).
+		Complete(r)
+}
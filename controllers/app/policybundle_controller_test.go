@@ -0,0 +1,119 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package app
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func policyBundleRequests(names ...string) []ctrl.Request {
+	reqs := make([]ctrl.Request, 0, len(names))
+	for _, name := range names {
+		reqs = append(reqs, ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: name}})
+	}
+	return reqs
+}
+
+func TestPolicyBundleMappers(t *testing.T) {
+	prod := &appv1alpha1.PolicyBundle{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod", Namespace: "default"},
+		Spec: appv1alpha1.PolicyBundleSpec{
+			ClusterSelector: metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+			PoliciesFrom:    []appv1alpha1.PolicySourceReference{{Name: "baseline"}, {Name: "prod-policies"}},
+		},
+		Status: appv1alpha1.PolicyBundleStatus{
+			Clusters: []appv1alpha1.ClusterPolicyBundleStatus{{ClusterName: "relabeled"}},
+		},
+	}
+	all := &appv1alpha1.PolicyBundle{
+		ObjectMeta: metav1.ObjectMeta{Name: "all", Namespace: "default"},
+		Spec: appv1alpha1.PolicyBundleSpec{
+			PoliciesFrom: []appv1alpha1.PolicySourceReference{{Name: "baseline"}},
+		},
+	}
+	other := &appv1alpha1.PolicyBundle{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"},
+		Spec: appv1alpha1.PolicyBundleSpec{
+			PoliciesFrom: []appv1alpha1.PolicySourceReference{{Name: "baseline"}},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(prod, all, other).Build()
+	r := &PolicyBundleReconciler{Client: c, Scheme: scheme.Scheme}
+	tests := []struct {
+		name   string
+		mapper func(client.Object) []ctrl.Request
+		obj    client.Object
+		want   []ctrl.Request
+	}{
+		{
+			name:   "configmap referenced by two bundles",
+			mapper: r.configMapToPolicyBundles,
+			obj:    &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "baseline", Namespace: "default"}},
+			want:   policyBundleRequests("all", "prod"),
+		},
+		{
+			name:   "configmap referenced by one bundle",
+			mapper: r.configMapToPolicyBundles,
+			obj:    &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "prod-policies", Namespace: "default"}},
+			want:   policyBundleRequests("prod"),
+		},
+		{
+			name:   "configmap not referenced",
+			mapper: r.configMapToPolicyBundles,
+			obj:    &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "kube-root-ca.crt", Namespace: "default"}},
+			want:   policyBundleRequests(),
+		},
+		{
+			name:   "cluster selected by two bundles",
+			mapper: r.clusterToPolicyBundles,
+			obj:    &appv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cool-cluster", Namespace: "default", Labels: map[string]string{"env": "prod"}}},
+			want:   policyBundleRequests("all", "prod"),
+		},
+		{
+			name:   "cluster selected by the empty selector",
+			mapper: r.clusterToPolicyBundles,
+			obj:    &appv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "dev-cluster", Namespace: "default"}},
+			want:   policyBundleRequests("all"),
+		},
+		{
+			name:   "cluster relabeled out of a bundle",
+			mapper: r.clusterToPolicyBundles,
+			obj:    &appv1alpha1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "relabeled", Namespace: "default", Labels: map[string]string{"env": "dev"}}},
+			want:   policyBundleRequests("all", "prod"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.mapper(tt.obj)
+			sort.Slice(got, func(i, j int) bool {
+				return got[i].Name < got[j].Name
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mapper() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
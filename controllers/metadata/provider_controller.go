@@ -18,6 +18,7 @@ package metadata
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -30,8 +31,11 @@ import (
 	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
 	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
 	"github.com/getupio-undistro/undistro/pkg/cloud"
+	"github.com/getupio-undistro/undistro/pkg/helm"
+	"github.com/getupio-undistro/undistro/pkg/registry"
 	"github.com/getupio-undistro/undistro/pkg/retry"
 	"github.com/getupio-undistro/undistro/pkg/scheme"
+	"github.com/getupio-undistro/undistro/pkg/undistro"
 	"github.com/getupio-undistro/undistro/pkg/util"
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -41,12 +45,13 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-var baseURL = "https://undistro.io/resources/metadata"
-
 // ProviderReconciler reconciles a provider object
 type ProviderReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// MetadataURL is where provider metadata is fetched from,
+	// defaults to undistro.MetadataURL.
+	MetadataURL string
 }
 
 func (r *ProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -105,28 +110,12 @@ func (r *ProviderReconciler) reconcile(ctx context.Context, log logr.Logger, p m
 	}
 	p.Status.RegionNames = cloud.RegionNames(p)
 	if p.Spec.AutoFetch {
-		u, err := url.Parse(baseURL)
+		byt, err := r.fetchMetadata(ctx, p.Name)
 		if err != nil {
 			p = metadatav1alpha1.ProviderNotReady(p, meta.URLInvalidReason, err.Error())
 			return p, ctrl.Result{}, err
 		}
-		u.Path = path.Join(u.Path, fmt.Sprintf("%s.yaml", p.Name))
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
-		if err != nil {
-			p = metadatav1alpha1.ProviderNotReady(p, meta.URLInvalidReason, err.Error())
-			return p, ctrl.Result{}, err
-		}
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			p = metadatav1alpha1.ProviderNotReady(p, meta.URLInvalidReason, err.Error())
-			return p, ctrl.Result{}, err
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode == http.StatusOK {
-			byt, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return p, ctrl.Result{}, err
-			}
+		if byt != nil {
 			objs, err := util.ToUnstructured(byt)
 			if err != nil {
 				return p, ctrl.Result{}, err
@@ -155,6 +144,45 @@ func (r *ProviderReconciler) reconcile(ctx context.Context, log logr.Logger, p m
 	return p, ctrl.Result{RequeueAfter: 24 * time.Hour}, nil
 }
 
+// fetchMetadata downloads the metadata of a provider and returns nil when
+// the provider has none published. oci:// URLs point to the artifacts
+// pushed by undistro install --bundle.
+func (r *ProviderReconciler) fetchMetadata(ctx context.Context, name string) ([]byte, error) {
+	metadataURL := r.MetadataURL
+	if metadataURL == "" {
+		metadataURL = undistro.MetadataURL
+	}
+	u, err := url.Parse(metadataURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, fmt.Sprintf("%s.yaml", name))
+	if u.Scheme == "oci" {
+		ref, err := helm.OCIReference(u.String())
+		if err != nil {
+			return nil, err
+		}
+		byt, err := registry.NewClient().PullArtifact(ctx, ref)
+		if errors.Is(err, registry.ErrNotFound) {
+			return nil, nil
+		}
+		return byt, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	return io.ReadAll(resp.Body)
+}
+
 func (r *ProviderReconciler) execMetadataFunc(ctx context.Context, p metadatav1alpha1.Provider, f cloud.MetadataFunc) (metadatav1alpha1.Provider, error) {
 	if f != nil {
 		objs, err := f(ctx, p)
@@ -30,6 +30,7 @@ import (
 	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
 	appcontrollers "github.com/getupio-undistro/undistro/controllers/app"
 	metadatacontrollers "github.com/getupio-undistro/undistro/controllers/metadata"
+	"github.com/getupio-undistro/undistro/pkg/hr"
 	"github.com/getupio-undistro/undistro/pkg/scheme"
 	"github.com/getupio-undistro/undistro/pkg/undistro"
 	"github.com/getupio-undistro/undistro/pkg/version"
@@ -46,13 +47,21 @@ func main() {
 	var undistroApiAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var chartRepository string
+	var imageRegistry string
+	var metadataURL string
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&undistroApiAddr, "undistro-api-addr", ":2020", "The address and port of the UnDistro API server")
+	flag.StringVar(&chartRepository, "chart-repository", undistro.DefaultRepo, "The repository of the charts UnDistro installs in clusters.")
+	flag.StringVar(&imageRegistry, "image-registry", "", "The registry images are pulled from on disconnected installations.")
+	flag.StringVar(&metadataURL, "metadata-url", undistro.MetadataURL, "The URL provider metadata is fetched from.")
 	klog.InitFlags(nil)
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	hr.Repository = chartRepository
+	hr.ImageRegistry = imageRegistry
 
 	cfg := ctrl.GetConfigOrDie()
 
@@ -75,8 +84,9 @@ func main() {
 	record.InitFromRecorder(mgr.GetEventRecorderFor("undistro-controller"))
 
 	if err = (&appcontrollers.ClusterReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		ImageRegistry: imageRegistry,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
 		os.Exit(1)
@@ -95,9 +105,24 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "DefaultPolicies")
 		os.Exit(1)
 	}
-	if err = (&metadatacontrollers.ProviderReconciler{
+	if err = (&appcontrollers.PolicyBundleReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PolicyBundle")
+		os.Exit(1)
+	}
+	if err = (&appcontrollers.AccessPolicyReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AccessPolicy")
+		os.Exit(1)
+	}
+	if err = (&metadatacontrollers.ProviderReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		MetadataURL: metadataURL,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Provider")
 		os.Exit(1)
@@ -131,6 +156,10 @@ func main() {
 		setupLog.Error(err, "unable to create webhook", "webhook", "DefaultPolicies")
 		os.Exit(1)
 	}
+	if err = (&appv1alpha1.Identity{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "Identity")
+		os.Exit(1)
+	}
 
 	// +kubebuilder:scaffold:builder
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
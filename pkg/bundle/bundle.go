@@ -0,0 +1,337 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package bundle packs the charts, images and provider metadata UnDistro
+// needs into a single archive for disconnected installations.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/getupio-undistro/undistro/pkg/registry"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"sigs.k8s.io/yaml"
+)
+
+const manifestFile = "bundle.yaml"
+
+// Manifest describes the content of a bundle,
+// it is stored as bundle.yaml at the root of the archive.
+type Manifest struct {
+	UnDistroVersion string   `json:"undistroVersion,omitempty"`
+	Charts          []Chart  `json:"charts,omitempty"`
+	Images          []Image  `json:"images,omitempty"`
+	Metadata        []string `json:"metadata,omitempty"`
+}
+
+// Chart is a chart archive stored in the charts directory.
+type Chart struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	File    string `json:"file"`
+}
+
+// Image is an image whose manifests and layers are stored in the blobs directory.
+type Image struct {
+	Name      string        `json:"name"`
+	Digest    digest.Digest `json:"digest"`
+	MediaType string        `json:"mediaType"`
+}
+
+// Content lists what goes into a bundle.
+type Content struct {
+	UnDistroVersion string
+	// Charts holds chart archives.
+	Charts [][]byte
+	Images []string
+	// Metadata holds provider metadata by provider name.
+	Metadata map[string][]byte
+}
+
+// Write pulls the images of content with c and writes the bundle to w.
+func Write(ctx context.Context, w io.Writer, c *registry.Client, content Content) (*Manifest, error) {
+	gw := gzip.NewWriter(w)
+	bw := &writer{
+		ctx:    ctx,
+		tw:     tar.NewWriter(gw),
+		client: c,
+		blobs:  make(map[digest.Digest]bool),
+	}
+	m := &Manifest{
+		UnDistroVersion: content.UnDistroVersion,
+	}
+	for _, data := range content.Charts {
+		ch, err := loader.LoadArchive(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		chart := Chart{
+			Name:    ch.Name(),
+			Version: ch.Metadata.Version,
+			File:    path.Join("charts", fmt.Sprintf("%s-%s.tgz", ch.Name(), ch.Metadata.Version)),
+		}
+		err = bw.writeFile(chart.File, data)
+		if err != nil {
+			return nil, err
+		}
+		m.Charts = append(m.Charts, chart)
+	}
+	for _, image := range content.Images {
+		ref, err := registry.ParseReference(image)
+		if err != nil {
+			return nil, err
+		}
+		desc, body, err := c.Manifest(ctx, ref)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to pull %s", image)
+		}
+		err = bw.writeManifest(ref, body)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to pull %s", image)
+		}
+		m.Images = append(m.Images, Image{
+			Name:      ref.String(),
+			Digest:    desc.Digest,
+			MediaType: desc.MediaType,
+		})
+	}
+	for name := range content.Metadata {
+		m.Metadata = append(m.Metadata, name)
+	}
+	sort.Strings(m.Metadata)
+	for _, name := range m.Metadata {
+		err := bw.writeFile(metadataPath(name), content.Metadata[name])
+		if err != nil {
+			return nil, err
+		}
+	}
+	byt, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	err = bw.writeFile(manifestFile, byt)
+	if err != nil {
+		return nil, err
+	}
+	err = bw.tw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return m, gw.Close()
+}
+
+type writer struct {
+	ctx    context.Context
+	tw     *tar.Writer
+	client *registry.Client
+	blobs  map[digest.Digest]bool
+}
+
+func (w *writer) writeFile(name string, data []byte) error {
+	err := w.tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(len(data)),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.tw.Write(data)
+	return err
+}
+
+// writeManifest writes a manifest with everything it points to.
+func (w *writer) writeManifest(ref registry.Reference, body []byte) error {
+	dgst := digest.FromBytes(body)
+	if w.blobs[dgst] {
+		return nil
+	}
+	m := registry.Manifest{}
+	err := json.Unmarshal(body, &m)
+	if err != nil {
+		return err
+	}
+	for _, desc := range m.Manifests {
+		child := ref
+		child.Tag = ""
+		child.Digest = desc.Digest
+		_, byt, err := w.client.Manifest(w.ctx, child)
+		if err != nil {
+			return err
+		}
+		err = w.writeManifest(child, byt)
+		if err != nil {
+			return err
+		}
+	}
+	if m.Config != nil {
+		err = w.writeBlob(ref, *m.Config)
+		if err != nil {
+			return err
+		}
+	}
+	for _, desc := range m.Layers {
+		// foreign layers are pulled from their own URLs
+		if len(desc.URLs) > 0 {
+			continue
+		}
+		err = w.writeBlob(ref, desc)
+		if err != nil {
+			return err
+		}
+	}
+	w.blobs[dgst] = true
+	return w.writeFile(blobPath(dgst), body)
+}
+
+func (w *writer) writeBlob(ref registry.Reference, desc ocispec.Descriptor) error {
+	if w.blobs[desc.Digest] {
+		return nil
+	}
+	rc, err := w.client.Blob(w.ctx, ref, desc.Digest)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	err = w.tw.WriteHeader(&tar.Header{
+		Name:     blobPath(desc.Digest),
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     desc.Size,
+	})
+	if err != nil {
+		return err
+	}
+	verifier := desc.Digest.Verifier()
+	_, err = io.CopyN(w.tw, io.TeeReader(rc, verifier), desc.Size)
+	if err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return errors.Errorf("blob %s of %s does not match its digest", desc.Digest, ref.Name())
+	}
+	w.blobs[desc.Digest] = true
+	return nil
+}
+
+// Bundle is a bundle extracted to a temporary directory.
+type Bundle struct {
+	Dir      string
+	Manifest Manifest
+}
+
+// Open extracts the bundle at filename, Close removes the extracted files.
+func Open(filename string) (*Bundle, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dir, err := os.MkdirTemp("", "undistro-bundle-")
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{Dir: dir}
+	err = extract(f, dir)
+	if err != nil {
+		b.Close()
+		return nil, errors.Wrapf(err, "failed to extract %s", filename)
+	}
+	byt, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	err = yaml.UnmarshalStrict(byt, &b.Manifest)
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Close removes the extracted files.
+func (b *Bundle) Close() error {
+	return os.RemoveAll(b.Dir)
+}
+
+// ReadFile reads a file of the bundle, e.g. one of the chart files.
+func (b *Bundle) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(b.path(name))
+}
+
+func (b *Bundle) path(name string) string {
+	return filepath.Join(b.Dir, filepath.FromSlash(name))
+}
+
+func extract(r io.Reader, dir string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || path.IsAbs(name) || strings.HasPrefix(name, "../") {
+			return errors.Errorf("unexpected entry %q", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		err = os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func blobPath(dgst digest.Digest) string {
+	return path.Join("blobs", dgst.Algorithm().String(), dgst.Encoded())
+}
+
+func metadataPath(name string) string {
+	return path.Join("metadata", name+".yaml")
+}
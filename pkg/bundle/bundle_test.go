@@ -0,0 +1,224 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/getupio-undistro/undistro/pkg/helm"
+	"github.com/getupio-undistro/undistro/pkg/registry"
+	"github.com/getupio-undistro/undistro/pkg/registry/registrytest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+var helmGetters = getter.Providers{
+	getter.Provider{
+		Schemes: []string{"oci"},
+		New:     helm.NewOCIGetter,
+	},
+}
+
+func testChart(t *testing.T) []byte {
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion: chart.APIVersionV2,
+			Name:       "app",
+			Version:    "0.1.0",
+		},
+		Values: map[string]interface{}{
+			"image": "registry.undistro.io/library/app:v1",
+		},
+		Templates: []*chart.File{
+			{
+				Name: "templates/deployment.yaml",
+				Data: []byte("spec:\n  containers:\n  - name: app\n    image: {{ .Values.image }}\n"),
+			},
+		},
+	}
+	dir := t.TempDir()
+	name, err := chartutil.Save(ch, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byt, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return byt
+}
+
+func host(t *testing.T, u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Host
+}
+
+func TestChartImages(t *testing.T) {
+	ch, err := loader.LoadArchive(bytes.NewReader(testChart(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ChartImages(ch, map[string]interface{}{"image": "quay.io/jetstack/cert-manager-controller:v1.5.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"quay.io/jetstack/cert-manager-controller:v1.5.3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChartImages() = %v, want %v", got, want)
+	}
+}
+
+func TestTemplateImages(t *testing.T) {
+	fsys := fstest.MapFS{
+		"aws/ec2.yaml": &fstest.MapFile{
+			Data: []byte(`
+    clusterConfiguration:
+      imageRepository: registry.undistro.io/k8s
+      dns:
+        imageRepository: registry.undistro.io/k8s/coredns
+        imageTag: v1.8.4
+      etcd:
+        local:
+          imageRepository: registry.undistro.io/k8s
+`),
+		},
+	}
+	got, err := TemplateImages(fsys, []string{"v1.22.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"registry.undistro.io/k8s/coredns/coredns:v1.8.4",
+		"registry.undistro.io/k8s/etcd:3.5.0-0",
+		"registry.undistro.io/k8s/kube-apiserver:v1.22.2",
+		"registry.undistro.io/k8s/kube-controller-manager:v1.22.2",
+		"registry.undistro.io/k8s/kube-proxy:v1.22.2",
+		"registry.undistro.io/k8s/kube-scheduler:v1.22.2",
+		"registry.undistro.io/k8s/pause:3.5",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TemplateImages() = %v, want %v", got, want)
+	}
+	if _, err := TemplateImages(fsys, []string{"v1.14.0"}); err == nil {
+		t.Error("TemplateImages() with unknown version succeeded")
+	}
+}
+
+func TestWriteAndPush(t *testing.T) {
+	srcServer, src := registrytest.NewServer()
+	defer srcServer.Close()
+	dstServer, dst := registrytest.NewServer()
+	defer dstServer.Close()
+	srcHost, dstHost := host(t, srcServer.URL), host(t, dstServer.URL)
+
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	layer := []byte("layer")
+	manifest, err := json.Marshal(registry.Manifest{
+		MediaType: registry.DockerManifest,
+		Config:    &ocispec.Descriptor{MediaType: "application/vnd.docker.container.image.v1+json", Digest: src.AddBlob(config), Size: int64(len(config))},
+		Layers:    []ocispec.Descriptor{{MediaType: "application/vnd.docker.image.rootfs.diff.tar.gzip", Digest: src.AddBlob(layer), Size: int64(len(layer))}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.AddManifest("library/app", "v1", registry.DockerManifest, manifest)
+
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Write(ctx, f, registry.NewClient(), Content{
+		UnDistroVersion: "v0.37.2",
+		Charts:          [][]byte{testChart(t)},
+		Images:          []string{srcHost + "/library/app:v1"},
+		Metadata:        map[string][]byte{"aws": []byte("kind: AWSMachine\n")},
+	})
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	want := Manifest{
+		UnDistroVersion: "v0.37.2",
+		Charts:          []Chart{{Name: "app", Version: "0.1.0", File: "charts/app-0.1.0.tgz"}},
+		Images:          []Image{{Name: srcHost + "/library/app:v1", Digest: b.Manifest.Images[0].Digest, MediaType: registry.DockerManifest}},
+		Metadata:        []string{"aws"},
+	}
+	if !reflect.DeepEqual(b.Manifest, want) {
+		t.Fatalf("Open() manifest = %+v, want %+v", b.Manifest, want)
+	}
+
+	if err := b.Push(ctx, registry.NewClient(), dstHost, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := dst.Manifest("library/app", "v1"); !ok || !bytes.Equal(got, manifest) {
+		t.Errorf("pushed manifest = %s, want %s", got, manifest)
+	}
+	repo, err := helm.NewChartRepository(ChartRepository(dstHost), helmGetters, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DownloadIndex(); err != nil {
+		t.Fatal(err)
+	}
+	cv, err := repo.Get("app", "0.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := repo.DownloadChart(cv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err := loader.LoadArchive(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ch.Name() != "app" {
+		t.Errorf("pulled chart %s, want app", ch.Name())
+	}
+	ref, err := helm.OCIReference(MetadataURL(dstHost) + "/aws.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := registry.NewClient().PullArtifact(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(md) != "kind: AWSMachine\n" {
+		t.Errorf("pulled metadata %q", md)
+	}
+}
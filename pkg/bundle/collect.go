@@ -0,0 +1,178 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package bundle
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/getupio-undistro/undistro/pkg/registry"
+	"github.com/getupio-undistro/undistro/pkg/undistro"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+)
+
+// kubeadmImages are the images kubeadm pulls besides the control plane
+// components, by Kubernetes minor version.
+var kubeadmImages = map[string][]string{
+	"1.19": {"pause:3.2", "etcd:3.4.13-0"},
+	"1.20": {"pause:3.2", "etcd:3.4.13-0"},
+	"1.21": {"pause:3.4.1", "etcd:3.4.13-0"},
+	"1.22": {"pause:3.5", "etcd:3.5.0-0"},
+}
+
+var (
+	imageRepositoryLine = regexp.MustCompile(`^\s*imageRepository:\s*["']?([^"'\s]+)`)
+	imageTagLine        = regexp.MustCompile(`^\s*imageTag:\s*["']?([^"'\s]+)`)
+)
+
+// ChartImages renders the chart with values and returns the images of the manifests.
+func ChartImages(ch *chart.Chart, values map[string]interface{}) ([]string, error) {
+	err := chartutil.ProcessDependencies(ch, values)
+	if err != nil {
+		return nil, err
+	}
+	opts := chartutil.ReleaseOptions{
+		Name:      ch.Name(),
+		Namespace: undistro.Namespace,
+		IsInstall: true,
+	}
+	renderValues, err := chartutil.ToRenderValues(ch, values, opts, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, err
+	}
+	files, err := engine.Render(ch, renderValues)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to render chart %s", ch.Name())
+	}
+	var buf bytes.Buffer
+	for name, content := range files {
+		if path.Ext(name) == ".yaml" || path.Ext(name) == ".yml" {
+			fmt.Fprintf(&buf, "---\n%s\n", content)
+		}
+	}
+	return registry.FindImages(buf.Bytes()), nil
+}
+
+// TemplateImages returns the images kubeadm pulls for the given Kubernetes
+// versions from the image repositories of the cluster templates in fsys.
+func TemplateImages(fsys fs.FS, versions []string) ([]string, error) {
+	repos := make(map[string]bool)
+	images := make(map[string]bool)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		f, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		// the DNS image repository is followed by its tag
+		dnsRepo := ""
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			if m := imageRepositoryLine.FindStringSubmatch(s.Text()); m != nil {
+				dnsRepo = ""
+				if strings.HasSuffix(m[1], "/coredns") {
+					dnsRepo = m[1]
+					continue
+				}
+				repos[m[1]] = true
+			}
+			if m := imageTagLine.FindStringSubmatch(s.Text()); m != nil && dnsRepo != "" {
+				images[fmt.Sprintf("%s/coredns:%s", dnsRepo, m[1])] = true
+				dnsRepo = ""
+			}
+		}
+		return s.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		sv, err := semver.NewVersion(v)
+		if err != nil {
+			return nil, err
+		}
+		extra, ok := kubeadmImages[fmt.Sprintf("%d.%d", sv.Major(), sv.Minor())]
+		if !ok {
+			return nil, errors.Errorf("no kubeadm images known for Kubernetes %s", v)
+		}
+		tag := "v" + sv.String()
+		for repo := range repos {
+			for _, c := range []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler", "kube-proxy"} {
+				images[fmt.Sprintf("%s/%s:%s", repo, c, tag)] = true
+			}
+			for _, e := range extra {
+				images[fmt.Sprintf("%s/%s", repo, e)] = true
+			}
+		}
+	}
+	res := make([]string, 0, len(images))
+	for img := range images {
+		res = append(res, img)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// FetchMetadata downloads the metadata published for the providers,
+// providers without metadata are skipped.
+func FetchMetadata(ctx context.Context, baseURL string, providers ...string) (map[string][]byte, error) {
+	res := make(map[string][]byte)
+	for _, p := range providers {
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, err
+		}
+		u.Path = path.Join(u.Path, fmt.Sprintf("%s.yaml", p))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		byt, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+			res[p] = byt
+		case http.StatusNotFound:
+		default:
+			return nil, errors.Errorf("failed to fetch %s metadata: %s", p, resp.Status)
+		}
+	}
+	return res, nil
+}
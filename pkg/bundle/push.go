@@ -0,0 +1,197 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/getupio-undistro/undistro/pkg/registry"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
+)
+
+// ChartRepository returns the URL of the chart repository
+// Push creates in the target registry.
+func ChartRepository(target string) string {
+	return fmt.Sprintf("oci://%s/charts", target)
+}
+
+// MetadataURL returns the URL of the provider metadata
+// Push creates in the target registry.
+func MetadataURL(target string) string {
+	return fmt.Sprintf("oci://%s/metadata", target)
+}
+
+// Push uploads the bundle to the target registry. Images keep their
+// repository paths, see registry.Relocate. Charts are stored as helm OCI
+// artifacts with an index.yaml artifact next to them, so ChartRepository
+// can be used as HelmRelease repository.
+func (b *Bundle) Push(ctx context.Context, c *registry.Client, target string, out io.Writer) error {
+	for _, img := range b.Manifest.Images {
+		fmt.Fprintf(out, "Pushing %s\n", img.Name)
+		err := b.pushImage(ctx, c, img, target)
+		if err != nil {
+			return errors.Wrapf(err, "failed to push %s", img.Name)
+		}
+	}
+	index := repo.NewIndexFile()
+	for _, chart := range b.Manifest.Charts {
+		fmt.Fprintf(out, "Pushing chart %s version %s\n", chart.Name, chart.Version)
+		cv, err := b.pushChart(ctx, c, chart, target)
+		if err != nil {
+			return errors.Wrapf(err, "failed to push chart %s", chart.Name)
+		}
+		index.Entries[chart.Name] = append(index.Entries[chart.Name], cv)
+	}
+	index.SortEntries()
+	byt, err := yaml.Marshal(index)
+	if err != nil {
+		return err
+	}
+	err = pushFile(ctx, c, target+"/charts/index.yaml", byt)
+	if err != nil {
+		return errors.Wrap(err, "failed to push chart index")
+	}
+	for _, name := range b.Manifest.Metadata {
+		fmt.Fprintf(out, "Pushing %s metadata\n", name)
+		byt, err := b.ReadFile(metadataPath(name))
+		if err != nil {
+			return err
+		}
+		err = pushFile(ctx, c, target+"/"+metadataPath(name), byt)
+		if err != nil {
+			return errors.Wrapf(err, "failed to push %s metadata", name)
+		}
+	}
+	return nil
+}
+
+func (b *Bundle) pushImage(ctx context.Context, c *registry.Client, img Image, target string) error {
+	name, err := registry.Relocate(img.Name, target)
+	if err != nil {
+		return err
+	}
+	dst, err := registry.ParseReference(name)
+	if err != nil {
+		return err
+	}
+	err = b.pushManifest(ctx, c, dst, img.MediaType, img.Digest)
+	if err != nil {
+		return err
+	}
+	if dst.Tag == "" {
+		return nil
+	}
+	body, err := b.ReadFile(blobPath(img.Digest))
+	if err != nil {
+		return err
+	}
+	dst.Digest = ""
+	return c.PushManifest(ctx, dst, img.MediaType, body)
+}
+
+// pushManifest pushes a manifest by digest after everything it points to.
+func (b *Bundle) pushManifest(ctx context.Context, c *registry.Client, dst registry.Reference, mediaType string, dgst digest.Digest) error {
+	body, err := b.ReadFile(blobPath(dgst))
+	if err != nil {
+		return err
+	}
+	m := registry.Manifest{}
+	err = json.Unmarshal(body, &m)
+	if err != nil {
+		return err
+	}
+	for _, desc := range m.Manifests {
+		err = b.pushManifest(ctx, c, dst, desc.MediaType, desc.Digest)
+		if err != nil {
+			return err
+		}
+	}
+	if m.Config != nil {
+		err = b.pushBlob(ctx, c, dst, *m.Config)
+		if err != nil {
+			return err
+		}
+	}
+	for _, desc := range m.Layers {
+		if len(desc.URLs) > 0 {
+			continue
+		}
+		err = b.pushBlob(ctx, c, dst, desc)
+		if err != nil {
+			return err
+		}
+	}
+	dst.Tag = ""
+	dst.Digest = dgst
+	return c.PushManifest(ctx, dst, mediaType, body)
+}
+
+func (b *Bundle) pushBlob(ctx context.Context, c *registry.Client, dst registry.Reference, desc ocispec.Descriptor) error {
+	f, err := os.Open(b.path(blobPath(desc.Digest)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.PushBlob(ctx, dst, desc, f)
+}
+
+func (b *Bundle) pushChart(ctx context.Context, c *registry.Client, chart Chart, target string) (*repo.ChartVersion, error) {
+	data, err := b.ReadFile(chart.File)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := loader.LoadArchive(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	config, err := json.Marshal(ch.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := registry.ParseReference(fmt.Sprintf("%s/charts/%s:%s", target, chart.Name, chart.Version))
+	if err != nil {
+		return nil, err
+	}
+	err = c.PushArtifact(ctx, ref, registry.ChartConfigMediaType, config, registry.ChartLayerMediaType, data)
+	if err != nil {
+		return nil, err
+	}
+	return &repo.ChartVersion{
+		Metadata: ch.Metadata,
+		URLs:     []string{"oci://" + ref.String()},
+		Created:  time.Now(),
+		Digest:   digest.FromBytes(data).Encoded(),
+	}, nil
+}
+
+func pushFile(ctx context.Context, c *registry.Client, name string, data []byte) error {
+	ref, err := registry.ParseReference(name)
+	if err != nil {
+		return err
+	}
+	return c.PushArtifact(ctx, ref, registry.FileConfigMediaType, []byte("{}"), registry.FileLayerMediaType, data)
+}
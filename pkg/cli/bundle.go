@@ -0,0 +1,345 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/bundle"
+	"github.com/getupio-undistro/undistro/pkg/config"
+	undistrofs "github.com/getupio-undistro/undistro/pkg/fs"
+	"github.com/getupio-undistro/undistro/pkg/helm"
+	"github.com/getupio-undistro/undistro/pkg/registry"
+	"github.com/getupio-undistro/undistro/pkg/undistro"
+	"github.com/getupio-undistro/undistro/pkg/version"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/getter"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+type BundleCreateOptions struct {
+	genericclioptions.IOStreams
+	ConfigPath         string
+	Config             *config.Config
+	Output             string
+	KubernetesVersions []string
+	Charts             []string
+	Images             []string
+	MetadataURL        string
+	Registry           *registry.Client
+}
+
+func NewBundleCreateOptions(streams genericclioptions.IOStreams) *BundleCreateOptions {
+	return &BundleCreateOptions{
+		IOStreams:          streams,
+		Output:             fmt.Sprintf("undistro-bundle-%s.tar.gz", version.Get().GitVersion),
+		KubernetesVersions: []string{defaultK8sVersion(appv1alpha1.OpenStackFlavor.String()), defaultK8sVersion(appv1alpha1.EC2.String())},
+		MetadataURL:        undistro.MetadataURL,
+	}
+}
+
+func (o *BundleCreateOptions) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.Output, "output", "o", o.Output, "File the bundle is written to")
+	flags.StringSliceVar(&o.KubernetesVersions, "kubernetes-version", o.KubernetesVersions, "Kubernetes versions whose control plane images are added for the cluster templates")
+	flags.StringArrayVar(&o.Charts, "chart", o.Charts, "Chart of the UnDistro repository to add as name:version, e.g. calico:3.19.1 (can be repeated)")
+	flags.StringArrayVar(&o.Images, "image", o.Images, "Image to add besides the ones found in the charts (can be repeated)")
+	flags.StringVar(&o.MetadataURL, "metadata-url", o.MetadataURL, "URL the provider metadata is fetched from")
+}
+
+func (o *BundleCreateOptions) Complete(f *ConfigFlags, cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return cmdutil.UsageErrorf(cmd, "%s", "too many arguments")
+	}
+	o.ConfigPath = *f.ConfigFile
+	o.Registry = registry.NewClient()
+	return nil
+}
+
+func (o *BundleCreateOptions) Validate() error {
+	for _, c := range o.Charts {
+		if name, ver := splitChart(c); name == "" || ver == "" {
+			return errors.Errorf("invalid chart %q, expected name:version", c)
+		}
+	}
+	if o.Config != nil {
+		return nil
+	}
+	var err error
+	o.Config, err = config.Load(o.ConfigPath)
+	return err
+}
+
+func (o *BundleCreateOptions) RunCreate(ctx context.Context) error {
+	content := bundle.Content{
+		UnDistroVersion: version.Get().GitVersion,
+	}
+	archives, err := embeddedCharts()
+	if err != nil {
+		return err
+	}
+	for _, c := range o.Charts {
+		fmt.Fprintf(o.Out, "Downloading chart %s\n", c)
+		byt, err := downloadChart(splitChart(c))
+		if err != nil {
+			return errors.Wrapf(err, "failed to download chart %s", c)
+		}
+		archives = append(archives, byt)
+	}
+	images := make(map[string]bool)
+	for _, img := range o.Images {
+		images[img] = true
+	}
+	for _, data := range archives {
+		ch, err := loader.LoadArchive(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		values, err := o.Config.Values(ch.Name())
+		if err != nil {
+			return err
+		}
+		found, err := bundle.ChartImages(ch, values)
+		if err != nil {
+			return err
+		}
+		for _, img := range found {
+			images[img] = true
+		}
+		content.Charts = append(content.Charts, data)
+	}
+	templates, err := fs.Sub(undistrofs.FS, "clustertemplates")
+	if err != nil {
+		return err
+	}
+	found, err := bundle.TemplateImages(templates, o.KubernetesVersions)
+	if err != nil {
+		return err
+	}
+	for _, img := range found {
+		images[img] = true
+	}
+	for img := range images {
+		content.Images = append(content.Images, img)
+	}
+	sort.Strings(content.Images)
+	providers := make([]string, 0, len(providersInfo))
+	for name := range providersInfo {
+		providers = append(providers, strings.TrimPrefix(name, "undistro-"))
+	}
+	sort.Strings(providers)
+	content.Metadata, err = bundle.FetchMetadata(ctx, o.MetadataURL, providers...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "Pulling %d images\n", len(content.Images))
+	f, err := os.Create(o.Output)
+	if err != nil {
+		return err
+	}
+	m, err := bundle.Write(ctx, f, o.Registry, content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(o.Output)
+		return err
+	}
+	fmt.Fprintf(o.Out, "Bundle %s created with %d charts, %d images and metadata of %d providers\n", o.Output, len(m.Charts), len(m.Images), len(m.Metadata))
+	return nil
+}
+
+// embeddedCharts returns the chart archives shipped with the CLI.
+func embeddedCharts() ([][]byte, error) {
+	var archives [][]byte
+	err := fs.WalkDir(undistrofs.ChartFS, "chart", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		byt, err := fs.ReadFile(undistrofs.ChartFS, path)
+		if err != nil {
+			return err
+		}
+		archives = append(archives, byt)
+		return nil
+	})
+	return archives, err
+}
+
+func splitChart(s string) (string, string) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i+1:]
+}
+
+func downloadChart(name, ver string) ([]byte, error) {
+	providers := getter.Providers{
+		getter.Provider{
+			Schemes: []string{"http", "https"},
+			New:     getter.NewHTTPGetter,
+		},
+	}
+	repo, err := helm.NewChartRepository(undistro.DefaultRepo, providers, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = repo.DownloadIndex()
+	if err != nil {
+		return nil, err
+	}
+	cv, err := repo.Get(name, ver)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := repo.DownloadChart(cv)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type BundlePushOptions struct {
+	genericclioptions.IOStreams
+	File             string
+	Target           string
+	RegistryUsername string
+	RegistryPassword string
+	Registry         *registry.Client
+}
+
+func NewBundlePushOptions(streams genericclioptions.IOStreams) *BundlePushOptions {
+	return &BundlePushOptions{
+		IOStreams: streams,
+	}
+}
+
+func (o *BundlePushOptions) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.Target, "registry", o.Target, "Registry the bundle is pushed to, e.g. registry.local:5000")
+	flags.StringVar(&o.RegistryUsername, "registry-username", o.RegistryUsername, "Username of the registry")
+	flags.StringVar(&o.RegistryPassword, "registry-password", o.RegistryPassword, "Password of the registry")
+}
+
+func (o *BundlePushOptions) Complete(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return cmdutil.UsageErrorf(cmd, "%s", "the bundle file is required")
+	}
+	o.File = args[0]
+	o.Registry = registry.NewClient()
+	o.Registry.Username = o.RegistryUsername
+	o.Registry.Password = o.RegistryPassword
+	return nil
+}
+
+func (o *BundlePushOptions) Validate() error {
+	if o.Target == "" {
+		return errors.New("--registry is required")
+	}
+	o.Target = strings.TrimSuffix(o.Target, "/")
+	return nil
+}
+
+func (o *BundlePushOptions) RunPush(ctx context.Context) error {
+	b, err := bundle.Open(o.File)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	return pushBundle(ctx, o.Out, b, o.Registry, o.Target)
+}
+
+func pushBundle(ctx context.Context, out io.Writer, b *bundle.Bundle, c *registry.Client, target string) error {
+	err := b.Push(ctx, c, target, out)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Charts are available at %s\n", bundle.ChartRepository(target))
+	return nil
+}
+
+func NewCmdBundle(f *ConfigFlags, streams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Manage bundles for disconnected installations",
+		Long: LongDesc(`Manage bundles for disconnected installations.
+		A bundle is an archive with the charts, the images and the provider metadata UnDistro needs,
+		so UnDistro can be installed where public registries can't be reached.`),
+	}
+	cmd.AddCommand(NewCmdBundleCreate(f, streams))
+	cmd.AddCommand(NewCmdBundlePush(streams))
+	return cmd
+}
+
+func NewCmdBundleCreate(f *ConfigFlags, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewBundleCreateOptions(streams)
+	cmd := &cobra.Command{
+		Use:                   "create",
+		DisableFlagsInUseLine: true,
+		Short:                 "Create a bundle",
+		Long: LongDesc(`Create a bundle.
+		The bundle has every chart shipped with the CLI, the images referenced by those charts rendered
+		with the configuration file values, the control plane images of the cluster templates and the provider metadata.
+		Use --chart to add the charts installed in workload clusters, like the CNI.`),
+		Example: Examples(`
+		# Create a bundle
+		undistro bundle create
+		# Create a bundle with the configuration file and the workload cluster CNI
+		undistro --config undistro-config.yaml bundle create --chart calico:3.19.1 -o undistro.tar.gz
+		`),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.RunCreate(cmd.Context()))
+		},
+	}
+	o.AddFlags(cmd.Flags())
+	return cmd
+}
+
+func NewCmdBundlePush(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewBundlePushOptions(streams)
+	cmd := &cobra.Command{
+		Use:                   "push [bundle file]",
+		DisableFlagsInUseLine: true,
+		Short:                 "Push a bundle to a registry",
+		Long: LongDesc(`Push a bundle to a registry.
+		Images keep their repository paths in the registry, charts are stored under charts
+		and provider metadata under metadata. Use it to make new versions available before running undistro upgrade.`),
+		Example: Examples(`
+		# Push a bundle
+		undistro bundle push undistro.tar.gz --registry registry.local:5000
+		`),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.RunPush(cmd.Context()))
+		},
+	}
+	o.AddFlags(cmd.Flags())
+	return cmd
+}
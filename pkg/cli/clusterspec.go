@@ -0,0 +1,285 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/util"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// CompactClusterSpec is the short cluster specification accepted by create cluster --from-file.
+// It is expanded to the recommended spec of the infrastructure, the fields set here replace the recommended ones.
+type CompactClusterSpec struct {
+	Name       string `json:"name,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	Infra      string `json:"infra,omitempty"`
+	Flavor     string `json:"flavor,omitempty"`
+	Region     string `json:"region,omitempty"`
+	K8sVersion string `json:"k8sVersion,omitempty"`
+	SSHKey     string `json:"sshKey,omitempty"`
+	// Path of clouds.yaml, required by openstack.
+	CloudsFile   string                        `json:"openstackCloudsFile,omitempty"`
+	Addons       *bool                         `json:"addons,omitempty"`
+	EnableAuth   *bool                         `json:"enableAuth,omitempty"`
+	ControlPlane *appv1alpha1.ControlPlaneNode `json:"controlPlane,omitempty"`
+	Workers      []appv1alpha1.WorkerNode      `json:"workers,omitempty"`
+	// CIDR blocks allowed to reach the bastion, the bastion ingress rules are kept when set.
+	BastionCIDRs []string `json:"bastionCIDRs,omitempty"`
+	VPCCIDR      string   `json:"vpcCIDR,omitempty"`
+	MultiZone    bool     `json:"multiZone,omitempty"`
+}
+
+func loadCompactClusterSpec(path string) (*CompactClusterSpec, error) {
+	byt, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec := CompactClusterSpec{}
+	err = yaml.UnmarshalStrict(byt, &spec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid cluster spec %s", path)
+	}
+	return &spec, nil
+}
+
+// applySpec sets the options to the fields of the compact spec, the options given by flags
+// are kept for the fields not set in the spec.
+func (o *ClusterOptions) applySpec(spec *CompactClusterSpec) {
+	o.Spec = spec
+	fill := func(opt *string, v string) {
+		if v != "" {
+			*opt = v
+		}
+	}
+	fill(&o.ClusterName, spec.Name)
+	fill(&o.Namespace, spec.Namespace)
+	fill(&o.Infra, spec.Infra)
+	fill(&o.Flavor, spec.Flavor)
+	fill(&o.Region, spec.Region)
+	fill(&o.K8sVersion, spec.K8sVersion)
+	fill(&o.SshKeyName, spec.SSHKey)
+	fill(&o.CloudsFile, spec.CloudsFile)
+	if spec.Addons != nil {
+		o.Addons = *spec.Addons
+	}
+	if spec.EnableAuth != nil {
+		o.AuthEnabled = *spec.EnableAuth
+	}
+}
+
+// expandCluster applies the compact spec to the cluster rendered from the recommended spec
+// and runs the validation of the Cluster webhook, so invalid clusters are not submitted.
+func (o *ClusterOptions) expandCluster(ctx context.Context, objs []unstructured.Unstructured) error {
+	for i := range objs {
+		if objs[i].GroupVersionKind() != appv1alpha1.GroupVersion.WithKind("Cluster") {
+			continue
+		}
+		cl := appv1alpha1.Cluster{}
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(objs[i].Object, &cl)
+		if err != nil {
+			return err
+		}
+		if o.Spec != nil {
+			o.Spec.overlay(&cl)
+			u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&cl)
+			if err != nil {
+				return err
+			}
+			unstructured.RemoveNestedField(u, "status")
+			unstructured.RemoveNestedField(u, "metadata", "creationTimestamp")
+			objs[i].Object = u
+		}
+		err = o.validateCluster(ctx, cl.DeepCopy())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *CompactClusterSpec) overlay(cl *appv1alpha1.Cluster) {
+	if s.ControlPlane != nil && !cl.Spec.InfrastructureProvider.IsManaged() {
+		cl.Spec.ControlPlane = s.ControlPlane
+	}
+	if len(s.Workers) > 0 {
+		cl.Spec.Workers = s.Workers
+	}
+	if len(s.BastionCIDRs) > 0 {
+		enabled := true
+		cl.Spec.Bastion = &appv1alpha1.Bastion{
+			Enabled:           &enabled,
+			AllowedCIDRBlocks: s.BastionCIDRs,
+		}
+	}
+	if s.VPCCIDR != "" {
+		cl.Spec.Network.VPC.CIDRBlock = s.VPCCIDR
+	}
+	cl.Spec.Network.MultiZone = cl.Spec.Network.MultiZone || s.MultiZone
+}
+
+func (o *ClusterOptions) validateCluster(ctx context.Context, cl *appv1alpha1.Cluster) error {
+	appv1alpha1.SetClient(o.Client)
+	cl.Default()
+	err := cl.ValidateCreate()
+	if err != nil {
+		return err
+	}
+	allErrs, err := validateClusterMetadata(ctx, o.Client, cl)
+	if err != nil {
+		return err
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(appv1alpha1.GroupVersion.WithKind("Cluster").GroupKind(), cl.Name, allErrs)
+}
+
+// validateClusterMetadata checks the cluster against the provider metadata in the management cluster.
+// Checks are skipped when the metadata was not populated yet.
+func validateClusterMetadata(ctx context.Context, c client.Client, cl *appv1alpha1.Cluster) (field.ErrorList, error) {
+	var allErrs field.ErrorList
+	infra := cl.Spec.InfrastructureProvider
+	md, err := getClusterMetadata(ctx, c, infra.Name, infra.Flavor)
+	if err != nil {
+		return nil, err
+	}
+	versions := md.k8sVersions()
+	if len(versions) > 0 && !util.ContainsStringInSlice(versions, normalizeVersion(cl.Spec.KubernetesVersion)) {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("spec", "kubernetesVersion"), cl.Spec.KubernetesVersion, versions))
+	}
+	regions := md.regions()
+	if len(regions) > 0 && !util.ContainsStringInSlice(regions, infra.Region) {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("spec", "infrastructureProvider", "region"), infra.Region, regions))
+	}
+	machineTypes := md.machineTypes()
+	if len(machineTypes) == 0 {
+		return allErrs, nil
+	}
+	if cl.Spec.ControlPlane != nil && cl.Spec.ControlPlane.MachineType != "" && !util.ContainsStringInSlice(machineTypes, cl.Spec.ControlPlane.MachineType) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "controlPlane", "machineType"), cl.Spec.ControlPlane.MachineType, "unknown machine type"))
+	}
+	for i, w := range cl.Spec.Workers {
+		for _, t := range w.MachineTypes() {
+			if !util.ContainsStringInSlice(machineTypes, t) {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "workers").Index(i).Child("machineType"), t, "unknown machine type"))
+			}
+		}
+	}
+	return allErrs, nil
+}
+
+// clusterMetadata is the metadata of an infrastructure provider and flavor published in the management cluster.
+type clusterMetadata struct {
+	provider *metadatav1alpha1.Provider
+	flavors  []metadatav1alpha1.Flavor
+	flavor   *metadatav1alpha1.Flavor
+	machines []metadatav1alpha1.AWSMachine
+}
+
+func getClusterMetadata(ctx context.Context, c client.Client, infra, flavor string) (clusterMetadata, error) {
+	md := clusterMetadata{}
+	providers := metadatav1alpha1.ProviderList{}
+	err := c.List(ctx, &providers)
+	if err != nil {
+		return md, err
+	}
+	for i := range providers.Items {
+		if providers.Items[i].Name == infra {
+			md.provider = &providers.Items[i]
+		}
+	}
+	flavors := metadatav1alpha1.FlavorList{}
+	err = c.List(ctx, &flavors)
+	if err != nil {
+		return md, err
+	}
+	for i := range flavors.Items {
+		f := &flavors.Items[i]
+		if f.Spec.ProviderRef != nil && f.Spec.ProviderRef.Name != infra {
+			continue
+		}
+		md.flavors = append(md.flavors, *f)
+		if f.Name == flavor {
+			md.flavor = f
+		}
+	}
+	if infra == appv1alpha1.Amazon.String() {
+		machines := metadatav1alpha1.AWSMachineList{}
+		err = c.List(ctx, &machines)
+		if err != nil {
+			return md, err
+		}
+		md.machines = machines.Items
+	}
+	return md, nil
+}
+
+func (md clusterMetadata) flavorNames(infra string) []string {
+	if len(md.flavors) == 0 {
+		return appv1alpha1.InfrastructureProvider{Name: infra}.Flavors()
+	}
+	names := make([]string, len(md.flavors))
+	for i, f := range md.flavors {
+		names[i] = f.Name
+	}
+	return names
+}
+
+func (md clusterMetadata) k8sVersions() []string {
+	if md.flavor == nil {
+		return nil
+	}
+	versions := make([]string, len(md.flavor.Spec.SupportedK8sVersions))
+	for i, v := range md.flavor.Spec.SupportedK8sVersions {
+		versions[i] = normalizeVersion(v)
+	}
+	return versions
+}
+
+func (md clusterMetadata) regions() []string {
+	if md.provider == nil {
+		return nil
+	}
+	return md.provider.Status.RegionNames
+}
+
+func (md clusterMetadata) machineTypes() []string {
+	types := make([]string, len(md.machines))
+	for i, m := range md.machines {
+		types[i] = m.Spec.InstanceType
+	}
+	return types
+}
+
+// normalizeVersion adds the v prefix, some flavors list versions without it.
+func normalizeVersion(v string) string {
+	if v == "" || strings.HasPrefix(v, "v") {
+		return v
+	}
+	return "v" + v
+}
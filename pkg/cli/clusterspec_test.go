@@ -0,0 +1,211 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/fs"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	"github.com/getupio-undistro/undistro/pkg/template"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newClusterMetadataClient() client.Client {
+	ref := &corev1.ObjectReference{Name: "aws"}
+	objs := []client.Object{
+		&metadatav1alpha1.Provider{
+			ObjectMeta: metav1.ObjectMeta{Name: "aws", Namespace: "undistro-system"},
+			Spec:       metadatav1alpha1.ProviderSpec{Category: metadatav1alpha1.ProviderInfra},
+			Status:     metadatav1alpha1.ProviderStatus{RegionNames: []string{"us-east-1", "sa-east-1"}},
+		},
+		&metadatav1alpha1.Flavor{
+			ObjectMeta: metav1.ObjectMeta{Name: "ec2"},
+			Spec:       metadatav1alpha1.FlavorSpec{ProviderRef: ref, SupportedK8sVersions: []string{"v1.21.3", "1.22.2"}},
+		},
+		&metadatav1alpha1.Flavor{
+			ObjectMeta: metav1.ObjectMeta{Name: "eks"},
+			Spec:       metadatav1alpha1.FlavorSpec{ProviderRef: ref, SupportedK8sVersions: []string{"v1.21.2"}},
+		},
+		&metadatav1alpha1.AWSMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "m5.large"},
+			Spec:       metadatav1alpha1.AWSMachineSpec{InstanceType: "m5.large", ProviderRef: ref},
+		},
+		&metadatav1alpha1.AWSMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "t3.medium"},
+			Spec:       metadatav1alpha1.AWSMachineSpec{InstanceType: "t3.medium", ProviderRef: ref},
+		},
+	}
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objs...).Build()
+}
+
+func TestClusterOptions_expandCluster(t *testing.T) {
+	replicas := int32(4)
+	tests := []struct {
+		name string
+		spec CompactClusterSpec
+		err  string
+	}{
+		{
+			name: "valid",
+			spec: CompactClusterSpec{
+				K8sVersion: "v1.22.2",
+				Workers: []appv1alpha1.WorkerNode{
+					{Node: appv1alpha1.Node{Replicas: &replicas, MachineType: "t3.medium"}},
+				},
+				BastionCIDRs: []string{"200.1.2.0/24"},
+				VPCCIDR:      "10.1.0.0/16",
+			},
+		},
+		{
+			name: "unsupported version",
+			spec: CompactClusterSpec{K8sVersion: "v1.23.0"},
+			err:  "spec.kubernetesVersion",
+		},
+		{
+			name: "unsupported region",
+			spec: CompactClusterSpec{K8sVersion: "v1.22.2", Region: "mars-1"},
+			err:  "spec.infrastructureProvider.region",
+		},
+		{
+			name: "unknown machine type",
+			spec: CompactClusterSpec{
+				K8sVersion: "v1.22.2",
+				Workers: []appv1alpha1.WorkerNode{
+					{Node: appv1alpha1.Node{Replicas: &replicas, MachineType: "m5.huge"}},
+				},
+			},
+			err: "spec.workers[0].machineType",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewClusterOptions(genericclioptions.NewTestIOStreamsDiscard())
+			o.Client = newClusterMetadataClient()
+			o.ClusterName = "cool-cluster"
+			o.Namespace = "default"
+			o.Infra = "aws"
+			o.Flavor = "ec2"
+			o.SshKeyName = "cool-key"
+			o.Region = "us-east-1"
+			o.applySpec(&tt.spec)
+			vars := map[string]interface{}{
+				"Flavor":     o.Flavor,
+				"SSHKey":     o.SshKeyName,
+				"Namespace":  o.Namespace,
+				"Name":       o.ClusterName,
+				"K8sVersion": o.K8sVersion,
+				"Region":     o.Region,
+				"Addons":     false,
+			}
+			objs, err := template.GetObjs(fs.DefaultArchFS, "defaultarch", o.Infra, vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = o.expandCluster(context.Background(), objs)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expandCluster() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expandCluster() error = %v", err)
+			}
+			cl := appv1alpha1.Cluster{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(objs[0].Object, &cl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cl.Spec.Workers) != 1 || cl.Spec.Workers[0].MachineType != "t3.medium" {
+				t.Errorf("workers = %v, want the spec workers", cl.Spec.Workers)
+			}
+			if cl.Spec.Bastion == nil || len(cl.Spec.Bastion.AllowedCIDRBlocks) != 1 || cl.Spec.Network.VPC.CIDRBlock != "10.1.0.0/16" {
+				t.Errorf("bastion = %v, network = %v", cl.Spec.Bastion, cl.Spec.Network)
+			}
+			if cl.Labels != nil {
+				t.Errorf("labels = %v, the defaults of the webhook must not be submitted", cl.Labels)
+			}
+		})
+	}
+}
+
+func TestClusterOptions_askClusterSpec(t *testing.T) {
+	answers := []string{
+		"cool-cluster", // name
+		"",             // namespace
+		"",             // infra
+		"ec2",          // flavor
+		"sa-east-1",    // region
+		"v1.20.0",      // unsupported version is asked again
+		"",             // version
+		"cool-key",     // ssh key
+		"1",            // control plane replicas
+		"m5.huge",      // unknown machine type is asked again
+		"t3.medium",    // control plane machine type
+		"1",            // worker pools
+		"3",            // pool replicas
+		"",             // pool machine type
+		"",             // infra pool
+		"200.1.2.0/24, 200.1.3.0/24",
+		"",  // vpc
+		"n", // addons
+		"",  // auth
+	}
+	streams, in, out, _ := genericclioptions.NewTestIOStreams()
+	in.WriteString(strings.Join(answers, "\n") + "\n")
+	o := NewClusterOptions(streams)
+	o.Client = newClusterMetadataClient()
+	o.Namespace = "default"
+	spec, err := o.askClusterSpec(context.Background())
+	if err != nil {
+		t.Fatalf("askClusterSpec() error = %v\n%s", err, out.String())
+	}
+	if spec.Namespace != "default" || spec.Infra != "aws" || spec.Region != "sa-east-1" || spec.K8sVersion != "v1.22.2" {
+		t.Errorf("askClusterSpec() = %+v", spec)
+	}
+	if *spec.ControlPlane.Replicas != 1 || spec.ControlPlane.MachineType != "t3.medium" {
+		t.Errorf("control plane = %+v", spec.ControlPlane)
+	}
+	if len(spec.Workers) != 1 || *spec.Workers[0].Replicas != 3 || spec.Workers[0].MachineType != "m5.large" || spec.Workers[0].InfraNode {
+		t.Errorf("workers = %+v", spec.Workers)
+	}
+	if len(spec.BastionCIDRs) != 2 || *spec.Addons || *spec.EnableAuth {
+		t.Errorf("askClusterSpec() = %+v", spec)
+	}
+	if !bytes.Contains(out.Bytes(), []byte(`Unknown value "m5.huge"`)) {
+		t.Errorf("askClusterSpec() output = %s", out.String())
+	}
+}
+
+func TestPrompter_endOfInput(t *testing.T) {
+	p := newPrompter(strings.NewReader("cool-cluster\n"), &bytes.Buffer{})
+	name := p.ask("Cluster name", "", nil, true)
+	p.ask("Namespace", "", nil, true)
+	if name != "cool-cluster" || p.err == nil {
+		t.Errorf("ask() = %q, err = %v", name, p.err)
+	}
+}
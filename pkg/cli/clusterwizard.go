@@ -0,0 +1,249 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/cloud"
+	"github.com/getupio-undistro/undistro/pkg/util"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maxPromptChoices is the number of choices listed in a question, longer lists like machine types are only validated.
+const maxPromptChoices = 20
+
+// prompter asks questions until the answers are valid.
+// After an error the questions are skipped and the error is kept, so it is checked once at the end.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	err error
+}
+
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	return &prompter{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+func (p *prompter) readLine() (string, error) {
+	line, err := p.in.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if err == io.EOF && line == "" {
+		return "", errors.New("unexpected end of input")
+	}
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return line, nil
+}
+
+func (p *prompter) ask(question, def string, choices []string, required bool) string {
+	if p.err != nil {
+		return ""
+	}
+	for {
+		fmt.Fprint(p.out, question)
+		if len(choices) > 0 && len(choices) <= maxPromptChoices {
+			fmt.Fprintf(p.out, " [%s]", strings.Join(choices, ", "))
+		}
+		if def != "" {
+			fmt.Fprintf(p.out, " (%s)", def)
+		}
+		fmt.Fprint(p.out, ": ")
+		answer, err := p.readLine()
+		if err != nil {
+			p.err = err
+			return ""
+		}
+		if answer == "" {
+			answer = def
+		}
+		switch {
+		case answer == "" && required:
+			fmt.Fprintln(p.out, "A value is required")
+		case answer != "" && len(choices) > 0 && !util.ContainsStringInSlice(choices, answer):
+			fmt.Fprintf(p.out, "Unknown value %q\n", answer)
+		default:
+			return answer
+		}
+	}
+}
+
+func (p *prompter) askInt(question string, def int32) int32 {
+	for p.err == nil {
+		answer := p.ask(question, strconv.Itoa(int(def)), nil, true)
+		i, err := strconv.ParseInt(answer, 10, 32)
+		if err == nil && i >= 0 {
+			return int32(i)
+		}
+		if p.err == nil {
+			fmt.Fprintf(p.out, "Invalid number %q\n", answer)
+		}
+	}
+	return 0
+}
+
+func (p *prompter) askBool(question string, def bool) bool {
+	d := "n"
+	if def {
+		d = "y"
+	}
+	return p.ask(question, d, []string{"y", "n"}, true) == "y"
+}
+
+func (p *prompter) askList(question string) []string {
+	answer := p.ask(question, "", nil, false)
+	if answer == "" {
+		return nil
+	}
+	items := strings.Split(answer, ",")
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items
+}
+
+// askClusterSpec asks the cluster spec offering the versions, regions and machine types
+// published by the providers in the management cluster. The options are used as defaults.
+func (o *ClusterOptions) askClusterSpec(ctx context.Context) (*CompactClusterSpec, error) {
+	p := newPrompter(o.In, o.Out)
+	spec := &CompactClusterSpec{}
+	spec.Name = p.ask("Cluster name", o.ClusterName, nil, true)
+	spec.Namespace = p.ask("Namespace", o.Namespace, nil, true)
+	infras, err := infraProviderNames(ctx, o.Client)
+	if err != nil {
+		return nil, err
+	}
+	spec.Infra = p.ask("Infrastructure provider", orDefault(o.Infra, infras[0]), infras, true)
+	md, err := getClusterMetadata(ctx, o.Client, spec.Infra, "")
+	if err != nil {
+		return nil, err
+	}
+	flavors := md.flavorNames(spec.Infra)
+	spec.Flavor = p.ask("Flavor", orDefault(o.Flavor, flavors[0]), flavors, true)
+	md, err = getClusterMetadata(ctx, o.Client, spec.Infra, spec.Flavor)
+	if err != nil {
+		return nil, err
+	}
+	regions := md.regions()
+	spec.Region = p.ask("Region", orDefault(o.Region, cloud.DefaultRegion(spec.Infra)), regions, len(regions) > 0)
+	versions := md.k8sVersions()
+	defVersion := orDefault(o.K8sVersion, defaultK8sVersion(spec.Flavor))
+	if len(versions) > 0 && !util.ContainsStringInSlice(versions, defVersion) {
+		defVersion = versions[len(versions)-1]
+	}
+	spec.K8sVersion = p.ask("Kubernetes version", defVersion, versions, true)
+	infra := appv1alpha1.InfrastructureProvider{Name: spec.Infra, Flavor: spec.Flavor}
+	spec.SSHKey = p.ask("SSH key name", o.SshKeyName, nil, !infra.IsManaged())
+	if spec.Infra == appv1alpha1.OpenStack.String() {
+		spec.CloudsFile = p.ask("Path of clouds.yaml", o.CloudsFile, nil, true)
+	}
+	machineTypes := md.machineTypes()
+	defType := defaultMachineType(spec.Infra)
+	if !infra.IsManaged() {
+		replicas := p.askInt("Control plane replicas", 3)
+		spec.ControlPlane = &appv1alpha1.ControlPlaneNode{
+			Node: appv1alpha1.Node{
+				Replicas:    &replicas,
+				MachineType: p.ask("Control plane machine type", defType, machineTypes, true),
+			},
+		}
+	}
+	pools := p.askInt("Worker pools", 2)
+	for i := int32(1); i <= pools; i++ {
+		replicas := p.askInt(fmt.Sprintf("Pool %d replicas", i), 2)
+		w := appv1alpha1.WorkerNode{
+			Node: appv1alpha1.Node{
+				Replicas:    &replicas,
+				MachineType: p.ask(fmt.Sprintf("Pool %d machine type", i), defType, machineTypes, true),
+			},
+			InfraNode: p.askBool(fmt.Sprintf("Pool %d runs infrastructure workloads", i), pools > 1 && i == pools),
+		}
+		spec.Workers = append(spec.Workers, w)
+	}
+	if spec.SSHKey != "" {
+		spec.BastionCIDRs = p.askList("CIDR blocks allowed to reach the bastion, comma separated (none)")
+	}
+	spec.VPCCIDR = p.ask("VPC CIDR block (provider default)", "", nil, false)
+	addons := p.askBool("Install default policies and observability", o.Addons)
+	spec.Addons = &addons
+	auth := p.askBool("Enable authentication", o.AuthEnabled)
+	spec.EnableAuth = &auth
+	if p.err != nil {
+		return nil, p.err
+	}
+	return spec, nil
+}
+
+// infraProviderNames returns the infrastructure providers installed in the management cluster,
+// or the supported ones when there are none.
+func infraProviderNames(ctx context.Context, c client.Client) ([]string, error) {
+	providers := metadatav1alpha1.ProviderList{}
+	err := c.List(ctx, &providers)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for _, p := range providers.Items {
+		if p.Spec.Category == metadatav1alpha1.ProviderInfra {
+			names = append(names, p.Name)
+		}
+	}
+	if len(names) == 0 {
+		names = []string{appv1alpha1.Amazon.String(), appv1alpha1.OpenStack.String()}
+	}
+	return names, nil
+}
+
+func defaultK8sVersion(flavor string) string {
+	switch flavor {
+	case appv1alpha1.OpenStackFlavor.String():
+		return "v1.21.3"
+	case appv1alpha1.EC2.String():
+		return "v1.22.2"
+	case appv1alpha1.EKS.String():
+		return "v1.21.2"
+	}
+	return ""
+}
+
+func defaultMachineType(infra string) string {
+	switch infra {
+	case appv1alpha1.Amazon.String():
+		return "m5.large"
+	case appv1alpha1.OpenStack.String():
+		return "m1.medium"
+	}
+	return ""
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
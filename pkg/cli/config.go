@@ -18,15 +18,19 @@ package cli
 import (
 	"context"
 	"flag"
+	"fmt"
 
 	"github.com/getupio-undistro/meta"
+	"github.com/getupio-undistro/undistro/pkg/config"
 	"github.com/getupio-undistro/undistro/pkg/undistro"
 	"github.com/getupio-undistro/undistro/pkg/util"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	knet "k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/klog/v2"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -58,42 +62,6 @@ func stringptr(s string) *string {
 	return &s
 }
 
-func identityEnabled(cfg map[string]interface{}) bool {
-	undistroCfg, ok := cfg["undistro"].(map[string]interface{})
-	if !ok {
-		return false
-	}
-	identityCfg, ok := undistroCfg["identity"].(map[string]interface{})
-	if !ok {
-		return false
-	}
-	enabled, ok := identityCfg["enabled"].(bool)
-	if !ok {
-		return false
-	}
-	return enabled
-}
-
-func getIPFromConfig(cfg map[string]interface{}) string {
-	undistroCfg, ok := cfg["undistro"].(map[string]interface{})
-	if !ok {
-		return ""
-	}
-	ingressCfg, ok := undistroCfg["ingress"].(map[string]interface{})
-	if !ok {
-		return ""
-	}
-	hosts, ok := ingressCfg["hosts"].([]interface{})
-	if !ok {
-		return ""
-	}
-
-	if len(hosts) == 0 {
-		return ""
-	}
-	return hosts[0].(string)
-}
-
 func defaultValues(ctx context.Context, c client.Client, name string) map[string]interface{} {
 	localClus, _ := util.IsLocalCluster(ctx, c)
 	ip, _ := knet.ChooseHostInterface()
@@ -154,3 +122,94 @@ func defaultValues(ctx context.Context, c client.Client, name string) map[string
 	}
 	return make(map[string]interface{})
 }
+
+type ConfigValidateOptions struct {
+	genericclioptions.IOStreams
+	ConfigPath string
+}
+
+func NewConfigValidateOptions(streams genericclioptions.IOStreams) *ConfigValidateOptions {
+	return &ConfigValidateOptions{
+		IOStreams: streams,
+	}
+}
+
+func (o *ConfigValidateOptions) Complete(f *ConfigFlags, cmd *cobra.Command, args []string) error {
+	o.ConfigPath = *f.ConfigFile
+	switch len(args) {
+	case 0:
+		if o.ConfigPath == "" {
+			return cmdutil.UsageErrorf(cmd, "%s", "configuration file is required")
+		}
+	case 1:
+		o.ConfigPath = args[0]
+	default:
+		return cmdutil.UsageErrorf(cmd, "%s", "too many arguments")
+	}
+	return nil
+}
+
+func (o *ConfigValidateOptions) RunValidate() error {
+	_, err := config.Load(o.ConfigPath)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(o.IOStreams.Out, "Configuration file %s is valid\n", o.ConfigPath)
+	return nil
+}
+
+func NewCmdConfig(f *ConfigFlags, streams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "config",
+		DisableFlagsInUseLine: true,
+		Short:                 "Manage the UnDistro configuration file",
+		Long:                  LongDesc(`Manage the UnDistro configuration file used by install, move and setup commands.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(NewCmdConfigValidate(f, streams))
+	cmd.AddCommand(NewCmdConfigSchema(streams))
+	return cmd
+}
+
+func NewCmdConfigValidate(f *ConfigFlags, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewConfigValidateOptions(streams)
+	cmd := &cobra.Command{
+		Use:                   "validate [file]",
+		DisableFlagsInUseLine: true,
+		Short:                 "Validate a configuration file",
+		Long: LongDesc(`Validate a configuration file.
+		Unknown sections and unknown fields of the sections known by UnDistro are reported,
+		other fields are passed to the charts as values.`),
+		Example: Examples(`
+		# Validate a configuration file
+		undistro config validate undistro-config.yaml
+		# Validate the configuration file set in the config flag
+		undistro --config undistro-config.yaml config validate
+		`),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.RunValidate())
+		},
+	}
+	return cmd
+}
+
+func NewCmdConfigSchema(streams genericclioptions.IOStreams) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "schema",
+		DisableFlagsInUseLine: true,
+		Short:                 "Print the JSON schema of the configuration file",
+		Long:                  LongDesc(`Print the JSON schema of the configuration file, used by editors for completion and validation.`),
+		Example: Examples(`
+		undistro config schema > undistro-config.schema.json
+		`),
+		Run: func(cmd *cobra.Command, args []string) {
+			byt, err := config.Schema()
+			cmdutil.CheckErr(err)
+			fmt.Fprintln(streams.Out, string(byt))
+		},
+	}
+	return cmd
+}
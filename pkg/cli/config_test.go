@@ -16,13 +16,12 @@ limitations under the License.
 package cli
 
 import (
-	"os"
 	"testing"
 
-	"sigs.k8s.io/yaml"
+	"github.com/getupio-undistro/undistro/pkg/config"
 )
 
-func Test_getIPFromConfig(t *testing.T) {
+func Test_configIngressHost(t *testing.T) {
 	type args struct {
 		file string
 	}
@@ -41,19 +40,13 @@ func Test_getIPFromConfig(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			byt, err := os.ReadFile(tt.args.file)
+			cfg, err := config.Load(tt.args.file)
 			if err != nil {
 				t.Error(err)
 				return
 			}
-			cfg := make(map[string]interface{})
-			err = yaml.Unmarshal(byt, &cfg)
-			if err != nil {
-				t.Error(err)
-				return
-			}
-			if got := getIPFromConfig(cfg); got != tt.want {
-				t.Errorf("getIPFromConfig() = %v, want %v", got, tt.want)
+			if got := cfg.IngressHost(); got != tt.want {
+				t.Errorf("IngressHost() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -31,6 +31,8 @@ import (
 	"github.com/spf13/pflag"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/kubectl/pkg/cmd/create"
@@ -52,6 +54,10 @@ type ClusterOptions struct {
 	AuthEnabled  bool
 	Addons       bool
 	CloudsFile   string
+	Interactive  bool
+	FromFile     string
+	Spec         *CompactClusterSpec
+	Client       client.Client
 	rawClouds    string
 }
 
@@ -64,23 +70,46 @@ func NewClusterOptions(streams genericclioptions.IOStreams) *ClusterOptions {
 
 func (o *ClusterOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
 	var err error
+	if len(args) > 1 {
+		return errors.New("required 1 argument")
+	}
+	if len(args) == 1 && o.ClusterName == "" {
+		o.ClusterName = args[0]
+	}
+	if o.FromFile != "" {
+		spec, err := loadCompactClusterSpec(o.FromFile)
+		if err != nil {
+			return err
+		}
+		o.applySpec(spec)
+	}
 	if o.Namespace == "" {
 		o.Namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
 		if err != nil {
 			return err
 		}
 	}
-	if o.K8sVersion == "" {
-		switch o.Flavor {
-		case "openstack":
-			o.K8sVersion = "v1.21.3"
-		case "ec2":
-			o.K8sVersion = "v1.22.2"
-		case "eks":
-			o.K8sVersion = "v1.21.2"
+	cfg, err := f.ToRESTConfig()
+	if err != nil {
+		return errors.Errorf("unable to get kubeconfig: %v", err)
+	}
+	o.Client, err = client.New(cfg, client.Options{
+		Scheme: scheme.Scheme,
+	})
+	if err != nil {
+		return errors.Errorf("unable to create client: %v", err)
+	}
+	if o.Interactive {
+		spec, err := o.askClusterSpec(cmd.Context())
+		if err != nil {
+			return err
 		}
+		o.applySpec(spec)
+	}
+	if o.K8sVersion == "" {
+		o.K8sVersion = defaultK8sVersion(o.Flavor)
 	}
-	if len(args) != 1 {
+	if o.ClusterName == "" {
 		return errors.New("required 1 argument")
 	}
 	if o.Infra == "" {
@@ -89,14 +118,7 @@ func (o *ClusterOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []
 	if o.Flavor == "" {
 		return errors.New("required flag: flavor")
 	}
-	err = o.validateInfraFlavor()
-	if err != nil {
-		return err
-	}
-	if o.ClusterName == "" {
-		o.ClusterName = args[0]
-	}
-	return nil
+	return o.validateInfraFlavor()
 }
 
 func (o *ClusterOptions) validateInfraFlavor() error {
@@ -155,17 +177,9 @@ func (o *ClusterOptions) setRegionByInfra(ctx context.Context, c client.Client)
 	return nil
 }
 
-func (o *ClusterOptions) RunCreateCluster(f cmdutil.Factory, cmd *cobra.Command) error {
-	cfg, err := f.ToRESTConfig()
-	if err != nil {
-		return errors.Errorf("unable to get kubeconfig: %v", err)
-	}
-	c, err := client.New(cfg, client.Options{
-		Scheme: scheme.Scheme,
-	})
-	if err != nil {
-		return errors.Errorf("unable to create client: %v", err)
-	}
+func (o *ClusterOptions) RunCreateCluster(cmd *cobra.Command) error {
+	var err error
+	c := o.Client
 	if o.Region == "" {
 		err = o.setRegionByInfra(cmd.Context(), c)
 		if err != nil {
@@ -188,6 +202,11 @@ func (o *ClusterOptions) RunCreateCluster(f cmdutil.Factory, cmd *cobra.Command)
 	if err != nil {
 		return err
 	}
+	err = o.expandCluster(cmd.Context(), objs)
+	if err != nil {
+		return err
+	}
+	o.printEstimatedCost(objs)
 	var file *os.File
 	if o.GenerateFile {
 		file, err = os.Create(fmt.Sprintf("%s.yaml", o.ClusterName))
@@ -230,6 +249,39 @@ func (o *ClusterOptions) RunCreateCluster(f cmdutil.Factory, cmd *cobra.Command)
 	return nil
 }
 
+// printEstimatedCost prints the cost of the clusters in objs. The estimate is
+// informational, so a cluster that can't be estimated is only warned about.
+func (o *ClusterOptions) printEstimatedCost(objs []unstructured.Unstructured) {
+	for _, obj := range objs {
+		if obj.GroupVersionKind() != appv1alpha1.GroupVersion.WithKind("Cluster") {
+			continue
+		}
+		cl := appv1alpha1.Cluster{}
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &cl)
+		if err != nil {
+			fmt.Fprintf(o.ErrOut, "Warning: unable to estimate the cost of cluster %s: %v\n", obj.GetName(), err)
+			continue
+		}
+		cl.Default()
+		cost, err := cloud.EstimateCost(&cl)
+		if err != nil {
+			fmt.Fprintf(o.ErrOut, "Warning: unable to estimate the cost of cluster %s: %v\n", cl.Name, err)
+			continue
+		}
+		if cost == nil {
+			continue
+		}
+		fmt.Fprintf(o.Out, "Estimated cost of cluster %s: %s-%s %s/hour, %s-%s %s/month\n",
+			cl.Name,
+			cost.MinHourlyCost, cost.MaxHourlyCost, cost.Currency,
+			cost.MinMonthlyCost, cost.MaxMonthlyCost, cost.Currency,
+		)
+		if cost.PriceRegion != "" && cost.PriceRegion != cl.Spec.InfrastructureProvider.Region {
+			fmt.Fprintf(o.Out, "The estimate uses %s prices, prices in %s may differ\n", cost.PriceRegion, cl.Spec.InfrastructureProvider.Region)
+		}
+	}
+}
+
 func (o *ClusterOptions) AddFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&o.Infra, "infra", o.Infra, "the infrastructure where cluster will be created")
 	flags.StringVar(&o.Flavor, "flavor", o.Flavor, "the flavor used to create cluster in selected infrastructure")
@@ -239,6 +291,8 @@ func (o *ClusterOptions) AddFlags(flags *pflag.FlagSet) {
 	flags.BoolVar(&o.GenerateFile, "generate-file", o.GenerateFile, "Generate cluster YAML file")
 	flags.BoolVar(&o.AuthEnabled, "enable-auth", o.AuthEnabled, "Activate the Authnz management feature")
 	flags.StringVar(&o.CloudsFile, "openstack-clouds-file", o.CloudsFile, "Path of clouds.yaml (required by provider openstack)")
+	flags.BoolVarP(&o.Interactive, "interactive", "i", o.Interactive, "Ask the cluster spec offering the versions, regions and machine types of the providers")
+	flags.StringVar(&o.FromFile, "from-file", o.FromFile, "Path of a compact cluster spec expanded to the recommended spec")
 }
 
 func NewCmdCluster(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
@@ -247,13 +301,20 @@ func NewCmdCluster(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobr
 		Use:                   "cluster [cluster name]",
 		DisableFlagsInUseLine: true,
 		Short:                 "Create a cluster based on recommended spec",
-		Long:                  LongDesc(`Create a cluster based on spec recommend by Getup`),
+		Long: LongDesc(`Create a cluster based on spec recommend by Getup.
+		The control plane, worker pools, bastion and network can be set in a compact spec with --from-file
+		or answered in the interactive mode, which offers the versions, regions and machine types published
+		by the providers in the management cluster. The cluster is validated like the Cluster webhook does before it is submitted.`),
 		Example: Examples(`
 		undistro create cluster cool-cluster -n cool-namespace --infra aws --flavor ec2
+		# Answer the cluster spec
+		undistro create cluster -i
+		# Expand a compact spec and save the cluster YAML
+		undistro create cluster --from-file cool-cluster.yaml --generate-file
 		`),
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(o.Complete(f, cmd, args))
-			cmdutil.CheckErr(o.RunCreateCluster(f, cmd))
+			cmdutil.CheckErr(o.RunCreateCluster(cmd))
 		},
 	}
 	o.AddFlags(cmd.Flags())
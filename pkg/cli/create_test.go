@@ -0,0 +1,89 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+func TestClusterOptions_printEstimatedCost(t *testing.T) {
+	three := int32(3)
+	tests := []struct {
+		name        string
+		machineType string
+		region      string
+		wantOut     []string
+		wantErrOut  string
+	}{
+		{
+			name:        "priced in the cluster region",
+			machineType: "m5.large",
+			region:      "us-east-1",
+			wantOut:     []string{"Estimated cost of cluster cool-cluster: 0.3880-0.3880 USD/hour"},
+		},
+		{
+			name:        "priced in another region",
+			machineType: "m5.large",
+			region:      "sa-east-1",
+			wantOut:     []string{"Estimated cost of cluster cool-cluster", "uses us-east-1 prices, prices in sa-east-1 may differ"},
+		},
+		{
+			name:        "missing price",
+			machineType: "x9.huge",
+			region:      "us-east-1",
+			wantErrOut:  "Warning: unable to estimate the cost of cluster cool-cluster: no price for instance type x9.huge",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := &appv1alpha1.Cluster{
+				TypeMeta:   metav1.TypeMeta{APIVersion: appv1alpha1.GroupVersion.String(), Kind: "Cluster"},
+				ObjectMeta: metav1.ObjectMeta{Name: "cool-cluster", Namespace: "default"},
+				Spec: appv1alpha1.ClusterSpec{
+					InfrastructureProvider: appv1alpha1.InfrastructureProvider{Name: "aws", Flavor: "eks", Region: tt.region},
+					Workers: []appv1alpha1.WorkerNode{
+						{Node: appv1alpha1.Node{Replicas: &three, MachineType: tt.machineType}},
+					},
+				},
+			}
+			obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			streams, _, out, errOut := genericclioptions.NewTestIOStreams()
+			o := NewClusterOptions(streams)
+			o.printEstimatedCost([]unstructured.Unstructured{{Object: obj}})
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("out = %q, want %q", out.String(), want)
+				}
+			}
+			if tt.wantOut == nil && out.Len() > 0 {
+				t.Errorf("out = %q, want empty", out.String())
+			}
+			if !strings.Contains(errOut.String(), tt.wantErrOut) {
+				t.Errorf("errOut = %q, want %q", errOut.String(), tt.wantErrOut)
+			}
+		})
+	}
+}
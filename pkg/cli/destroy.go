@@ -16,10 +16,9 @@ limitations under the License.
 package cli
 
 import (
-	"context"
-	"fmt"
-	"os/exec"
+	"strings"
 
+	"github.com/getupio-undistro/undistro/pkg/local"
 	"github.com/getupio-undistro/undistro/pkg/undistro"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -30,13 +29,17 @@ import (
 
 type DestroyOptions struct {
 	genericclioptions.IOStreams
-	Provider string
-	Name     string
+	Provider  string
+	Name      string
+	Commander local.Commander
+
+	tool local.Tool
 }
 
 func NewDestroyOptions(streams genericclioptions.IOStreams) *DestroyOptions {
 	return &DestroyOptions{
 		IOStreams: streams,
+		Commander: local.Exec{},
 	}
 }
 
@@ -51,36 +54,19 @@ func (o *DestroyOptions) Complete(args []string) error {
 		}
 		o.Provider = args[0]
 	}
-	if o.Provider != "kind" {
-		return errors.Errorf("unable to destroy resources in provider %s, please use the provider UI or CLI directly", o.Provider)
+	if !local.IsTool(o.Provider) {
+		return errors.Errorf("unable to destroy resources in provider %s, please use the provider UI or CLI directly, supported tools are %s", o.Provider, strings.Join(local.Tools, ", "))
 	}
 	if o.Name == "" {
 		o.Name = undistro.LocalCluster
 	}
-	return nil
+	var err error
+	o.tool, err = local.NewTool(o.Provider, o.Commander)
+	return err
 }
 
 func (o *DestroyOptions) RunDestroy(cmd *cobra.Command) error {
-	c := o.getCmd(cmd.Context())
-	if c == nil {
-		return errors.Errorf("unknown provider: %s", o.Provider)
-	}
-	c.Stdin = o.IOStreams.In
-	c.Stderr = o.IOStreams.ErrOut
-	c.Stdout = o.IOStreams.Out
-	return c.Run()
-}
-
-func (o *DestroyOptions) getCmd(ctx context.Context) *exec.Cmd {
-	var script string
-	switch o.Provider {
-	case "kind":
-		script = fmt.Sprintf(undistro.KindCmdDestroy, o.Name)
-	default:
-		return nil
-	}
-	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", script)
-	return cmd
+	return o.tool.Delete(cmd.Context(), o.IOStreams, o.Name)
 }
 
 func NewCmdDestroy(streams genericclioptions.IOStreams) *cobra.Command {
@@ -92,6 +78,8 @@ func NewCmdDestroy(streams genericclioptions.IOStreams) *cobra.Command {
 		Long:                  LongDesc(`Destroy undistro environment`),
 		Example: Examples(`
 		undistro destroy kind --name undistro-cluster
+		undistro destroy k3d
+		undistro destroy minikube --name undistro-cluster
 		`),
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(o.Complete(args))
@@ -144,5 +144,8 @@ func NewCmdKubeconfig(f cmdutil.Factory, streams genericclioptions.IOStreams) *c
 func NewCmdGet(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
 	cmd := get.NewCmdGet("undistro", f, streams)
 	cmd.AddCommand(NewCmdKubeconfig(f, streams))
+	cmd.AddCommand(NewCmdViolations(f, streams))
+	cmd.AddCommand(NewCmdGetClusters(f, streams))
+	cmd.AddCommand(NewCmdGetHelmReleases(f, streams))
 	return cmd
 }
@@ -22,20 +22,24 @@ import (
 	"io"
 	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"time"
 
 	"github.com/getupio-undistro/meta"
 	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
 	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/bundle"
 	"github.com/getupio-undistro/undistro/pkg/capi"
 	"github.com/getupio-undistro/undistro/pkg/certmanager"
+	"github.com/getupio-undistro/undistro/pkg/config"
 	undistrofs "github.com/getupio-undistro/undistro/pkg/fs"
 	"github.com/getupio-undistro/undistro/pkg/helm"
 	"github.com/getupio-undistro/undistro/pkg/https"
 	"github.com/getupio-undistro/undistro/pkg/kube"
+	"github.com/getupio-undistro/undistro/pkg/local"
+	"github.com/getupio-undistro/undistro/pkg/registry"
 	"github.com/getupio-undistro/undistro/pkg/retry"
 	"github.com/getupio-undistro/undistro/pkg/scheme"
 	"github.com/getupio-undistro/undistro/pkg/undistro"
@@ -43,34 +47,40 @@ import (
 	"github.com/getupio-undistro/undistro/pkg/version"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
-	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/release"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	knet "k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	uexec "k8s.io/utils/exec"
 	"sigs.k8s.io/cluster-api/util/kubeconfig"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/yaml"
 )
 
-var (
-	getters = getter.Providers{
-		getter.Provider{
-			Schemes: []string{"http", "https"},
-			New:     getter.NewHTTPGetter,
-		},
-	}
-)
-
 const (
 	undistroRepo  = undistro.DefaultRepo
 	ns            = undistro.Namespace
 	cfgSecretName = "undistro-config"
+
+	installOutputText = "text"
+	installOutputJSON = "json"
+
+	// installTimeoutCode is the exit code of an install that timed out
+	// waiting for the core components, any other failure exits with 1.
+	installTimeoutCode = 2
+
+	// chartTimeout is how long helm waits for the resources of a core chart.
+	chartTimeout = 5 * time.Minute
 )
 
 var providersInfo = map[string]metadatav1alpha1.ProviderInfo{
@@ -104,18 +114,44 @@ var providersInfo = map[string]metadatav1alpha1.ProviderInfo{
 }
 
 type InstallOptions struct {
-	ConfigPath  string
-	ClusterName string
-	Remote      bool
+	ConfigPath       string
+	Config           *config.Config
+	ClusterName      string
+	Remote           bool
+	Bundle           string
+	Registry         string
+	RegistryUsername string
+	RegistryPassword string
+	Timeout          time.Duration
+	Wait             bool
+	Output           string
+	// Commander runs the CLIs of the local cluster tools
+	Commander local.Commander
 	genericclioptions.IOStreams
+
+	bundle *bundle.Bundle
 }
 
 func NewInstallOptions(streams genericclioptions.IOStreams) *InstallOptions {
 	return &InstallOptions{
+		Timeout:   30 * time.Minute,
+		Wait:      true,
+		Output:    installOutputText,
+		Commander: local.Exec{},
 		IOStreams: streams,
 	}
 }
 
+func (o *InstallOptions) AddFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&o.Bundle, "bundle", o.Bundle, "Bundle created by undistro bundle create to install from, requires --registry")
+	flags.StringVar(&o.Registry, "registry", o.Registry, "Registry the bundle is pushed to, e.g. registry.local:5000")
+	flags.StringVar(&o.RegistryUsername, "registry-username", o.RegistryUsername, "Username of the registry")
+	flags.StringVar(&o.RegistryPassword, "registry-password", o.RegistryPassword, "Password of the registry")
+	flags.DurationVar(&o.Timeout, "timeout", o.Timeout, "Time to install the core components and wait for them to be ready")
+	flags.BoolVar(&o.Wait, "wait", o.Wait, "Wait for the core components to be ready, if false the command returns once they are submitted")
+	flags.StringVarP(&o.Output, "output", "o", o.Output, "Output format, one of text or json")
+}
+
 func (o *InstallOptions) Complete(f *ConfigFlags, cmd *cobra.Command, args []string) error {
 	o.ConfigPath = *f.ConfigFile
 	switch len(args) {
@@ -132,72 +168,44 @@ func (o *InstallOptions) Complete(f *ConfigFlags, cmd *cobra.Command, args []str
 }
 
 func (o *InstallOptions) Validate() error {
-	if o.ConfigPath != "" {
-		_, err := os.Stat(o.ConfigPath)
-		if err != nil {
-			return err
-		}
+	if o.Bundle != "" && o.Registry == "" {
+		return errors.New("--registry is required to install from a bundle")
 	}
-	return nil
-}
-
-func (o *InstallOptions) installChart(restGetter genericclioptions.RESTClientGetter, chartName string, overrideValuesMap map[string]interface{}) (*appv1alpha1.HelmRelease, error) {
-	overrideValues := &apiextensionsv1.JSON{}
-	if overrideValuesMap == nil {
-		overrideValuesMap = make(map[string]interface{})
+	if o.Output != installOutputText && o.Output != installOutputJSON {
+		return errors.Errorf("unsupported output %q, use one of text or json", o.Output)
 	}
-	if len(overrideValuesMap) > 0 {
-		byt, err := json.Marshal(overrideValuesMap)
-		if err != nil {
-			return nil, err
-		}
-		overrideValues.Raw = byt
+	if o.Timeout <= 0 {
+		return errors.New("--timeout must be greater than zero")
 	}
-	var (
-		file  fs.File
-		found bool
-	)
-	err := fs.WalkDir(undistrofs.ChartFS, "chart", func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if found {
-			return nil
-		}
-
-		if d.IsDir() {
-			return nil
-		}
-		file, err = undistrofs.ChartFS.Open(path)
-		if err != nil {
-			return err
-		}
-		name := filepath.Base(path)
-		name = util.ChartNameByFile(name)
-		if name == chartName {
-			found = true
-		}
+	o.Registry = strings.TrimSuffix(o.Registry, "/")
+	if o.Config != nil {
 		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	chart, err := loader.LoadArchive(file)
-	if err != nil {
-		return nil, err
 	}
-	fmt.Fprintf(o.IOStreams.Out, "Installing %s version %s\n", chart.Name(), chart.AppVersion())
-	for _, dep := range chart.Dependencies() {
-		fmt.Fprintf(o.IOStreams.Out, "Installing %s version %s\n", dep.Name(), dep.AppVersion())
+	var err error
+	o.Config, err = config.Load(o.ConfigPath)
+	return err
+}
+
+// progress returns where progress messages are written,
+// standard error when the output is meant to be parsed.
+func (o *InstallOptions) progress() io.Writer {
+	if o.Output == installOutputJSON {
+		return o.IOStreams.ErrOut
 	}
-	wait := true
+	return o.IOStreams.Out
+}
+
+// helmRelease builds the HelmRelease of a core chart, waiting for
+// its resources to be ready only when the install waits.
+func (o *InstallOptions) helmRelease(chartName string, chart *chart.Chart, overrideValues *apiextensionsv1.JSON) appv1alpha1.HelmRelease {
+	wait := o.Wait
 	forceUpgrade := false
 	reset := false
 	reuse := false
 	history := 0
 	_, dev := os.LookupEnv("DEV_ENV")
 	label := strings.TrimPrefix(chartName, "undistro-")
-	hr := appv1alpha1.HelmRelease{
+	return appv1alpha1.HelmRelease{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: appv1alpha1.GroupVersion.String(),
 			Kind:       "HelmRelease",
@@ -213,11 +221,12 @@ func (o *InstallOptions) installChart(restGetter genericclioptions.RESTClientGet
 			Paused: dev,
 			Chart: appv1alpha1.ChartSource{
 				RepoChartSource: appv1alpha1.RepoChartSource{
-					RepoURL: undistroRepo,
+					RepoURL: o.chartRepository(),
 					Name:    chartName,
 					Version: chart.Metadata.Version,
 				},
 			},
+			ImageRegistry:   o.imageRegistry(),
 			ReleaseName:     chartName,
 			TargetNamespace: ns,
 			Values:          overrideValues,
@@ -227,11 +236,44 @@ func (o *InstallOptions) installChart(restGetter genericclioptions.RESTClientGet
 			ReuseValues:     &reuse,
 			ForceUpgrade:    &forceUpgrade,
 			Timeout: &metav1.Duration{
-				Duration: 5 * time.Minute,
+				Duration: chartTimeout,
 			},
 		},
 	}
-	err = retry.WithExponentialBackoff(retry.NewBackoff(), func() error {
+}
+
+// installTimeout returns how long helm waits for a chart installed by the CLI,
+// the chart timeout bounded by the install deadline.
+func installTimeout(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return chartTimeout
+	}
+	remaining := time.Until(deadline)
+	if remaining < chartTimeout {
+		return remaining
+	}
+	return chartTimeout
+}
+
+func (o *InstallOptions) installChart(ctx context.Context, restGetter genericclioptions.RESTClientGetter, chartName string, chart *chart.Chart, overrideValuesMap map[string]interface{}) (*appv1alpha1.HelmRelease, error) {
+	overrideValues := &apiextensionsv1.JSON{}
+	if overrideValuesMap == nil {
+		overrideValuesMap = make(map[string]interface{})
+	}
+	if len(overrideValuesMap) > 0 {
+		byt, err := json.Marshal(overrideValuesMap)
+		if err != nil {
+			return nil, err
+		}
+		overrideValues.Raw = byt
+	}
+	fmt.Fprintf(o.progress(), "Installing %s version %s\n", chart.Name(), chart.AppVersion())
+	for _, dep := range chart.Dependencies() {
+		fmt.Fprintf(o.progress(), "Installing %s version %s\n", dep.Name(), dep.AppVersion())
+	}
+	hr := o.helmRelease(chartName, chart, overrideValues)
+	err := retry.WithExponentialBackoffContext(ctx, retry.NewBackoff(), func() error {
 		runner, err := helm.NewRunner(restGetter, ns, log.Log)
 		if err != nil {
 			return err
@@ -244,14 +286,20 @@ func (o *InstallOptions) installChart(restGetter genericclioptions.RESTClientGet
 			}
 		}
 		chart.Values = util.MergeMaps(chart.Values, m)
-		rel, _ := runner.ObserveLastRelease(hr)
+		// the install is bounded by the deadline, the HelmRelease
+		// keeps the chart timeout for the controller
+		install := hr.DeepCopy()
+		install.Spec.Timeout = &metav1.Duration{
+			Duration: installTimeout(ctx),
+		}
+		rel, _ := runner.ObserveLastRelease(*install)
 		if rel == nil {
-			_, err = runner.Install(hr, chart, chart.Values)
+			_, err = runner.Install(*install, chart, chart.Values)
 			if err != nil {
 				return err
 			}
 		} else if rel.Info.Status == release.StatusDeployed {
-			_, err = runner.Upgrade(hr, chart, chart.Values)
+			_, err = runner.Upgrade(*install, chart, chart.Values)
 			if err != nil {
 				return err
 			}
@@ -261,7 +309,101 @@ func (o *InstallOptions) installChart(restGetter genericclioptions.RESTClientGet
 	return &hr, err
 }
 
-func (o *InstallOptions) checkEnabledList(ctx context.Context, c client.Client, cfg map[string]interface{}) []string {
+// loadChart loads a chart from the bundle being installed,
+// or from the charts shipped with the CLI.
+func (o *InstallOptions) loadChart(chartName string) (*chart.Chart, error) {
+	if o.bundle != nil {
+		for _, c := range o.bundle.Manifest.Charts {
+			if c.Name != chartName {
+				continue
+			}
+			byt, err := o.bundle.ReadFile(c.File)
+			if err != nil {
+				return nil, err
+			}
+			return loader.LoadArchive(bytes.NewReader(byt))
+		}
+		return nil, errors.Errorf("chart %s is not in the bundle", chartName)
+	}
+	var (
+		file  fs.File
+		found bool
+	)
+	err := fs.WalkDir(undistrofs.ChartFS, "chart", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if found {
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+		file, err = undistrofs.ChartFS.Open(path)
+		if err != nil {
+			return err
+		}
+		name := filepath.Base(path)
+		name = util.ChartNameByFile(name)
+		if name == chartName {
+			found = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loader.LoadArchive(file)
+}
+
+func (o *InstallOptions) chartRepository() string {
+	if o.bundle != nil {
+		return bundle.ChartRepository(o.Registry)
+	}
+	return undistroRepo
+}
+
+func (o *InstallOptions) imageRegistry() string {
+	if o.bundle != nil {
+		return o.Registry
+	}
+	return ""
+}
+
+// bundleValues points the controllers to the registry the bundle was pushed to.
+// Helm does not post render hooks, so the images of hooks are moved with values.
+func (o *InstallOptions) bundleValues(chartName string) map[string]interface{} {
+	if o.bundle == nil {
+		return nil
+	}
+	switch chartName {
+	case "undistro":
+		return map[string]interface{}{
+			"bundle": map[string]interface{}{
+				"chartRepository": bundle.ChartRepository(o.Registry),
+				"imageRegistry":   o.Registry,
+				"metadataURL":     bundle.MetadataURL(o.Registry),
+			},
+		}
+	case "ingress-nginx":
+		reg, _ := registry.Relocate("registry.undistro.io/k8s", o.Registry)
+		return map[string]interface{}{
+			"controller": map[string]interface{}{
+				"admissionWebhooks": map[string]interface{}{
+					"patch": map[string]interface{}{
+						"image": map[string]interface{}{
+							"registry": reg,
+						},
+					},
+				},
+			},
+		}
+	}
+	return nil
+}
+
+func (o *InstallOptions) checkEnabledList(ctx context.Context, c client.Client, cfg *config.Config) []string {
 	p := []string{"cert-manager", "cluster-api", "undistro", "ingress-nginx"}
 	localClus, err := util.IsLocalCluster(ctx, c)
 	if err != nil {
@@ -271,24 +413,8 @@ func (o *InstallOptions) checkEnabledList(ctx context.Context, c client.Client,
 		p = []string{"metallb", "cert-manager", "cluster-api", "undistro", "ingress-nginx"}
 	}
 
-	for k := range cfg {
-		_, ok := providersInfo[k]
-		if !ok {
-			continue
-		}
-		item, ok := cfg[k].(map[string]interface{})
-		if !ok {
-			continue
-		}
-		v, ok := item["enabled"]
-		if !ok {
-			continue
-		}
-		enabled, ok := v.(bool)
-		if !ok {
-			continue
-		}
-		if !enabled {
+	for k := range providersInfo {
+		if !cfg.Enabled(k) {
 			continue
 		}
 		if !util.ContainsStringInSlice(p, k) {
@@ -331,14 +457,16 @@ func (o *InstallOptions) applyMetadata(ctx context.Context, c client.Client, pro
 	return nil
 }
 
-func (o *InstallOptions) installCore(ctx context.Context, c client.Client, restGetter genericclioptions.RESTClientGetter, cfg map[string]interface{}, charts ...string) ([]appv1alpha1.HelmRelease, error) {
+// installCore installs the charts in order, returning the HelmReleases
+// installed or skipped before any error.
+func (o *InstallOptions) installCore(ctx context.Context, c client.Client, restGetter genericclioptions.RESTClientGetter, cfg *config.Config, charts ...string) ([]appv1alpha1.HelmRelease, map[string]bool, error) {
 	var depsMap = map[string]string{
 		"cert-manager": certmanager.TestResources,
 		"cluster-api":  capi.TestResources,
 		"undistro":     undistro.TestResources,
 	}
-	globalValue, hasGlobal := cfg["global"]
-	hrs := make([]appv1alpha1.HelmRelease, len(charts))
+	hrs := make([]appv1alpha1.HelmRelease, 0, len(charts))
+	skipped := make(map[string]bool)
 	n := corev1.Namespace{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
@@ -350,51 +478,108 @@ func (o *InstallOptions) installCore(ctx context.Context, c client.Client, restG
 	}
 	_, err := util.CreateOrUpdate(ctx, c, &n)
 	if err != nil {
-		return nil, err
+		return hrs, skipped, err
 	}
-	for i, chart := range charts {
-		values := defaultValues(ctx, c, chart)
-		cfgValues, ok := cfg[chart]
-		if !ok {
-			cfgValues = make(map[string]interface{})
+	for _, chartName := range charts {
+		cfgValues, err := cfg.Values(chartName)
+		if err != nil {
+			return hrs, skipped, err
 		}
-		vMap, ok := cfgValues.(map[string]interface{})
-		if ok {
-			if hasGlobal {
-				vMap["global"] = globalValue
-			}
-			values = util.MergeMaps(vMap, values)
+		values := util.MergeMaps(cfgValues, defaultValues(ctx, c, chartName))
+		values = util.MergeMaps(values, o.bundleValues(chartName))
+		ch, err := o.loadChart(chartName)
+		if err != nil {
+			return hrs, skipped, err
 		}
-		hr, err := o.installChart(restGetter, chart, values)
+		// a previous install may have been interrupted, the components
+		// it left ready are kept as they are
+		ready, err := readyRelease(ctx, c, chartName, ch.Metadata.Version, values)
 		if err != nil {
-			return nil, err
+			return hrs, skipped, err
 		}
-		testRes := depsMap[chart]
+		if ready != nil {
+			fmt.Fprintf(o.progress(), "%s version %s is already ready, skipping\n", chartName, ch.AppVersion())
+			hrs = append(hrs, *ready)
+			skipped[chartName] = true
+			continue
+		}
+		hr, err := o.installChart(ctx, restGetter, chartName, ch, values)
+		if err != nil {
+			return hrs, skipped, err
+		}
+		hrs = append(hrs, *hr)
+		testRes := depsMap[chartName]
 		if testRes != "" {
 			objs, err := util.ToUnstructured([]byte(testRes))
 			if err != nil {
-				return nil, err
+				return hrs, skipped, err
 			}
 			for _, o := range objs {
-				err = retry.WithExponentialBackoff(retry.NewBackoff(), func() error {
+				err = retry.WithExponentialBackoffContext(ctx, retry.NewBackoff(), func() error {
 					_, err = util.CreateOrUpdate(ctx, c, &o)
 					return err
 				})
 			}
 		}
-		if chart == "undistro" {
+		if chartName == "undistro" {
 			isLocal, ok := values["local"].(bool)
 			if ok && isLocal {
-				fmt.Fprintf(o.IOStreams.Out, "Installing local certificates\n")
+				fmt.Fprintf(o.progress(), "Installing local certificates\n")
 				err = https.InstallLocalCert(ctx, c)
 				if err != nil {
-					return nil, err
+					return hrs, skipped, err
 				}
 			}
 		}
-		hrs[i] = *hr
 	}
-	return hrs, nil
+	return hrs, skipped, nil
+}
+
+// readyRelease returns the HelmRelease left by a previous install when it
+// is Ready with the given chart version and values, nil otherwise.
+func readyRelease(ctx context.Context, c client.Client, name, version string, values map[string]interface{}) (*appv1alpha1.HelmRelease, error) {
+	hr := appv1alpha1.HelmRelease{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: ns, Name: name}, &hr)
+	if err != nil {
+		// the HelmRelease CRD doesn't exist before UnDistro is installed
+		if apierrors.IsNotFound(err) || apimeta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !releaseUpToDate(hr, version, values) {
+		return nil, nil
+	}
+	return &hr, nil
+}
+
+func releaseUpToDate(hr appv1alpha1.HelmRelease, version string, values map[string]interface{}) bool {
+	if hr.Spec.Chart.Version != version || hr.Status.LastAppliedRevision != version {
+		return false
+	}
+	if hr.Status.ObservedGeneration != hr.Generation || !meta.InReadyCondition(hr.Status.Conditions) {
+		return false
+	}
+	// compare decoded values, the API server doesn't keep the encoding
+	current := make(map[string]interface{})
+	if hr.Spec.Values != nil && len(hr.Spec.Values.Raw) > 0 {
+		err := json.Unmarshal(hr.Spec.Values.Raw, &current)
+		if err != nil {
+			return false
+		}
+	}
+	desired := make(map[string]interface{})
+	if len(values) > 0 {
+		byt, err := json.Marshal(values)
+		if err != nil {
+			return false
+		}
+		err = json.Unmarshal(byt, &desired)
+		if err != nil {
+			return false
+		}
+	}
+	return reflect.DeepEqual(current, desired)
 }
 
 func validateSupervisorConfig(ctx context.Context, c client.Client, localClus util.LocalClusterType, ingressAddr string, out io.Writer) error {
@@ -408,68 +593,40 @@ func validateSupervisorConfig(ctx context.Context, c client.Client, localClus ut
 		}
 		issuer = fmt.Sprintf(supervisorURL, ip.String())
 	}
-	// retrieve the config map for update
-	cmKey := client.ObjectKey{
-		Name:      "identity-config",
-		Namespace: undistro.Namespace,
-	}
-	cm := corev1.ConfigMap{}
-	err := c.Get(ctx, cmKey, &cm)
-	if err != nil {
-		return err
-	}
-	// edit configmap issuer address
-	f := cm.Data["federationdomain.yaml"]
-	fede := strings.ReplaceAll(f, "|", "")
-	fedeDomain := make(map[string]interface{})
-	byt := []byte(fede)
-	err = yaml.Unmarshal(byt, &fedeDomain)
-	if err != nil {
-		return err
-	}
-	fedeDomain["issuer"] = issuer
-	b, err := yaml.Marshal(fedeDomain)
+	// set the issuer address in the management cluster identity
+	identities := appv1alpha1.IdentityList{}
+	err := c.List(ctx, &identities)
 	if err != nil {
 		return err
 	}
-	cm.Data["federationdomain.yaml"] = string(b)
-	cm.TypeMeta = metav1.TypeMeta{
-		Kind:       "ConfigMap",
-		APIVersion: corev1.SchemeGroupVersion.String(),
-	}
-	// update the configmap
-	_, err = util.CreateOrUpdate(ctx, c, &cm)
-	if err != nil {
-		return err
+	for _, i := range identities.Items {
+		if !util.IsMgmtCluster(i.Spec.ClusterName) {
+			continue
+		}
+		if i.Spec.FederationDomain == nil {
+			i.Spec.FederationDomain = &appv1alpha1.FederationDomain{}
+		}
+		i.Spec.FederationDomain.Issuer = issuer
+		i.TypeMeta = metav1.TypeMeta{
+			Kind:       "Identity",
+			APIVersion: appv1alpha1.GroupVersion.String(),
+		}
+		_, err = util.CreateOrUpdate(ctx, c, &i)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func validateLocalClusConfig(ctx context.Context, c client.Client, out io.Writer, clusTyp util.LocalClusterType, name string) error {
-	// get local cluster container ip for metallb configuration
-	if name == "" {
-		name = "kind"
-	}
-	containerName := ""
-	switch clusTyp {
-	case util.Kind:
-		containerName = fmt.Sprintf("%s-control-plane", name)
-	case util.Minikube:
-		containerName = "minikube"
-	}
-	cmd := exec.Command(
-		"docker",
-		"inspect", "-f", "'{{range.NetworkSettings.Networks}}{{.IPAddress}}{{end}}'", containerName,
-	)
-	b := bytes.Buffer{}
-	cmd.Stdout = &b
-	err := cmd.Run()
+func validateLocalClusConfig(ctx context.Context, c client.Client, cmd local.Commander) error {
+	// get the address of the local cluster for metallb configuration
+	addr, err := local.Address(ctx, c, cmd)
 	if err != nil {
-		fmt.Fprintf(out, "error getting %s container IP %s\n", containerName, err.Error())
+		return err
 	}
-	containerIP := strings.TrimSpace(strings.ReplaceAll(b.String(), "'", ""))
-	// format container IP
-	containerIPFmt := fmt.Sprintf("%s-%s", containerIP, containerIP)
+	// format address range
+	addrRange := fmt.Sprintf("%s-%s", addr, addr)
 	// retrieve metallb configmap
 	cmKey := client.ObjectKey{
 		Name:      "metallb-config",
@@ -497,7 +654,10 @@ func validateLocalClusConfig(ctx context.Context, c client.Client, out io.Writer
 	if err != nil {
 		return err
 	}
-	ayaml.AddressPools[0].Addresses = []string{containerIPFmt}
+	if len(ayaml.AddressPools) == 0 {
+		return errors.Errorf("configmap %s has no address pools", cmKey)
+	}
+	ayaml.AddressPools[0].Addresses = []string{addrRange}
 	by, err := yaml.Marshal(ayaml)
 	if err != nil {
 		return err
@@ -512,27 +672,17 @@ func validateLocalClusConfig(ctx context.Context, c client.Client, out io.Writer
 	if err != nil {
 		return err
 	}
-
-	cmd = exec.Command(
-		"kubectl",
-		"-n", "undistro-system",
+	// restart metallb controller to load the new config
+	_, err = cmd.Output(ctx, "kubectl",
+		"-n", undistro.Namespace,
 		"rollout", "restart", "deployment", "metallb-controller",
 	)
-	err = cmd.Run()
-	if err != nil {
-		return err
-	}
-	// call undistro rollout to update metallb config
-	return nil
+	return err
 }
 
 func (o *InstallOptions) validateLocalEnvironment(ctx context.Context, c client.Client, clusTyp util.LocalClusterType) error {
-	fmt.Fprintln(o.IOStreams.Out, "Cluster is local. Preparing environment...")
-	err := validateLocalClusConfig(ctx, c, o.IOStreams.Out, clusTyp, util.ObjectKeyFromString(o.ClusterName).Name)
-	if err != nil {
-		return err
-	}
-	return nil
+	fmt.Fprintf(o.progress(), "Cluster is local (%s). Preparing environment...\n", clusTyp)
+	return validateLocalClusConfig(ctx, c, o.Commander)
 }
 
 func (o *InstallOptions) RunInstall(f cmdutil.Factory, cmd *cobra.Command) error {
@@ -564,103 +714,245 @@ func (o *InstallOptions) RunInstall(f cmdutil.Factory, cmd *cobra.Command) error
 			return err
 		}
 	}
-	cfg := make(map[string]interface{})
-	if o.ConfigPath != "" {
-		byt, err := os.ReadFile(o.ConfigPath)
+	if o.Config == nil {
+		o.Config, err = config.Load(o.ConfigPath)
+		if err != nil {
+			return err
+		}
+	}
+	if o.Bundle != "" {
+		o.bundle, err = bundle.Open(o.Bundle)
 		if err != nil {
 			return err
 		}
-		err = yaml.Unmarshal(byt, &cfg)
+		defer o.bundle.Close()
+		rc := registry.NewClient()
+		rc.Username = o.RegistryUsername
+		rc.Password = o.RegistryPassword
+		err = pushBundle(cmd.Context(), o.progress(), o.bundle, rc, o.Registry)
 		if err != nil {
 			return err
 		}
 	}
+	cfg := o.Config
 	providers := o.checkEnabledList(cmd.Context(), c, cfg)
 	if providers == nil {
 		return errors.New("is required to install at least one provider")
 	}
-	hrs, err := o.installCore(cmd.Context(), c, restGetter, cfg, providers...)
+	// the timeout bounds the whole install, not only the wait for the components
+	ctx, cancel := context.WithTimeout(cmd.Context(), o.Timeout)
+	defer cancel()
+	report, installErr := o.install(ctx, c, restGetter, cfg, providers)
+	if installErr != nil {
+		report.Error = installErr.Error()
+	}
+	err = o.printReport(report)
 	if err != nil {
 		return err
 	}
-	localClus, err := util.IsLocalCluster(cmd.Context(), c)
+	return installErr
+}
+
+// install installs the core components and waits for them. The report is
+// returned on every error, so it is printed before the command fails.
+func (o *InstallOptions) install(ctx context.Context, c client.Client, restGetter genericclioptions.RESTClientGetter, cfg *config.Config, providers []string) (InstallReport, error) {
+	hrs, skipped, err := o.installCore(ctx, c, restGetter, cfg, providers...)
 	if err != nil {
-		return err
+		return submittedReport(hrs, skipped), err
 	}
-	if identityEnabled(cfg) {
-		err = validateSupervisorConfig(cmd.Context(), c, localClus, getIPFromConfig(cfg), o.IOStreams.Out)
+	localClus, err := util.IsLocalCluster(ctx, c)
+	if err != nil {
+		return submittedReport(hrs, skipped), err
+	}
+	if cfg.IdentityEnabled() {
+		err = validateSupervisorConfig(ctx, c, localClus, cfg.IngressHost(), o.progress())
 		if err != nil {
-			return err
+			return submittedReport(hrs, skipped), err
 		}
 	}
 	if localClus != util.NonLocal {
-		err = o.validateLocalEnvironment(cmd.Context(), c, localClus)
+		err = o.validateLocalEnvironment(ctx, c, localClus)
 		if err != nil {
-			return err
+			return submittedReport(hrs, skipped), err
 		}
 	}
-	fmt.Fprintln(o.IOStreams.Out, "Applying metadata...")
-	err = o.applyMetadata(cmd.Context(), c, providers...)
+	fmt.Fprintln(o.progress(), "Applying metadata...")
+	err = o.applyMetadata(ctx, c, providers...)
 	if err != nil {
-		return err
+		return submittedReport(hrs, skipped), err
 	}
-	fmt.Fprint(o.IOStreams.Out, "Waiting all components to be ready")
-	deadline := time.Now().Add(30 * time.Minute)
-	ctx, cancel := context.WithDeadline(cmd.Context(), deadline)
-	defer cancel()
+	return o.waitCore(ctx, c, hrs, skipped)
+}
+
+func (o *InstallOptions) printReport(report InstallReport) error {
+	if o.Output == installOutputJSON {
+		byt, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.IOStreams.Out, string(byt))
+		return nil
+	}
+	return printInstallReport(o.IOStreams.Out, report, o.Wait)
+}
+
+// InstallComponent is the status of a core component at the end of an install.
+type InstallComponent struct {
+	Name    string                 `json:"name"`
+	Version string                 `json:"version"`
+	Skipped bool                   `json:"skipped,omitempty"`
+	Ready   metav1.ConditionStatus `json:"ready"`
+	Reason  string                 `json:"reason,omitempty"`
+	Message string                 `json:"message,omitempty"`
+}
+
+// InstallReport is the outcome of an install, printed with -o json.
+type InstallReport struct {
+	Ready      bool               `json:"ready"`
+	Error      string             `json:"error,omitempty"`
+	URL        string             `json:"url,omitempty"`
+	Components []InstallComponent `json:"components"`
+}
+
+func (r InstallReport) notReady() []string {
+	names := make([]string, 0)
+	for _, comp := range r.Components {
+		if comp.Ready != metav1.ConditionTrue {
+			names = append(names, comp.Name)
+		}
+	}
+	return names
+}
+
+// submittedReport is the report of an install that failed before waiting,
+// the skipped components are ready and the state of the others is unknown.
+func submittedReport(hrs []appv1alpha1.HelmRelease, skipped map[string]bool) InstallReport {
+	report := InstallReport{
+		Components: make([]InstallComponent, 0, len(hrs)),
+	}
+	for _, hr := range hrs {
+		comp := InstallComponent{
+			Name:    hr.Name,
+			Version: hr.Spec.Chart.Version,
+			Skipped: skipped[hr.Name],
+			Ready:   metav1.ConditionUnknown,
+		}
+		if comp.Skipped {
+			comp.Ready = metav1.ConditionTrue
+		}
+		report.Components = append(report.Components, comp)
+	}
+	return report
+}
+
+// waitCore submits the core HelmReleases and polls them until all of them are Ready,
+// or only once when not waiting. A timeout is returned with installTimeoutCode.
+func (o *InstallOptions) waitCore(ctx context.Context, c client.Client, hrs []appv1alpha1.HelmRelease, skipped map[string]bool) (InstallReport, error) {
+	report := InstallReport{}
+	for _, hr := range hrs {
+		if hr.Name != "undistro" || hr.Spec.Values == nil {
+			continue
+		}
+		type undistroCfg struct {
+			Ingress struct {
+				IpAddresses []string `json:"ipAddresses,omitempty"`
+				Hosts       []string `json:"hosts,omitempty"`
+			} `json:"ingress,omitempty"`
+		}
+		unCfg := undistroCfg{}
+		err := json.Unmarshal(hr.Spec.Values.Raw, &unCfg)
+		if err != nil {
+			continue
+		}
+		addrs := append(unCfg.Ingress.IpAddresses, unCfg.Ingress.Hosts...)
+		if len(addrs) > 0 {
+			report.URL = fmt.Sprintf("https://%s", addrs[0])
+		}
+	}
+	if o.Wait {
+		fmt.Fprintln(o.progress(), "Waiting all components to be ready")
+	}
+	announced := make(map[string]bool)
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
 	for {
+		report.Ready = true
+		report.Components = make([]InstallComponent, 0, len(hrs))
+		for _, hr := range hrs {
+			comp := InstallComponent{
+				Name:    hr.Name,
+				Version: hr.Spec.Chart.Version,
+				Skipped: skipped[hr.Name],
+				Ready:   metav1.ConditionUnknown,
+			}
+			comp.Reason, comp.Message = o.observeRelease(ctx, c, hr, comp.Skipped, &comp.Ready)
+			if comp.Ready != metav1.ConditionTrue {
+				report.Ready = false
+			} else if o.Wait && !announced[hr.Name] {
+				fmt.Fprintf(o.progress(), "%s is ready\n", hr.Name)
+				announced[hr.Name] = true
+			}
+			report.Components = append(report.Components, comp)
+		}
+		if report.Ready || !o.Wait {
+			return report, nil
+		}
 		select {
 		case <-ctx.Done():
-			fmt.Fprint(o.ErrOut, "\n\n")
-			return ctx.Err()
-		default:
-			ready := true
-			var addrs []string
-			for _, hr := range hrs {
-				if hr.Name == "undistro" {
-					type undistroCfg struct {
-						Ingress struct {
-							IpAddresses []string `json:"ipAddresses,omitempty"`
-							Hosts       []string `json:"hosts,omitempty"`
-						} `json:"ingress,omitempty"`
-					}
-					unCfg := undistroCfg{}
-					err = json.Unmarshal(hr.Spec.Values.Raw, &unCfg)
-					if err == nil {
-						addrs = append(unCfg.Ingress.IpAddresses, unCfg.Ingress.Hosts...)
-					}
-				}
-				_, err = util.CreateOrUpdate(ctx, c, &hr)
-				if err != nil {
-					ready = false
-					continue
-				}
-				gettedHr := appv1alpha1.HelmRelease{}
-				key := client.ObjectKeyFromObject(&hr)
-				err = c.Get(ctx, key, &gettedHr)
-				if err != nil {
-					ready = false
-					continue
-				}
-				if !meta.InReadyCondition(gettedHr.Status.Conditions) {
-					ready = false
-					continue
-				}
+			if ctx.Err() != context.DeadlineExceeded {
+				return report, ctx.Err()
 			}
-			if ready {
-				fmt.Fprintln(o.IOStreams.Out, "\n\nManagement cluster is ready to use.")
-				if len(addrs) > 0 {
-					guiUrl := fmt.Sprintf("https://%s", addrs[0])
-					fmt.Fprintf(o.IOStreams.Out, "UI is available at %s\n", guiUrl)
-				}
-				return nil
+			return report, uexec.CodeExitError{
+				Err:  errors.Errorf("timed out after %s waiting for %s", o.Timeout, strings.Join(report.notReady(), ", ")),
+				Code: installTimeoutCode,
 			}
-			fmt.Fprint(o.IOStreams.Out, ".")
-			<-time.After(15 * time.Second)
+		case <-ticker.C:
 		}
 	}
 }
 
+// observeRelease applies the HelmRelease unless it was skipped and
+// reads its Ready condition, returning the condition reason and message.
+func (o *InstallOptions) observeRelease(ctx context.Context, c client.Client, hr appv1alpha1.HelmRelease, skipped bool, status *metav1.ConditionStatus) (string, string) {
+	if !skipped {
+		_, err := util.CreateOrUpdate(ctx, c, &hr)
+		if err != nil {
+			return "", err.Error()
+		}
+	}
+	current := appv1alpha1.HelmRelease{}
+	err := c.Get(ctx, client.ObjectKeyFromObject(&hr), &current)
+	if err != nil {
+		return "", err.Error()
+	}
+	cond := apimeta.FindStatusCondition(current.Status.Conditions, meta.ReadyCondition)
+	if cond == nil {
+		return "", ""
+	}
+	*status = cond.Status
+	return cond.Reason, cond.Message
+}
+
+func printInstallReport(out io.Writer, report InstallReport, wait bool) error {
+	if report.Ready {
+		fmt.Fprintln(out, "\nManagement cluster is ready to use.")
+		if report.URL != "" {
+			fmt.Fprintf(out, "UI is available at %s\n", report.URL)
+		}
+		return nil
+	}
+	if !wait {
+		fmt.Fprintln(out, "\nCore components submitted, run undistro status to follow them.")
+	}
+	fmt.Fprintln(out)
+	w := printers.GetNewTabWriter(out)
+	fmt.Fprintln(w, "NAME\tVERSION\tREADY\tREASON\tMESSAGE")
+	for _, comp := range report.Components {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", comp.Name, comp.Version, comp.Ready, orNone(comp.Reason), orNone(comp.Message))
+	}
+	return w.Flush()
+}
+
 func NewCmdInstall(f *ConfigFlags, streams genericclioptions.IOStreams) *cobra.Command {
 	o := NewInstallOptions(streams)
 	cmd := &cobra.Command{
@@ -669,7 +961,11 @@ func NewCmdInstall(f *ConfigFlags, streams genericclioptions.IOStreams) *cobra.C
 		Short:                 "Install UnDistro",
 		Long: LongDesc(`Install UnDistro.
 		If cluster argument exists UnDistro will be installed in this remote cluster.
-		If config file exists UnDistro will be installed using file's configurations`),
+		If config file exists UnDistro will be installed using file's configurations.
+		Core components already ready with the same version and values are skipped,
+		so an interrupted install can be run again.
+		Exits with 0 when UnDistro is ready, or submitted with --wait=false,
+		2 when timed out waiting for the core components and 1 on any other error`),
 		Example: Examples(`
 		# Install UnDistro in local cluster
 		undistro install
@@ -677,15 +973,19 @@ func NewCmdInstall(f *ConfigFlags, streams genericclioptions.IOStreams) *cobra.C
 		undistro install undistro-production/cool-product-cluster
 		# Install UnDistro with configuration file
 		undistro --config undistro-config.yaml install undistro-production/cool-product-cluster
+		# Install UnDistro from a bundle in a disconnected site
+		undistro install --bundle undistro.tar.gz --registry registry.local:5000
+		# Install UnDistro in a pipeline, waiting up to 20 minutes and reporting components as JSON
+		undistro install --timeout 20m -o json
+		# Install UnDistro without waiting for the components to be ready
+		undistro install --wait=false
 		`),
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(o.Complete(f, cmd, args))
 			cmdutil.CheckErr(o.Validate())
-			err := o.RunInstall(cmdutil.NewFactory(f), cmd)
-			if err != nil {
-				fmt.Fprintf(o.ErrOut, "error: %v\n", err)
-			}
+			cmdutil.CheckErr(o.RunInstall(cmdutil.NewFactory(f), cmd))
 		},
 	}
+	o.AddFlags(cmd.Flags())
 	return cmd
 }
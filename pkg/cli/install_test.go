@@ -0,0 +1,231 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	"helm.sh/helm/v3/pkg/chart"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	uexec "k8s.io/utils/exec"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func coreRelease(name, version string, values string, conditions []metav1.Condition) *appv1alpha1.HelmRelease {
+	hr := &appv1alpha1.HelmRelease{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appv1alpha1.GroupVersion.String(),
+			Kind:       "HelmRelease",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+		Spec: appv1alpha1.HelmReleaseSpec{
+			Chart: appv1alpha1.ChartSource{
+				RepoChartSource: appv1alpha1.RepoChartSource{
+					Name:    name,
+					Version: version,
+				},
+			},
+		},
+		Status: appv1alpha1.HelmReleaseStatus{
+			Conditions:          conditions,
+			LastAppliedRevision: version,
+		},
+	}
+	if values != "" {
+		hr.Spec.Values = &apiextensionsv1.JSON{Raw: []byte(values)}
+	}
+	return hr
+}
+
+func TestReadyRelease(t *testing.T) {
+	now := time.Now()
+	ready := readyCondition(metav1.ConditionTrue, "ReconciliationSucceeded", "Release reconciliation succeeded", now)
+	values := map[string]interface{}{
+		"installCRDs": true,
+		"replicas":    2,
+	}
+	tests := []struct {
+		name    string
+		hr      *appv1alpha1.HelmRelease
+		version string
+		want    bool
+	}{
+		{
+			name:    "not installed",
+			version: "1.5.3",
+		},
+		{
+			name:    "ready with the same version and values",
+			hr:      coreRelease("cert-manager", "1.5.3", `{"replicas":2,"installCRDs":true}`, ready),
+			version: "1.5.3",
+			want:    true,
+		},
+		{
+			name:    "ready with another version",
+			hr:      coreRelease("cert-manager", "1.5.2", `{"installCRDs":true,"replicas":2}`, ready),
+			version: "1.5.3",
+		},
+		{
+			name:    "ready with other values",
+			hr:      coreRelease("cert-manager", "1.5.3", `{"installCRDs":false,"replicas":2}`, ready),
+			version: "1.5.3",
+		},
+		{
+			name:    "not ready",
+			hr:      coreRelease("cert-manager", "1.5.3", `{"installCRDs":true,"replicas":2}`, readyCondition(metav1.ConditionFalse, "InstallFailed", "timed out", now)),
+			version: "1.5.3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(scheme.Scheme)
+			if tt.hr != nil {
+				builder = builder.WithObjects(tt.hr)
+			}
+			got, err := readyRelease(context.Background(), builder.Build(), "cert-manager", tt.version, values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got != nil) != tt.want {
+				t.Errorf("readyRelease() = %v, want ready %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallOptions_waitCore(t *testing.T) {
+	now := time.Now()
+	certManager := coreRelease("cert-manager", "1.5.3", "", readyCondition(metav1.ConditionTrue, "ReconciliationSucceeded", "Release reconciliation succeeded", now))
+	undistroRelease := coreRelease("undistro", "0.37.0", `{"ingress":{"hosts":["undistro.local"]}}`, nil)
+	hrs := []appv1alpha1.HelmRelease{*certManager, *undistroRelease}
+	skipped := map[string]bool{"cert-manager": true}
+	tests := []struct {
+		name     string
+		wait     bool
+		wantCode int
+	}{
+		{
+			name: "no wait",
+		},
+		{
+			name:     "timeout",
+			wait:     true,
+			wantCode: installTimeoutCode,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(certManager).Build()
+			o := NewInstallOptions(genericclioptions.NewTestIOStreamsDiscard())
+			o.Wait = tt.wait
+			o.Timeout = 10 * time.Millisecond
+			ctx, cancel := context.WithTimeout(context.Background(), o.Timeout)
+			defer cancel()
+			report, err := o.waitCore(ctx, c, hrs, skipped)
+			var exitErr uexec.CodeExitError
+			switch {
+			case tt.wantCode == 0 && err != nil:
+				t.Fatalf("waitCore() error = %v", err)
+			case tt.wantCode != 0 && (!errors.As(err, &exitErr) || exitErr.Code != tt.wantCode):
+				t.Fatalf("waitCore() error = %v, want exit code %d", err, tt.wantCode)
+			}
+			if report.Ready {
+				t.Error("report is ready, undistro is not")
+			}
+			if report.URL != "https://undistro.local" {
+				t.Errorf("URL = %q", report.URL)
+			}
+			if len(report.Components) != 2 || !report.Components[0].Skipped || report.Components[0].Ready != metav1.ConditionTrue {
+				t.Errorf("components = %+v", report.Components)
+			}
+			var hr appv1alpha1.HelmRelease
+			err = c.Get(context.Background(), client.ObjectKeyFromObject(undistroRelease), &hr)
+			if err != nil {
+				t.Errorf("undistro HelmRelease was not submitted: %v", err)
+			}
+		})
+	}
+}
+
+func TestInstallOptions_helmRelease(t *testing.T) {
+	ch := &chart.Chart{Metadata: &chart.Metadata{Name: "undistro", Version: "0.37.0"}}
+	tests := []struct {
+		name string
+		wait bool
+	}{
+		{name: "wait", wait: true},
+		{name: "no wait"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewInstallOptions(genericclioptions.NewTestIOStreamsDiscard())
+			o.Wait = tt.wait
+			hr := o.helmRelease("undistro", ch, nil)
+			if hr.Spec.Wait == nil || *hr.Spec.Wait != tt.wait {
+				t.Errorf("Spec.Wait = %v, want %v", hr.Spec.Wait, tt.wait)
+			}
+			if hr.Spec.Timeout.Duration != chartTimeout {
+				t.Errorf("Spec.Timeout = %s, want %s", hr.Spec.Timeout.Duration, chartTimeout)
+			}
+		})
+	}
+}
+
+func TestInstallTimeout(t *testing.T) {
+	if got := installTimeout(context.Background()); got != chartTimeout {
+		t.Errorf("installTimeout() without deadline = %s, want %s", got, chartTimeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if got := installTimeout(ctx); got > time.Minute {
+		t.Errorf("installTimeout() = %s, want at most the remaining minute", got)
+	}
+}
+
+func TestInstallOptions_printReportOnError(t *testing.T) {
+	hrs := []appv1alpha1.HelmRelease{
+		*coreRelease("cert-manager", "1.5.3", "", nil),
+		*coreRelease("cluster-api", "0.4.4", "", nil),
+	}
+	report := submittedReport(hrs, map[string]bool{"cert-manager": true})
+	report.Error = "applying metadata: forbidden"
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewInstallOptions(streams)
+	o.Output = installOutputJSON
+	err := o.printReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := InstallReport{}
+	err = json.Unmarshal(out.Bytes(), &got)
+	if err != nil {
+		t.Fatalf("output is not a JSON report: %v\n%s", err, out.String())
+	}
+	if got.Ready || got.Error != report.Error {
+		t.Errorf("report = %+v, want not ready with error %q", got, report.Error)
+	}
+	if len(got.Components) != 2 || got.Components[0].Ready != metav1.ConditionTrue || got.Components[1].Ready != metav1.ConditionUnknown {
+		t.Errorf("components = %+v", got.Components)
+	}
+}
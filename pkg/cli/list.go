@@ -0,0 +1,362 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/getupio-undistro/meta"
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ListOptions lists UnDistro objects as a table or with the kubectl printers.
+type ListOptions struct {
+	genericclioptions.IOStreams
+	PrintFlags    *genericclioptions.PrintFlags
+	Namespace     string
+	AllNamespaces bool
+	LabelSelector string
+	Names         []string
+	Client        client.Client
+}
+
+func NewListOptions(streams genericclioptions.IOStreams) *ListOptions {
+	return &ListOptions{
+		IOStreams:  streams,
+		PrintFlags: genericclioptions.NewPrintFlags("").WithTypeSetter(scheme.Scheme),
+	}
+}
+
+func (o *ListOptions) AddFlags(cmd *cobra.Command) {
+	o.PrintFlags.AddFlags(cmd)
+	cmd.Flags().BoolVarP(&o.AllNamespaces, "all-namespaces", "A", o.AllNamespaces, "List the objects across all namespaces")
+	cmd.Flags().StringVarP(&o.LabelSelector, "selector", "l", o.LabelSelector, "Selector (label query) to filter on, e.g. -l key1=value1,key2=value2")
+}
+
+func (o *ListOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	var err error
+	o.Namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return err
+	}
+	o.Names = args
+	cfg, err := f.ToRESTConfig()
+	if err != nil {
+		return errors.Errorf("unable to get config: %v", err)
+	}
+	o.Client, err = client.New(cfg, client.Options{
+		Scheme: scheme.Scheme,
+	})
+	if err != nil {
+		return errors.Errorf("unable to create client: %v", err)
+	}
+	return nil
+}
+
+func (o *ListOptions) Validate() error {
+	if o.AllNamespaces && len(o.Names) > 0 {
+		return errors.New("names can't be used with --all-namespaces")
+	}
+	_, err := labels.Parse(o.LabelSelector)
+	return err
+}
+
+func (o *ListOptions) outputFormat() string {
+	if o.PrintFlags.OutputFormat == nil {
+		return ""
+	}
+	return *o.PrintFlags.OutputFormat
+}
+
+// list lists the objects selected by the options in the list and keeps only the named ones.
+func (o *ListOptions) list(ctx context.Context, list client.ObjectList, resource string) error {
+	opts := []client.ListOption{}
+	if !o.AllNamespaces {
+		opts = append(opts, client.InNamespace(o.Namespace))
+	}
+	if o.LabelSelector != "" {
+		selector, err := labels.Parse(o.LabelSelector)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, client.MatchingLabelsSelector{Selector: selector})
+	}
+	err := o.Client.List(ctx, list, opts...)
+	if err != nil {
+		return err
+	}
+	if len(o.Names) == 0 {
+		return nil
+	}
+	items, err := apimeta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	found := make([]runtime.Object, 0, len(o.Names))
+	for _, name := range o.Names {
+		var obj runtime.Object
+		for _, item := range items {
+			if item.(client.Object).GetName() == name {
+				obj = item
+				break
+			}
+		}
+		if obj == nil {
+			return errors.Errorf("%s %q not found in namespace %s", resource, name, o.Namespace)
+		}
+		found = append(found, obj)
+	}
+	return apimeta.SetList(list, found)
+}
+
+// print prints the list with the kubectl printers, or with printTable when no output format or wide is set.
+func (o *ListOptions) print(list client.ObjectList, printTable func(w io.Writer, wide bool) error) error {
+	switch o.outputFormat() {
+	case "", "wide":
+		if apimeta.LenList(list) == 0 {
+			if o.AllNamespaces {
+				fmt.Fprintln(o.IOStreams.ErrOut, "No resources found")
+				return nil
+			}
+			fmt.Fprintf(o.IOStreams.ErrOut, "No resources found in %s namespace.\n", o.Namespace)
+			return nil
+		}
+		w := printers.GetNewTabWriter(o.IOStreams.Out)
+		err := printTable(w, o.outputFormat() == "wide")
+		if err != nil {
+			return err
+		}
+		return w.Flush()
+	}
+	p, err := o.PrintFlags.ToPrinter()
+	if err != nil {
+		return err
+	}
+	items, err := apimeta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	// typed objects listed by the client have no type
+	for _, item := range items {
+		gvks, _, err := scheme.Scheme.ObjectKinds(item)
+		if err != nil {
+			return err
+		}
+		item.GetObjectKind().SetGroupVersionKind(gvks[0])
+	}
+	// the name printer doesn't print lists
+	if o.outputFormat() == "name" {
+		for _, item := range items {
+			err = p.PrintObj(item, o.IOStreams.Out)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err = apimeta.SetList(list, items)
+	if err != nil {
+		return err
+	}
+	return p.PrintObj(list, o.IOStreams.Out)
+}
+
+func (o *ListOptions) RunGetClusters(ctx context.Context) error {
+	list := appv1alpha1.ClusterList{}
+	err := o.list(ctx, &list, "cluster")
+	if err != nil {
+		return err
+	}
+	return o.print(&list, func(w io.Writer, wide bool) error {
+		return printClusters(w, list.Items, o.AllNamespaces, wide, time.Now())
+	})
+}
+
+func (o *ListOptions) RunGetHelmReleases(ctx context.Context) error {
+	list := appv1alpha1.HelmReleaseList{}
+	err := o.list(ctx, &list, "helm release")
+	if err != nil {
+		return err
+	}
+	return o.print(&list, func(w io.Writer, wide bool) error {
+		return printHelmReleases(w, list.Items, o.AllNamespaces, wide, time.Now())
+	})
+}
+
+// readyColumns returns the status, reason and message of the Ready condition.
+func readyColumns(conditions []metav1.Condition) (string, string, string) {
+	c := apimeta.FindStatusCondition(conditions, meta.ReadyCondition)
+	if c == nil {
+		return string(metav1.ConditionUnknown), "", ""
+	}
+	return string(c.Status), c.Reason, c.Message
+}
+
+func age(t metav1.Time, now time.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(now.Sub(t.Time))
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+func printRow(w io.Writer, allNamespaces bool, namespace string, columns ...string) {
+	if allNamespaces {
+		columns = append([]string{namespace}, columns...)
+	}
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+}
+
+func printClusters(w io.Writer, clusters []appv1alpha1.Cluster, allNamespaces, wide bool, now time.Time) error {
+	header := []string{"NAME", "PROVIDER", "FLAVOR", "K8S", "WORKER POOLS", "WORKERS", "READY", "REASON", "AGE"}
+	if wide {
+		header = append(header, "REGION", "CONTROL PLANE", "BASTION IP", "MESSAGE")
+	}
+	printRow(w, allNamespaces, "NAMESPACE", header...)
+	for _, cl := range clusters {
+		ready, reason, msg := readyColumns(cl.Status.Conditions)
+		// the status version is the one running in the cluster
+		version := cl.Status.KubernetesVersion
+		if version == "" {
+			version = cl.Spec.KubernetesVersion
+		}
+		row := []string{
+			cl.Name,
+			orNone(cl.Spec.InfrastructureProvider.Name),
+			orNone(cl.Spec.InfrastructureProvider.Flavor),
+			orNone(version),
+			fmt.Sprint(cl.Status.TotalWorkerPools),
+			fmt.Sprint(cl.Status.TotalWorkerReplicas),
+			ready,
+			orNone(reason),
+			age(cl.CreationTimestamp, now),
+		}
+		if wide {
+			controlPlane := "<none>"
+			if cl.Spec.ControlPlane != nil && cl.Spec.ControlPlane.Replicas != nil {
+				controlPlane = fmt.Sprint(*cl.Spec.ControlPlane.Replicas)
+			}
+			row = append(row, orNone(cl.Spec.InfrastructureProvider.Region), controlPlane, orNone(cl.Status.BastionPublicIP), msg)
+		}
+		printRow(w, allNamespaces, cl.Namespace, row...)
+	}
+	return nil
+}
+
+func printHelmReleases(w io.Writer, hrs []appv1alpha1.HelmRelease, allNamespaces, wide bool, now time.Time) error {
+	header := []string{"NAME", "CLUSTER", "CHART", "VERSION", "READY", "REASON", "AGE"}
+	if wide {
+		header = append(header, "REPOSITORY", "TARGET NAMESPACE", "REVISION", "MESSAGE")
+	}
+	printRow(w, allNamespaces, "NAMESPACE", header...)
+	for _, hr := range hrs {
+		ready, reason, msg := readyColumns(hr.Status.Conditions)
+		cluster := hr.Spec.ClusterName
+		if cluster == "" {
+			cluster = "management"
+		}
+		row := []string{
+			hr.Name,
+			cluster,
+			orNone(hr.Spec.Chart.Name),
+			orNone(hr.Spec.Chart.Version),
+			ready,
+			orNone(reason),
+			age(hr.CreationTimestamp, now),
+		}
+		if wide {
+			row = append(row, orNone(hr.Spec.Chart.RepoURL), orNone(hr.Spec.TargetNamespace), orNone(hr.Status.LastAppliedRevision), msg)
+		}
+		printRow(w, allNamespaces, hr.Namespace, row...)
+	}
+	return nil
+}
+
+func NewCmdGetClusters(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewListOptions(streams)
+	cmd := &cobra.Command{
+		Use:                   "clusters [cluster name...]",
+		Aliases:               []string{"cluster", "cl"},
+		DisableFlagsInUseLine: true,
+		Short:                 "List clusters",
+		Long: LongDesc(`List clusters created or imported by UnDistro.
+		Shows the provider, the Kubernetes version, the worker pools and replicas and the Ready condition of each cluster.`),
+		Example: Examples(`
+		# List clusters in default namespace
+		undistro get clusters
+		# List clusters in all namespaces with the bastion IP
+		undistro get clusters -A -o wide
+		# List clusters with a label as yaml
+		undistro get clusters -l team=platform -o yaml
+		# Get the Kubernetes version of a cluster
+		undistro get clusters cool-cluster -n cool-namespace -o jsonpath='{.items[0].status.kubernetesVersion}'
+		`),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.RunGetClusters(cmd.Context()))
+		},
+	}
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func NewCmdGetHelmReleases(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewListOptions(streams)
+	cmd := &cobra.Command{
+		Use:                   "helmreleases [release name...]",
+		Aliases:               []string{"helmrelease", "hr"},
+		DisableFlagsInUseLine: true,
+		Short:                 "List Helm releases",
+		Long: LongDesc(`List Helm releases managed by UnDistro.
+		Shows the cluster, the chart and version and the Ready condition of each release.`),
+		Example: Examples(`
+		# List the releases of the management cluster
+		undistro get helmreleases -n undistro-system
+		# List releases in all namespaces with the repository and revision
+		undistro get helmreleases -A -o wide
+		`),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.RunGetHelmReleases(cmd.Context()))
+		},
+	}
+	o.AddFlags(cmd)
+	return cmd
+}
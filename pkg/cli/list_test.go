@@ -0,0 +1,195 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/getupio-undistro/meta"
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var listNow = time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+
+func newListCluster(ns, name string, labels map[string]string) *appv1alpha1.Cluster {
+	replicas := int32(3)
+	return &appv1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         ns,
+			Labels:            labels,
+			CreationTimestamp: metav1.NewTime(listNow.Add(-49 * time.Hour)),
+		},
+		Spec: appv1alpha1.ClusterSpec{
+			KubernetesVersion: "v1.21.5",
+			InfrastructureProvider: appv1alpha1.InfrastructureProvider{
+				Name:   "aws",
+				Flavor: "ec2",
+				Region: "us-east-1",
+			},
+			ControlPlane: &appv1alpha1.ControlPlaneNode{
+				Node: appv1alpha1.Node{Replicas: &replicas},
+			},
+		},
+		Status: appv1alpha1.ClusterStatus{
+			TotalWorkerPools:    2,
+			TotalWorkerReplicas: 5,
+			BastionPublicIP:     "54.1.2.3",
+			Conditions: []metav1.Condition{
+				{Type: meta.ReadyCondition, Status: metav1.ConditionTrue, Reason: meta.InstallSucceededReason, Message: "Cluster ready"},
+			},
+		},
+	}
+}
+
+func Test_printClusters(t *testing.T) {
+	tests := []struct {
+		name          string
+		allNamespaces bool
+		wide          bool
+		want          string
+	}{
+		{
+			name: "table",
+			want: `NAME           PROVIDER   FLAVOR   K8S       WORKER POOLS   WORKERS   READY   REASON                   AGE
+cool-cluster   aws        ec2      v1.21.5   2              5         True    InstallSucceededReason   2d1h
+`,
+		},
+		{
+			name:          "wide in all namespaces",
+			allNamespaces: true,
+			wide:          true,
+			want: `NAMESPACE   NAME           PROVIDER   FLAVOR   K8S       WORKER POOLS   WORKERS   READY   REASON                   AGE    REGION      CONTROL PLANE   BASTION IP   MESSAGE
+default     cool-cluster   aws        ec2      v1.21.5   2              5         True    InstallSucceededReason   2d1h   us-east-1   3               54.1.2.3     Cluster ready
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := bytes.Buffer{}
+			w := printers.GetNewTabWriter(&out)
+			err := printClusters(w, []appv1alpha1.Cluster{*newListCluster("default", "cool-cluster", nil)}, tt.allNamespaces, tt.wide, listNow)
+			if err != nil {
+				t.Fatalf("printClusters() error = %v", err)
+			}
+			w.Flush()
+			if out.String() != tt.want {
+				t.Errorf("printClusters() =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_printHelmReleases(t *testing.T) {
+	hr := appv1alpha1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "kyverno-cool-cluster",
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(listNow.Add(-10 * time.Minute)),
+		},
+		Spec: appv1alpha1.HelmReleaseSpec{
+			ClusterName:     "default/cool-cluster",
+			TargetNamespace: "kyverno",
+			Chart: appv1alpha1.ChartSource{
+				RepoChartSource: appv1alpha1.RepoChartSource{
+					RepoURL: "https://registry.undistro.io/chartrepo/library",
+					Name:    "kyverno",
+					Version: "v2.0.3",
+				},
+			},
+		},
+	}
+	out := bytes.Buffer{}
+	w := printers.GetNewTabWriter(&out)
+	err := printHelmReleases(w, []appv1alpha1.HelmRelease{hr}, false, true, listNow)
+	if err != nil {
+		t.Fatalf("printHelmReleases() error = %v", err)
+	}
+	w.Flush()
+	want := `NAME                   CLUSTER                CHART     VERSION   READY     REASON   AGE   REPOSITORY                                       TARGET NAMESPACE   REVISION   MESSAGE
+kyverno-cool-cluster   default/cool-cluster   kyverno   v2.0.3    Unknown   <none>   10m   https://registry.undistro.io/chartrepo/library   kyverno            <none>     
+`
+	if out.String() != want {
+		t.Errorf("printHelmReleases() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestListOptions_RunGetClusters(t *testing.T) {
+	tests := []struct {
+		name          string
+		allNamespaces bool
+		selector      string
+		names         []string
+		output        string
+		want          string
+		wantErr       bool
+	}{
+		{
+			name:   "namespace",
+			output: "name",
+			want:   "cluster.app.undistro.io/cool-cluster\ncluster.app.undistro.io/other-cluster\n",
+		},
+		{
+			name:          "all namespaces with selector",
+			allNamespaces: true,
+			selector:      "team=platform",
+			output:        "jsonpath={range .items[*]}{.metadata.namespace}/{.metadata.name} {end}",
+			want:          "default/cool-cluster undistro-system/management ",
+		},
+		{
+			name:   "named cluster",
+			names:  []string{"other-cluster"},
+			output: "jsonpath={.items[*].spec.infrastructureProvider.flavor}",
+			want:   "ec2",
+		},
+		{
+			name:    "missing cluster",
+			names:   []string{"missing"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := bytes.Buffer{}
+			o := NewListOptions(genericclioptions.IOStreams{Out: &out, ErrOut: &out})
+			o.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+				newListCluster("default", "cool-cluster", map[string]string{"team": "platform"}),
+				newListCluster("default", "other-cluster", nil),
+				newListCluster("undistro-system", "management", map[string]string{"team": "platform"}),
+			).Build()
+			o.Namespace = "default"
+			o.AllNamespaces = tt.allNamespaces
+			o.LabelSelector = tt.selector
+			o.Names = tt.names
+			o.PrintFlags.OutputFormat = &tt.output
+			err := o.RunGetClusters(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunGetClusters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && out.String() != tt.want {
+				t.Errorf("RunGetClusters() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
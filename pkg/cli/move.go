@@ -18,10 +18,10 @@ package cli
 import (
 	"context"
 	"fmt"
-	"os"
 
 	"github.com/getupio-undistro/meta"
 	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/config"
 	"github.com/getupio-undistro/undistro/pkg/graph"
 	"github.com/getupio-undistro/undistro/pkg/kube"
 	"github.com/getupio-undistro/undistro/pkg/retry"
@@ -45,6 +45,7 @@ import (
 
 type MoveOptions struct {
 	ConfigPath  string
+	Config      *config.Config
 	ClusterName string
 	Namespace   string
 	genericclioptions.IOStreams
@@ -79,13 +80,12 @@ func (o *MoveOptions) Complete(f *ConfigFlags, cmd *cobra.Command, args []string
 }
 
 func (o *MoveOptions) Validate() error {
-	if o.ConfigPath != "" {
-		_, err := os.Stat(o.ConfigPath)
-		if err != nil {
-			return err
-		}
+	if o.Config != nil {
+		return nil
 	}
-	return nil
+	var err error
+	o.Config, err = config.Load(o.ConfigPath)
+	return err
 }
 
 func (o *MoveOptions) RunMove(f cmdutil.Factory, cmd *cobra.Command) error {
@@ -95,12 +95,11 @@ func (o *MoveOptions) RunMove(f cmdutil.Factory, cmd *cobra.Command) error {
 		Namespace: o.Namespace,
 		Name:      o.ClusterName,
 	}
-	iopts := &InstallOptions{
-		ConfigPath:  o.ConfigPath,
-		ClusterName: key.String(),
-		IOStreams:   o.IOStreams,
-		Remote:      true,
-	}
+	iopts := NewInstallOptions(o.IOStreams)
+	iopts.ConfigPath = o.ConfigPath
+	iopts.Config = o.Config
+	iopts.ClusterName = key.String()
+	iopts.Remote = true
 	err := iopts.RunInstall(f, cmd)
 	if err != nil {
 		return err
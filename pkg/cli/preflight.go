@@ -0,0 +1,251 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/compatibility"
+	"github.com/getupio-undistro/undistro/pkg/config"
+	"github.com/getupio-undistro/undistro/pkg/preflight"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	"github.com/getupio-undistro/undistro/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	preflightOutputText = "text"
+	preflightOutputJSON = "json"
+)
+
+type PreflightOptions struct {
+	genericclioptions.IOStreams
+	Filenames []string
+	Output    string
+	Env       preflight.Env
+}
+
+func NewPreflightOptions(streams genericclioptions.IOStreams) *PreflightOptions {
+	return &PreflightOptions{
+		IOStreams: streams,
+		Output:    preflightOutputText,
+	}
+}
+
+func (o *PreflightOptions) AddFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVarP(&o.Filenames, "filename", "f", o.Filenames, "Files with the workload clusters to check, the management cluster is checked when not set")
+	flags.StringVarP(&o.Output, "output", "o", o.Output, "Output format, one of text or json")
+}
+
+func (o *PreflightOptions) Complete(f *ConfigFlags, cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return cmdutil.UsageErrorf(cmd, "%s", "too many arguments")
+	}
+	var err error
+	o.Env.Config, err = config.Load(*f.ConfigFile)
+	if err != nil {
+		return err
+	}
+	o.Env.Matrix, err = compatibility.Default()
+	if err != nil {
+		return err
+	}
+	factory := cmdutil.NewFactory(f)
+	cfg, err := factory.ToRESTConfig()
+	if err != nil {
+		return errors.Errorf("unable to get config: %v", err)
+	}
+	o.Env.Client, err = client.New(cfg, client.Options{
+		Scheme: scheme.Scheme,
+	})
+	if err != nil {
+		return errors.Errorf("unable to create client: %v", err)
+	}
+	// the kubernetes-version check warns when the version is unknown
+	dc, err := factory.ToDiscoveryClient()
+	if err == nil {
+		v, err := dc.ServerVersion()
+		if err == nil {
+			o.Env.KubernetesVersion = v.GitVersion
+		}
+	}
+	o.Env.Scope = preflight.Management
+	if len(o.Filenames) > 0 {
+		o.Env.Scope = preflight.Workload
+		o.Env.Clusters, err = readClusters(o.Filenames...)
+	}
+	return err
+}
+
+func (o *PreflightOptions) Validate() error {
+	if o.Output != preflightOutputText && o.Output != preflightOutputJSON {
+		return errors.Errorf("unsupported output %q, use one of text or json", o.Output)
+	}
+	return nil
+}
+
+// readClusters reads the UnDistro clusters of manifest files.
+func readClusters(filenames ...string) ([]appv1alpha1.Cluster, error) {
+	clusters := make([]appv1alpha1.Cluster, 0)
+	for _, name := range filenames {
+		byt, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		objs, err := util.ToUnstructured(byt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read %s", name)
+		}
+		for _, obj := range objs {
+			if obj.GroupVersionKind() != appv1alpha1.GroupVersion.WithKind("Cluster") {
+				continue
+			}
+			cl := appv1alpha1.Cluster{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &cl)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read cluster %s in %s", obj.GetName(), name)
+			}
+			if cl.Namespace == "" {
+				cl.Namespace = "default"
+			}
+			clusters = append(clusters, cl)
+		}
+	}
+	if len(clusters) == 0 {
+		return nil, errors.Errorf("no clusters in %s", strings.Join(filenames, ", "))
+	}
+	return clusters, nil
+}
+
+// checks adds the validation of the Cluster webhook to the checks of the preflight package.
+func (o *PreflightOptions) checks() []preflight.Check {
+	return append(preflight.Checks(), preflight.Check{
+		Name:  "cluster-spec",
+		Scope: preflight.Workload,
+		Run: func(ctx context.Context, env *preflight.Env) []preflight.Result {
+			co := ClusterOptions{Client: env.Client}
+			results := make([]preflight.Result, 0, len(env.Clusters))
+			for _, cl := range env.Clusters {
+				key := client.ObjectKeyFromObject(&cl)
+				err := co.validateCluster(ctx, cl.DeepCopy())
+				if err != nil {
+					results = append(results, preflight.Result{
+						Status:      preflight.Fail,
+						Message:     fmt.Sprintf("cluster %s is invalid: %v", key, err),
+						Remediation: "fix the cluster spec, undistro create cluster --generate-file renders a valid one",
+					})
+					continue
+				}
+				results = append(results, preflight.Result{Status: preflight.Pass, Message: fmt.Sprintf("cluster %s is valid", key)})
+			}
+			return results
+		},
+	})
+}
+
+func (o *PreflightOptions) RunPreflight(ctx context.Context) error {
+	report := preflight.Run(ctx, &o.Env, o.checks()...)
+	if o.Output == preflightOutputJSON {
+		byt, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.IOStreams.Out, string(byt))
+	} else {
+		err := printPreflight(o.IOStreams.Out, report)
+		if err != nil {
+			return err
+		}
+	}
+	if report.Failures > 0 {
+		return errors.Errorf("%d of %d checks failed", report.Failures, len(report.Results))
+	}
+	return nil
+}
+
+func printPreflight(out io.Writer, report preflight.Report) error {
+	w := printers.GetNewTabWriter(out)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tMESSAGE")
+	for _, r := range report.Results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Check, strings.ToUpper(string(r.Status)), r.Message)
+	}
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "\n%d passed, %d warnings, %d failures\n", report.Passed, report.Warnings, report.Failures)
+	if err != nil {
+		return err
+	}
+	if report.Warnings+report.Failures == 0 {
+		return nil
+	}
+	fmt.Fprintln(out, "\nRemediation:")
+	for _, r := range report.Results {
+		if r.Status == preflight.Pass || r.Remediation == "" {
+			continue
+		}
+		_, err = fmt.Fprintf(out, "  %s: %s\n", r.Check, r.Remediation)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewCmdPreflight(f *ConfigFlags, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewPreflightOptions(streams)
+	cmd := &cobra.Command{
+		Use:                   "preflight",
+		DisableFlagsInUseLine: true,
+		Short:                 "Check the management cluster or workload clusters before installing them",
+		Long: LongDesc(`Check the management cluster before UnDistro is installed,
+		or the workload clusters of the files before they are created.
+		The management cluster checks are the kubernetes version supported by UnDistro,
+		CRDs of UnDistro components installed by other tools, the reachability of the ingress addresses,
+		the secrets of the enabled providers and AWS credentials not belonging to the root user.
+		The workload cluster checks are the cluster spec, the provider secrets and account,
+		networks overlapping in a cluster or with other clusters and an estimate of the AWS service quotas they use.
+		Each check passes, warns or fails with a hint to fix it, and the command exits with 1 when a check fails.`),
+		Example: Examples(`
+		# Check the management cluster before installing UnDistro
+		undistro --config undistro-config.yaml preflight
+		# Check a cluster before creating it
+		undistro preflight -f cool-cluster.yaml
+		# Print the report as JSON
+		undistro preflight -o json
+		`),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.RunPreflight(cmd.Context()))
+		},
+	}
+	o.AddFlags(cmd.Flags())
+	return cmd
+}
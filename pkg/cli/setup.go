@@ -18,11 +18,14 @@ package cli
 import (
 	"fmt"
 	"io/ioutil"
+	"strings"
 	"time"
 
 	"github.com/getupio-undistro/meta"
 	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/config"
 	"github.com/getupio-undistro/undistro/pkg/kube"
+	"github.com/getupio-undistro/undistro/pkg/local"
 	"github.com/getupio-undistro/undistro/pkg/retry"
 	"github.com/getupio-undistro/undistro/pkg/scheme"
 	"github.com/getupio-undistro/undistro/pkg/undistro"
@@ -33,8 +36,6 @@ import (
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/kind/pkg/cluster"
-	kindcmd "sigs.k8s.io/kind/pkg/cmd"
 )
 
 type SetupOptions struct {
@@ -42,24 +43,32 @@ type SetupOptions struct {
 	Provider          string
 	Name              string
 	ConfigPath        string
+	Config            *config.Config
 	Flavor            string
 	SSHKeyName        string
 	CloudsFile        string
 	KubernetesVersion string
 	Region            string
-	rawCfg            *ConfigFlags
+	// Bootstrap is the local cluster tool the bootstrap cluster is created with
+	// when the management cluster is created in a cloud provider
+	Bootstrap string
+	Commander local.Commander
+	rawCfg    *ConfigFlags
 }
 
 func NewSetupOptions(streams genericclioptions.IOStreams) *SetupOptions {
 	return &SetupOptions{
 		IOStreams: streams,
 		Name:      "undistro",
+		Bootstrap: util.Kind.String(),
+		Commander: local.Exec{},
 	}
 }
 
 func (o *SetupOptions) AddFlags(flags *pflag.FlagSet) {
 	flags.StringVar(&o.Name, "name", o.Name, "name of the cluster (default: undistro)")
 	flags.StringVar(&o.Flavor, "flavor", o.Flavor, "flavor used for management cluster")
+	flags.StringVar(&o.Bootstrap, "bootstrap", o.Bootstrap, "local cluster tool used to create the bootstrap cluster, one of kind, k3d or minikube")
 	flags.StringVar(&o.Region, "region", o.Region, "region used for management cluster")
 	flags.StringVar(&o.SSHKeyName, "ssh-key-name", o.SSHKeyName, "ssh key name used to create the management cluster")
 	flags.StringVar(&o.KubernetesVersion, "kubernetes-version", o.KubernetesVersion, "Kubernetes version used to create the management cluster")
@@ -75,6 +84,9 @@ func (o *SetupOptions) Complete(f *ConfigFlags, args []string) error {
 	default:
 		return errors.New("required 1 argument")
 	}
+	if !local.IsTool(o.Bootstrap) {
+		return errors.Errorf("unsupported bootstrap tool %s, use one of %s", o.Bootstrap, strings.Join(local.Tools, ", "))
+	}
 	if o.Provider == "openstack" {
 		if o.CloudsFile == "" {
 			return errors.New("clouds file is required for provider openstack")
@@ -84,34 +96,34 @@ func (o *SetupOptions) Complete(f *ConfigFlags, args []string) error {
 	if o.Name == "" {
 		o.Name = undistro.LocalCluster
 	}
-	return nil
+	// the configuration is checked before the bootstrap cluster is created
+	var err error
+	o.Config, err = config.Load(o.ConfigPath)
+	return err
 }
 
 func (o *SetupOptions) RunSetup(cmd *cobra.Command, args []string) error {
 	fmt.Fprintln(o.IOStreams.Out, "Setup a bootstrap cluster")
-	provider := cluster.NewProvider(
-		cluster.ProviderWithLogger(kindcmd.NewLogger()),
-		cluster.ProviderWithDocker(),
-	)
-	err := provider.Create(
-		o.Name,
-		cluster.CreateWithRawConfig([]byte(undistro.KindCfg)),
-		cluster.CreateWithNodeImage(""),
-		cluster.CreateWithRetain(false),
-		cluster.CreateWithWaitForReady(time.Duration(0)),
-		cluster.CreateWithKubeconfigPath(""),
-		cluster.CreateWithDisplayUsage(true),
-		cluster.CreateWithDisplaySalutation(true),
-	)
+	// local tools set UnDistro up in the cluster they create,
+	// cloud providers in a management cluster created from a bootstrap one
+	toolName := o.Provider
+	if !local.IsTool(toolName) {
+		toolName = o.Bootstrap
+	}
+	tool, err := local.NewTool(toolName, o.Commander)
 	if err != nil {
 		return err
 	}
-	opts := InstallOptions{
-		ConfigPath:  o.ConfigPath,
-		IOStreams:   o.IOStreams,
-		Remote:      false,
-		ClusterName: o.Name,
+	err = tool.Create(cmd.Context(), o.IOStreams, o.Name)
+	if err != nil {
+		return err
 	}
+	opts := NewInstallOptions(o.IOStreams)
+	opts.ConfigPath = o.ConfigPath
+	opts.Config = o.Config
+	opts.Remote = false
+	opts.ClusterName = o.Name
+	opts.Commander = o.Commander
 	err = opts.Complete(o.rawCfg, cmd, args)
 	if err != nil {
 		return err
@@ -122,7 +134,7 @@ func (o *SetupOptions) RunSetup(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	if o.Provider != "kind" {
+	if !local.IsTool(o.Provider) {
 		cfg, err := factory.ToRESTConfig()
 		if err != nil {
 			return err
@@ -151,7 +163,7 @@ func (o *SetupOptions) RunSetup(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
-		err = createClusterOpts.RunCreateCluster(factory, cmd)
+		err = createClusterOpts.RunCreateCluster(cmd)
 		if err != nil {
 			return err
 		}
@@ -183,6 +195,7 @@ func (o *SetupOptions) RunSetup(cmd *cobra.Command, args []string) error {
 		}
 		moveOpts := MoveOptions{
 			ConfigPath:  o.ConfigPath,
+			Config:      o.Config,
 			IOStreams:   o.IOStreams,
 			ClusterName: undistro.MgmtClusterName,
 			Namespace:   undistro.Namespace,
@@ -191,7 +204,7 @@ func (o *SetupOptions) RunSetup(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
-		return retry.WithExponentialBackoff(retry.NewBackoff(), func() error {
+		err = retry.WithExponentialBackoff(retry.NewBackoff(), func() error {
 			ierr := moveOpts.RunMove(factory, cmd)
 			if ierr != nil {
 				fmt.Fprintf(o.IOStreams.ErrOut, "%s, retrying...\n", ierr)
@@ -199,8 +212,13 @@ func (o *SetupOptions) RunSetup(cmd *cobra.Command, args []string) error {
 			}
 			return nil
 		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.IOStreams.Out, "Delete the bootstrap cluster")
+		return tool.Delete(cmd.Context(), o.IOStreams, o.Name)
 	}
-	return provider.Delete(o.Name, "")
+	return nil
 }
 
 func NewCmdSetup(f *ConfigFlags, streams genericclioptions.IOStreams) *cobra.Command {
@@ -212,6 +230,9 @@ func NewCmdSetup(f *ConfigFlags, streams genericclioptions.IOStreams) *cobra.Com
 		Long:                  LongDesc(`Setup a tool`),
 		Example: Examples(`
 		undistro setup kind --name undistro-cluster
+		undistro setup k3d
+		undistro setup minikube --name undistro-cluster
+		undistro setup aws --bootstrap k3d --ssh-key-name undistro
 		`),
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(o.Complete(f, args))
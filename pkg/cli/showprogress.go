@@ -18,36 +18,98 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
-	"github.com/getupio-undistro/undistro/pkg/fs"
 	"github.com/getupio-undistro/undistro/pkg/scheme"
-	"github.com/getupio-undistro/undistro/pkg/template"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/cli-runtime/pkg/printers"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	capiexp "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	progressOutputText = "text"
+	progressOutputJSON = "json"
+	progressWaitReady  = "ready"
+	progressInterval   = 5 * time.Second
+)
+
+// ProgressNode is an object in the tree of a cluster, from the UnDistro Cluster down to the machines.
+type ProgressNode struct {
+	Kind      string                 `json:"kind"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Name      string                 `json:"name"`
+	Phase     string                 `json:"phase,omitempty"`
+	Ready     metav1.ConditionStatus `json:"ready"`
+	Reason    string                 `json:"reason,omitempty"`
+	Message   string                 `json:"message,omitempty"`
+	Children  []*ProgressNode        `json:"children,omitempty"`
+}
+
+// ProgressEvent is printed in the json output each time a node of the tree is added or changes.
+type ProgressEvent struct {
+	Time metav1.Time `json:"time"`
+	ProgressNode
+}
+
+func (n *ProgressNode) id() string {
+	return fmt.Sprintf("%s/%s/%s", n.Kind, n.Namespace, n.Name)
+}
+
+// walk calls fn for the node and its descendants, parents first.
+func (n *ProgressNode) walk(fn func(*ProgressNode)) {
+	fn(n)
+	for _, child := range n.Children {
+		child.walk(fn)
+	}
+}
+
+// NotReady returns the nodes of the tree whose Ready condition is not true.
+func (n *ProgressNode) NotReady() []*ProgressNode {
+	notReady := make([]*ProgressNode, 0)
+	n.walk(func(node *ProgressNode) {
+		if node.Ready != metav1.ConditionTrue {
+			notReady = append(notReady, node)
+		}
+	})
+	return notReady
+}
+
 type ShowProgressOptions struct {
 	genericclioptions.IOStreams
 	Namespace   string
 	ClusterName string
+	Output      string
+	WaitFor     string
+	Timeout     time.Duration
+	Client      client.Client
 }
 
 func NewShowProgressOptions(streams genericclioptions.IOStreams) *ShowProgressOptions {
 	return &ShowProgressOptions{
 		IOStreams: streams,
+		Output:    progressOutputText,
 	}
 }
 
+func (o *ShowProgressOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format, one of text or json")
+	cmd.Flags().StringVar(&o.WaitFor, "wait-for", o.WaitFor, "Exit when the condition is met, only ready is supported")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", o.Timeout, "Time to wait before giving up, zero means never")
+}
+
 func (o *ShowProgressOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
 	var err error
 	o.Namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
@@ -58,69 +120,304 @@ func (o *ShowProgressOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, ar
 		return errors.New("required 1 argument")
 	}
 	o.ClusterName = args[0]
-	return nil
-}
-
-func (o *ShowProgressOptions) RunShowProgress(f cmdutil.Factory, cmd *cobra.Command) error {
 	cfg, err := f.ToRESTConfig()
 	if err != nil {
 		return errors.Errorf("unable to get config: %v", err)
 	}
-	cfg.Timeout = 0
-	c, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		return errors.Errorf("unable to create event client: %v", err)
-	}
-	k8sClient, err := client.New(cfg, client.Options{
+	o.Client, err = client.New(cfg, client.Options{
 		Scheme: scheme.Scheme,
 	})
 	if err != nil {
 		return errors.Errorf("unable to create client: %v", err)
 	}
+	return nil
+}
+
+func (o *ShowProgressOptions) Validate() error {
+	if o.Output != progressOutputText && o.Output != progressOutputJSON {
+		return errors.Errorf("unsupported output %q, use one of text or json", o.Output)
+	}
+	if o.WaitFor != "" && o.WaitFor != progressWaitReady {
+		return errors.Errorf("unsupported wait condition %q, only %s is supported", o.WaitFor, progressWaitReady)
+	}
+	if o.Timeout < 0 {
+		return errors.New("timeout can't be negative")
+	}
+	return nil
+}
+
+// Tree reads the cluster and its child objects.
+// Objects not created yet by the controllers are left out of the tree.
+func (o *ShowProgressOptions) Tree(ctx context.Context) (*ProgressNode, error) {
 	key := client.ObjectKey{
 		Name:      o.ClusterName,
 		Namespace: o.Namespace,
 	}
-	obj := appv1alpha1.Cluster{}
-	err = k8sClient.Get(cmd.Context(), key, &obj)
+	cl := appv1alpha1.Cluster{}
+	err := o.Client.Get(ctx, key, &cl)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	root := &ProgressNode{
+		Kind:      "Cluster",
+		Namespace: cl.Namespace,
+		Name:      cl.Name,
+	}
+	root.Ready, root.Reason, root.Message = undistroReady(cl.Status.Conditions)
+	capiCluster := clusterv1.Cluster{}
+	err = o.Client.Get(ctx, key, &capiCluster)
+	if client.IgnoreNotFound(err) != nil {
+		return nil, err
 	}
-	vars := map[string]interface{}{
-		"ENV":     make(map[string]interface{}),
-		"Cluster": &obj,
+	if err == nil {
+		capiNode, err := o.capiTree(ctx, &capiCluster)
+		if err != nil {
+			return nil, err
+		}
+		root.Children = append(root.Children, capiNode)
 	}
-	objs, err := template.GetObjs(fs.FS, "clustertemplates", obj.GetTemplate(), vars)
+	releases := appv1alpha1.HelmReleaseList{}
+	err = o.Client.List(ctx, &releases, client.InNamespace(o.Namespace))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	w, err := c.CoreV1().Events(o.Namespace).Watch(cmd.Context(), metav1.ListOptions{
-		Watch: true,
-	})
+	for _, hr := range releases.Items {
+		if hr.Spec.ClusterName != key.String() {
+			continue
+		}
+		node := &ProgressNode{
+			Kind:      "HelmRelease",
+			Namespace: hr.Namespace,
+			Name:      hr.Name,
+		}
+		node.Ready, node.Reason, node.Message = undistroReady(hr.Status.Conditions)
+		root.Children = append(root.Children, node)
+	}
+	return root, nil
+}
+
+func (o *ShowProgressOptions) capiTree(ctx context.Context, capiCluster *clusterv1.Cluster) (*ProgressNode, error) {
+	node := capiNode("CAPICluster", capiCluster, capiCluster.Status.Phase, capiCluster.GetConditions())
+	clusterLabel := client.MatchingLabels{clusterv1.ClusterLabelName: capiCluster.Name}
+	if ref := capiCluster.Spec.ControlPlaneRef; ref != nil {
+		cp := unstructured.Unstructured{}
+		cp.SetAPIVersion(ref.APIVersion)
+		cp.SetKind(ref.Kind)
+		err := o.Client.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: capiCluster.Namespace}, &cp)
+		if client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		if err == nil {
+			cpNode := controlPlaneNode(&cp)
+			machines := clusterv1.MachineList{}
+			err = o.Client.List(ctx, &machines, client.InNamespace(capiCluster.Namespace), clusterLabel, client.HasLabels{clusterv1.MachineControlPlaneLabelName})
+			if err != nil {
+				return nil, err
+			}
+			cpNode.Children = machineNodes(machines.Items)
+			node.Children = append(node.Children, cpNode)
+		}
+	}
+	mds := clusterv1.MachineDeploymentList{}
+	err := o.Client.List(ctx, &mds, client.InNamespace(capiCluster.Namespace), clusterLabel)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	for i := range mds.Items {
+		md := &mds.Items[i]
+		mdNode := capiNode("MachineDeployment", md, md.Status.Phase, md.GetConditions())
+		machines := clusterv1.MachineList{}
+		err = o.Client.List(ctx, &machines, client.InNamespace(capiCluster.Namespace), client.MatchingLabels{clusterv1.MachineDeploymentLabelName: md.Name})
+		if err != nil {
+			return nil, err
+		}
+		mdNode.Children = machineNodes(machines.Items)
+		node.Children = append(node.Children, mdNode)
 	}
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
-	go func(ctx context.Context) {
-		for e := range w.ResultChan() {
-			ev, ok := e.Object.(*corev1.Event)
-			if !ok {
-				continue
+	mps := capiexp.MachinePoolList{}
+	err = o.Client.List(ctx, &mps, client.InNamespace(capiCluster.Namespace), clusterLabel)
+	if err != nil {
+		return nil, err
+	}
+	for i := range mps.Items {
+		mp := &mps.Items[i]
+		node.Children = append(node.Children, capiNode("MachinePool", mp, mp.Status.Phase, mp.GetConditions()))
+	}
+	return node, nil
+}
+
+func undistroReady(conditions []metav1.Condition) (metav1.ConditionStatus, string, string) {
+	status, reason, message := readyColumns(conditions)
+	return metav1.ConditionStatus(status), reason, message
+}
+
+func capiNode(kind string, obj client.Object, phase string, conditions clusterv1.Conditions) *ProgressNode {
+	node := &ProgressNode{
+		Kind:      kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Phase:     phase,
+		Ready:     metav1.ConditionUnknown,
+	}
+	for _, c := range conditions {
+		if c.Type == clusterv1.ReadyCondition {
+			node.Ready = metav1.ConditionStatus(c.Status)
+			node.Reason = c.Reason
+			node.Message = c.Message
+		}
+	}
+	return node
+}
+
+func machineNodes(machines []clusterv1.Machine) []*ProgressNode {
+	nodes := make([]*ProgressNode, 0, len(machines))
+	for i := range machines {
+		m := &machines[i]
+		nodes = append(nodes, capiNode("Machine", m, m.Status.Phase, m.GetConditions()))
+	}
+	return nodes
+}
+
+// controlPlaneNode reads the status of a control plane of any provider,
+// the conditions follow the Cluster API contract and status.ready is used when they are missing.
+func controlPlaneNode(cp *unstructured.Unstructured) *ProgressNode {
+	node := &ProgressNode{
+		Kind:      cp.GetKind(),
+		Namespace: cp.GetNamespace(),
+		Name:      cp.GetName(),
+		Ready:     metav1.ConditionUnknown,
+	}
+	node.Phase, _, _ = unstructured.NestedString(cp.Object, "status", "phase")
+	conditions, _, _ := unstructured.NestedSlice(cp.Object, "status", "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]interface{})
+		if !ok || m["type"] != string(clusterv1.ReadyCondition) {
+			continue
+		}
+		status, _ := m["status"].(string)
+		node.Ready = metav1.ConditionStatus(status)
+		node.Reason, _ = m["reason"].(string)
+		node.Message, _ = m["message"].(string)
+		return node
+	}
+	ready, found, _ := unstructured.NestedBool(cp.Object, "status", "ready")
+	if found && ready {
+		node.Ready = metav1.ConditionTrue
+	}
+	return node
+}
+
+// progressEvents returns the nodes added or changed since the previous tree.
+func progressEvents(prev map[string]ProgressNode, tree *ProgressNode, now time.Time) []ProgressEvent {
+	events := make([]ProgressEvent, 0)
+	tree.walk(func(n *ProgressNode) {
+		flat := *n
+		flat.Children = nil
+		if old, ok := prev[n.id()]; ok && old.Phase == flat.Phase && old.Ready == flat.Ready && old.Reason == flat.Reason && old.Message == flat.Message {
+			return
+		}
+		prev[n.id()] = flat
+		events = append(events, ProgressEvent{
+			Time:         metav1.NewTime(now),
+			ProgressNode: flat,
+		})
+	})
+	return events
+}
+
+func printProgressTree(out io.Writer, tree *ProgressNode) error {
+	w := printers.GetNewTabWriter(out)
+	fmt.Fprintln(w, "NAME\tPHASE\tREADY\tREASON\tMESSAGE")
+	printProgressNode(w, tree, "", "")
+	return w.Flush()
+}
+
+func printProgressNode(w io.Writer, n *ProgressNode, prefix, childPrefix string) {
+	message := strings.ReplaceAll(n.Message, "\n", " ")
+	fmt.Fprintf(w, "%s%s/%s\t%s\t%s\t%s\t%s\n", prefix, n.Kind, n.Name, orNone(n.Phase), n.Ready, orNone(n.Reason), orNone(message))
+	for i, child := range n.Children {
+		if i == len(n.Children)-1 {
+			printProgressNode(w, child, childPrefix+"└─", childPrefix+"  ")
+			continue
+		}
+		printProgressNode(w, child, childPrefix+"├─", childPrefix+"│ ")
+	}
+}
+
+// RunShowProgress polls the cluster tree and prints it each time it changes.
+// With WaitFor it returns when the whole tree is ready and fails when the timeout expires
+// or the command is interrupted first.
+func (o *ShowProgressOptions) RunShowProgress(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	prev := make(map[string]ProgressNode)
+	var tree *ProgressNode
+	for {
+		current, err := o.Tree(ctx)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		// a read cut by the deadline keeps the last tree for the not ready objects of the error
+		if err == nil {
+			tree = current
+			err = o.printProgress(prev, tree)
+			if err != nil {
+				return err
 			}
-			if ev.CreationTimestamp.After(obj.GetCreationTimestamp().Time) && (ev.Reason != "" || ev.Message != "") && ev.Count == 1 {
-				for _, item := range objs {
-					if item.GetName() == ev.InvolvedObject.Name {
-						fmt.Fprintf(o.IOStreams.Out, "Reason: %s Message: %s\n", ev.Reason, ev.Message)
-						break
-					}
-				}
+			if o.WaitFor == progressWaitReady && len(tree.NotReady()) == 0 && len(tree.Children) > 0 {
+				return nil
 			}
 		}
-	}(cmd.Context())
-	<-sig
-	w.Stop()
-	return nil
+		select {
+		case <-ctx.Done():
+			if o.WaitFor != "" {
+				return o.waitError(ctx.Err(), tree)
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (o *ShowProgressOptions) printProgress(prev map[string]ProgressNode, tree *ProgressNode) error {
+	events := progressEvents(prev, tree, time.Now())
+	if len(events) == 0 {
+		return nil
+	}
+	if o.Output == progressOutputJSON {
+		for _, e := range events {
+			byt, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(o.IOStreams.Out, string(byt))
+		}
+		return nil
+	}
+	fmt.Fprintln(o.IOStreams.Out)
+	return printProgressTree(o.IOStreams.Out, tree)
+}
+
+func (o *ShowProgressOptions) waitError(ctxErr error, tree *ProgressNode) error {
+	reason := "interrupted"
+	if errors.Is(ctxErr, context.DeadlineExceeded) {
+		reason = "timed out"
+	}
+	if tree == nil {
+		return errors.Errorf("%s waiting for cluster %s/%s", reason, o.Namespace, o.ClusterName)
+	}
+	notReady := make([]string, 0)
+	for _, n := range tree.NotReady() {
+		notReady = append(notReady, fmt.Sprintf("%s/%s", n.Kind, n.Name))
+	}
+	return errors.Errorf("%s waiting for cluster %s/%s to be %s, not ready: %s", reason, o.Namespace, o.ClusterName, o.WaitFor, strings.Join(notReady, ", "))
 }
 
 func NewCmdShowProgress(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
@@ -128,18 +425,29 @@ func NewCmdShowProgress(f cmdutil.Factory, streams genericclioptions.IOStreams)
 	cmd := &cobra.Command{
 		Use:                   "show-progress [cluster name]",
 		DisableFlagsInUseLine: true,
-		Short:                 "Show events of a clusters and child objects",
-		Long:                  LongDesc(`Show events of a clusters and child objects`),
+		Short:                 "Show the progress of a cluster and its child objects",
+		Long: LongDesc(`Show the progress of a cluster and its child objects.
+		Prints the tree of the cluster, from the Cluster API cluster and control plane to machine deployments,
+		machine pools, machines and the cluster Helm releases, with the phase and Ready condition of each object.
+		The tree is printed again when it changes. The json output prints one event per line
+		each time an object is added or changes. With --wait-for=ready the command exits when all objects are ready
+		and fails when the timeout expires first.`),
 		Example: Examples(`
-		# Show events of a clusters and child objects in default namespace
+		# Show the progress of a cluster in default namespace
 		undistro show-progress cool-cluster
-		# Show events of a clusters and child objects in others namespace
+		# Show the progress of a cluster in others namespace
 		undistro show-progress cool-cluster -n cool-namespace
+		# Wait up to 30 minutes for the cluster to be ready
+		undistro show-progress cool-cluster --wait-for=ready --timeout=30m
+		# Stream progress events for automation
+		undistro show-progress cool-cluster -o json
 		`),
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdutil.CheckErr(o.Complete(f, cmd, args))
-			cmdutil.CheckErr(o.RunShowProgress(f, cmd))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.RunShowProgress(cmd.Context()))
 		},
 	}
+	o.AddFlags(cmd)
 	return cmd
 }
@@ -0,0 +1,195 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getupio-undistro/meta"
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	capicp "sigs.k8s.io/cluster-api/controlplane/kubeadm/api/v1alpha4"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newProgressObjects(workerReady corev1.ConditionStatus) []client.Object {
+	labels := map[string]string{clusterv1.ClusterLabelName: "cool-cluster"}
+	ready := clusterv1.Conditions{{Type: clusterv1.ReadyCondition, Status: corev1.ConditionTrue}}
+	return []client.Object{
+		&appv1alpha1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cool-cluster", Namespace: "default"},
+			Status: appv1alpha1.ClusterStatus{
+				Conditions: []metav1.Condition{
+					{Type: meta.ReadyCondition, Status: metav1.ConditionTrue, Reason: "ClusterReady"},
+				},
+			},
+		},
+		&clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cool-cluster", Namespace: "default"},
+			Spec: clusterv1.ClusterSpec{
+				ControlPlaneRef: &corev1.ObjectReference{
+					APIVersion: capicp.GroupVersion.String(),
+					Kind:       "KubeadmControlPlane",
+					Name:       "cool-cluster",
+				},
+			},
+			Status: clusterv1.ClusterStatus{Phase: "Provisioned", Conditions: ready},
+		},
+		&capicp.KubeadmControlPlane{
+			ObjectMeta: metav1.ObjectMeta{Name: "cool-cluster", Namespace: "default"},
+			Status:     capicp.KubeadmControlPlaneStatus{Conditions: ready},
+		},
+		&clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cool-cluster-cp-1",
+				Namespace: "default",
+				Labels:    map[string]string{clusterv1.ClusterLabelName: "cool-cluster", clusterv1.MachineControlPlaneLabelName: ""},
+			},
+			Status: clusterv1.MachineStatus{Phase: "Running", Conditions: ready},
+		},
+		&clusterv1.MachineDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "cool-cluster-mp-0", Namespace: "default", Labels: labels},
+			Status:     clusterv1.MachineDeploymentStatus{Phase: "ScalingUp", Conditions: ready},
+		},
+		&clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cool-cluster-mp-0-1",
+				Namespace: "default",
+				Labels:    map[string]string{clusterv1.ClusterLabelName: "cool-cluster", clusterv1.MachineDeploymentLabelName: "cool-cluster-mp-0"},
+			},
+			Status: clusterv1.MachineStatus{
+				Phase:      "Provisioning",
+				Conditions: clusterv1.Conditions{{Type: clusterv1.ReadyCondition, Status: workerReady, Reason: "WaitingForBootstrapData"}},
+			},
+		},
+		&appv1alpha1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Name: "calico-cool-cluster", Namespace: "default"},
+			Spec:       appv1alpha1.HelmReleaseSpec{ClusterName: "default/cool-cluster"},
+			Status: appv1alpha1.HelmReleaseStatus{
+				Conditions: []metav1.Condition{
+					{Type: meta.ReadyCondition, Status: metav1.ConditionTrue, Reason: "InstallSucceeded"},
+				},
+			},
+		},
+		&appv1alpha1.HelmRelease{
+			ObjectMeta: metav1.ObjectMeta{Name: "calico-other-cluster", Namespace: "default"},
+			Spec:       appv1alpha1.HelmReleaseSpec{ClusterName: "default/other-cluster"},
+		},
+	}
+}
+
+func newProgressOptions(workerReady corev1.ConditionStatus) (*ShowProgressOptions, *bytes.Buffer) {
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewShowProgressOptions(streams)
+	o.Namespace = "default"
+	o.ClusterName = "cool-cluster"
+	o.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(newProgressObjects(workerReady)...).Build()
+	return o, out
+}
+
+func TestShowProgressOptions_Tree(t *testing.T) {
+	o, _ := newProgressOptions(corev1.ConditionFalse)
+	tree, err := o.Tree(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	err = printProgressTree(out, tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `NAME                                      PHASE          READY   REASON                    MESSAGE
+Cluster/cool-cluster                      <none>         True    ClusterReady              <none>
+├─CAPICluster/cool-cluster                Provisioned    True    <none>                    <none>
+│ ├─KubeadmControlPlane/cool-cluster      <none>         True    <none>                    <none>
+│ │ └─Machine/cool-cluster-cp-1           Running        True    <none>                    <none>
+│ └─MachineDeployment/cool-cluster-mp-0   ScalingUp      True    <none>                    <none>
+│   └─Machine/cool-cluster-mp-0-1         Provisioning   False   WaitingForBootstrapData   <none>
+└─HelmRelease/calico-cool-cluster         <none>         True    InstallSucceeded          <none>
+`
+	if got := out.String(); got != want {
+		t.Errorf("printProgressTree() =\n%s\nwant\n%s", got, want)
+	}
+	notReady := tree.NotReady()
+	if len(notReady) != 1 || notReady[0].Name != "cool-cluster-mp-0-1" {
+		t.Errorf("NotReady() = %v", notReady)
+	}
+	prev := make(map[string]ProgressNode)
+	if events := progressEvents(prev, tree, time.Now()); len(events) != 7 {
+		t.Errorf("progressEvents() = %d events, want 7", len(events))
+	}
+	if events := progressEvents(prev, tree, time.Now()); len(events) != 0 {
+		t.Errorf("progressEvents() of unchanged tree = %v", events)
+	}
+}
+
+func TestShowProgressOptions_RunShowProgress(t *testing.T) {
+	tests := []struct {
+		name        string
+		workerReady corev1.ConditionStatus
+		interrupt   bool
+		err         string
+	}{
+		{
+			name:        "ready",
+			workerReady: corev1.ConditionTrue,
+		},
+		{
+			name:        "timeout",
+			workerReady: corev1.ConditionFalse,
+			err:         "timed out waiting for cluster default/cool-cluster to be ready, not ready: Machine/cool-cluster-mp-0-1",
+		},
+		{
+			name:        "interrupted",
+			workerReady: corev1.ConditionFalse,
+			interrupt:   true,
+			err:         "interrupted waiting for cluster default/cool-cluster to be ready, not ready: Machine/cool-cluster-mp-0-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, out := newProgressOptions(tt.workerReady)
+			o.Output = progressOutputJSON
+			o.WaitFor = progressWaitReady
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.interrupt {
+				time.AfterFunc(100*time.Millisecond, cancel)
+			} else {
+				o.Timeout = 100 * time.Millisecond
+			}
+			err := o.RunShowProgress(ctx)
+			if tt.err == "" && err != nil {
+				t.Errorf("RunShowProgress() error = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("RunShowProgress() error = %v, want %q", err, tt.err)
+			}
+			if lines := strings.Count(out.String(), "\n"); lines != 7 {
+				t.Errorf("RunShowProgress() printed %d events, want 7:\n%s", lines, out.String())
+			}
+		})
+	}
+}
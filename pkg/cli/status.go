@@ -0,0 +1,352 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/getupio-undistro/meta"
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	"github.com/getupio-undistro/undistro/pkg/util"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	statusOutputText  = "text"
+	statusOutputJSON  = "json"
+	statusOutputJUnit = "junit"
+)
+
+// ObjectStatus is the health of an object reconciled by UnDistro.
+type ObjectStatus struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Healthy   bool   `json:"healthy"`
+	// Condition is the last condition of the object.
+	Condition metav1.Condition `json:"condition"`
+	// LastReconciled is when the current generation of the object was reconciled successfully.
+	// It is unset while the object is not ready or the controller didn't observe the last generation.
+	LastReconciled *metav1.Time `json:"lastReconciled,omitempty"`
+}
+
+// StatusReport is the health of the objects in the management cluster.
+type StatusReport struct {
+	Healthy   bool           `json:"healthy"`
+	Unhealthy int            `json:"unhealthy"`
+	Objects   []ObjectStatus `json:"objects"`
+}
+
+type objectWithConditions interface {
+	client.Object
+	GetStatusConditions() *[]metav1.Condition
+}
+
+type StatusOptions struct {
+	genericclioptions.IOStreams
+	Output string
+	Client client.Client
+}
+
+func NewStatusOptions(streams genericclioptions.IOStreams) *StatusOptions {
+	return &StatusOptions{
+		IOStreams: streams,
+		Output:    statusOutputText,
+	}
+}
+
+func (o *StatusOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "Output format, one of text, json or junit")
+}
+
+func (o *StatusOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return cmdutil.UsageErrorf(cmd, "%s", "too many arguments")
+	}
+	cfg, err := f.ToRESTConfig()
+	if err != nil {
+		return errors.Errorf("unable to get config: %v", err)
+	}
+	o.Client, err = client.New(cfg, client.Options{
+		Scheme: scheme.Scheme,
+	})
+	if err != nil {
+		return errors.Errorf("unable to create client: %v", err)
+	}
+	return nil
+}
+
+func (o *StatusOptions) Validate() error {
+	switch o.Output {
+	case statusOutputText, statusOutputJSON, statusOutputJUnit:
+		return nil
+	}
+	return errors.Errorf("unsupported output %q, use one of text, json or junit", o.Output)
+}
+
+// Report walks the objects reconciled by UnDistro in all namespaces.
+func (o *StatusOptions) Report(ctx context.Context) (StatusReport, error) {
+	lists := []struct {
+		kind string
+		list client.ObjectList
+	}{
+		{"Provider", &metadatav1alpha1.ProviderList{}},
+		{"Cluster", &appv1alpha1.ClusterList{}},
+		{"HelmRelease", &appv1alpha1.HelmReleaseList{}},
+		{"DefaultPolicies", &appv1alpha1.DefaultPoliciesList{}},
+		{"Identity", &appv1alpha1.IdentityList{}},
+		{"Observer", &appv1alpha1.ObserverList{}},
+	}
+	report := StatusReport{
+		Objects: make([]ObjectStatus, 0),
+	}
+	for _, l := range lists {
+		err := o.Client.List(ctx, l.list)
+		if err != nil {
+			return report, errors.Wrapf(err, "unable to list %s", l.kind)
+		}
+		items, err := apimeta.ExtractList(l.list)
+		if err != nil {
+			return report, err
+		}
+		for _, item := range items {
+			obj, ok := item.(objectWithConditions)
+			if !ok {
+				continue
+			}
+			st := objectStatus(l.kind, obj)
+			if !st.Healthy {
+				report.Unhealthy++
+			}
+			report.Objects = append(report.Objects, st)
+		}
+	}
+	report.Healthy = report.Unhealthy == 0
+	return report, nil
+}
+
+func objectStatus(kind string, obj objectWithConditions) ObjectStatus {
+	conditions := *obj.GetStatusConditions()
+	st := ObjectStatus{
+		Kind:      kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Healthy:   meta.InReadyCondition(conditions),
+		Condition: util.LastCondition(conditions),
+	}
+	if ready := apimeta.FindStatusCondition(conditions, meta.ReadyCondition); ready != nil {
+		st.LastReconciled = lastReconciled(obj, *ready)
+		// the last condition may be a component one, the failure is in the Ready condition
+		if !st.Healthy {
+			st.Condition = *ready
+		}
+	}
+	return st
+}
+
+// lastReconciled returns the time the Ready condition turned true for the current generation of the object.
+// The generation is observed in the condition or, when controllers don't set it there, in the object status.
+func lastReconciled(obj objectWithConditions, ready metav1.Condition) *metav1.Time {
+	if ready.Status != metav1.ConditionTrue {
+		return nil
+	}
+	observed := ready.ObservedGeneration
+	if observed == 0 {
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil
+		}
+		observed, _, _ = unstructured.NestedInt64(u, "status", "observedGeneration")
+	}
+	if observed != obj.GetGeneration() {
+		return nil
+	}
+	t := ready.LastTransitionTime
+	return &t
+}
+
+func (o *StatusOptions) RunStatus(ctx context.Context) error {
+	report, err := o.Report(ctx)
+	if err != nil {
+		return err
+	}
+	switch o.Output {
+	case statusOutputJSON:
+		byt, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.IOStreams.Out, string(byt))
+	case statusOutputJUnit:
+		err = printStatusJUnit(o.IOStreams.Out, report)
+	default:
+		err = printStatus(o.IOStreams.Out, report, time.Now())
+	}
+	if err != nil {
+		return err
+	}
+	if !report.Healthy {
+		return errors.Errorf("%d of %d objects are unhealthy", report.Unhealthy, len(report.Objects))
+	}
+	return nil
+}
+
+func printStatus(out io.Writer, report StatusReport, now time.Time) error {
+	w := printers.GetNewTabWriter(out)
+	fmt.Fprintln(w, "KIND\tNAMESPACE\tNAME\tHEALTH\tCONDITION\tREASON\tLAST RECONCILED")
+	for _, st := range report.Objects {
+		health := "OK"
+		if !st.Healthy {
+			health = "FAIL"
+		}
+		reconciled := "<none>"
+		if st.LastReconciled != nil {
+			reconciled = age(*st.LastReconciled, now)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", st.Kind, orNone(st.Namespace), st.Name, health, orNone(st.Condition.Type), orNone(st.Condition.Reason), reconciled)
+	}
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+	if report.Healthy {
+		_, err = fmt.Fprintf(out, "\nAll %d objects are healthy\n", len(report.Objects))
+		return err
+	}
+	fmt.Fprintf(out, "\n%d of %d objects are unhealthy:\n", report.Unhealthy, len(report.Objects))
+	for _, st := range report.Objects {
+		if st.Healthy {
+			continue
+		}
+		name := st.Name
+		if st.Namespace != "" {
+			name = fmt.Sprintf("%s/%s", st.Namespace, st.Name)
+		}
+		_, err = fmt.Fprintf(out, "  %s %s: %s: %s\n", st.Kind, name, orNone(st.Condition.Reason), orNone(st.Condition.Message))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// printStatusJUnit prints a test suite per kind with a test case per object, failed when the object is unhealthy.
+func printStatusJUnit(out io.Writer, report StatusReport) error {
+	suites := junitTestSuites{
+		Tests:    len(report.Objects),
+		Failures: report.Unhealthy,
+	}
+	index := make(map[string]int)
+	for _, st := range report.Objects {
+		i, ok := index[st.Kind]
+		if !ok {
+			i = len(suites.Suites)
+			index[st.Kind] = i
+			suites.Suites = append(suites.Suites, junitTestSuite{Name: st.Kind})
+		}
+		name := st.Name
+		if st.Namespace != "" {
+			name = fmt.Sprintf("%s/%s", st.Namespace, st.Name)
+		}
+		tc := junitTestCase{
+			Name:      name,
+			ClassName: st.Kind,
+		}
+		if !st.Healthy {
+			tc.Failure = &junitFailure{
+				Message: st.Condition.Reason,
+				Type:    st.Condition.Type,
+				Text:    st.Condition.Message,
+			}
+			suites.Suites[i].Failures++
+		}
+		suites.Suites[i].Tests++
+		suites.Suites[i].Cases = append(suites.Suites[i].Cases, tc)
+	}
+	byt, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s%s\n", xml.Header, byt)
+	return err
+}
+
+func NewCmdStatus(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewStatusOptions(streams)
+	cmd := &cobra.Command{
+		Use:                   "status",
+		DisableFlagsInUseLine: true,
+		Short:                 "Show the health of the objects managed by UnDistro",
+		Long: LongDesc(`Show the health of the objects managed by UnDistro.
+		Walks providers, clusters, Helm releases, default policies, identities and observers in all namespaces
+		of the management cluster and shows their last condition and the time since their last successful reconciliation.
+		Exits with a non-zero code when an object is not ready.`),
+		Example: Examples(`
+		# Show the health of the management cluster
+		undistro status
+		# Gate a pipeline on the management cluster health
+		undistro status -o junit > undistro-status.xml
+		`),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.RunStatus(cmd.Context()))
+		},
+	}
+	o.AddFlags(cmd)
+	return cmd
+}
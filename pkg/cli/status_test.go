@@ -0,0 +1,186 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getupio-undistro/meta"
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func readyCondition(status metav1.ConditionStatus, reason, message string, since time.Time) []metav1.Condition {
+	return []metav1.Condition{
+		{
+			Type:               meta.ReadyCondition,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			LastTransitionTime: metav1.NewTime(since),
+		},
+	}
+}
+
+func TestStatusOptions_RunStatus(t *testing.T) {
+	now := time.Now()
+	provider := &metadatav1alpha1.Provider{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws", Generation: 1},
+		Status: metadatav1alpha1.ProviderStatus{
+			ObservedGeneration: 1,
+			Conditions:         readyCondition(metav1.ConditionTrue, "InstallSucceeded", "Release reconciliation succeeded", now.Add(-time.Hour)),
+		},
+	}
+	cluster := &appv1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cool-cluster", Namespace: "default", Generation: 2},
+		Status: appv1alpha1.ClusterStatus{
+			ObservedGeneration: 2,
+			Conditions:         readyCondition(metav1.ConditionTrue, "InstallSucceeded", "Cluster ready", now.Add(-5*time.Hour)),
+		},
+	}
+	hr := &appv1alpha1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "kyverno-cool-cluster", Namespace: "default"},
+		Status: appv1alpha1.HelmReleaseStatus{
+			Conditions: readyCondition(metav1.ConditionFalse, "InstallFailed", "timed out waiting for the condition", now.Add(-10*time.Minute)),
+		},
+	}
+	tests := []struct {
+		name     string
+		objs     []client.Object
+		output   string
+		contains []string
+		wantErr  bool
+	}{
+		{
+			name:     "healthy text",
+			objs:     []client.Object{provider, cluster},
+			output:   statusOutputText,
+			contains: []string{"LAST RECONCILED", "Provider", "aws", "OK", "Cluster", "cool-cluster", "5h", "All 2 objects are healthy"},
+		},
+		{
+			name:     "unhealthy text",
+			objs:     []client.Object{provider, cluster, hr},
+			output:   statusOutputText,
+			contains: []string{"FAIL", "1 of 3 objects are unhealthy", "HelmRelease default/kyverno-cool-cluster: InstallFailed: timed out waiting for the condition"},
+			wantErr:  true,
+		},
+		{
+			name:     "unhealthy json",
+			objs:     []client.Object{cluster, hr},
+			output:   statusOutputJSON,
+			contains: []string{`"healthy": false`, `"unhealthy": 1`, `"reason": "InstallFailed"`, `"lastReconciled": `},
+			wantErr:  true,
+		},
+		{
+			name:   "unhealthy junit",
+			objs:   []client.Object{cluster, hr},
+			output: statusOutputJUnit,
+			contains: []string{
+				`<testsuites tests="2" failures="1">`,
+				`<testsuite name="HelmRelease" tests="1" failures="1">`,
+				`<failure message="InstallFailed" type="` + meta.ReadyCondition + `">timed out waiting for the condition</failure>`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := bytes.Buffer{}
+			o := NewStatusOptions(genericclioptions.IOStreams{Out: &out, ErrOut: &out})
+			o.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.objs...).Build()
+			o.Output = tt.output
+			err := o.RunStatus(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("RunStatus() output doesn't contain %q\n%s", s, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestLastReconciled(t *testing.T) {
+	since := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name       string
+		generation int64
+		observed   int64
+		condition  metav1.Condition
+		want       bool
+	}{
+		{
+			name:       "ready for the current generation",
+			generation: 2,
+			observed:   2,
+			condition:  readyCondition(metav1.ConditionTrue, "InstallSucceeded", "Cluster ready", since)[0],
+			want:       true,
+		},
+		{
+			name:       "generation not observed",
+			generation: 3,
+			observed:   2,
+			condition:  readyCondition(metav1.ConditionTrue, "InstallSucceeded", "Cluster ready", since)[0],
+		},
+		{
+			name:       "generation observed in the condition",
+			generation: 3,
+			observed:   2,
+			condition: metav1.Condition{
+				Type:               meta.ReadyCondition,
+				Status:             metav1.ConditionTrue,
+				ObservedGeneration: 3,
+				LastTransitionTime: metav1.NewTime(since),
+			},
+			want: true,
+		},
+		{
+			name:       "not ready",
+			generation: 2,
+			observed:   2,
+			condition:  readyCondition(metav1.ConditionFalse, "InstallFailed", "timed out", since)[0],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := &appv1alpha1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "cool-cluster", Namespace: "default", Generation: tt.generation},
+				Status: appv1alpha1.ClusterStatus{
+					ObservedGeneration: tt.observed,
+					Conditions:         []metav1.Condition{tt.condition},
+				},
+			}
+			got := lastReconciled(cl, tt.condition)
+			if (got != nil) != tt.want {
+				t.Fatalf("lastReconciled() = %v, want set %v", got, tt.want)
+			}
+			if got != nil && !got.Equal(&tt.condition.LastTransitionTime) {
+				t.Errorf("lastReconciled() = %v, want %v", got, tt.condition.LastTransitionTime)
+			}
+		})
+	}
+}
@@ -113,9 +113,14 @@ func NewUndistroCommand(in io.Reader, out, err io.Writer) *cobra.Command {
 	cmd.AddCommand(NewCmdGet(f, ioStreams))
 	cmd.AddCommand(NewCmdCreate(f, ioStreams))
 	cmd.AddCommand(NewCmdInstall(cfgFlags, ioStreams))
+	cmd.AddCommand(NewCmdPreflight(cfgFlags, ioStreams))
+	cmd.AddCommand(NewCmdBundle(cfgFlags, ioStreams))
 	cmd.AddCommand(NewCmdMove(cfgFlags, ioStreams))
+	cmd.AddCommand(NewCmdConfig(cfgFlags, ioStreams))
 	cmd.AddCommand(NewCmdShowProgress(f, ioStreams))
+	cmd.AddCommand(NewCmdStatus(f, ioStreams))
 	cmd.AddCommand(NewCmdUpgrade(f, ioStreams))
+	cmd.AddCommand(NewCmdRollback(f, ioStreams))
 	cmd.AddCommand(NewCmdCompletion(ioStreams))
 	cmd.AddCommand(version.NewVersionCommand())
 	return cmd
@@ -16,105 +16,369 @@ limitations under the License.
 package cli
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"sort"
+	"time"
 
+	"github.com/getupio-undistro/meta"
 	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
-	"github.com/getupio-undistro/undistro/pkg/helm"
+	"github.com/getupio-undistro/undistro/pkg/compatibility"
 	"github.com/getupio-undistro/undistro/pkg/scheme"
+	"github.com/getupio-undistro/undistro/pkg/util"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
-	"helm.sh/helm/v3/pkg/getter"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const (
+	upgradeHistoryName = "undistro-upgrade-history"
+	upgradeHistoryKey  = "history"
+	// maxUpgradeHistory is the number of upgrades that can be rolled back.
+	maxUpgradeHistory = 10
+)
+
+var upgradePollInterval = 5 * time.Second
+
+// upgradeRecord is the set of chart versions installed before an upgrade.
+type upgradeRecord struct {
+	Time    metav1.Time       `json:"time"`
+	Release string            `json:"release"`
+	Charts  map[string]string `json:"charts"`
+}
+
 type UpgradeOptions struct {
 	genericclioptions.IOStreams
-	Version string
+	Version           string
+	Plan              bool
+	Timeout           time.Duration
+	KubernetesVersion string
+	Matrix            compatibility.Matrix
+	Client            client.Client
 }
 
-const releaseName = "undistro"
-
 func NewUpgradeOptions(streams genericclioptions.IOStreams) *UpgradeOptions {
 	return &UpgradeOptions{
 		IOStreams: streams,
+		Timeout:   10 * time.Minute,
 	}
 }
 
 func (o *UpgradeOptions) AddFlags(flags *pflag.FlagSet) {
-	flags.StringVar(&o.Version, "version", "", "provider version to upgrade")
+	flags.StringVar(&o.Version, "version", "", "UnDistro version to upgrade to (default the latest version of the compatibility matrix)")
+	flags.BoolVar(&o.Plan, "plan", o.Plan, "Print the plan without applying it")
+	flags.DurationVar(&o.Timeout, "timeout", o.Timeout, "Time to wait for each component to be ready")
 }
 
-func (o *UpgradeOptions) RunUpgrade(f cmdutil.Factory, cmd *cobra.Command) error {
-	var clientOpts []getter.Option
+func (o *UpgradeOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return cmdutil.UsageErrorf(cmd, "%s", "too many arguments, all core components are upgraded together")
+	}
+	var err error
+	o.Matrix, err = compatibility.Default()
+	if err != nil {
+		return err
+	}
+	dc, err := f.ToDiscoveryClient()
+	if err != nil {
+		return errors.Errorf("unable to create discovery client: %v", err)
+	}
+	v, err := dc.ServerVersion()
+	if err != nil {
+		return errors.Errorf("unable to get kubernetes version: %v", err)
+	}
+	o.KubernetesVersion = v.GitVersion
+	o.Client, err = newUpgradeClient(f)
+	return err
+}
+
+func newUpgradeClient(f cmdutil.Factory) (client.Client, error) {
 	cfg, err := f.ToRESTConfig()
 	if err != nil {
-		return errors.Errorf("unable to get config: %v", err)
+		return nil, errors.Errorf("unable to get config: %v", err)
 	}
-	workloadClient, err := client.New(cfg, client.Options{
+	c, err := client.New(cfg, client.Options{
 		Scheme: scheme.Scheme,
 	})
 	if err != nil {
-		return errors.Errorf("unable to create client: %v", err)
+		return nil, errors.Errorf("unable to create client: %v", err)
 	}
-	key := client.ObjectKey{
-		Name:      releaseName,
-		Namespace: ns,
+	return c, nil
+}
+
+func (o *UpgradeOptions) RunUpgrade(ctx context.Context) error {
+	release := o.Matrix.Latest()
+	if o.Version != "" {
+		var err error
+		release, err = o.Matrix.Get(o.Version)
+		if err != nil {
+			return err
+		}
 	}
-	p := appv1alpha1.HelmRelease{}
-	err = workloadClient.Get(cmd.Context(), key, &p)
+	installed, err := installedCharts(ctx, o.Client)
 	if err != nil {
 		return err
 	}
-	if o.Version == "" {
-		chartRepo, err := helm.NewChartRepository(undistroRepo, getters, clientOpts)
+	plan, err := compatibility.PlanUpgrade(release, installed, o.KubernetesVersion)
+	if err != nil {
+		return err
+	}
+	if len(plan.Steps) == 0 {
+		fmt.Fprintf(o.Out, "UnDistro is up to date with release %s\n", release.Version)
+		return nil
+	}
+	fmt.Fprintf(o.Out, "Upgrade plan from UnDistro %s to %s:\n", installed[compatibility.UnDistroChart], release.Version)
+	err = printPlan(o.Out, plan)
+	if err != nil || o.Plan {
+		return err
+	}
+	err = recordUpgrade(ctx, o.Client, release.Version, installed)
+	if err != nil {
+		return err
+	}
+	err = applyPlan(ctx, o.Client, o.Out, plan, o.Timeout)
+	if err != nil {
+		return errors.Wrap(err, "upgrade failed, run undistro rollback to restore the previous versions")
+	}
+	fmt.Fprintf(o.Out, "UnDistro upgraded to %s\n", release.Version)
+	return nil
+}
+
+// installedCharts returns the versions of the core components installed in the management cluster.
+func installedCharts(ctx context.Context, c client.Client) (map[string]string, error) {
+	installed := make(map[string]string)
+	for _, chart := range compatibility.Order {
+		hr := appv1alpha1.HelmRelease{}
+		err := c.Get(ctx, client.ObjectKey{Name: chart, Namespace: ns}, &hr)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
-		p.TypeMeta = metav1.TypeMeta{
-			Kind:       "HelmRelease",
-			APIVersion: appv1alpha1.GroupVersion.String(),
+		installed[chart] = hr.Spec.Chart.Version
+	}
+	return installed, nil
+}
+
+func printPlan(out io.Writer, plan compatibility.Plan) error {
+	w := printers.GetNewTabWriter(out)
+	fmt.Fprintln(w, "STEP\tCOMPONENT\tFROM\tTO")
+	for i, s := range plan.Steps {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", i+1, s.Chart, s.From, s.To)
+	}
+	return w.Flush()
+}
+
+// applyPlan moves the HelmReleases to the versions of the plan, one at a time,
+// waiting for each one to be ready with the new version before moving the next.
+func applyPlan(ctx context.Context, c client.Client, out io.Writer, plan compatibility.Plan, timeout time.Duration) error {
+	for _, s := range plan.Steps {
+		fmt.Fprintf(out, "Moving %s from %s to %s\n", s.Chart, s.From, s.To)
+		key := client.ObjectKey{Name: s.Chart, Namespace: ns}
+		hr := appv1alpha1.HelmRelease{}
+		err := c.Get(ctx, key, &hr)
+		if err != nil {
+			return err
 		}
-		fmt.Fprintf(o.IOStreams.Out, "Downloading repository index\n")
-		err = chartRepo.DownloadIndex()
+		hr.Spec.Chart.Version = s.To
+		hr.Spec.Paused = false
+		err = c.Update(ctx, &hr)
 		if err != nil {
-			return errors.Wrap(err, "failed to download repository index")
+			return errors.Wrapf(err, "unable to update %s", s.Chart)
 		}
-		versions := chartRepo.Index.Entries[releaseName]
-		if versions.Len() == 0 {
-			return errors.Errorf("chart %s not found", releaseName)
+		err = wait.PollImmediate(upgradePollInterval, timeout, func() (bool, error) {
+			err := c.Get(ctx, key, &hr)
+			if err != nil {
+				return false, err
+			}
+			return hr.Status.LastAppliedRevision == s.To && meta.InReadyCondition(hr.Status.Conditions), nil
+		})
+		if errors.Is(err, wait.ErrWaitTimeout) {
+			_, reason, message := readyColumns(hr.Status.Conditions)
+			return errors.Errorf("%s %s is not ready after %s: %s: %s", s.Chart, s.To, timeout, orNone(reason), orNone(message))
 		}
-		version := versions[0]
-		fmt.Fprintf(o.IOStreams.Out, "Upgrading to %s version\n", version.Version)
-		_, err = chartRepo.Get(releaseName, version.Version)
 		if err != nil {
 			return err
 		}
-		o.Version = version.Version
 	}
-	p.Spec.Chart.RepoChartSource.Version = o.Version
-	p.Spec.Paused = false
-	return workloadClient.Update(cmd.Context(), &p)
+	return nil
+}
+
+func readUpgradeHistory(ctx context.Context, c client.Client) (corev1.ConfigMap, []upgradeRecord, error) {
+	cm := corev1.ConfigMap{}
+	history := make([]upgradeRecord, 0)
+	err := c.Get(ctx, client.ObjectKey{Name: upgradeHistoryName, Namespace: ns}, &cm)
+	if apierrors.IsNotFound(err) {
+		cm.Name = upgradeHistoryName
+		cm.Namespace = ns
+		return cm, history, nil
+	}
+	if err != nil {
+		return cm, nil, err
+	}
+	if raw := cm.Data[upgradeHistoryKey]; raw != "" {
+		err = json.Unmarshal([]byte(raw), &history)
+		if err != nil {
+			return cm, nil, errors.Wrapf(err, "invalid upgrade history in %s/%s", ns, upgradeHistoryName)
+		}
+	}
+	return cm, history, nil
+}
+
+func writeUpgradeHistory(ctx context.Context, c client.Client, cm corev1.ConfigMap, history []upgradeRecord) error {
+	if len(history) > maxUpgradeHistory {
+		history = history[len(history)-maxUpgradeHistory:]
+	}
+	byt, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	cm.TypeMeta = metav1.TypeMeta{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+	}
+	cm.Data = map[string]string{
+		upgradeHistoryKey: string(byt),
+	}
+	_, err = util.CreateOrUpdate(ctx, c, &cm)
+	return err
+}
+
+// recordUpgrade saves the versions installed before an upgrade, so it can be rolled back.
+func recordUpgrade(ctx context.Context, c client.Client, release string, installed map[string]string) error {
+	cm, history, err := readUpgradeHistory(ctx, c)
+	if err != nil {
+		return err
+	}
+	history = append(history, upgradeRecord{
+		Time:    metav1.Now(),
+		Release: release,
+		Charts:  installed,
+	})
+	return writeUpgradeHistory(ctx, c, cm, history)
 }
 
 func NewCmdUpgrade(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
 	o := NewUpgradeOptions(streams)
 	cmd := &cobra.Command{
-		Use:                   "upgrade [provider name]",
+		Use:                   "upgrade",
+		DisableFlagsInUseLine: true,
+		Short:                 "Upgrade the core components of UnDistro",
+		Long: LongDesc(`Upgrade the core components of UnDistro.
+		Plans the upgrade of every installed core component to the chart versions of a release
+		in the compatibility matrix shipped with the CLI, checking the current UnDistro version and the
+		management cluster Kubernetes version. The components are upgraded in dependency order,
+		waiting for each one to be ready. The previous versions are recorded so undistro rollback can restore them.`),
+		Example: Examples(`
+		# Show the plan to upgrade to the latest release
+		undistro upgrade --plan
+		# Upgrade to the latest release
+		undistro upgrade
+		# Upgrade to a release
+		undistro upgrade --version v0.37.2
+		`),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.RunUpgrade(cmd.Context()))
+		},
+	}
+	o.AddFlags(cmd.Flags())
+	return cmd
+}
+
+type RollbackOptions struct {
+	genericclioptions.IOStreams
+	Plan    bool
+	Timeout time.Duration
+	Client  client.Client
+}
+
+func NewRollbackOptions(streams genericclioptions.IOStreams) *RollbackOptions {
+	return &RollbackOptions{
+		IOStreams: streams,
+		Timeout:   10 * time.Minute,
+	}
+}
+
+func (o *RollbackOptions) AddFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.Plan, "plan", o.Plan, "Print the plan without applying it")
+	flags.DurationVar(&o.Timeout, "timeout", o.Timeout, "Time to wait for each component to be ready")
+}
+
+func (o *RollbackOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) != 0 {
+		return cmdutil.UsageErrorf(cmd, "%s", "too many arguments")
+	}
+	var err error
+	o.Client, err = newUpgradeClient(f)
+	return err
+}
+
+// RunRollback restores the versions recorded before the last upgrade and removes the record.
+func (o *RollbackOptions) RunRollback(ctx context.Context) error {
+	cm, history, err := readUpgradeHistory(ctx, o.Client)
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		return errors.New("no upgrade to roll back")
+	}
+	last := history[len(history)-1]
+	installed, err := installedCharts(ctx, o.Client)
+	if err != nil {
+		return err
+	}
+	plan := compatibility.PlanRollback(last.Charts, installed)
+	fmt.Fprintf(o.Out, "Rollback plan of the upgrade to %s made at %s:\n", last.Release, last.Time.Format(time.RFC3339))
+	err = printPlan(o.Out, plan)
+	if err != nil || o.Plan {
+		return err
+	}
+	err = applyPlan(ctx, o.Client, o.Out, plan, o.Timeout)
+	if err != nil {
+		return errors.Wrap(err, "rollback failed")
+	}
+	err = writeUpgradeHistory(ctx, o.Client, cm, history[:len(history)-1])
+	if err != nil {
+		return err
+	}
+	versions := make([]string, 0, len(last.Charts))
+	for chart, v := range last.Charts {
+		versions = append(versions, fmt.Sprintf("%s %s", chart, v))
+	}
+	sort.Strings(versions)
+	fmt.Fprintf(o.Out, "Rolled back to %v\n", versions)
+	return nil
+}
+
+func NewCmdRollback(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewRollbackOptions(streams)
+	cmd := &cobra.Command{
+		Use:                   "rollback",
 		DisableFlagsInUseLine: true,
-		Short:                 "Upgrade a provider",
-		Long:                  LongDesc(`Upgrade a provider to specified version`),
+		Short:                 "Roll back the last upgrade of UnDistro",
+		Long: LongDesc(`Roll back the last upgrade of UnDistro.
+		Restores the core components to the versions recorded before the last upgrade,
+		in reverse dependency order, waiting for each one to be ready.`),
 		Example: Examples(`
-		# Upgrade provider to specified version
-		undistro upgrade undistro --version 0.1.17
-		# Upgrade provider to latest version
-		undistro upgrade undistro
+		# Show the rollback plan
+		undistro rollback --plan
+		# Roll back the last upgrade
+		undistro rollback
 		`),
 		Run: func(cmd *cobra.Command, args []string) {
-			cmdutil.CheckErr(o.RunUpgrade(f, cmd))
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.RunRollback(cmd.Context()))
 		},
 	}
 	o.AddFlags(cmd.Flags())
@@ -0,0 +1,136 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getupio-undistro/meta"
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/compatibility"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newUpgradeRelease(name, version, applied string) *appv1alpha1.HelmRelease {
+	return &appv1alpha1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+		Spec: appv1alpha1.HelmReleaseSpec{
+			Chart: appv1alpha1.ChartSource{
+				RepoChartSource: appv1alpha1.RepoChartSource{Name: name, Version: version},
+			},
+		},
+		Status: appv1alpha1.HelmReleaseStatus{
+			LastAppliedRevision: applied,
+			Conditions: []metav1.Condition{
+				{Type: meta.ReadyCondition, Status: metav1.ConditionTrue, Reason: "UpgradeSucceeded"},
+			},
+		},
+	}
+}
+
+func chartVersions(t *testing.T, c client.Client) map[string]string {
+	installed, err := installedCharts(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return installed
+}
+
+func TestUpgradeOptions_RunUpgrade(t *testing.T) {
+	upgradePollInterval = time.Millisecond
+	matrix, err := compatibility.Parse([]byte(`
+releases:
+  - version: v0.38.0
+    upgradeFrom: ">= 0.37.0"
+    charts:
+      cert-manager: 1.6.1
+      undistro: 0.38.0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the controller is faked by releases already reporting the new versions as applied
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		newUpgradeRelease("cert-manager", "1.5.3", "1.6.1"),
+		newUpgradeRelease("undistro", "0.37.2", "0.38.0"),
+	).Build()
+	streams, _, out, _ := genericclioptions.NewTestIOStreams()
+	o := NewUpgradeOptions(streams)
+	o.Matrix = matrix
+	o.Client = c
+	o.Timeout = time.Second
+	o.Plan = true
+	err = o.RunUpgrade(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := chartVersions(t, c); got["undistro"] != "0.37.2" {
+		t.Errorf("--plan changed the versions: %v", got)
+	}
+	if !strings.Contains(out.String(), "2      undistro       0.37.2   0.38.0") {
+		t.Errorf("RunUpgrade() plan =\n%s", out.String())
+	}
+
+	o.Plan = false
+	err = o.RunUpgrade(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := chartVersions(t, c); got["undistro"] != "0.38.0" || got["cert-manager"] != "1.6.1" {
+		t.Errorf("RunUpgrade() versions = %v", got)
+	}
+	_, history, err := readUpgradeHistory(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Charts["undistro"] != "0.37.2" {
+		t.Errorf("upgrade history = %v", history)
+	}
+
+	hr := appv1alpha1.HelmRelease{}
+	for _, name := range []string{"cert-manager", "undistro"} {
+		err = c.Get(context.Background(), client.ObjectKey{Name: name, Namespace: ns}, &hr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hr.Status.LastAppliedRevision = history[0].Charts[name]
+		err = c.Update(context.Background(), &hr)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := NewRollbackOptions(streams)
+	r.Client = c
+	r.Timeout = time.Second
+	err = r.RunRollback(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := chartVersions(t, c); got["undistro"] != "0.37.2" || got["cert-manager"] != "1.5.3" {
+		t.Errorf("RunRollback() versions = %v", got)
+	}
+	err = r.RunRollback(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no upgrade to roll back") {
+		t.Errorf("RunRollback() without history error = %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/printers"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type ViolationsOptions struct {
+	genericclioptions.IOStreams
+	Namespace   string
+	ClusterName string
+}
+
+func NewViolationsOptions(streams genericclioptions.IOStreams) *ViolationsOptions {
+	return &ViolationsOptions{
+		IOStreams: streams,
+	}
+}
+
+func (o *ViolationsOptions) Complete(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	var err error
+	o.Namespace, _, err = f.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("required 1 argument")
+	}
+	o.ClusterName = args[0]
+	return nil
+}
+
+func (o *ViolationsOptions) RunGetViolations(f cmdutil.Factory, cmd *cobra.Command) error {
+	cfg, err := f.ToRESTConfig()
+	if err != nil {
+		return errors.Errorf("unable to get config: %v", err)
+	}
+	c, err := client.New(cfg, client.Options{
+		Scheme: scheme.Scheme,
+	})
+	if err != nil {
+		return errors.Errorf("unable to create client: %v", err)
+	}
+	list := appv1alpha1.DefaultPoliciesList{}
+	err = c.List(cmd.Context(), &list, client.InNamespace(o.Namespace))
+	if err != nil {
+		return err
+	}
+	clusterName := o.ClusterName
+	if clusterName == "management" {
+		clusterName = ""
+	}
+	for _, p := range list.Items {
+		if p.Spec.ClusterName == clusterName {
+			return printViolations(o.IOStreams.Out, p)
+		}
+	}
+	return errors.Errorf("no default policies found for cluster %s in namespace %s", o.ClusterName, o.Namespace)
+}
+
+func printViolations(out io.Writer, p appv1alpha1.DefaultPolicies) error {
+	if len(p.Status.PolicyReports) == 0 {
+		_, err := fmt.Fprintln(out, "No policy reports found")
+		return err
+	}
+	w := printers.GetNewTabWriter(out)
+	fmt.Fprintln(w, "POLICY\tPASS\tFAIL\tWARN\tERROR\tSKIP")
+	for _, s := range p.Status.PolicyReports {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", s.Policy, s.Pass, s.Fail, s.Warn, s.Error, s.Skip)
+	}
+	if len(p.Status.TopOffenders) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "RESOURCE\tFAILURES\tPOLICIES")
+		for _, o := range p.Status.TopOffenders {
+			resource := fmt.Sprintf("%s/%s", o.Kind, o.Name)
+			if o.Namespace != "" {
+				resource = fmt.Sprintf("%s/%s/%s", o.Kind, o.Namespace, o.Name)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\n", resource, o.Failures, strings.Join(o.Policies, ","))
+		}
+	}
+	return w.Flush()
+}
+
+func NewCmdViolations(f cmdutil.Factory, streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewViolationsOptions(streams)
+	cmd := &cobra.Command{
+		Use:                   "violations [cluster name]",
+		DisableFlagsInUseLine: true,
+		Short:                 "Get policy violations of a cluster",
+		Long: LongDesc(`Get policy violations of a cluster.
+		Shows the pass, fail, warn, error and skip results of each policy
+		and the resources with most failures, as reported by Kyverno.`),
+		Example: Examples(`
+		# Get policy violations of a cluster in default namespace
+		undistro get violations cool-cluster
+		# Get policy violations of a cluster in others namespace
+		undistro get violations cool-cluster -n cool-namespace
+		# Get policy violations of the management cluster
+		undistro get violations management -n undistro-system
+		`),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(f, cmd, args))
+			cmdutil.CheckErr(o.RunGetViolations(f, cmd))
+		},
+	}
+	return cmd
+}
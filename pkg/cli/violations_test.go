@@ -0,0 +1,67 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+)
+
+func Test_printViolations(t *testing.T) {
+	tests := []struct {
+		name   string
+		status appv1alpha1.DefaultPoliciesStatus
+		want   string
+	}{
+		{
+			name: "without reports",
+			want: "No policy reports found\n",
+		},
+		{
+			name: "with offenders",
+			status: appv1alpha1.DefaultPoliciesStatus{
+				PolicyReports: []appv1alpha1.PolicyReportSummary{
+					{Policy: "disallow-latest-tag", Pass: 3, Fail: 2},
+					{Policy: "traffic-deny", Pass: 5},
+				},
+				TopOffenders: []appv1alpha1.PolicyOffender{
+					{Kind: "Pod", Namespace: "default", Name: "nginx", Failures: 2, Policies: []string{"disallow-latest-tag"}},
+				},
+			},
+			want: `POLICY                PASS   FAIL   WARN   ERROR   SKIP
+disallow-latest-tag   3      2      0      0       0
+traffic-deny          5      0      0      0       0
+
+RESOURCE              FAILURES   POLICIES
+Pod/default/nginx     2          disallow-latest-tag
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := bytes.Buffer{}
+			err := printViolations(&out, appv1alpha1.DefaultPolicies{Status: tt.status})
+			if err != nil {
+				t.Fatalf("printViolations() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("printViolations() =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -167,6 +167,16 @@ func kindByFlavor(flavor string) string {
 	return ""
 }
 
+// machinePoolKind returns the kind of the infrastructure machine pool of a worker.
+// EKS pools with mixed instances are self-managed because managed nodegroups
+// don't support them.
+func machinePoolKind(flavor string, w appv1alpha1.WorkerNode) string {
+	if w.MixedInstances() {
+		return "AWSMachinePool"
+	}
+	return kindByFlavor(flavor)
+}
+
 func launchTemplateRef(u unstructured.Unstructured) (appv1alpha1.LaunchTemplateReference, error) {
 	var (
 		ref     appv1alpha1.LaunchTemplateReference
@@ -190,15 +200,16 @@ func launchTemplateRef(u unstructured.Unstructured) (appv1alpha1.LaunchTemplateR
 }
 
 func ReconcileLaunchTemplate(ctx context.Context, r client.Client, cl *appv1alpha1.Cluster, capiCluster *capi.Cluster) error {
-	for i := range cl.Spec.Workers {
-		if !cl.Spec.InfrastructureProvider.IsManaged() {
+	for i, w := range cl.Spec.Workers {
+		kind := machinePoolKind(cl.Spec.InfrastructureProvider.Flavor, w)
+		if kind == "AWSMachinePool" {
 			key := client.ObjectKey{
 				Name:      fmt.Sprintf("%s-mp-%d", cl.Name, i),
 				Namespace: cl.GetNamespace(),
 			}
 			u := unstructured.Unstructured{}
 			u.SetAPIVersion("infrastructure.cluster.x-k8s.io/v1alpha4")
-			u.SetKind(kindByFlavor(cl.Spec.InfrastructureProvider.Flavor))
+			u.SetKind(kind)
 			err := r.Get(ctx, key, &u)
 			if err != nil {
 				return client.IgnoreNotFound(err)
@@ -414,6 +425,7 @@ func getData(secret *corev1.Secret, key string) string {
 }
 
 type Account struct {
+	sess      *session.Session
 	stsClient stsiface.STSAPI
 	out       *sts.GetCallerIdentityOutput
 }
@@ -423,6 +435,15 @@ func NewAccount(ctx context.Context, c client.Client) (*Account, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewAccountFromCredentials(cred)
+}
+
+// NewAccountFromCredentials returns the account of credentials
+// not stored in the management cluster yet.
+func NewAccountFromCredentials(cred AwsCredentials) (*Account, error) {
+	if cred.Region == "" {
+		cred.Region = DefaultAWSRegion
+	}
 	sess, err := session.NewSession(&aws.Config{
 		Region: aws.String(cred.Region),
 		Credentials: credentials.NewStaticCredentials(
@@ -440,6 +461,7 @@ func NewAccount(ctx context.Context, c client.Client) (*Account, error) {
 		return nil, err
 	}
 	return &Account{
+		sess:      sess,
 		stsClient: stsClient,
 		out:       out,
 	}, nil
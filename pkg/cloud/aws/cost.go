@@ -0,0 +1,120 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package aws
+
+import (
+	"strconv"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+const (
+	hoursPerMonth = 730
+	// classic load balancer in front of the API server of EC2 clusters
+	elbPricePerHour = 0.025
+	// EKS control plane fee
+	eksPricePerHour = 0.10
+	// bastion instance type used by CAPA when not set
+	defaultBastionInstanceType = "t2.micro"
+	// region of the on-demand prices in instancetypes.json
+	priceRegion = "us-east-1"
+)
+
+func instancePrices() (map[string]float64, error) {
+	specs := make([]metadatav1alpha1.AWSMachineSpec, 0)
+	err := json.Unmarshal(instanceTypes, &specs)
+	if err != nil {
+		return nil, err
+	}
+	prices := make(map[string]float64, len(specs))
+	for _, spec := range specs {
+		if spec.PricePerHour == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(spec.PricePerHour, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid price for instance type %s", spec.InstanceType)
+		}
+		prices[spec.InstanceType] = price
+	}
+	return prices, nil
+}
+
+// EstimateCost computes the on-demand cost of the cluster control plane, workers,
+// bastion and load balancers. Workers with autoscaling enabled are counted with their
+// minimum size in the minimum cost and with their maximum size in the maximum cost.
+func EstimateCost(cl *appv1alpha1.Cluster) (*appv1alpha1.EstimatedCost, error) {
+	prices, err := instancePrices()
+	if err != nil {
+		return nil, err
+	}
+	priceOf := func(instanceType string) (float64, error) {
+		price, ok := prices[instanceType]
+		if !ok {
+			return 0, errors.Errorf("no price for instance type %s", instanceType)
+		}
+		return price, nil
+	}
+	var fixed float64
+	if cl.Spec.InfrastructureProvider.IsManaged() {
+		fixed += eksPricePerHour
+	} else {
+		fixed += elbPricePerHour
+		if cl.Spec.ControlPlane != nil && cl.Spec.ControlPlane.Replicas != nil {
+			price, err := priceOf(cl.Spec.ControlPlane.MachineType)
+			if err != nil {
+				return nil, err
+			}
+			fixed += price * float64(*cl.Spec.ControlPlane.Replicas)
+		}
+	}
+	if cl.Spec.Bastion != nil && cl.Spec.Bastion.Enabled != nil && *cl.Spec.Bastion.Enabled {
+		instanceType := cl.Spec.Bastion.InstanceType
+		if instanceType == "" {
+			instanceType = defaultBastionInstanceType
+		}
+		price, err := priceOf(instanceType)
+		if err != nil {
+			return nil, err
+		}
+		fixed += price
+	}
+	minCost, maxCost := fixed, fixed
+	for _, w := range cl.Spec.Workers {
+		price, err := priceOf(w.MachineType)
+		if err != nil {
+			return nil, err
+		}
+		var minSize, maxSize int32
+		if w.Autoscale.Enabled {
+			minSize, maxSize = w.Autoscale.MinSize, w.Autoscale.MaxSize
+		} else if w.Replicas != nil {
+			minSize, maxSize = *w.Replicas, *w.Replicas
+		}
+		minCost += price * float64(minSize)
+		maxCost += price * float64(maxSize)
+	}
+	return &appv1alpha1.EstimatedCost{
+		Currency:       "USD",
+		MinHourlyCost:  strconv.FormatFloat(minCost, 'f', 4, 64),
+		MaxHourlyCost:  strconv.FormatFloat(maxCost, 'f', 4, 64),
+		MinMonthlyCost: strconv.FormatFloat(minCost*hoursPerMonth, 'f', 2, 64),
+		MaxMonthlyCost: strconv.FormatFloat(maxCost*hoursPerMonth, 'f', 2, 64),
+		PriceRegion:    priceRegion,
+	}, nil
+}
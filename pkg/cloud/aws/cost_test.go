@@ -0,0 +1,104 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package aws
+
+import (
+	"reflect"
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+)
+
+func TestEstimateCost(t *testing.T) {
+	one, three := int32(1), int32(3)
+	enabled := true
+	tests := []struct {
+		name    string
+		spec    appv1alpha1.ClusterSpec
+		want    *appv1alpha1.EstimatedCost
+		wantErr bool
+	}{
+		{
+			name: "ec2 with bastion and autoscaling",
+			spec: appv1alpha1.ClusterSpec{
+				InfrastructureProvider: appv1alpha1.InfrastructureProvider{Name: "aws", Flavor: "ec2"},
+				ControlPlane: &appv1alpha1.ControlPlaneNode{
+					Node: appv1alpha1.Node{Replicas: &three, MachineType: "t3.medium"},
+				},
+				Bastion: &appv1alpha1.Bastion{Enabled: &enabled},
+				Workers: []appv1alpha1.WorkerNode{
+					{
+						Node:      appv1alpha1.Node{Replicas: &one, MachineType: "t3.large"},
+						Autoscale: appv1alpha1.Autoscaling{Enabled: true, MinSize: 1, MaxSize: 3},
+					},
+				},
+			},
+			// 0.025 elb + 3 * 0.0416 control plane + 0.0116 bastion + [1, 3] * 0.0832 workers
+			want: &appv1alpha1.EstimatedCost{
+				Currency:       "USD",
+				MinHourlyCost:  "0.2446",
+				MaxHourlyCost:  "0.4110",
+				MinMonthlyCost: "178.56",
+				MaxMonthlyCost: "300.03",
+				PriceRegion:    "us-east-1",
+			},
+		},
+		{
+			name: "eks",
+			spec: appv1alpha1.ClusterSpec{
+				InfrastructureProvider: appv1alpha1.InfrastructureProvider{Name: "aws", Flavor: "eks"},
+				Workers: []appv1alpha1.WorkerNode{
+					{
+						Node: appv1alpha1.Node{Replicas: &three, MachineType: "m5.large"},
+					},
+				},
+			},
+			// 0.10 control plane + 3 * 0.096 workers
+			want: &appv1alpha1.EstimatedCost{
+				Currency:       "USD",
+				MinHourlyCost:  "0.3880",
+				MaxHourlyCost:  "0.3880",
+				MinMonthlyCost: "283.24",
+				MaxMonthlyCost: "283.24",
+				PriceRegion:    "us-east-1",
+			},
+		},
+		{
+			name: "unknown instance type",
+			spec: appv1alpha1.ClusterSpec{
+				InfrastructureProvider: appv1alpha1.InfrastructureProvider{Name: "aws", Flavor: "eks"},
+				Workers: []appv1alpha1.WorkerNode{
+					{
+						Node: appv1alpha1.Node{Replicas: &one, MachineType: "x9.huge"},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := &appv1alpha1.Cluster{Spec: tt.spec}
+			got, err := EstimateCost(cl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EstimateCost() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EstimateCost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
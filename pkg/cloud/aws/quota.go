@@ -0,0 +1,156 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package aws
+
+import (
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Running On-Demand Standard (A, C, D, H, I, M, R, T, Z) instances
+	vcpuQuotaCode = "L-1216C47A"
+	// VPCs per Region
+	vpcQuotaCode = "L-F678F1CE"
+	// EC2-VPC Elastic IPs
+	eipQuotaCode = "L-0263D0A3"
+	// availability zones CAPA spreads the subnets of multi zone clusters
+	multiZoneCount = 3
+)
+
+// Resources are the amounts of the resources limited by service quotas
+// that clusters use in a region.
+type Resources struct {
+	VCPUs      int `json:"vcpus"`
+	VPCs       int `json:"vpcs"`
+	ElasticIPs int `json:"elasticIPs"`
+}
+
+func instanceVCPUs() (map[string]int, error) {
+	specs := make([]metadatav1alpha1.AWSMachineSpec, 0)
+	err := json.Unmarshal(instanceTypes, &specs)
+	if err != nil {
+		return nil, err
+	}
+	vcpus := make(map[string]int, len(specs))
+	for _, spec := range specs {
+		if spec.Vcpus == "" {
+			continue
+		}
+		n, err := strconv.Atoi(spec.Vcpus)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid vcpus for instance type %s", spec.InstanceType)
+		}
+		vcpus[spec.InstanceType] = n
+	}
+	return vcpus, nil
+}
+
+// EstimateResources computes the resources used by clusters at their maximum size.
+// Every cluster creating its network uses a VPC and a NAT gateway with an Elastic IP
+// in each availability zone, clusters in an existing VPC don't use any.
+func EstimateResources(clusters []appv1alpha1.Cluster) (Resources, error) {
+	res := Resources{}
+	vcpus, err := instanceVCPUs()
+	if err != nil {
+		return res, err
+	}
+	vcpusOf := func(instanceType string) (int, error) {
+		n, ok := vcpus[instanceType]
+		if !ok {
+			return 0, errors.Errorf("no vcpus for instance type %s", instanceType)
+		}
+		return n, nil
+	}
+	vpcs := make(map[string]bool)
+	for _, cl := range clusters {
+		if !cl.Spec.InfrastructureProvider.IsManaged() && cl.Spec.ControlPlane != nil && cl.Spec.ControlPlane.Replicas != nil {
+			n, err := vcpusOf(cl.Spec.ControlPlane.MachineType)
+			if err != nil {
+				return res, err
+			}
+			res.VCPUs += n * int(*cl.Spec.ControlPlane.Replicas)
+		}
+		if cl.Spec.Bastion != nil && cl.Spec.Bastion.Enabled != nil && *cl.Spec.Bastion.Enabled {
+			instanceType := cl.Spec.Bastion.InstanceType
+			if instanceType == "" {
+				instanceType = defaultBastionInstanceType
+			}
+			n, err := vcpusOf(instanceType)
+			if err != nil {
+				return res, err
+			}
+			res.VCPUs += n
+		}
+		for _, w := range cl.Spec.Workers {
+			n, err := vcpusOf(w.MachineType)
+			if err != nil {
+				return res, err
+			}
+			var size int32
+			if w.Autoscale.Enabled {
+				size = w.Autoscale.MaxSize
+			} else if w.Replicas != nil {
+				size = *w.Replicas
+			}
+			res.VCPUs += n * int(size)
+		}
+		if cl.Spec.Network.VPC.ID != "" {
+			vpcs[cl.Spec.Network.VPC.ID] = true
+			continue
+		}
+		res.VPCs++
+		if cl.Spec.Network.MultiZone {
+			res.ElasticIPs += multiZoneCount
+		} else {
+			res.ElasticIPs++
+		}
+	}
+	res.VPCs += len(vpcs)
+	return res, nil
+}
+
+// Quotas returns the service quotas of the account in the region.
+func (a *Account) Quotas(region string) (Resources, error) {
+	svc := servicequotas.New(a.sess, aws.NewConfig().WithRegion(region))
+	quota := func(service, code string) (int, error) {
+		out, err := svc.GetServiceQuota(&servicequotas.GetServiceQuotaInput{
+			ServiceCode: aws.String(service),
+			QuotaCode:   aws.String(code),
+		})
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to get %s quota %s", service, code)
+		}
+		return int(aws.Float64Value(out.Quota.Value)), nil
+	}
+	res := Resources{}
+	var err error
+	res.VCPUs, err = quota("ec2", vcpuQuotaCode)
+	if err != nil {
+		return res, err
+	}
+	res.VPCs, err = quota("vpc", vpcQuotaCode)
+	if err != nil {
+		return res, err
+	}
+	res.ElasticIPs, err = quota("ec2", eipQuotaCode)
+	return res, err
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package aws
+
+import (
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+)
+
+func TestEstimateResources(t *testing.T) {
+	one, three := int32(1), int32(3)
+	enabled := true
+	ec2 := appv1alpha1.Cluster{
+		Spec: appv1alpha1.ClusterSpec{
+			InfrastructureProvider: appv1alpha1.InfrastructureProvider{Name: "aws", Flavor: "ec2"},
+			ControlPlane: &appv1alpha1.ControlPlaneNode{
+				Node: appv1alpha1.Node{Replicas: &three, MachineType: "t3.medium"},
+			},
+			Bastion: &appv1alpha1.Bastion{Enabled: &enabled},
+			Workers: []appv1alpha1.WorkerNode{
+				{
+					Node:      appv1alpha1.Node{Replicas: &one, MachineType: "t3.large"},
+					Autoscale: appv1alpha1.Autoscaling{Enabled: true, MinSize: 1, MaxSize: 3},
+				},
+			},
+			Network: appv1alpha1.Network{MultiZone: true},
+		},
+	}
+	eks := appv1alpha1.Cluster{
+		Spec: appv1alpha1.ClusterSpec{
+			InfrastructureProvider: appv1alpha1.InfrastructureProvider{Name: "aws", Flavor: "eks"},
+			Workers: []appv1alpha1.WorkerNode{
+				{
+					Node: appv1alpha1.Node{Replicas: &three, MachineType: "m5.large"},
+				},
+			},
+			Network: appv1alpha1.Network{VPC: appv1alpha1.NetworkSpec{ID: "vpc-1"}},
+		},
+	}
+	unknown := appv1alpha1.Cluster{
+		Spec: appv1alpha1.ClusterSpec{
+			InfrastructureProvider: appv1alpha1.InfrastructureProvider{Name: "aws", Flavor: "eks"},
+			Workers: []appv1alpha1.WorkerNode{
+				{
+					Node: appv1alpha1.Node{Replicas: &one, MachineType: "x9.huge"},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		clusters []appv1alpha1.Cluster
+		want     Resources
+		wantErr  bool
+	}{
+		{
+			name:     "ec2 multi zone",
+			clusters: []appv1alpha1.Cluster{ec2},
+			// 3 * 2 control plane + 1 bastion + 3 * 2 workers
+			want: Resources{VCPUs: 13, VPCs: 1, ElasticIPs: 3},
+		},
+		{
+			name:     "clusters sharing a vpc",
+			clusters: []appv1alpha1.Cluster{ec2, eks, eks},
+			want:     Resources{VCPUs: 25, VPCs: 2, ElasticIPs: 3},
+		},
+		{
+			name:     "unknown instance type",
+			clusters: []appv1alpha1.Cluster{unknown},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EstimateResources(tt.clusters)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EstimateResources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("EstimateResources() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
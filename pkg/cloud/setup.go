@@ -98,6 +98,15 @@ func ReconcileIntegration(ctx context.Context, r client.Client, log logr.Logger,
 	return nil
 }
 
+// EstimateCost of the cluster infrastructure. It returns nil when the provider has no price data.
+func EstimateCost(cl *appv1alpha1.Cluster) (*appv1alpha1.EstimatedCost, error) {
+	switch cl.Spec.InfrastructureProvider.Name {
+	case appv1alpha1.Amazon.String():
+		return aws.EstimateCost(cl)
+	}
+	return nil, nil
+}
+
 func CalicoValues(cl *appv1alpha1.Cluster) map[string]interface{} {
 	values := make(map[string]interface{})
 	switch cl.Spec.InfrastructureProvider.Flavor {
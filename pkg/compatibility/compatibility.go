@@ -0,0 +1,213 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package compatibility
+
+import (
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/getupio-undistro/undistro/pkg/fs"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// UnDistroChart is the chart whose version is the UnDistro version of the management cluster.
+const UnDistroChart = "undistro"
+
+// Order is the dependency order of the core components.
+// They are upgraded in this order and rolled back in the reverse order.
+var Order = []string{"metallb", "cert-manager", "cluster-api", UnDistroChart, "ingress-nginx", "undistro-aws"}
+
+// Release is a set of chart versions of the core components released together.
+type Release struct {
+	Version string `json:"version"`
+	// Kubernetes is the constraint of the management cluster versions supported by the release.
+	Kubernetes string `json:"kubernetes,omitempty"`
+	// UpgradeFrom is the constraint of the UnDistro versions that can be upgraded to the release.
+	UpgradeFrom string            `json:"upgradeFrom,omitempty"`
+	Charts      map[string]string `json:"charts"`
+}
+
+// Matrix is the list of releases, newest first.
+type Matrix struct {
+	Releases []Release `json:"releases"`
+}
+
+// Default returns the matrix shipped with the binary.
+func Default() (Matrix, error) {
+	return Parse(fs.CompatibilityMatrix)
+}
+
+// Parse reads a matrix and checks its versions and constraints.
+func Parse(data []byte) (Matrix, error) {
+	m := Matrix{}
+	err := yaml.UnmarshalStrict(data, &m)
+	if err != nil {
+		return m, errors.Wrap(err, "invalid compatibility matrix")
+	}
+	if len(m.Releases) == 0 {
+		return m, errors.New("compatibility matrix has no releases")
+	}
+	versions := make(map[string]*semver.Version)
+	for _, r := range m.Releases {
+		v, err := semver.NewVersion(r.Version)
+		if err != nil {
+			return m, errors.Wrapf(err, "invalid release version %q", r.Version)
+		}
+		versions[r.Version] = v
+		for _, c := range []string{r.Kubernetes, r.UpgradeFrom} {
+			if c == "" {
+				continue
+			}
+			_, err = semver.NewConstraint(c)
+			if err != nil {
+				return m, errors.Wrapf(err, "release %s: invalid constraint %q", r.Version, c)
+			}
+		}
+		if r.Charts[UnDistroChart] == "" {
+			return m, errors.Errorf("release %s: missing %s chart version", r.Version, UnDistroChart)
+		}
+	}
+	sort.SliceStable(m.Releases, func(i, j int) bool {
+		return versions[m.Releases[i].Version].GreaterThan(versions[m.Releases[j].Version])
+	})
+	return m, nil
+}
+
+// Get returns the release of the version, the v prefix is optional.
+func (m Matrix) Get(version string) (Release, error) {
+	want, err := semver.NewVersion(version)
+	if err != nil {
+		return Release{}, errors.Wrapf(err, "invalid version %q", version)
+	}
+	for _, r := range m.Releases {
+		if semver.MustParse(r.Version).Equal(want) {
+			return r, nil
+		}
+	}
+	return Release{}, errors.Errorf("release %s is not in the compatibility matrix, use a CLI of the release to upgrade to it", version)
+}
+
+// Latest returns the newest release.
+func (m Matrix) Latest() Release {
+	return m.Releases[0]
+}
+
+// CheckKubernetes returns an error when the release doesn't support
+// the kubernetes version of the management cluster.
+func (r Release) CheckKubernetes(kubernetesVersion string) error {
+	if r.Kubernetes == "" {
+		return nil
+	}
+	c, err := semver.NewConstraint(r.Kubernetes)
+	if err != nil {
+		return err
+	}
+	kv, err := semver.NewVersion(kubernetesVersion)
+	if err != nil {
+		return errors.Wrapf(err, "invalid kubernetes version %q", kubernetesVersion)
+	}
+	// vendors add prerelease suffixes like -eks-f8587c, which don't match constraints
+	release, _ := kv.SetPrerelease("")
+	if !c.Check(&release) {
+		return errors.Errorf("UnDistro %s doesn't support kubernetes %s in the management cluster, it requires %s", r.Version, kubernetesVersion, r.Kubernetes)
+	}
+	return nil
+}
+
+// Step moves a chart from a version to another.
+type Step struct {
+	Chart string `json:"chart"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Plan is the ordered list of steps moving the installed charts to a set of versions.
+type Plan struct {
+	Release string `json:"release,omitempty"`
+	Steps   []Step `json:"steps"`
+}
+
+// Versions returns the chart versions after the plan is applied.
+func (p Plan) Versions() map[string]string {
+	versions := make(map[string]string)
+	for _, s := range p.Steps {
+		versions[s.Chart] = s.To
+	}
+	return versions
+}
+
+// PlanUpgrade returns the steps moving the installed charts to the release in dependency order.
+// Installed maps the charts to their versions, the charts not installed are left out of the plan.
+// The kubernetes version of the management cluster is checked when it is not empty.
+func PlanUpgrade(r Release, installed map[string]string, kubernetesVersion string) (Plan, error) {
+	current, ok := installed[UnDistroChart]
+	if !ok {
+		return Plan{}, errors.Errorf("%s is not installed", UnDistroChart)
+	}
+	cv, err := semver.NewVersion(current)
+	if err != nil {
+		return Plan{}, errors.Wrapf(err, "invalid %s version %q", UnDistroChart, current)
+	}
+	if cv.GreaterThan(semver.MustParse(r.Version)) {
+		return Plan{}, errors.Errorf("UnDistro %s is newer than %s, use undistro rollback to revert an upgrade", current, r.Version)
+	}
+	if r.UpgradeFrom != "" {
+		c, err := semver.NewConstraint(r.UpgradeFrom)
+		if err != nil {
+			return Plan{}, err
+		}
+		if !c.Check(cv) {
+			return Plan{}, errors.Errorf("UnDistro %s can't be upgraded to %s, it requires %s", current, r.Version, r.UpgradeFrom)
+		}
+	}
+	if kubernetesVersion != "" {
+		err = r.CheckKubernetes(kubernetesVersion)
+		if err != nil {
+			return Plan{}, err
+		}
+	}
+	plan := Plan{
+		Release: r.Version,
+		Steps:   make([]Step, 0),
+	}
+	for _, chart := range Order {
+		from, ok := installed[chart]
+		to := r.Charts[chart]
+		if !ok || to == "" || from == to {
+			continue
+		}
+		plan.Steps = append(plan.Steps, Step{Chart: chart, From: from, To: to})
+	}
+	return plan, nil
+}
+
+// PlanRollback returns the steps moving the installed charts back to the versions in reverse dependency order.
+func PlanRollback(versions map[string]string, installed map[string]string) Plan {
+	plan := Plan{
+		Steps: make([]Step, 0),
+	}
+	for i := len(Order) - 1; i >= 0; i-- {
+		chart := Order[i]
+		from, ok := installed[chart]
+		to := versions[chart]
+		if !ok || to == "" || from == to {
+			continue
+		}
+		plan.Steps = append(plan.Steps, Step{Chart: chart, From: from, To: to})
+	}
+	return plan
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package compatibility
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMatrix = `
+releases:
+  - version: v0.37.2
+    kubernetes: ">= 1.19.0, < 1.23.0"
+    upgradeFrom: ">= 0.36.0"
+    charts:
+      cert-manager: 1.5.3
+      cluster-api: 0.4.2
+      undistro: 0.37.2
+  - version: v0.38.0
+    kubernetes: ">= 1.20.0, < 1.24.0"
+    upgradeFrom: ">= 0.37.0"
+    charts:
+      cert-manager: 1.6.1
+      cluster-api: 0.4.4
+      undistro: 0.38.0
+      ingress-nginx: 4.0.10
+`
+
+func TestDefault(t *testing.T) {
+	m, err := Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range m.Releases {
+		for chart := range r.Charts {
+			found := false
+			for _, c := range Order {
+				found = found || c == chart
+			}
+			if !found {
+				t.Errorf("release %s: chart %s is not in the upgrade order", r.Version, chart)
+			}
+		}
+	}
+}
+
+func TestPlanUpgrade(t *testing.T) {
+	m, err := Parse([]byte(testMatrix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Latest().Version != "v0.38.0" {
+		t.Fatalf("Latest() = %s, want v0.38.0", m.Latest().Version)
+	}
+	tests := []struct {
+		name       string
+		release    string
+		installed  map[string]string
+		kubernetes string
+		want       []Step
+		err        string
+	}{
+		{
+			name:       "dependency order",
+			release:    "0.38.0",
+			installed:  map[string]string{"undistro": "0.37.2", "cluster-api": "0.4.2", "cert-manager": "1.5.3", "ingress-nginx": "4.0.6", "metallb": "0.10.2"},
+			kubernetes: "v1.21.2-eks-0389ca3",
+			want: []Step{
+				{Chart: "cert-manager", From: "1.5.3", To: "1.6.1"},
+				{Chart: "cluster-api", From: "0.4.2", To: "0.4.4"},
+				{Chart: "undistro", From: "0.37.2", To: "0.38.0"},
+				{Chart: "ingress-nginx", From: "4.0.6", To: "4.0.10"},
+			},
+		},
+		{
+			name:      "up to date",
+			release:   "v0.38.0",
+			installed: map[string]string{"undistro": "0.38.0", "cert-manager": "1.6.1"},
+			want:      []Step{},
+		},
+		{
+			name:      "too old",
+			release:   "v0.38.0",
+			installed: map[string]string{"undistro": "0.36.4"},
+			err:       "requires >= 0.37.0",
+		},
+		{
+			name:      "downgrade",
+			release:   "v0.37.2",
+			installed: map[string]string{"undistro": "0.38.0"},
+			err:       "use undistro rollback",
+		},
+		{
+			name:       "unsupported kubernetes",
+			release:    "v0.37.2",
+			installed:  map[string]string{"undistro": "0.37.0"},
+			kubernetes: "v1.23.1",
+			err:        "doesn't support kubernetes v1.23.1",
+		},
+		{
+			name:      "unknown release",
+			release:   "v0.39.0",
+			installed: map[string]string{"undistro": "0.38.0"},
+			err:       "not in the compatibility matrix",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := m.Get(tt.release)
+			var plan Plan
+			if err == nil {
+				plan, err = PlanUpgrade(r, tt.installed, tt.kubernetes)
+			}
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("PlanUpgrade() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PlanUpgrade() error = %v", err)
+			}
+			if !reflect.DeepEqual(plan.Steps, tt.want) {
+				t.Errorf("PlanUpgrade() = %v, want %v", plan.Steps, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanRollback(t *testing.T) {
+	previous := map[string]string{"undistro": "0.37.2", "cert-manager": "1.5.3", "ingress-nginx": "4.0.6"}
+	installed := map[string]string{"undistro": "0.38.0", "cert-manager": "1.6.1", "ingress-nginx": "4.0.6"}
+	want := []Step{
+		{Chart: "undistro", From: "0.38.0", To: "0.37.2"},
+		{Chart: "cert-manager", From: "1.6.1", To: "1.5.3"},
+	}
+	if got := PlanRollback(previous, installed); !reflect.DeepEqual(got.Steps, want) {
+		t.Errorf("PlanRollback() = %v, want %v", got.Steps, want)
+	}
+}
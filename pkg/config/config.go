@@ -0,0 +1,370 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config contains the UnDistro configuration file used by the install, move and setup commands.
+package config
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// GroupVersion is the apiVersion of the configuration file.
+	GroupVersion = "config.undistro.io/v1alpha1"
+	// Kind is the kind of the configuration file.
+	Kind = "UnDistroConfig"
+
+	defaultAWSRegion = "us-east-1"
+)
+
+// Config is the UnDistro configuration file. Each chart section is passed to the chart as values,
+// with the global section, so fields unknown by UnDistro are kept as chart values.
+// Files without apiVersion, written before the configuration was versioned, are read as v1alpha1.
+type Config struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+
+	Global              *Global    `json:"global,omitempty"`
+	UnDistro            *UnDistro  `json:"undistro,omitempty"`
+	CertManager         *Chart     `json:"cert-manager,omitempty"`
+	ClusterAPI          *Chart     `json:"cluster-api,omitempty"`
+	IngressNginx        *Chart     `json:"ingress-nginx,omitempty"`
+	MetalLB             *Chart     `json:"metallb,omitempty"`
+	KubePrometheusStack *Chart     `json:"kube-prometheus-stack,omitempty"`
+	AWS                 *AWS       `json:"undistro-aws,omitempty"`
+	OpenStack           *OpenStack `json:"undistro-openstack,omitempty"`
+}
+
+// Global values are set in every chart.
+type Global struct {
+	UnDistroRepository string                 `json:"undistroRepository,omitempty"`
+	UnDistroVersion    string                 `json:"undistroVersion,omitempty"`
+	Values             map[string]interface{} `json:"-"`
+}
+
+// Chart is a chart installed when enabled.
+type Chart struct {
+	Enabled bool                   `json:"enabled,omitempty"`
+	Values  map[string]interface{} `json:"-"`
+}
+
+// UnDistro is the configuration of the UnDistro chart, always installed.
+type UnDistro struct {
+	Local    *bool                  `json:"local,omitempty"`
+	Ingress  *Ingress               `json:"ingress,omitempty"`
+	Identity *Identity              `json:"identity,omitempty"`
+	Values   map[string]interface{} `json:"-"`
+}
+
+// Ingress exposes the UnDistro UI and the identity supervisor.
+type Ingress struct {
+	Enabled     *bool             `json:"enabled,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Hosts of the ingress, the first one is the address of the identity issuer.
+	Hosts       []string    `json:"hosts,omitempty"`
+	IPAddresses []string    `json:"ipAddresses,omitempty"`
+	TLS         *IngressTLS `json:"tls,omitempty"`
+}
+
+// IngressTLS is the certificate of the ingress.
+type IngressTLS struct {
+	CommonName string `json:"commonName,omitempty"`
+	Email      string `json:"email,omitempty"`
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// Identity enables the management cluster identity, the providers are chart values.
+type Identity struct {
+	Enabled bool                   `json:"enabled,omitempty"`
+	Values  map[string]interface{} `json:"-"`
+}
+
+// AWS is the configuration of the AWS provider chart.
+type AWS struct {
+	Enabled     bool                   `json:"enabled,omitempty"`
+	Credentials *AWSCredentials        `json:"credentials,omitempty"`
+	Values      map[string]interface{} `json:"-"`
+}
+
+type AWSCredentials struct {
+	AccessKeyID     string `json:"accessKeyID,omitempty"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+	SessionToken    string `json:"sessionToken,omitempty"`
+	Region          string `json:"region,omitempty"`
+}
+
+// OpenStack is the configuration of the OpenStack provider chart.
+type OpenStack struct {
+	Enabled     bool                   `json:"enabled,omitempty"`
+	Credentials *OpenStackCredentials  `json:"credentials,omitempty"`
+	Values      map[string]interface{} `json:"-"`
+}
+
+type OpenStackCredentials struct {
+	// CAFile is the base64 encoded certificate authority of the OpenStack API.
+	CAFile            string `json:"caFile,omitempty"`
+	DNSNameServers    string `json:"dnsNameServers,omitempty"`
+	ExternalNetworkID string `json:"externalNetworkID,omitempty"`
+}
+
+func (g *Global) UnmarshalJSON(b []byte) error {
+	type global Global
+	return unmarshalValues(b, (*global)(g), &g.Values)
+}
+
+func (g Global) MarshalJSON() ([]byte, error) {
+	type global Global
+	return marshalValues(global(g), g.Values)
+}
+
+func (c *Chart) UnmarshalJSON(b []byte) error {
+	type chart Chart
+	return unmarshalValues(b, (*chart)(c), &c.Values)
+}
+
+func (c Chart) MarshalJSON() ([]byte, error) {
+	type chart Chart
+	return marshalValues(chart(c), c.Values)
+}
+
+func (u *UnDistro) UnmarshalJSON(b []byte) error {
+	type undistro UnDistro
+	return unmarshalValues(b, (*undistro)(u), &u.Values)
+}
+
+func (u UnDistro) MarshalJSON() ([]byte, error) {
+	type undistro UnDistro
+	return marshalValues(undistro(u), u.Values)
+}
+
+func (i *Identity) UnmarshalJSON(b []byte) error {
+	type identity Identity
+	return unmarshalValues(b, (*identity)(i), &i.Values)
+}
+
+func (i Identity) MarshalJSON() ([]byte, error) {
+	type identity Identity
+	return marshalValues(identity(i), i.Values)
+}
+
+func (a *AWS) UnmarshalJSON(b []byte) error {
+	type aws AWS
+	return unmarshalValues(b, (*aws)(a), &a.Values)
+}
+
+func (a AWS) MarshalJSON() ([]byte, error) {
+	type aws AWS
+	return marshalValues(aws(a), a.Values)
+}
+
+func (o *OpenStack) UnmarshalJSON(b []byte) error {
+	type openstack OpenStack
+	return unmarshalValues(b, (*openstack)(o), &o.Values)
+}
+
+func (o OpenStack) MarshalJSON() ([]byte, error) {
+	type openstack OpenStack
+	return marshalValues(openstack(o), o.Values)
+}
+
+// Load reads, defaults and validates the configuration file.
+// An empty path returns the default configuration.
+func Load(path string) (*Config, error) {
+	if path == "" {
+		c := &Config{}
+		c.Default()
+		return c, nil
+	}
+	byt, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(byt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid configuration file %s", path)
+	}
+	err = c.Validate()
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid configuration file %s", path)
+	}
+	return c, nil
+}
+
+// Parse decodes and defaults a configuration, failing on unknown sections and on unknown fields of the typed sections.
+func Parse(data []byte) (*Config, error) {
+	byt, err := yaml.YAMLToJSONStrict(data)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	sections := make(map[string]json.RawMessage)
+	err = json.Unmarshal(byt, &sections)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.ValueOf(c).Elem()
+	for name, raw := range sections {
+		f := fieldByJSONName(v, name)
+		if !f.IsValid() {
+			return nil, unknownFieldError(name, jsonFields(v.Type()))
+		}
+		err = json.Unmarshal(raw, f.Addr().Interface())
+		if err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+	}
+	c.Default()
+	return c, nil
+}
+
+// Default sets the default values of the configuration.
+func (c *Config) Default() {
+	if c.APIVersion == "" {
+		c.APIVersion = GroupVersion
+	}
+	if c.Kind == "" {
+		c.Kind = Kind
+	}
+	if c.AWS != nil && c.AWS.Credentials != nil && c.AWS.Credentials.Region == "" {
+		c.AWS.Credentials.Region = defaultAWSRegion
+	}
+}
+
+// Validate checks the configuration.
+func (c *Config) Validate() error {
+	var allErrs field.ErrorList
+	if c.APIVersion != GroupVersion {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("apiVersion"), c.APIVersion, []string{GroupVersion}))
+	}
+	if c.Kind != Kind {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("kind"), c.Kind, []string{Kind}))
+	}
+	if c.UnDistro != nil && c.UnDistro.Ingress != nil {
+		p := field.NewPath("undistro", "ingress")
+		for i, h := range c.UnDistro.Ingress.Hosts {
+			if net.ParseIP(h) != nil {
+				continue
+			}
+			for _, msg := range validation.IsDNS1123Subdomain(h) {
+				allErrs = append(allErrs, field.Invalid(p.Child("hosts").Index(i), h, msg))
+			}
+		}
+		for i, ip := range c.UnDistro.Ingress.IPAddresses {
+			if net.ParseIP(ip) == nil {
+				allErrs = append(allErrs, field.Invalid(p.Child("ipAddresses").Index(i), ip, "must be a valid IP address"))
+			}
+		}
+	}
+	if c.AWS != nil && c.AWS.Enabled {
+		p := field.NewPath("undistro-aws", "credentials")
+		creds := c.AWS.Credentials
+		if creds == nil {
+			creds = &AWSCredentials{}
+		}
+		if creds.AccessKeyID == "" {
+			allErrs = append(allErrs, field.Required(p.Child("accessKeyID"), "AWS credentials are required by the AWS provider"))
+		}
+		if creds.SecretAccessKey == "" {
+			allErrs = append(allErrs, field.Required(p.Child("secretAccessKey"), "AWS credentials are required by the AWS provider"))
+		}
+	}
+	if c.OpenStack != nil && c.OpenStack.Enabled {
+		p := field.NewPath("undistro-openstack", "credentials")
+		creds := c.OpenStack.Credentials
+		if creds == nil {
+			creds = &OpenStackCredentials{}
+		}
+		if creds.DNSNameServers == "" {
+			allErrs = append(allErrs, field.Required(p.Child("dnsNameServers"), "DNS name servers are required by the OpenStack provider"))
+		}
+		if creds.ExternalNetworkID == "" {
+			allErrs = append(allErrs, field.Required(p.Child("externalNetworkID"), "external network is required by the OpenStack provider"))
+		}
+	}
+	return allErrs.ToAggregate()
+}
+
+// section returns the section of a chart, nil when the section isn't in the configuration.
+func (c *Config) section(chart string) interface{} {
+	f := fieldByJSONName(reflect.ValueOf(c).Elem(), chart)
+	if !f.IsValid() || f.Kind() != reflect.Ptr || f.IsNil() || chart == "global" {
+		return nil
+	}
+	return f.Interface()
+}
+
+// Enabled returns true when the chart section is enabled in the configuration.
+func (c *Config) Enabled(chart string) bool {
+	switch s := c.section(chart).(type) {
+	case *Chart:
+		return s.Enabled
+	case *AWS:
+		return s.Enabled
+	case *OpenStack:
+		return s.Enabled
+	}
+	return false
+}
+
+// Values returns the chart section as chart values, with the global section.
+func (c *Config) Values(chart string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	s := c.section(chart)
+	if s != nil {
+		err := remarshal(s, &values)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.Global != nil {
+		global := make(map[string]interface{})
+		err := remarshal(c.Global, &global)
+		if err != nil {
+			return nil, err
+		}
+		values["global"] = global
+	}
+	return values, nil
+}
+
+// IdentityEnabled returns true when the management cluster identity is enabled.
+func (c *Config) IdentityEnabled() bool {
+	return c.UnDistro != nil && c.UnDistro.Identity != nil && c.UnDistro.Identity.Enabled
+}
+
+// IngressHost returns the first host of the UnDistro ingress.
+func (c *Config) IngressHost() string {
+	if c.UnDistro == nil || c.UnDistro.Ingress == nil || len(c.UnDistro.Ingress.Hosts) == 0 {
+		return ""
+	}
+	return c.UnDistro.Ingress.Hosts[0]
+}
+
+func remarshal(in, out interface{}) error {
+	byt, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(byt, out)
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "legacy file without apiVersion",
+			data: "global:\n  undistroVersion: v0.37.2\nundistro:\n  ingress:\n    hosts:\n    - k8s.example.com\n",
+		},
+		{
+			name: "unknown chart values are kept",
+			data: "apiVersion: config.undistro.io/v1alpha1\nkind: UnDistroConfig\nundistro:\n  resources:\n    limits:\n      cpu: 1\n",
+		},
+		{
+			name: "typo in section",
+			data: "undistro-aw:\n  enabled: true\n",
+			err:  `unknown field "undistro-aw", did you mean "undistro-aws"?`,
+		},
+		{
+			name: "typo in typed field",
+			data: "undistro:\n  ingress:\n    host:\n    - k8s.example.com\n",
+			err:  `undistro: ingress: unknown field "host", did you mean "hosts"?`,
+		},
+		{
+			name: "typo in nested typed field",
+			data: "undistro:\n  ingress:\n    tls:\n      secretNam: cert\n",
+			err:  `did you mean "secretName"?`,
+		},
+		{
+			name: "typo in chart field",
+			data: "cert-manager:\n  enabeld: true\n",
+			err:  `unknown field "enabeld", did you mean "enabled"?`,
+		},
+		{
+			name: "typo in provider field",
+			data: "undistro-aws:\n  credential:\n    region: us-east-2\n",
+			err:  `unknown field "credential", did you mean "credentials"?`,
+		},
+		{
+			name: "chart values unlike typed fields are kept",
+			data: "cert-manager:\n  enabled: true\n  installCRDs: true\nundistro:\n  image:\n    tag: v0.37.2\n",
+		},
+		{
+			name: "wrong type",
+			data: "undistro-aws:\n  enabled: \"yes\"\n",
+			err:  "undistro-aws",
+		},
+		{
+			name: "duplicated key",
+			data: "metallb:\n  enabled: true\nmetallb:\n  enabled: false\n",
+			err:  "already set",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if tt.err == "" && err != nil {
+				t.Errorf("Parse() error = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			data: "undistro-aws:\n  enabled: true\n  credentials:\n    accessKeyID: id\n    secretAccessKey: secret\nundistro:\n  ingress:\n    hosts:\n    - k8s.example.com\n    ipAddresses:\n    - 172.18.0.2\n",
+		},
+		{
+			name:    "unsupported apiVersion",
+			data:    "apiVersion: config.undistro.io/v1\n",
+			wantErr: true,
+		},
+		{
+			name:    "aws without credentials",
+			data:    "undistro-aws:\n  enabled: true\n",
+			wantErr: true,
+		},
+		{
+			name: "disabled aws without credentials",
+			data: "undistro-aws:\n  enabled: false\n",
+		},
+		{
+			name:    "invalid ingress host",
+			data:    "undistro:\n  ingress:\n    hosts:\n    - https://k8s.example.com\n",
+			wantErr: true,
+		},
+		{
+			name:    "openstack without network",
+			data:    "undistro-openstack:\n  enabled: true\n  credentials:\n    dnsNameServers: 8.8.8.8\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Values(t *testing.T) {
+	data := `
+global:
+  undistroVersion: v0.37.2
+undistro-aws:
+  enabled: true
+  credentials:
+    accessKeyID: id
+    secretAccessKey: secret
+  replicaCount: 2
+  enabledControllers: all
+`
+	c, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Enabled("undistro-aws") || c.Enabled("metallb") {
+		t.Errorf("Enabled() = %v, %v", c.Enabled("undistro-aws"), c.Enabled("metallb"))
+	}
+	got, err := c.Values("undistro-aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"enabled": true,
+		"credentials": map[string]interface{}{
+			"accessKeyID":     "id",
+			"secretAccessKey": "secret",
+			"region":          "us-east-1",
+		},
+		"replicaCount":       float64(2),
+		"enabledControllers": "all",
+		"global": map[string]interface{}{
+			"undistroVersion": "v0.37.2",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() = %v, want %v", got, want)
+	}
+}
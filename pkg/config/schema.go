@@ -0,0 +1,86 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Schema returns the JSON schema of the configuration file, used by editors for completion.
+// Sections passed as chart values accept any field besides the typed ones.
+func Schema() ([]byte, error) {
+	s := typeSchema(reflect.TypeOf(Config{}))
+	props := s["properties"].(map[string]interface{})
+	props["apiVersion"] = map[string]interface{}{
+		"type": "string",
+		"enum": []string{GroupVersion},
+	}
+	props["kind"] = map[string]interface{}{
+		"type": "string",
+		"enum": []string{Kind},
+	}
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "UnDistro configuration"
+	return json.MarshalIndent(s, "", "  ")
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem()),
+		}
+	case reflect.Struct:
+		props := make(map[string]interface{})
+		additional := false
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name == "-" && f.Name == "Values" {
+				additional = true
+				continue
+			}
+			if name == "" || name == "-" {
+				continue
+			}
+			props[name] = typeSchema(f.Type)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": additional,
+		}
+	}
+	// chart values
+	return map[string]interface{}{}
+}
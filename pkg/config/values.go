@@ -0,0 +1,217 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// jsonFields returns the json names of the fields of a struct type.
+func jsonFields(t reflect.Type) []string {
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// decodeStrict decodes data into v failing on unknown fields, which are reported with the closest known field.
+func decodeStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		return nil
+	}
+	const unknownPrefix = "json: unknown field "
+	if !strings.HasPrefix(err.Error(), unknownPrefix) {
+		return err
+	}
+	name := strings.Trim(strings.TrimPrefix(err.Error(), unknownPrefix), `"`)
+	return unknownFieldError(name, nestedJSONFields(reflect.TypeOf(v).Elem()))
+}
+
+// nestedJSONFields returns the json names of the fields of a struct type and of its nested structs.
+func nestedJSONFields(t reflect.Type) []string {
+	fields := jsonFields(t)
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i).Type
+		for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice || ft.Kind() == reflect.Map {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			fields = append(fields, nestedJSONFields(ft)...)
+		}
+	}
+	return fields
+}
+
+func unknownFieldError(name string, known []string) error {
+	if s := closest(name, known); s != "" {
+		return errors.Errorf("unknown field %q, did you mean %q?", name, s)
+	}
+	return errors.Errorf("unknown field %q", name)
+}
+
+// maxTypoDistance is the largest edit distance between a chart value and a typed
+// field for the value to be taken as a typo of the field.
+const maxTypoDistance = 2
+
+// typoOf returns the typed field name is a typo of, chart values named
+// far enough from every typed field are passed through.
+func typoOf(name string, fields []string) string {
+	s := closest(name, fields)
+	if s == "" || distance(strings.ToLower(name), strings.ToLower(s)) > maxTypoDistance {
+		return ""
+	}
+	return s
+}
+
+// closest returns the known name with the smallest edit distance to name, when the distance is small enough to be a typo.
+func closest(name string, known []string) string {
+	best, bestDistance := "", len(name)/2+1
+	for _, k := range known {
+		if d := distance(strings.ToLower(name), strings.ToLower(k)); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(n ...int) int {
+	m := n[0]
+	for _, v := range n[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// unmarshalValues decodes the fields of the typed struct pointed by typed strictly and keeps
+// the remaining ones, which are chart values UnDistro doesn't know, in values.
+func unmarshalValues(data []byte, typed interface{}, values *map[string]interface{}) error {
+	all := make(map[string]json.RawMessage)
+	err := json.Unmarshal(data, &all)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]json.RawMessage)
+	rest := make(map[string]interface{})
+	fields := jsonFields(reflect.TypeOf(typed).Elem())
+	for _, name := range fields {
+		if v, ok := all[name]; ok {
+			known[name] = v
+			delete(all, name)
+		}
+	}
+	for k, raw := range all {
+		// a typo of a typed field would be silently passed to the chart
+		if s := typoOf(k, fields); s != "" {
+			return errors.Errorf("unknown field %q, did you mean %q?", k, s)
+		}
+		var v interface{}
+		err = json.Unmarshal(raw, &v)
+		if err != nil {
+			return err
+		}
+		rest[k] = v
+	}
+	byt, err := json.Marshal(known)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(byt, typed)
+	if err != nil {
+		return err
+	}
+	// the typed fields are checked field by field to report where the error is
+	for name, raw := range known {
+		field := fieldByJSONName(reflect.ValueOf(typed).Elem(), name)
+		if !field.IsValid() || field.Kind() != reflect.Ptr || field.Type().Elem().Kind() != reflect.Struct {
+			continue
+		}
+		if _, ok := field.Interface().(json.Unmarshaler); ok {
+			continue
+		}
+		err = decodeStrict(raw, reflect.New(field.Type().Elem()).Interface())
+		if err != nil {
+			return errors.Wrap(err, name)
+		}
+	}
+	*values = nil
+	if len(rest) > 0 {
+		*values = rest
+	}
+	return nil
+}
+
+// marshalValues encodes the typed struct merged with the chart values.
+func marshalValues(typed interface{}, values map[string]interface{}) ([]byte, error) {
+	byt, err := json.Marshal(typed)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		m[k] = v
+	}
+	err = json.Unmarshal(byt, &m)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+func fieldByJSONName(v reflect.Value, name string) reflect.Value {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("json"), ",")[0] == name {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
@@ -37,3 +37,6 @@ var DefaultArchFS embed.FS
 
 //go:embed policies
 var PoliciesFS embed.FS
+
+//go:embed compatibility.yaml
+var CompatibilityMatrix []byte
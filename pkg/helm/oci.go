@@ -0,0 +1,67 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/getupio-undistro/undistro/pkg/registry"
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+// OCIGetter fetches files stored as single layer OCI artifacts.
+// The URL path is read as a repository with an optional tag, so both
+// oci://registry.local/charts/index.yaml and oci://registry.local/charts/calico:v3.19.1
+// are valid URLs.
+type OCIGetter struct {
+	client *registry.Client
+}
+
+// NewOCIGetter constructs a valid oci:// protocol getter.
+func NewOCIGetter(_ ...getter.Option) (getter.Getter, error) {
+	return &OCIGetter{client: registry.NewClient()}, nil
+}
+
+// Get performs a Get from repo.Getter and returns the body.
+func (g *OCIGetter) Get(u string, _ ...getter.Option) (*bytes.Buffer, error) {
+	ref, err := OCIReference(u)
+	if err != nil {
+		return nil, err
+	}
+	byt, err := g.client.PullArtifact(context.Background(), ref)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(byt), nil
+}
+
+// OCIReference returns the reference of an oci:// URL.
+func OCIReference(u string) (registry.Reference, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return registry.Reference{}, err
+	}
+	if parsed.Scheme != "oci" {
+		return registry.Reference{}, fmt.Errorf("invalid OCI URL %q", u)
+	}
+	// chart repositories append a dot to the host to avoid search domains
+	host := strings.TrimSuffix(parsed.Host, ".")
+	return registry.ParseReference(host + parsed.Path)
+}
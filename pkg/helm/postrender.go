@@ -0,0 +1,42 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package helm
+
+import (
+	"bytes"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/registry"
+	"helm.sh/helm/v3/pkg/postrender"
+)
+
+// imageRelocator moves the images of the rendered manifests to a registry.
+type imageRelocator struct {
+	registry string
+}
+
+var _ postrender.PostRenderer = imageRelocator{}
+
+func (r imageRelocator) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBuffer(registry.RelocateImages(renderedManifests.Bytes(), r.registry)), nil
+}
+
+func postRenderer(hr appv1alpha1.HelmRelease) postrender.PostRenderer {
+	if hr.Spec.ImageRegistry == "" {
+		return nil
+	}
+	return imageRelocator{registry: hr.Spec.ImageRegistry}
+}
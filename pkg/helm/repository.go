@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/url"
 	"path"
 	"sort"
@@ -169,7 +170,7 @@ func (r *ChartRepository) DownloadChart(chart *repo.ChartVersion) (*bytes.Buffer
 		u = repoURL.ResolveReference(u)
 		u.RawQuery = q.Encode()
 	} else if u.Host != "" {
-		u.Host = fmt.Sprintf("%s.", u.Host)
+		u.Host = fqdn(u)
 	}
 	r.Options = append(r.Options, getter.WithURL(u.String()))
 	return r.Client.Get(u.String(), r.Options...)
@@ -202,7 +203,7 @@ func (r *ChartRepository) DownloadIndex() error {
 	if err != nil {
 		return err
 	}
-	u.Host = fmt.Sprintf("%s.", u.Host)
+	u.Host = fqdn(u)
 	u.RawPath = path.Join(u.RawPath, "index.yaml")
 	u.Path = path.Join(u.Path, "index.yaml")
 
@@ -217,3 +218,17 @@ func (r *ChartRepository) DownloadIndex() error {
 
 	return r.LoadIndex(b)
 }
+
+// fqdn returns the host of u with a trailing dot in the host name,
+// so the resolver does not try search domains. Ports and IP addresses
+// are kept intact.
+func fqdn(u *url.URL) string {
+	h := u.Hostname()
+	if net.ParseIP(h) != nil || strings.HasSuffix(h, ".") {
+		return u.Host
+	}
+	if p := u.Port(); p != "" {
+		return net.JoinHostPort(h+".", p)
+	}
+	return h + "."
+}
@@ -246,6 +246,19 @@ func TestChartRepository_DownloadIndex(t *testing.T) {
 		t.Errorf("DownloadIndex() requested URL = %s, wantURL %s", mg.requestedURL, expected)
 	}
 	verifyLocalIndex(t, r.Index)
+
+	for url, want := range map[string]string{
+		"https://example.com:8443/charts": "https://example.com.:8443/charts/index.yaml",
+		"http://127.0.0.1:5000":           "http://127.0.0.1:5000/index.yaml",
+	} {
+		r.URL = url
+		if err := r.DownloadIndex(); err != nil {
+			t.Fatal(err)
+		}
+		if mg.requestedURL != want {
+			t.Errorf("DownloadIndex() requested URL = %s, wantURL %s", mg.requestedURL, want)
+		}
+	}
 }
 
 // Index load tests are derived from https://github.com/helm/helm/blob/v3.3.4/pkg/repo/index_test.go#L108
@@ -81,6 +81,7 @@ func (r *Runner) Install(hr appv1alpha1.HelmRelease, chart *chart.Chart, values
 	install.SkipCRDs = hr.Spec.SkipCRDs
 	install.DependencyUpdate = true
 	install.CreateNamespace = true
+	install.PostRenderer = postRenderer(hr)
 	return install.Run(chart, values.AsMap())
 }
 
@@ -99,6 +100,7 @@ func (r *Runner) Upgrade(hr appv1alpha1.HelmRelease, chart *chart.Chart, values
 	upgrade.Recreate = true
 	upgrade.Devel = true
 	upgrade.Install = true
+	upgrade.PostRenderer = postRenderer(hr)
 	rel, err := upgrade.Run(hr.Spec.ReleaseName, chart, values.AsMap())
 	return rel, err
 }
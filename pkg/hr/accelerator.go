@@ -0,0 +1,73 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package hr
+
+import (
+	"sort"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/util"
+	"github.com/pkg/errors"
+)
+
+type acceleratorChart struct {
+	name      string
+	version   string
+	namespace string
+}
+
+// acceleratorCharts maps accelerator types to the chart installing their drivers and device plugin
+var acceleratorCharts = map[string]acceleratorChart{
+	"nvidia": {
+		name:      "gpu-operator",
+		version:   "1.8.2",
+		namespace: "gpu-operator-resources",
+	},
+}
+
+// PrepareAccelerator returns the release that installs the drivers and device plugin
+// of the accelerator type in the cluster.
+func PrepareAccelerator(cl *appv1alpha1.Cluster, accType string) (appv1alpha1.HelmRelease, error) {
+	chart, ok := acceleratorCharts[accType]
+	if !ok {
+		return appv1alpha1.HelmRelease{}, errors.Errorf("accelerator %s is not supported", accType)
+	}
+	// EKS nodes use the accelerated AMI which already has the drivers and container toolkit
+	managed := cl.Spec.InfrastructureProvider.IsManaged()
+	values := map[string]interface{}{
+		"driver": map[string]interface{}{
+			"enabled": !managed,
+		},
+		"toolkit": map[string]interface{}{
+			"enabled": !managed,
+		},
+	}
+	return Prepare(chart.name, chart.namespace, cl.GetNamespace(), chart.version, cl.Name, values)
+}
+
+// UnusedAcceleratorReleases returns the releases of the accelerator types
+// no pool of the cluster requests, which must be uninstalled.
+func UnusedAcceleratorReleases(cl *appv1alpha1.Cluster) []string {
+	used := cl.Accelerators()
+	releases := make([]string, 0)
+	for accType, chart := range acceleratorCharts {
+		if !util.ContainsStringInSlice(used, accType) {
+			releases = append(releases, chart.name)
+		}
+	}
+	sort.Strings(releases)
+	return releases
+}
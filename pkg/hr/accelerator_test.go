@@ -0,0 +1,75 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package hr
+
+import (
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+)
+
+func TestPrepareAccelerator(t *testing.T) {
+	tests := []struct {
+		name       string
+		flavor     string
+		accType    string
+		wantValues string
+		wantErr    bool
+	}{
+		{
+			name:       "nvidia on ec2 installs drivers",
+			flavor:     appv1alpha1.EC2.String(),
+			accType:    "nvidia",
+			wantValues: `{"driver":{"enabled":true},"toolkit":{"enabled":true}}`,
+		},
+		{
+			name:       "nvidia on eks uses the ami drivers",
+			flavor:     appv1alpha1.EKS.String(),
+			accType:    "nvidia",
+			wantValues: `{"driver":{"enabled":false},"toolkit":{"enabled":false}}`,
+		},
+		{
+			name:    "unsupported accelerator",
+			flavor:  appv1alpha1.EC2.String(),
+			accType: "aws",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := &appv1alpha1.Cluster{}
+			cl.Name = "test"
+			cl.Namespace = "default"
+			cl.Spec.InfrastructureProvider = appv1alpha1.InfrastructureProvider{
+				Name:   appv1alpha1.Amazon.String(),
+				Flavor: tt.flavor,
+			}
+			got, err := PrepareAccelerator(cl, tt.accType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PrepareAccelerator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Spec.ClusterName != "default/test" {
+				t.Errorf("PrepareAccelerator() clusterName = %s, want default/test", got.Spec.ClusterName)
+			}
+			if string(got.Spec.Values.Raw) != tt.wantValues {
+				t.Errorf("PrepareAccelerator() values = %s, want %s", got.Spec.Values.Raw, tt.wantValues)
+			}
+		})
+	}
+}
@@ -34,6 +34,13 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
+// Repository and ImageRegistry are set on the HelmReleases created by Prepare.
+// The manager points them to a local registry on disconnected installations.
+var (
+	Repository    = undistro.DefaultRepo
+	ImageRegistry string
+)
+
 func Install(ctx context.Context, c client.Client, log logr.Logger, hr appv1alpha1.HelmRelease, cl *appv1alpha1.Cluster) error {
 	msg := fmt.Sprintf("Check condition for %s release", hr.Name)
 	log.Info(msg, "lastAppliedVersion", hr.Status.LastAppliedRevision)
@@ -70,11 +77,12 @@ func Prepare(releaseName, targetNs, clusterNs, version, clName string, v map[str
 		Values:          values,
 		Chart: appv1alpha1.ChartSource{
 			RepoChartSource: appv1alpha1.RepoChartSource{
-				RepoURL: undistro.DefaultRepo,
+				RepoURL: Repository,
 				Name:    releaseName,
 				Version: version,
 			},
 		},
+		ImageRegistry: ImageRegistry,
 	}
 	if !util.IsMgmtCluster(clName) {
 		hrSpec.ClusterName = fmt.Sprintf("%s/%s", clusterNs, clName)
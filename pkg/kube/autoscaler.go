@@ -0,0 +1,91 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kube
+
+import (
+	"bufio"
+	"context"
+	"strings"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	autoscalerStatusName        = "cluster-autoscaler-status"
+	autoscalerStatusNamespace   = "kube-system"
+	autoscalerStatusKey         = "status"
+	autoscalerLastUpdatedAnnKey = "cluster-autoscaler.kubernetes.io/last-updated"
+)
+
+// AutoscalerStatus reads the status ConfigMap written by the cluster-autoscaler
+// in the workload cluster.
+func AutoscalerStatus(ctx context.Context, r client.Client, cl *appv1alpha1.Cluster) (*appv1alpha1.ClusterAutoscalerStatus, error) {
+	c, err := NewClusterClient(ctx, r, cl.Name, cl.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	cm := corev1.ConfigMap{}
+	key := client.ObjectKey{
+		Name:      autoscalerStatusName,
+		Namespace: autoscalerStatusNamespace,
+	}
+	err = c.Get(ctx, key, &cm)
+	if err != nil {
+		return nil, err
+	}
+	status := ParseAutoscalerStatus(cm.Data[autoscalerStatusKey])
+	status.LastUpdated = cm.Annotations[autoscalerLastUpdatedAnnKey]
+	return status, nil
+}
+
+// ParseAutoscalerStatus extracts the cluster-wide health, scale up and scale down
+// summaries from the human readable status reported by the cluster-autoscaler.
+func ParseAutoscalerStatus(data string) *appv1alpha1.ClusterAutoscalerStatus {
+	status := appv1alpha1.ClusterAutoscalerStatus{}
+	inClusterWide := false
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "Cluster-wide:" {
+			inClusterWide = true
+			continue
+		}
+		if !inClusterWide {
+			continue
+		}
+		// other sections like NodeGroups start at the beginning of the line
+		if trimmed != "" && !strings.HasPrefix(line, " ") {
+			break
+		}
+		split := strings.SplitN(trimmed, ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(split[1])
+		switch split[0] {
+		case "Health":
+			status.Health = value
+		case "ScaleUp":
+			status.ScaleUp = value
+		case "ScaleDown":
+			status.ScaleDown = value
+		}
+	}
+	return &status
+}
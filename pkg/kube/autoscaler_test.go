@@ -0,0 +1,68 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kube
+
+import (
+	"reflect"
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+)
+
+const autoscalerStatusSample = `Cluster-autoscaler status at 2021-10-18 12:00:00.000000000 +0000 UTC:
+Cluster-wide:
+  Health:      Healthy (ready=3 unready=0 notStarted=0 longNotStarted=0 registered=3 longUnregistered=0)
+               LastProbeTime:      2021-10-18 12:00:00.000000000 +0000 UTC
+               LastTransitionTime: 2021-10-18 11:00:00.000000000 +0000 UTC
+  ScaleUp:     NoActivity (ready=3 registered=3)
+               LastProbeTime:      2021-10-18 12:00:00.000000000 +0000 UTC
+  ScaleDown:   NoCandidates (candidates=0)
+               LastProbeTime:      2021-10-18 12:00:00.000000000 +0000 UTC
+
+NodeGroups:
+  Name:        MachineDeployment/default/test-mp-0
+  Health:      Healthy (ready=3 unready=0 notStarted=0 longNotStarted=0 registered=3 longUnregistered=0 cloudProviderTarget=3 (minSize=1, maxSize=5))
+`
+
+func TestParseAutoscalerStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want *appv1alpha1.ClusterAutoscalerStatus
+	}{
+		{
+			name: "cluster wide section",
+			data: autoscalerStatusSample,
+			want: &appv1alpha1.ClusterAutoscalerStatus{
+				Health:    "Healthy (ready=3 unready=0 notStarted=0 longNotStarted=0 registered=3 longUnregistered=0)",
+				ScaleUp:   "NoActivity (ready=3 registered=3)",
+				ScaleDown: "NoCandidates (candidates=0)",
+			},
+		},
+		{
+			name: "empty status",
+			data: "",
+			want: &appv1alpha1.ClusterAutoscalerStatus{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseAutoscalerStatus(tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAutoscalerStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
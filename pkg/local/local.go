@@ -0,0 +1,269 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package local creates and deletes the local clusters UnDistro is set up in
+// with kind, k3d or minikube, and finds the node addresses MetalLB announces in them.
+package local
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/getupio-undistro/meta"
+	"github.com/getupio-undistro/undistro/pkg/undistro"
+	"github.com/getupio-undistro/undistro/pkg/util"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Tools are the names of the supported local cluster tools.
+var Tools = []string{util.Kind.String(), util.K3d.String(), util.Minikube.String()}
+
+// Commander runs the CLIs the local clusters are managed with,
+// tests replace it with a fake.
+type Commander interface {
+	// Run runs the command attached to the streams.
+	Run(ctx context.Context, streams genericclioptions.IOStreams, name string, args ...string) error
+	// Output runs the command and returns its standard output.
+	Output(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// Exec runs the commands in the host.
+type Exec struct{}
+
+func lookPath(name string) error {
+	_, err := exec.LookPath(name)
+	if err != nil {
+		return errors.Errorf("%s is required, please install it: %v", name, err)
+	}
+	return nil
+}
+
+func (Exec) Run(ctx context.Context, streams genericclioptions.IOStreams, name string, args ...string) error {
+	err := lookPath(name)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = streams.In
+	cmd.Stdout = streams.Out
+	cmd.Stderr = streams.ErrOut
+	return cmd.Run()
+}
+
+func (Exec) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	err := lookPath(name)
+	if err != nil {
+		return nil, err
+	}
+	stderr := bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s %s: %s", name, strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Tool creates and deletes local clusters.
+type Tool interface {
+	// Create creates the cluster with the ports 80 and 443 of the host mapped
+	// to its control plane node, where ingress-nginx is scheduled.
+	Create(ctx context.Context, streams genericclioptions.IOStreams, name string) error
+	Delete(ctx context.Context, streams genericclioptions.IOStreams, name string) error
+	// Address returns the address of a node of the cluster reachable from the host.
+	Address(ctx context.Context, node corev1.Node) (string, error)
+}
+
+// NewTool returns the local cluster tool of the name.
+func NewTool(name string, cmd Commander) (Tool, error) {
+	switch name {
+	case util.Kind.String():
+		return kind{cmd: cmd}, nil
+	case util.K3d.String():
+		return k3d{cmd: cmd}, nil
+	case util.Minikube.String():
+		return minikube{cmd: cmd}, nil
+	}
+	return nil, errors.Errorf("unsupported local cluster tool %s, use one of %s", name, strings.Join(Tools, ", "))
+}
+
+// IsTool returns true when the name is a local cluster tool.
+func IsTool(name string) bool {
+	return util.ContainsStringInSlice(Tools, name)
+}
+
+// Address returns the address of the local cluster MetalLB announces,
+// the one of the first control plane node.
+func Address(ctx context.Context, c client.Client, cmd Commander) (string, error) {
+	nodes := corev1.NodeList{}
+	err := c.List(ctx, &nodes)
+	if err != nil {
+		return "", err
+	}
+	sort.SliceStable(nodes.Items, func(i, j int) bool {
+		return isControlPlane(nodes.Items[i]) && !isControlPlane(nodes.Items[j])
+	})
+	for _, node := range nodes.Items {
+		typ := util.NodeLocalClusterType(node)
+		if typ == util.NonLocal {
+			continue
+		}
+		tool, err := NewTool(typ.String(), cmd)
+		if err != nil {
+			return "", err
+		}
+		return tool.Address(ctx, node)
+	}
+	return "", errors.New("cluster is not local")
+}
+
+func isControlPlane(node corev1.Node) bool {
+	_, cp := node.Labels["node-role.kubernetes.io/control-plane"]
+	_, master := node.Labels[meta.LabelK8sMaster]
+	return cp || master
+}
+
+// containerAddress returns the address of the container of a node in the docker network,
+// or the internal address of the node when it isn't a docker container.
+func containerAddress(ctx context.Context, cmd Commander, node corev1.Node) (string, error) {
+	out, err := cmd.Output(ctx, "docker", "inspect", "-f", "{{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", node.Name)
+	if err == nil {
+		fields := strings.Fields(string(out))
+		if len(fields) > 0 {
+			return fields[0], nil
+		}
+	}
+	addr := internalAddress(node)
+	if addr == "" {
+		return "", errors.Errorf("unable to get the address of node %s: %v", node.Name, err)
+	}
+	return addr, nil
+}
+
+func internalAddress(node corev1.Node) string {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+type kind struct {
+	cmd Commander
+}
+
+// Create creates the cluster with the UnDistro kind config, written to a
+// temporary file because kind reads the config from a path.
+func (t kind) Create(ctx context.Context, streams genericclioptions.IOStreams, name string) error {
+	f, err := os.CreateTemp("", "undistro-kind-*.yaml")
+	if err != nil {
+		return errors.Wrap(err, "unable to create the kind config")
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(undistro.KindCfg)
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "unable to write the kind config")
+	}
+	err = f.Close()
+	if err != nil {
+		return errors.Wrap(err, "unable to write the kind config")
+	}
+	return t.cmd.Run(ctx, streams, "kind", "create", "cluster", "--name", name, "--config", f.Name())
+}
+
+func (t kind) Delete(ctx context.Context, streams genericclioptions.IOStreams, name string) error {
+	return t.cmd.Run(ctx, streams, "kind", "delete", "cluster", "--name", name)
+}
+
+// Address returns the address of the node container, kind names it after the node.
+func (t kind) Address(ctx context.Context, node corev1.Node) (string, error) {
+	return containerAddress(ctx, t.cmd, node)
+}
+
+type k3d struct {
+	cmd Commander
+}
+
+// Create creates the cluster without traefik and servicelb,
+// UnDistro installs ingress-nginx and MetalLB instead.
+func (t k3d) Create(ctx context.Context, streams genericclioptions.IOStreams, name string) error {
+	return t.cmd.Run(ctx, streams, "k3d", "cluster", "create", name,
+		"--api-port", "6443",
+		"--port", "80:80@server:0",
+		"--port", "443:443@server:0",
+		"--k3s-arg", "--disable=traefik@server:0",
+		"--k3s-arg", "--disable=servicelb@server:0",
+		"--k3s-arg", "--kube-apiserver-arg=cors-allowed-origins=http://*,https://*@server:0",
+		"--k3s-node-label", "ingress-ready=true@server:0",
+		"--wait",
+	)
+}
+
+func (t k3d) Delete(ctx context.Context, streams genericclioptions.IOStreams, name string) error {
+	return t.cmd.Run(ctx, streams, "k3d", "cluster", "delete", name)
+}
+
+// Address returns the address of the node container, k3d names it after the node.
+func (t k3d) Address(ctx context.Context, node corev1.Node) (string, error) {
+	return containerAddress(ctx, t.cmd, node)
+}
+
+type minikube struct {
+	cmd Commander
+}
+
+// Create creates the cluster in a docker container, so the ports of the host can be mapped to it.
+func (t minikube) Create(ctx context.Context, streams genericclioptions.IOStreams, name string) error {
+	return t.cmd.Run(ctx, streams, "minikube", "start",
+		"--profile", name,
+		"--driver", "docker",
+		"--ports", "80:80",
+		"--ports", "443:443",
+		"--extra-config", "apiserver.cors-allowed-origins=http://*,https://*",
+		"--extra-config", "kubelet.node-labels=ingress-ready=true",
+		"--wait", "all",
+	)
+}
+
+func (t minikube) Delete(ctx context.Context, streams genericclioptions.IOStreams, name string) error {
+	return t.cmd.Run(ctx, streams, "minikube", "delete", "--profile", name)
+}
+
+// Address returns the address minikube reports for the profile of the node.
+func (t minikube) Address(ctx context.Context, node corev1.Node) (string, error) {
+	profile := node.Labels[util.MinikubeProfileLabel]
+	if profile == "" {
+		profile = node.Name
+	}
+	out, err := t.cmd.Output(ctx, "minikube", "ip", "--profile", profile)
+	if err == nil && strings.TrimSpace(string(out)) != "" {
+		return strings.TrimSpace(string(out)), nil
+	}
+	addr := internalAddress(node)
+	if addr == "" {
+		return "", errors.Errorf("unable to get the address of minikube profile %s: %v", profile, err)
+	}
+	return addr, nil
+}
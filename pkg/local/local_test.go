@@ -0,0 +1,191 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package local
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/getupio-undistro/meta"
+	"github.com/getupio-undistro/undistro/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeCommander records the commands and answers Output with the outputs of the command lines.
+type fakeCommander struct {
+	calls   []string
+	outputs map[string]string
+}
+
+func (f *fakeCommander) Run(_ context.Context, _ genericclioptions.IOStreams, name string, args ...string) error {
+	f.calls = append(f.calls, strings.Join(append([]string{name}, args...), " "))
+	return nil
+}
+
+func (f *fakeCommander) Output(_ context.Context, name string, args ...string) ([]byte, error) {
+	line := strings.Join(append([]string{name}, args...), " ")
+	f.calls = append(f.calls, line)
+	out, ok := f.outputs[line]
+	if !ok {
+		return nil, errors.New("command failed")
+	}
+	return []byte(out), nil
+}
+
+func TestNewTool(t *testing.T) {
+	for _, name := range Tools {
+		_, err := NewTool(name, &fakeCommander{})
+		if err != nil {
+			t.Errorf("NewTool(%s) error = %v", name, err)
+		}
+	}
+	_, err := NewTool("aws", &fakeCommander{})
+	if err == nil {
+		t.Error("NewTool(aws) expected error")
+	}
+}
+
+func TestToolCommands(t *testing.T) {
+	tests := []struct {
+		tool   string
+		create bool
+		want   string
+	}{
+		{tool: "kind", want: "kind delete cluster --name undistro"},
+		{tool: "k3d", want: "k3d cluster delete undistro"},
+		{tool: "minikube", want: "minikube delete --profile undistro"},
+		{tool: "kind", create: true, want: "kind create cluster --name undistro --config "},
+		{tool: "k3d", create: true, want: "k3d cluster create undistro"},
+		{tool: "minikube", create: true, want: "minikube start --profile undistro --driver docker"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			cmd := &fakeCommander{}
+			tool, err := NewTool(tt.tool, cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.create {
+				err = tool.Create(context.Background(), genericclioptions.IOStreams{}, "undistro")
+			} else {
+				err = tool.Delete(context.Background(), genericclioptions.IOStreams{}, "undistro")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cmd.calls) != 1 || !strings.HasPrefix(cmd.calls[0], tt.want) {
+				t.Errorf("calls = %v, want %s", cmd.calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddress(t *testing.T) {
+	const inspect = "docker inspect -f {{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}} "
+	tests := []struct {
+		name    string
+		nodes   []client.Object
+		outputs map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "kind control plane",
+			nodes: []client.Object{
+				&corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "undistro-worker"},
+					Spec:       corev1.NodeSpec{ProviderID: "kind://docker/undistro/undistro-worker"},
+				},
+				&corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "undistro-control-plane", Labels: map[string]string{meta.LabelK8sMaster: ""}},
+					Spec:       corev1.NodeSpec{ProviderID: "kind://docker/undistro/undistro-control-plane"},
+				},
+			},
+			outputs: map[string]string{
+				inspect + "undistro-worker":        "172.18.0.3 \n",
+				inspect + "undistro-control-plane": "172.18.0.2 \n",
+			},
+			want: "172.18.0.2",
+		},
+		{
+			name: "k3d",
+			nodes: []client.Object{
+				&corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "k3d-undistro-server-0", Labels: map[string]string{"node-role.kubernetes.io/control-plane": "true"}},
+					Spec:       corev1.NodeSpec{ProviderID: "k3s://k3d-undistro-server-0"},
+				},
+			},
+			outputs: map[string]string{
+				inspect + "k3d-undistro-server-0": "172.19.0.2 \n",
+			},
+			want: "172.19.0.2",
+		},
+		{
+			name: "k3d without docker",
+			nodes: []client.Object{
+				&corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "k3d-undistro-server-0"},
+					Spec:       corev1.NodeSpec{ProviderID: "k3s://k3d-undistro-server-0"},
+					Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+						{Type: corev1.NodeHostName, Address: "k3d-undistro-server-0"},
+						{Type: corev1.NodeInternalIP, Address: "172.19.0.2"},
+					}},
+				},
+			},
+			want: "172.19.0.2",
+		},
+		{
+			name: "minikube",
+			nodes: []client.Object{
+				&corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "undistro", Labels: map[string]string{util.MinikubeProfileLabel: "undistro"}},
+				},
+			},
+			outputs: map[string]string{
+				"minikube ip --profile undistro": "192.168.49.2\n",
+			},
+			want: "192.168.49.2",
+		},
+		{
+			name: "not local",
+			nodes: []client.Object{
+				&corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "ip-10-0-1-10.ec2.internal"},
+					Spec:       corev1.NodeSpec{ProviderID: "aws:///us-east-1a/i-0123456789abcdef0"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithObjects(tt.nodes...).Build()
+			got, err := Address(context.Background(), c, &fakeCommander{outputs: tt.outputs})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Address() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Address() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
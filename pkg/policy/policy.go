@@ -0,0 +1,189 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package policy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"path/filepath"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/getupio-undistro/undistro/pkg/fs"
+	"github.com/getupio-undistro/undistro/pkg/util"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Render executes the policy template with the parameter values.
+// Parameters use the [[ ]] delimiters because Kyverno variables already use {{ }}.
+func Render(name string, byt []byte, values map[string]interface{}) ([]byte, error) {
+	tmpl, err := template.New(name).Delims("[[", "]]").Funcs(sprig.TxtFuncMap()).Parse(string(byt))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid policy %s", name)
+	}
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+	buf := bytes.Buffer{}
+	err = tmpl.Execute(&buf, values)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to render policy %s", name)
+	}
+	return buf.Bytes(), nil
+}
+
+// Names returns the names of the policies in a policy template
+func Names(name string, byt []byte) ([]string, error) {
+	byt, err := Render(name, byt, nil)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := util.ToUnstructured(byt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid policy %s", name)
+	}
+	names := make([]string, len(objs))
+	for i, o := range objs {
+		names[i] = o.GetName()
+	}
+	return names, nil
+}
+
+// DefaultNames returns the names of the policies embedded in UnDistro
+func DefaultNames() ([]string, error) {
+	dir, err := fs.PoliciesFS.ReadDir("policies")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(dir))
+	for _, f := range dir {
+		if f.IsDir() {
+			continue
+		}
+		byt, err := fs.PoliciesFS.ReadFile(filepath.Join("policies", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		n, err := Names(f.Name(), byt)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, n...)
+	}
+	return names, nil
+}
+
+// Configure sets the validation failure action of the policy, when not empty,
+// and excludes the namespaces from its rules. Rules excluding users, groups or roles
+// are kept as they are because Kyverno only excludes requests matching all the
+// exclude conditions.
+func Configure(o *unstructured.Unstructured, action string, excludeNamespaces []string) error {
+	if action != "" {
+		err := unstructured.SetNestedField(o.Object, action, "spec", "validationFailureAction")
+		if err != nil {
+			return err
+		}
+	}
+	if len(excludeNamespaces) == 0 {
+		return nil
+	}
+	rules, ok, err := unstructured.NestedSlice(o.Object, "spec", "rules")
+	if !ok || err != nil {
+		return err
+	}
+	for i := range rules {
+		rule, ok := rules[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		exclude, _, err := unstructured.NestedMap(rule, "exclude")
+		if err != nil {
+			return err
+		}
+		if hasUserInfo(exclude) {
+			continue
+		}
+		namespaces, _, err := unstructured.NestedStringSlice(exclude, "resources", "namespaces")
+		if err != nil {
+			return err
+		}
+		set := sets.NewString(namespaces...).Insert(excludeNamespaces...)
+		err = unstructured.SetNestedStringSlice(rule, set.List(), "exclude", "resources", "namespaces")
+		if err != nil {
+			return err
+		}
+		rules[i] = rule
+	}
+	return unstructured.SetNestedSlice(o.Object, rules, "spec", "rules")
+}
+
+func hasUserInfo(exclude map[string]interface{}) bool {
+	for _, k := range []string{"clusterRoles", "roles", "subjects"} {
+		if _, ok := exclude[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ClusterPolicyGVK is the kind of the Kyverno cluster-wide policies
+var ClusterPolicyGVK = schema.GroupVersionKind{
+	Group:   "kyverno.io",
+	Version: "v1",
+	Kind:    "ClusterPolicy",
+}
+
+// Prune deletes the cluster policies matching the owner labels, or named in previous,
+// that are not desired anymore. Names in previous cover policies applied before
+// the owner labels existed. It returns the names of the deleted policies.
+func Prune(ctx context.Context, c client.Client, owner client.MatchingLabels, previous []string, desired sets.String) ([]string, error) {
+	owned := sets.NewString(previous...)
+	l := unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(ClusterPolicyGVK.GroupVersion().WithKind(ClusterPolicyGVK.Kind + "List"))
+	err := c.List(ctx, &l, owner)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range l.Items {
+		owned.Insert(o.GetName())
+	}
+	stale := owned.Difference(desired).List()
+	for _, name := range stale {
+		u := unstructured.Unstructured{}
+		u.SetGroupVersionKind(ClusterPolicyGVK)
+		u.SetName(name)
+		err = c.Delete(ctx, &u)
+		if client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+	}
+	return stale, nil
+}
+
+// Revision returns the checksum of a set of rendered policies
+func Revision(policies [][]byte) string {
+	h := sha1.New()
+	for _, p := range policies {
+		h.Write(p)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package policy
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/getupio-undistro/undistro/pkg/util"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRender(t *testing.T) {
+	byt, err := os.ReadFile("../fs/policies/disallow-latest-tag.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		values    map[string]interface{}
+		rules     int
+		wantImage string
+	}{
+		{
+			name:  "without parameters",
+			rules: 2,
+		},
+		{
+			name: "with allowed registries",
+			values: map[string]interface{}{
+				"allowedRegistries": []interface{}{"registry.example.com", "ghcr.io/example"},
+			},
+			rules:     3,
+			wantImage: "registry.example.com/* | ghcr.io/example/*",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Render("disallow-latest-tag", byt, tt.values)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			objs, err := util.ToUnstructured(out)
+			if err != nil {
+				t.Fatalf("rendered policy is invalid: %v", err)
+			}
+			rules, _, _ := unstructured.NestedSlice(objs[0].Object, "spec", "rules")
+			if len(rules) != tt.rules {
+				t.Fatalf("got %d rules, want %d", len(rules), tt.rules)
+			}
+			if tt.wantImage != "" {
+				containers, _, _ := unstructured.NestedSlice(rules[2].(map[string]interface{}), "validate", "pattern", "spec", "containers")
+				image := containers[0].(map[string]interface{})["image"]
+				if image != tt.wantImage {
+					t.Errorf("got image pattern %v, want %s", image, tt.wantImage)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderKeepsKyvernoVariables(t *testing.T) {
+	byt, err := os.ReadFile("../fs/policies/disallow-delete-kyverno.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Render("disallow-delete-kyverno", byt, nil)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if !strings.Contains(string(out), "{{request.operation}}") {
+		t.Errorf("Kyverno variables were not preserved:\n%s", out)
+	}
+}
+
+func TestNames(t *testing.T) {
+	byt, err := os.ReadFile("../fs/policies/network-policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Names("network-policy.yaml", byt)
+	if err != nil {
+		t.Fatalf("Names() error = %v", err)
+	}
+	if want := []string{"traffic-deny"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	policy := `
+apiVersion: kyverno.io/v1
+kind: ClusterPolicy
+metadata:
+  name: sample
+spec:
+  validationFailureAction: enforce
+  rules:
+    - name: by-namespace
+      exclude:
+        resources:
+          namespaces:
+            - kube-system
+    - name: without-exclude
+    - name: by-role
+      exclude:
+        clusterRoles:
+          - cluster-admin
+`
+	tests := []struct {
+		name           string
+		action         string
+		namespaces     []string
+		wantAction     string
+		wantNamespaces [][]string
+	}{
+		{
+			name:           "unchanged",
+			wantAction:     "enforce",
+			wantNamespaces: [][]string{{"kube-system"}, nil, nil},
+		},
+		{
+			name:           "audit with excluded namespaces",
+			action:         "audit",
+			namespaces:     []string{"team-a", "kube-system"},
+			wantAction:     "audit",
+			wantNamespaces: [][]string{{"kube-system", "team-a"}, {"kube-system", "team-a"}, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs, err := util.ToUnstructured([]byte(policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := objs[0]
+			err = Configure(&o, tt.action, tt.namespaces)
+			if err != nil {
+				t.Fatalf("Configure() error = %v", err)
+			}
+			action, _, _ := unstructured.NestedString(o.Object, "spec", "validationFailureAction")
+			if action != tt.wantAction {
+				t.Errorf("got action %s, want %s", action, tt.wantAction)
+			}
+			rules, _, _ := unstructured.NestedSlice(o.Object, "spec", "rules")
+			for i, r := range rules {
+				got, _, _ := unstructured.NestedStringSlice(r.(map[string]interface{}), "exclude", "resources", "namespaces")
+				if !reflect.DeepEqual(got, tt.wantNamespaces[i]) {
+					t.Errorf("rule %d excluded namespaces = %v, want %v", i, got, tt.wantNamespaces[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	newPolicy := func(name string, labels map[string]string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(ClusterPolicyGVK)
+		u.SetName(name)
+		u.SetLabels(labels)
+		return u
+	}
+	owner := map[string]string{"undistro.io/default-policies": "defaultpolicies"}
+	tests := []struct {
+		name        string
+		previous    []string
+		desired     []string
+		wantDeleted []string
+		wantKept    []string
+	}{
+		{
+			name:     "nothing to prune",
+			desired:  []string{"owned", "excluded"},
+			wantKept: []string{"owned", "excluded", "unlabeled", "other-owner"},
+		},
+		{
+			name:        "prune labeled and previously applied policies",
+			previous:    []string{"unlabeled", "removed"},
+			desired:     []string{"owned"},
+			wantDeleted: []string{"excluded", "removed", "unlabeled"},
+			wantKept:    []string{"owned", "other-owner"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).WithObjects(
+				newPolicy("owned", owner),
+				newPolicy("excluded", owner),
+				newPolicy("unlabeled", nil),
+				newPolicy("other-owner", map[string]string{"undistro.io/default-policies": "other"}),
+			).Build()
+			deleted, err := Prune(context.Background(), c, owner, tt.previous, sets.NewString(tt.desired...))
+			if err != nil {
+				t.Fatalf("Prune() error = %v", err)
+			}
+			if len(deleted) != len(tt.wantDeleted) || !sets.NewString(deleted...).HasAll(tt.wantDeleted...) {
+				t.Errorf("Prune() = %v, want %v", deleted, tt.wantDeleted)
+			}
+			for _, name := range tt.wantKept {
+				err = c.Get(context.Background(), client.ObjectKey{Name: name}, newPolicy(name, nil))
+				if err != nil {
+					t.Errorf("policy %s was not kept: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRevision(t *testing.T) {
+	a := Revision([][]byte{[]byte("a"), []byte("b")})
+	if a != Revision([][]byte{[]byte("a"), []byte("b")}) {
+		t.Error("Revision() is not deterministic")
+	}
+	if a == Revision([][]byte{[]byte("a"), []byte("c")}) {
+		t.Error("Revision() did not change with the policies")
+	}
+}
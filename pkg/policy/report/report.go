@@ -0,0 +1,156 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"context"
+	"sort"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MaxOffenders is the number of resources kept in the DefaultPolicies top offenders
+const MaxOffenders = 10
+
+var reportKinds = []schema.GroupVersionKind{
+	{Group: "wgpolicyk8s.io", Version: "v1alpha2", Kind: "PolicyReportList"},
+	{Group: "wgpolicyk8s.io", Version: "v1alpha2", Kind: "ClusterPolicyReportList"},
+}
+
+type resource struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// List returns the policy reports and cluster policy reports created by Kyverno in the cluster
+func List(ctx context.Context, c client.Client) ([]unstructured.Unstructured, error) {
+	reports := make([]unstructured.Unstructured, 0)
+	for _, gvk := range reportKinds {
+		l := unstructured.UnstructuredList{}
+		l.SetGroupVersionKind(gvk)
+		err := c.List(ctx, &l)
+		if err != nil {
+			// policy report CRDs are installed by Kyverno
+			if apimeta.IsNoMatchError(err) {
+				continue
+			}
+			return nil, err
+		}
+		reports = append(reports, l.Items...)
+	}
+	return reports, nil
+}
+
+// Summarize counts the results of each policy in the reports and returns
+// up to maxOffenders resources with most failures.
+func Summarize(reports []unstructured.Unstructured, maxOffenders int) ([]appv1alpha1.PolicyReportSummary, []appv1alpha1.PolicyOffender) {
+	summaries := make(map[string]*appv1alpha1.PolicyReportSummary)
+	offenders := make(map[resource]sets.String)
+	failures := make(map[resource]int)
+	for _, r := range reports {
+		results, _, _ := unstructured.NestedSlice(r.Object, "results")
+		for _, res := range results {
+			m, ok := res.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			policy, _, _ := unstructured.NestedString(m, "policy")
+			// v1alpha1 reports use status instead of result
+			result, ok, _ := unstructured.NestedString(m, "result")
+			if !ok {
+				result, _, _ = unstructured.NestedString(m, "status")
+			}
+			s, ok := summaries[policy]
+			if !ok {
+				s = &appv1alpha1.PolicyReportSummary{Policy: policy}
+				summaries[policy] = s
+			}
+			switch result {
+			case "pass":
+				s.Pass++
+			case "fail":
+				s.Fail++
+			case "warn":
+				s.Warn++
+			case "error":
+				s.Error++
+			case "skip":
+				s.Skip++
+			}
+			if result != "fail" {
+				continue
+			}
+			resources, _, _ := unstructured.NestedSlice(m, "resources")
+			for _, rsc := range resources {
+				rm, ok := rsc.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				key := resource{}
+				key.kind, _, _ = unstructured.NestedString(rm, "kind")
+				key.namespace, _, _ = unstructured.NestedString(rm, "namespace")
+				key.name, _, _ = unstructured.NestedString(rm, "name")
+				if _, ok := offenders[key]; !ok {
+					offenders[key] = sets.NewString()
+				}
+				offenders[key].Insert(policy)
+				failures[key]++
+			}
+		}
+	}
+
+	summaryList := make([]appv1alpha1.PolicyReportSummary, 0, len(summaries))
+	for _, s := range summaries {
+		summaryList = append(summaryList, *s)
+	}
+	sort.Slice(summaryList, func(i, j int) bool {
+		return summaryList[i].Policy < summaryList[j].Policy
+	})
+
+	offenderList := make([]appv1alpha1.PolicyOffender, 0, len(offenders))
+	for key, policies := range offenders {
+		offenderList = append(offenderList, appv1alpha1.PolicyOffender{
+			Kind:      key.kind,
+			Namespace: key.namespace,
+			Name:      key.name,
+			Failures:  failures[key],
+			Policies:  policies.List(),
+		})
+	}
+	sort.Slice(offenderList, func(i, j int) bool {
+		a, b := offenderList[i], offenderList[j]
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	if len(offenderList) > maxOffenders {
+		offenderList = offenderList[:maxOffenders]
+	}
+	return summaryList, offenderList
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/util"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const reports = `
+apiVersion: wgpolicyk8s.io/v1alpha2
+kind: PolicyReport
+metadata:
+  name: polr-ns-default
+  namespace: default
+results:
+  - policy: disallow-latest-tag
+    rule: validate-image-tag
+    result: fail
+    resources:
+      - apiVersion: v1
+        kind: Pod
+        name: nginx
+        namespace: default
+  - policy: require-requests-limits
+    rule: validate-resources
+    result: fail
+    resources:
+      - apiVersion: v1
+        kind: Pod
+        name: nginx
+        namespace: default
+  - policy: disallow-latest-tag
+    rule: validate-image-tag
+    result: pass
+    resources:
+      - apiVersion: v1
+        kind: Pod
+        name: api
+        namespace: default
+  - policy: disallow-host-path
+    rule: host-path
+    result: warn
+    resources:
+      - apiVersion: v1
+        kind: Pod
+        name: api
+        namespace: default
+---
+apiVersion: wgpolicyk8s.io/v1alpha1
+kind: ClusterPolicyReport
+metadata:
+  name: clusterpolicyreport
+results:
+  - policy: disallow-latest-tag
+    rule: validate-image-tag
+    status: fail
+    resources:
+      - apiVersion: v1
+        kind: Pod
+        name: worker
+        namespace: jobs
+  - policy: traffic-deny
+    rule: deny-all-traffic
+    status: skip
+`
+
+func TestSummarize(t *testing.T) {
+	objs, err := util.ToUnstructured([]byte(reports))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		reports       []unstructured.Unstructured
+		maxOffenders  int
+		wantSummaries []appv1alpha1.PolicyReportSummary
+		wantOffenders []appv1alpha1.PolicyOffender
+	}{
+		{
+			name:          "no reports",
+			maxOffenders:  MaxOffenders,
+			wantSummaries: []appv1alpha1.PolicyReportSummary{},
+			wantOffenders: []appv1alpha1.PolicyOffender{},
+		},
+		{
+			name:         "reports",
+			reports:      objs,
+			maxOffenders: MaxOffenders,
+			wantSummaries: []appv1alpha1.PolicyReportSummary{
+				{Policy: "disallow-host-path", Warn: 1},
+				{Policy: "disallow-latest-tag", Pass: 1, Fail: 2},
+				{Policy: "require-requests-limits", Fail: 1},
+				{Policy: "traffic-deny", Skip: 1},
+			},
+			wantOffenders: []appv1alpha1.PolicyOffender{
+				{Kind: "Pod", Namespace: "default", Name: "nginx", Failures: 2, Policies: []string{"disallow-latest-tag", "require-requests-limits"}},
+				{Kind: "Pod", Namespace: "jobs", Name: "worker", Failures: 1, Policies: []string{"disallow-latest-tag"}},
+			},
+		},
+		{
+			name:         "limit offenders",
+			reports:      objs,
+			maxOffenders: 1,
+			wantSummaries: []appv1alpha1.PolicyReportSummary{
+				{Policy: "disallow-host-path", Warn: 1},
+				{Policy: "disallow-latest-tag", Pass: 1, Fail: 2},
+				{Policy: "require-requests-limits", Fail: 1},
+				{Policy: "traffic-deny", Skip: 1},
+			},
+			wantOffenders: []appv1alpha1.PolicyOffender{
+				{Kind: "Pod", Namespace: "default", Name: "nginx", Failures: 2, Policies: []string{"disallow-latest-tag", "require-requests-limits"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summaries, offenders := Summarize(tt.reports, tt.maxOffenders)
+			if !reflect.DeepEqual(summaries, tt.wantSummaries) {
+				t.Errorf("Summarize() summaries = %+v, want %+v", summaries, tt.wantSummaries)
+			}
+			if !reflect.DeepEqual(offenders, tt.wantOffenders) {
+				t.Errorf("Summarize() offenders = %+v, want %+v", offenders, tt.wantOffenders)
+			}
+		})
+	}
+}
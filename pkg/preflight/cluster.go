@@ -0,0 +1,241 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/cloud/aws"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type clusterCIDRs struct {
+	vpc      *net.IPNet
+	pods     []*net.IPNet
+	services []*net.IPNet
+}
+
+func parseCIDRs(cl appv1alpha1.Cluster) (clusterCIDRs, error) {
+	cidrs := clusterCIDRs{}
+	parse := func(s string) (*net.IPNet, error) {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, errors.Errorf("invalid CIDR %q", s)
+		}
+		return n, nil
+	}
+	var err error
+	if cl.Spec.Network.VPC.CIDRBlock != "" {
+		cidrs.vpc, err = parse(cl.Spec.Network.VPC.CIDRBlock)
+		if err != nil {
+			return cidrs, err
+		}
+	}
+	if cl.Spec.Network.Pods != nil {
+		for _, s := range cl.Spec.Network.Pods.CIDRBlocks {
+			n, err := parse(s)
+			if err != nil {
+				return cidrs, err
+			}
+			cidrs.pods = append(cidrs.pods, n)
+		}
+	}
+	if cl.Spec.Network.Services != nil {
+		for _, s := range cl.Spec.Network.Services.CIDRBlocks {
+			n, err := parse(s)
+			if err != nil {
+				return cidrs, err
+			}
+			cidrs.services = append(cidrs.services, n)
+		}
+	}
+	return cidrs, nil
+}
+
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+func overlapsAny(a []*net.IPNet, b ...*net.IPNet) (*net.IPNet, *net.IPNet) {
+	for _, x := range a {
+		for _, y := range b {
+			if y != nil && overlaps(x, y) {
+				return x, y
+			}
+		}
+	}
+	return nil, nil
+}
+
+// checkCIDROverlap fails when the pods, services and VPC networks of a cluster overlap,
+// and warns when the VPCs of clusters in the same region overlap, they can't be peered.
+// Only the clusters to be created are checked in the workload scope.
+func checkCIDROverlap(ctx context.Context, env *Env) []Result {
+	clusters, isNew, err := env.clusters(ctx)
+	if err != nil {
+		return []Result{{Status: Fail, Message: fmt.Sprintf("unable to list clusters: %v", err)}}
+	}
+	if len(clusters) == 0 {
+		return nil
+	}
+	checked := func(i int) bool {
+		return env.Scope&Management != 0 || isNew[i]
+	}
+	results := make([]Result, 0)
+	cidrs := make([]clusterCIDRs, len(clusters))
+	valid := make([]bool, len(clusters))
+	for i, cl := range clusters {
+		name := client.ObjectKeyFromObject(&cl).String()
+		cidrs[i], err = parseCIDRs(cl)
+		if err != nil {
+			if checked(i) {
+				results = append(results, Result{
+					Status:      Fail,
+					Message:     fmt.Sprintf("cluster %s: %v", name, err),
+					Remediation: "fix the network of the cluster spec",
+				})
+			}
+			continue
+		}
+		valid[i] = true
+		if !checked(i) {
+			continue
+		}
+		c := cidrs[i]
+		for _, pair := range [][2][]*net.IPNet{
+			{c.pods, c.services},
+			{c.pods, {c.vpc}},
+			{c.services, {c.vpc}},
+		} {
+			a, b := overlapsAny(pair[0], pair[1]...)
+			if a == nil {
+				continue
+			}
+			results = append(results, Result{
+				Status:      Fail,
+				Message:     fmt.Sprintf("cluster %s: networks %s and %s overlap", name, a, b),
+				Remediation: "use distinct CIDR blocks for the pods, the services and the VPC of the cluster",
+			})
+		}
+	}
+	for i := range clusters {
+		for j := i + 1; j < len(clusters); j++ {
+			if !valid[i] || !valid[j] || !(checked(i) || checked(j)) {
+				continue
+			}
+			a, b := clusters[i], clusters[j]
+			if cidrs[i].vpc == nil || cidrs[j].vpc == nil {
+				continue
+			}
+			if a.Spec.InfrastructureProvider.Name != b.Spec.InfrastructureProvider.Name || a.Spec.InfrastructureProvider.Region != b.Spec.InfrastructureProvider.Region {
+				continue
+			}
+			// clusters sharing a VPC share its CIDR
+			if a.Spec.Network.VPC.ID != "" && a.Spec.Network.VPC.ID == b.Spec.Network.VPC.ID {
+				continue
+			}
+			if !overlaps(cidrs[i].vpc, cidrs[j].vpc) {
+				continue
+			}
+			results = append(results, Result{
+				Status:      Warn,
+				Message:     fmt.Sprintf("VPCs of clusters %s (%s) and %s (%s) overlap", client.ObjectKeyFromObject(&a), cidrs[i].vpc, client.ObjectKeyFromObject(&b), cidrs[j].vpc),
+				Remediation: "set distinct spec.network.vpc.cidrBlock to peer the VPCs of the clusters",
+			})
+		}
+	}
+	if len(results) == 0 {
+		return []Result{{Status: Pass, Message: fmt.Sprintf("no overlapping networks between %d clusters", len(clusters))}}
+	}
+	return results
+}
+
+// checkAWSQuotas estimates the resources used by the AWS clusters of each region
+// at their maximum size and compares them with the service quotas of the account.
+func checkAWSQuotas(ctx context.Context, env *Env) []Result {
+	clusters, isNew, err := env.clusters(ctx)
+	if err != nil {
+		return []Result{{Status: Fail, Message: fmt.Sprintf("unable to list clusters: %v", err)}}
+	}
+	regions := make(map[string][]appv1alpha1.Cluster)
+	created := make(map[string]bool)
+	for i, cl := range clusters {
+		if cl.Spec.InfrastructureProvider.Name != appv1alpha1.Amazon.String() {
+			continue
+		}
+		region := cl.Spec.InfrastructureProvider.Region
+		if region == "" {
+			region = aws.DefaultAWSRegion
+		}
+		regions[region] = append(regions[region], cl)
+		created[region] = created[region] || isNew[i]
+	}
+	names := make([]string, 0, len(regions))
+	for region := range regions {
+		// regions without clusters to be created are not changed
+		if created[region] {
+			names = append(names, region)
+		}
+	}
+	sort.Strings(names)
+	results := make([]Result, 0)
+	for _, region := range names {
+		usage, err := aws.EstimateResources(regions[region])
+		if err != nil {
+			results = append(results, Result{Status: Warn, Message: fmt.Sprintf("unable to estimate the resources in %s: %v", region, err)})
+			continue
+		}
+		estimate := fmt.Sprintf("%d vCPUs, %d VPCs and %d Elastic IPs", usage.VCPUs, usage.VPCs, usage.ElasticIPs)
+		quotas, err := env.Quotas(ctx, region)
+		if err != nil {
+			results = append(results, Result{
+				Status:      Warn,
+				Message:     fmt.Sprintf("clusters in %s use up to %s, unable to get the service quotas: %v", region, estimate, err),
+				Remediation: "check the quotas in the Service Quotas console, the credentials need servicequotas:GetServiceQuota",
+			})
+			continue
+		}
+		exceeded := make([]string, 0)
+		for _, q := range []struct {
+			name         string
+			usage, quota int
+		}{
+			{"vCPUs", usage.VCPUs, quotas.VCPUs},
+			{"VPCs", usage.VPCs, quotas.VPCs},
+			{"Elastic IPs", usage.ElasticIPs, quotas.ElasticIPs},
+		} {
+			if q.usage > q.quota {
+				exceeded = append(exceeded, fmt.Sprintf("%d %s of %d", q.usage, q.name, q.quota))
+			}
+		}
+		if len(exceeded) > 0 {
+			results = append(results, Result{
+				Status:      Warn,
+				Message:     fmt.Sprintf("clusters in %s may exceed the service quotas: %s", region, strings.Join(exceeded, ", ")),
+				Remediation: fmt.Sprintf("request a quota increase in %s in the Service Quotas console", region),
+			})
+			continue
+		}
+		results = append(results, Result{Status: Pass, Message: fmt.Sprintf("clusters in %s use up to %s, within the service quotas", region, estimate)})
+	}
+	return results
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/compatibility"
+	"github.com/getupio-undistro/undistro/pkg/undistro"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+	dialTimeout                    = 5 * time.Second
+)
+
+// crdCharts maps the groups of the CRDs installed by UnDistro to their charts,
+// subgroups like infrastructure.cluster.x-k8s.io belong to the chart of the group.
+var crdCharts = map[string]string{
+	"cert-manager.io":  "cert-manager",
+	"cluster.x-k8s.io": "cluster-api",
+	"undistro.io":      compatibility.UnDistroChart,
+}
+
+// installedChart returns true when the chart is installed by UnDistro in the management cluster.
+func (env *Env) installedChart(ctx context.Context, chart string) (bool, error) {
+	hr := appv1alpha1.HelmRelease{}
+	err := env.Client.Get(ctx, client.ObjectKey{Namespace: undistro.Namespace, Name: chart}, &hr)
+	if apierrors.IsNotFound(err) || apimeta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// release returns the UnDistro release installed in the management cluster,
+// or the latest one when it isn't installed.
+func (env *Env) release(ctx context.Context) compatibility.Release {
+	hr := appv1alpha1.HelmRelease{}
+	err := env.Client.Get(ctx, client.ObjectKey{Namespace: undistro.Namespace, Name: compatibility.UnDistroChart}, &hr)
+	if err == nil {
+		r, err := env.Matrix.Get(hr.Spec.Chart.Version)
+		if err == nil {
+			return r
+		}
+	}
+	return env.Matrix.Latest()
+}
+
+func checkKubernetesVersion(ctx context.Context, env *Env) []Result {
+	if len(env.Matrix.Releases) == 0 {
+		return []Result{{Status: Warn, Message: "no compatibility matrix to check the kubernetes version"}}
+	}
+	if env.KubernetesVersion == "" {
+		return []Result{{Status: Warn, Message: "unable to get the kubernetes version of the management cluster"}}
+	}
+	r := env.release(ctx)
+	err := r.CheckKubernetes(env.KubernetesVersion)
+	if err != nil {
+		return []Result{{
+			Status:      Fail,
+			Message:     err.Error(),
+			Remediation: fmt.Sprintf("use a management cluster with kubernetes %s", r.Kubernetes),
+		}}
+	}
+	return []Result{{Status: Pass, Message: fmt.Sprintf("kubernetes %s is supported by UnDistro %s", env.KubernetesVersion, r.Version)}}
+}
+
+func crdChart(group string) string {
+	for g, chart := range crdCharts {
+		if group == g || strings.HasSuffix(group, "."+g) {
+			return chart
+		}
+	}
+	return ""
+}
+
+// checkCRDConflicts finds the CRDs of the UnDistro charts installed by something else,
+// their charts can't be installed or would change CRDs other software relies on.
+func checkCRDConflicts(ctx context.Context, env *Env) []Result {
+	crds := apiextensionsv1.CustomResourceDefinitionList{}
+	err := env.Client.List(ctx, &crds)
+	if err != nil {
+		return []Result{{Status: Fail, Message: fmt.Sprintf("unable to list CRDs: %v", err)}}
+	}
+	conflicts := make(map[string][]apiextensionsv1.CustomResourceDefinition)
+	installed := make(map[string]bool)
+	for _, crd := range crds.Items {
+		chart := crdChart(crd.Spec.Group)
+		if chart == "" {
+			continue
+		}
+		ok, found := installed[chart]
+		if !found {
+			ok, err = env.installedChart(ctx, chart)
+			if err != nil {
+				return []Result{{Status: Fail, Message: fmt.Sprintf("unable to get %s HelmRelease: %v", chart, err)}}
+			}
+			installed[chart] = ok
+		}
+		if !ok {
+			conflicts[chart] = append(conflicts[chart], crd)
+		}
+	}
+	if len(conflicts) == 0 {
+		return []Result{{Status: Pass, Message: "no CRDs of UnDistro components installed by other tools"}}
+	}
+	charts := make([]string, 0, len(conflicts))
+	for chart := range conflicts {
+		charts = append(charts, chart)
+	}
+	sort.Strings(charts)
+	results := make([]Result, 0, len(charts))
+	for _, chart := range charts {
+		names := make([]string, 0)
+		var owner string
+		for _, crd := range conflicts[chart] {
+			names = append(names, crd.Name)
+			if rel := crd.Annotations[helmReleaseNameAnnotation]; rel != "" {
+				owner = fmt.Sprintf("%s/%s", crd.Annotations[helmReleaseNamespaceAnnotation], rel)
+			}
+		}
+		sort.Strings(names)
+		if owner != "" {
+			results = append(results, Result{
+				Status:      Fail,
+				Message:     fmt.Sprintf("CRDs of %s belong to the Helm release %s: %s", chart, owner, strings.Join(names, ", ")),
+				Remediation: fmt.Sprintf("uninstall the Helm release %s and its CRDs before installing UnDistro", owner),
+			})
+			continue
+		}
+		results = append(results, Result{
+			Status:      Warn,
+			Message:     fmt.Sprintf("CRDs of %s exist and are not managed by UnDistro: %s", chart, strings.Join(names, ", ")),
+			Remediation: fmt.Sprintf("remove the existing %s installation, UnDistro replaces its CRDs with the versions it supports", chart),
+		})
+	}
+	return results
+}
+
+// checkIngress resolves the addresses of the UnDistro ingress and connects to them.
+// They aren't served before UnDistro is installed, so unreachable addresses are warnings.
+func checkIngress(ctx context.Context, env *Env) []Result {
+	ingress := env.Config.UnDistro
+	if ingress == nil || ingress.Ingress == nil || len(ingress.Ingress.Hosts)+len(ingress.Ingress.IPAddresses) == 0 {
+		return []Result{{Status: Pass, Message: "no ingress hosts or addresses configured"}}
+	}
+	results := make([]Result, 0)
+	addrs := append([]string{}, ingress.Ingress.IPAddresses...)
+	for _, host := range ingress.Ingress.Hosts {
+		_, err := env.LookupHost(ctx, host)
+		if err != nil {
+			results = append(results, Result{
+				Status:      Fail,
+				Message:     fmt.Sprintf("unable to resolve %s: %v", host, err),
+				Remediation: fmt.Sprintf("create a DNS record of %s pointing to the ingress-nginx load balancer", host),
+			})
+			continue
+		}
+		addrs = append(addrs, host)
+	}
+	for _, addr := range addrs {
+		dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+		conn, err := env.Dial(dialCtx, "tcp", net.JoinHostPort(addr, "443"))
+		cancel()
+		if err != nil {
+			results = append(results, Result{
+				Status:      Warn,
+				Message:     fmt.Sprintf("%s is not reachable on port 443: %v", addr, err),
+				Remediation: "it is expected before UnDistro is installed, otherwise check the ingress-nginx service and the firewall rules",
+			})
+			continue
+		}
+		conn.Close()
+		results = append(results, Result{Status: Pass, Message: fmt.Sprintf("%s is reachable on port 443", addr)})
+	}
+	return results
+}
+
+func checkProviderSecrets(ctx context.Context, env *Env) []Result {
+	results := make([]Result, 0)
+	for _, p := range env.providers() {
+		name, ok := providerSecrets[p]
+		if !ok {
+			continue
+		}
+		s, err := env.secret(ctx, p)
+		if err != nil {
+			results = append(results, Result{Status: Fail, Message: fmt.Sprintf("unable to get secret %s: %v", name, err)})
+			continue
+		}
+		switch {
+		case s != nil:
+			results = append(results, Result{Status: Pass, Message: fmt.Sprintf("secret %s of %s exists", name, p)})
+		case env.Scope&Management != 0 && env.configCredentials(p):
+			results = append(results, Result{Status: Pass, Message: fmt.Sprintf("secret %s of %s is created from the configuration on install", name, p)})
+		default:
+			results = append(results, Result{
+				Status:      Fail,
+				Message:     fmt.Sprintf("secret %s of %s doesn't exist in %s", name, p, undistro.Namespace),
+				Remediation: fmt.Sprintf("set the credentials in the undistro-%s section of the configuration file and run undistro install", p),
+			})
+		}
+	}
+	return results
+}
+
+// checkAWSAccount fails when the AWS credentials belong to the root user,
+// clusters must be created by an IAM user.
+func checkAWSAccount(ctx context.Context, env *Env) []Result {
+	found := false
+	for _, p := range env.providers() {
+		found = found || p == appv1alpha1.Amazon.String()
+	}
+	if !found {
+		return nil
+	}
+	a, err := env.Account(ctx, appv1alpha1.Amazon.String())
+	if err != nil {
+		return []Result{{
+			Status:      Warn,
+			Message:     fmt.Sprintf("unable to get the AWS account: %v", err),
+			Remediation: "check the AWS credentials and the connection to AWS",
+		}}
+	}
+	if a.IsRoot() {
+		return []Result{{
+			Status:      Fail,
+			Message:     fmt.Sprintf("AWS credentials of account %s belong to the root user", a.GetID()),
+			Remediation: "create an IAM user with the policies required by UnDistro and use its access keys",
+		}}
+	}
+	return []Result{{Status: Pass, Message: fmt.Sprintf("AWS account %s uses %s", a.GetID(), a.GetUsername())}}
+}
@@ -0,0 +1,284 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package preflight checks a management cluster before UnDistro is installed
+// and the workload clusters before they are created.
+package preflight
+
+import (
+	"context"
+	"net"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/cloud"
+	"github.com/getupio-undistro/undistro/pkg/cloud/aws"
+	"github.com/getupio-undistro/undistro/pkg/compatibility"
+	"github.com/getupio-undistro/undistro/pkg/config"
+	"github.com/getupio-undistro/undistro/pkg/undistro"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Scope is the set of clusters a check applies to.
+type Scope int
+
+const (
+	// Management checks the management cluster UnDistro is installed in.
+	Management Scope = 1 << iota
+	// Workload checks the workload clusters to be created.
+	Workload
+)
+
+// Result is the outcome of a check with a hint to fix it.
+type Result struct {
+	Check       string `json:"check"`
+	Status      Status `json:"status"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// Check is a named check, it returns a result for each thing it inspects.
+type Check struct {
+	Name  string
+	Scope Scope
+	Run   func(ctx context.Context, env *Env) []Result
+}
+
+// Env is what the checks inspect.
+type Env struct {
+	// Scope is the scope of the checks being run.
+	Scope Scope
+	// Client of the management cluster.
+	Client client.Client
+	// KubernetesVersion of the management cluster.
+	KubernetesVersion string
+	Matrix            compatibility.Matrix
+	Config            *config.Config
+	// Clusters are the workload clusters to be created.
+	// They are checked against the clusters of the management cluster.
+	Clusters []appv1alpha1.Cluster
+
+	// Account returns the account of an infrastructure provider.
+	Account func(ctx context.Context, provider string) (cloud.Account, error)
+	// Quotas returns the AWS service quotas of a region.
+	Quotas func(ctx context.Context, region string) (aws.Resources, error)
+	// LookupHost and Dial reach the ingress addresses.
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+	Dial       func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Report is the outcome of all checks.
+type Report struct {
+	Passed   int      `json:"passed"`
+	Warnings int      `json:"warnings"`
+	Failures int      `json:"failures"`
+	Results  []Result `json:"results"`
+}
+
+// Checks returns the checks shipped with UnDistro.
+func Checks() []Check {
+	return []Check{
+		{Name: "kubernetes-version", Scope: Management, Run: checkKubernetesVersion},
+		{Name: "crd-conflicts", Scope: Management, Run: checkCRDConflicts},
+		{Name: "ingress", Scope: Management, Run: checkIngress},
+		{Name: "provider-secrets", Scope: Management | Workload, Run: checkProviderSecrets},
+		{Name: "aws-account", Scope: Management | Workload, Run: checkAWSAccount},
+		{Name: "cidr-overlap", Scope: Management | Workload, Run: checkCIDROverlap},
+		{Name: "aws-quotas", Scope: Workload, Run: checkAWSQuotas},
+	}
+}
+
+// Run runs the checks of the env scope.
+func Run(ctx context.Context, env *Env, checks ...Check) Report {
+	env.defaults()
+	report := Report{
+		Results: make([]Result, 0),
+	}
+	for _, c := range checks {
+		if c.Scope&env.Scope == 0 {
+			continue
+		}
+		for _, r := range c.Run(ctx, env) {
+			if r.Check == "" {
+				r.Check = c.Name
+			}
+			switch r.Status {
+			case Pass:
+				report.Passed++
+			case Warn:
+				report.Warnings++
+			default:
+				r.Status = Fail
+				report.Failures++
+			}
+			report.Results = append(report.Results, r)
+		}
+	}
+	return report
+}
+
+func (env *Env) defaults() {
+	if env.Scope == 0 {
+		env.Scope = Management
+	}
+	if env.Config == nil {
+		env.Config = &config.Config{}
+	}
+	if env.Account == nil {
+		env.Account = env.account
+	}
+	if env.Quotas == nil {
+		env.Quotas = env.quotas
+	}
+	if env.LookupHost == nil {
+		env.LookupHost = net.DefaultResolver.LookupHost
+	}
+	if env.Dial == nil {
+		d := net.Dialer{}
+		env.Dial = d.DialContext
+	}
+}
+
+// providerSecrets are the secrets holding the credentials of the infrastructure providers.
+var providerSecrets = map[string]string{
+	appv1alpha1.Amazon.String():    "undistro-aws-config",
+	appv1alpha1.OpenStack.String(): "undistro-openstack-config",
+}
+
+// providers returns the infrastructure providers of the scope, the ones enabled in
+// the configuration for the management cluster and the ones of the workload clusters.
+func (env *Env) providers() []string {
+	providers := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		providers = append(providers, name)
+	}
+	if env.Scope&Management != 0 {
+		for _, name := range []string{appv1alpha1.Amazon.String(), appv1alpha1.OpenStack.String()} {
+			if env.Config.Enabled("undistro-" + name) {
+				add(name)
+			}
+		}
+	}
+	if env.Scope&Workload != 0 {
+		for _, cl := range env.Clusters {
+			add(cl.Spec.InfrastructureProvider.Name)
+		}
+	}
+	return providers
+}
+
+// configCredentials returns true when the configuration has the credentials of the provider,
+// its secret is created from them on install.
+func (env *Env) configCredentials(provider string) bool {
+	switch provider {
+	case appv1alpha1.Amazon.String():
+		return env.Config.AWS != nil && env.Config.AWS.Credentials != nil && env.Config.AWS.Credentials.AccessKeyID != ""
+	case appv1alpha1.OpenStack.String():
+		return env.Config.OpenStack != nil && env.Config.OpenStack.Credentials != nil
+	}
+	return false
+}
+
+func (env *Env) secret(ctx context.Context, provider string) (*corev1.Secret, error) {
+	s := corev1.Secret{}
+	key := client.ObjectKey{Namespace: undistro.Namespace, Name: providerSecrets[provider]}
+	err := env.Client.Get(ctx, key, &s)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (env *Env) account(ctx context.Context, provider string) (cloud.Account, error) {
+	if provider != appv1alpha1.Amazon.String() {
+		return nil, errors.Errorf("provider %s has no account", provider)
+	}
+	s, err := env.secret(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil && env.configCredentials(provider) {
+		creds := env.Config.AWS.Credentials
+		return aws.NewAccountFromCredentials(aws.AwsCredentials{
+			AccessKeyID:     creds.AccessKeyID,
+			SecretAccessKey: creds.SecretAccessKey,
+			SessionToken:    creds.SessionToken,
+			Region:          creds.Region,
+		})
+	}
+	return aws.NewAccount(ctx, env.Client)
+}
+
+func (env *Env) quotas(ctx context.Context, region string) (aws.Resources, error) {
+	a, err := env.Account(ctx, appv1alpha1.Amazon.String())
+	if err != nil {
+		return aws.Resources{}, err
+	}
+	q, ok := a.(interface {
+		Quotas(region string) (aws.Resources, error)
+	})
+	if !ok {
+		return aws.Resources{}, errors.New("account has no service quotas")
+	}
+	return q.Quotas(region)
+}
+
+// clusters returns the clusters of the management cluster and the workload clusters
+// to be created, new tells the ones to be created apart.
+func (env *Env) clusters(ctx context.Context) (clusters []appv1alpha1.Cluster, isNew []bool, err error) {
+	list := appv1alpha1.ClusterList{}
+	err = env.Client.List(ctx, &list)
+	// UnDistro CRDs don't exist before it is installed
+	if err != nil && !apimeta.IsNoMatchError(err) {
+		return nil, nil, err
+	}
+	created := make(map[string]bool)
+	for _, cl := range env.Clusters {
+		created[client.ObjectKeyFromObject(&cl).String()] = true
+	}
+	for _, cl := range list.Items {
+		// a cluster being created again is checked as a new one
+		if created[client.ObjectKeyFromObject(&cl).String()] {
+			continue
+		}
+		clusters = append(clusters, cl)
+		isNew = append(isNew, false)
+	}
+	for _, cl := range env.Clusters {
+		clusters = append(clusters, cl)
+		isNew = append(isNew, true)
+	}
+	return clusters, isNew, nil
+}
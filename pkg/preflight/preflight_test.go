@@ -0,0 +1,269 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package preflight
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	"github.com/getupio-undistro/undistro/pkg/cloud"
+	"github.com/getupio-undistro/undistro/pkg/cloud/aws"
+	"github.com/getupio-undistro/undistro/pkg/compatibility"
+	"github.com/getupio-undistro/undistro/pkg/config"
+	"github.com/getupio-undistro/undistro/pkg/scheme"
+	"github.com/getupio-undistro/undistro/pkg/undistro"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeAccount struct {
+	username string
+}
+
+func (a fakeAccount) GetID() string       { return "123456789012" }
+func (a fakeAccount) GetUsername() string { return a.username }
+func (a fakeAccount) IsRoot() bool        { return a.username == "arn:aws:iam::123456789012:root" }
+
+func crd(group, name string, annotations map[string]string) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+		Spec:       apiextensionsv1.CustomResourceDefinitionSpec{Group: group},
+	}
+}
+
+func awsCluster(name, vpc, pods string) appv1alpha1.Cluster {
+	three := int32(3)
+	cl := appv1alpha1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: appv1alpha1.ClusterSpec{
+			InfrastructureProvider: appv1alpha1.InfrastructureProvider{Name: "aws", Flavor: "eks", Region: "us-east-1"},
+			Workers: []appv1alpha1.WorkerNode{
+				{Node: appv1alpha1.Node{Replicas: &three, MachineType: "m5.large"}},
+			},
+		},
+	}
+	cl.Spec.Network.VPC.CIDRBlock = vpc
+	if pods != "" {
+		cl.Spec.Network.Pods = &capi.NetworkRanges{CIDRBlocks: []string{pods}}
+	}
+	return cl
+}
+
+func TestChecks(t *testing.T) {
+	matrix := compatibility.Matrix{
+		Releases: []compatibility.Release{
+			{Version: "0.37.0", Kubernetes: ">= 1.20.0", Charts: map[string]string{"undistro": "0.37.0"}},
+		},
+	}
+	undistroHR := &appv1alpha1.HelmRelease{
+		ObjectMeta: metav1.ObjectMeta{Name: "undistro", Namespace: undistro.Namespace},
+		Spec: appv1alpha1.HelmReleaseSpec{
+			Chart: appv1alpha1.ChartSource{RepoChartSource: appv1alpha1.RepoChartSource{Name: "undistro", Version: "0.37.0"}},
+		},
+	}
+	awsSecret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "undistro-aws-config", Namespace: undistro.Namespace}}
+	awsEnabled := &config.Config{AWS: &config.AWS{Enabled: true}}
+	existing := awsCluster("existing", "10.0.0.0/16", "")
+	tests := []struct {
+		name  string
+		check func(context.Context, *Env) []Result
+		env   Env
+		objs  []client.Object
+		want  []Status
+	}{
+		{
+			name:  "supported kubernetes version",
+			check: checkKubernetesVersion,
+			env:   Env{Matrix: matrix, KubernetesVersion: "v1.21.2-eks-0389ca3"},
+			objs:  []client.Object{undistroHR},
+			want:  []Status{Pass},
+		},
+		{
+			name:  "unsupported kubernetes version",
+			check: checkKubernetesVersion,
+			env:   Env{Matrix: matrix, KubernetesVersion: "v1.19.1"},
+			want:  []Status{Fail},
+		},
+		{
+			name:  "crds of other tools",
+			check: checkCRDConflicts,
+			env:   Env{},
+			objs: []client.Object{
+				undistroHR,
+				crd("cert-manager.io", "certificates.cert-manager.io", map[string]string{
+					helmReleaseNameAnnotation:      "cert-manager",
+					helmReleaseNamespaceAnnotation: "cert-manager",
+				}),
+				crd("cluster.x-k8s.io", "clusters.cluster.x-k8s.io", nil),
+				crd("infrastructure.cluster.x-k8s.io", "awsclusters.infrastructure.cluster.x-k8s.io", nil),
+				crd("app.undistro.io", "clusters.app.undistro.io", nil),
+				crd("example.com", "widgets.example.com", nil),
+			},
+			want: []Status{Fail, Warn},
+		},
+		{
+			name:  "ingress",
+			check: checkIngress,
+			env: Env{
+				Config: &config.Config{UnDistro: &config.UnDistro{Ingress: &config.Ingress{
+					Hosts:       []string{"undistro.example.com", "missing.example.com"},
+					IPAddresses: []string{"10.1.1.1"},
+				}}},
+				LookupHost: func(_ context.Context, host string) ([]string, error) {
+					if host == "missing.example.com" {
+						return nil, errors.New("no such host")
+					}
+					return []string{"10.1.1.2"}, nil
+				},
+				Dial: func(_ context.Context, _, address string) (net.Conn, error) {
+					if address == "10.1.1.1:443" {
+						return nil, errors.New("connection refused")
+					}
+					c, _ := net.Pipe()
+					return c, nil
+				},
+			},
+			want: []Status{Fail, Warn, Pass},
+		},
+		{
+			name:  "provider secret from the configuration",
+			check: checkProviderSecrets,
+			env: Env{Config: &config.Config{AWS: &config.AWS{
+				Enabled:     true,
+				Credentials: &config.AWSCredentials{AccessKeyID: "id", SecretAccessKey: "secret"},
+			}}},
+			want: []Status{Pass},
+		},
+		{
+			name:  "missing provider secret of a workload cluster",
+			check: checkProviderSecrets,
+			env:   Env{Scope: Workload, Config: awsEnabled, Clusters: []appv1alpha1.Cluster{awsCluster("new", "", "")}},
+			want:  []Status{Fail},
+		},
+		{
+			name:  "root account",
+			check: checkAWSAccount,
+			env: Env{Config: awsEnabled, Account: func(context.Context, string) (cloud.Account, error) {
+				return fakeAccount{username: "arn:aws:iam::123456789012:root"}, nil
+			}},
+			objs: []client.Object{awsSecret},
+			want: []Status{Fail},
+		},
+		{
+			name:  "iam account",
+			check: checkAWSAccount,
+			env: Env{Config: awsEnabled, Account: func(context.Context, string) (cloud.Account, error) {
+				return fakeAccount{username: "arn:aws:iam::123456789012:user/undistro"}, nil
+			}},
+			objs: []client.Object{awsSecret},
+			want: []Status{Pass},
+		},
+		{
+			name:  "overlapping networks",
+			check: checkCIDROverlap,
+			env: Env{Scope: Workload, Clusters: []appv1alpha1.Cluster{
+				awsCluster("peer", "10.0.128.0/17", ""),
+				awsCluster("pods", "10.10.0.0/16", "10.10.0.0/20"),
+			}},
+			objs: []client.Object{&existing},
+			want: []Status{Fail, Warn},
+		},
+		{
+			name:  "distinct networks",
+			check: checkCIDROverlap,
+			env:   Env{Scope: Workload, Clusters: []appv1alpha1.Cluster{awsCluster("new", "10.1.0.0/16", "192.168.0.0/16")}},
+			objs:  []client.Object{&existing},
+			want:  []Status{Pass},
+		},
+		{
+			name:  "quotas exceeded",
+			check: checkAWSQuotas,
+			env: Env{
+				Scope:    Workload,
+				Clusters: []appv1alpha1.Cluster{awsCluster("new", "", "")},
+				Quotas: func(context.Context, string) (aws.Resources, error) {
+					return aws.Resources{VCPUs: 8, VPCs: 5, ElasticIPs: 5}, nil
+				},
+			},
+			objs: []client.Object{&existing},
+			want: []Status{Warn},
+		},
+		{
+			name:  "quotas unknown",
+			check: checkAWSQuotas,
+			env: Env{
+				Scope:    Workload,
+				Clusters: []appv1alpha1.Cluster{awsCluster("new", "", "")},
+				Quotas: func(context.Context, string) (aws.Resources, error) {
+					return aws.Resources{}, errors.New("access denied")
+				},
+			},
+			want: []Status{Warn},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.env.Client = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.objs...).Build()
+			tt.env.defaults()
+			got := make([]Status, 0)
+			for _, r := range tt.check(context.Background(), &tt.env) {
+				got = append(got, r.Status)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("results = %v, want %v", tt.check(context.Background(), &tt.env), tt.want)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	results := func(statuses ...Status) func(context.Context, *Env) []Result {
+		return func(context.Context, *Env) []Result {
+			rs := make([]Result, 0)
+			for _, s := range statuses {
+				rs = append(rs, Result{Status: s})
+			}
+			return rs
+		}
+	}
+	checks := []Check{
+		{Name: "management", Scope: Management, Run: results(Pass, Warn)},
+		{Name: "both", Scope: Management | Workload, Run: results(Fail)},
+		{Name: "workload", Scope: Workload, Run: results(Pass, "unknown")},
+	}
+	env := Env{Scope: Workload, Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()}
+	got := Run(context.Background(), &env, checks...)
+	want := Report{
+		Passed:   1,
+		Failures: 2,
+		Results: []Result{
+			{Check: "both", Status: Fail},
+			{Check: "workload", Status: Pass},
+			{Check: "workload", Status: Fail},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Run() = %+v, want %+v", got, want)
+	}
+}
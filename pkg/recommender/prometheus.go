@@ -0,0 +1,181 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package recommender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	prometheusNamespace = "monitoring"
+	prometheusService   = "kube-prometheus-stack-prometheus"
+	prometheusPort      = 9090
+
+	victoriaMetricsService = "vmsingle-victoria-metrics-k8s-stack"
+	victoriaMetricsPort    = 8429
+)
+
+const (
+	cpuRequestsQuery       = `sum by (node) (kube_pod_container_resource_requests{resource="cpu"} * on (namespace, pod) group_left() max by (namespace, pod) (kube_pod_status_phase{phase=~"Pending|Running"} == 1))`
+	memoryRequestsQuery    = `sum by (node) (kube_pod_container_resource_requests{resource="memory"} * on (namespace, pod) group_left() max by (namespace, pod) (kube_pod_status_phase{phase=~"Pending|Running"} == 1))`
+	cpuAllocatableQuery    = `sum by (node) (kube_node_status_allocatable{resource="cpu"})`
+	memoryAllocatableQuery = `sum by (node) (kube_node_status_allocatable{resource="memory"})`
+)
+
+// Prometheus is a client of the Prometheus HTTP API.
+type Prometheus struct {
+	client  *http.Client
+	address string
+}
+
+// Sample is an element of an instant vector.
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+type queryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Value  []interface{}     `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+func NewPrometheus(client *http.Client, address string) *Prometheus {
+	return &Prometheus{
+		client:  client,
+		address: strings.TrimSuffix(address, "/"),
+	}
+}
+
+// NewClusterPrometheus returns a client to the Prometheus installed by the Observer
+// in the cluster. Requests go through the cluster API server proxy.
+func NewClusterPrometheus(cfg *rest.Config) (*Prometheus, error) {
+	return newClusterService(cfg, prometheusService, prometheusPort)
+}
+
+// NewClusterVictoriaMetrics returns a client to the Prometheus API of the VictoriaMetrics
+// installed by the Observer in the cluster. Requests go through the cluster API server proxy.
+func NewClusterVictoriaMetrics(cfg *rest.Config) (*Prometheus, error) {
+	return newClusterService(cfg, victoriaMetricsService, victoriaMetricsPort)
+}
+
+func newClusterService(cfg *rest.Config, service string, port int) (*Prometheus, error) {
+	transport, err := rest.TransportFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	address := fmt.Sprintf(
+		"%s/api/v1/namespaces/%s/services/%s:%d/proxy",
+		strings.TrimSuffix(cfg.Host, "/"),
+		prometheusNamespace,
+		service,
+		port,
+	)
+	return NewPrometheus(&http.Client{Transport: transport}, address), nil
+}
+
+// Query evaluates an instant query that results in a vector.
+func (p *Prometheus) Query(ctx context.Context, query string) ([]Sample, error) {
+	values := url.Values{}
+	values.Set("query", query)
+	addr := fmt.Sprintf("%s/api/v1/query?%s", p.address, values.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	qr := queryResponse{}
+	err = json.NewDecoder(resp.Body).Decode(&qr)
+	if err != nil {
+		return nil, errors.Errorf("unable to decode prometheus response: %s: %v", http.StatusText(resp.StatusCode), err)
+	}
+	if qr.Status != "success" {
+		return nil, errors.Errorf("prometheus query failed: %s: %s", qr.ErrorType, qr.Error)
+	}
+	if qr.Data.ResultType != "vector" {
+		return nil, errors.Errorf("unexpected prometheus result type %s", qr.Data.ResultType)
+	}
+	samples := make([]Sample, len(qr.Data.Result))
+	for i, r := range qr.Data.Result {
+		if len(r.Value) != 2 {
+			return nil, errors.Errorf("invalid prometheus sample %v", r.Value)
+		}
+		str, ok := r.Value[1].(string)
+		if !ok {
+			return nil, errors.Errorf("invalid prometheus sample value %v", r.Value[1])
+		}
+		v, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, err
+		}
+		samples[i] = Sample{
+			Labels: r.Metric,
+			Value:  v,
+		}
+	}
+	return samples, nil
+}
+
+// NodeResources returns the resources requested by the pods running in each node
+// and the node allocatable resources, indexed by node name.
+func (p *Prometheus) NodeResources(ctx context.Context) (map[string]NodeResources, error) {
+	nodes := make(map[string]NodeResources)
+	queries := []struct {
+		query string
+		set   func(*NodeResources, float64)
+	}{
+		{cpuRequestsQuery, func(n *NodeResources, v float64) { n.CPURequests = v }},
+		{memoryRequestsQuery, func(n *NodeResources, v float64) { n.MemoryRequests = v }},
+		{cpuAllocatableQuery, func(n *NodeResources, v float64) { n.CPUAllocatable = v }},
+		{memoryAllocatableQuery, func(n *NodeResources, v float64) { n.MemoryAllocatable = v }},
+	}
+	for _, q := range queries {
+		samples, err := p.Query(ctx, q.query)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range samples {
+			name := s.Labels["node"]
+			if name == "" {
+				continue
+			}
+			n := nodes[name]
+			q.set(&n, s.Value)
+			nodes[name] = n
+		}
+	}
+	return nodes, nil
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package recommender
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
+)
+
+const (
+	// pools with requests between these ratios of the allocatable resources are right sized
+	lowRequestsRatio  = 0.4
+	highRequestsRatio = 0.8
+	// requests ratio targeted by the recommendations
+	targetRequestsRatio = 0.65
+	gib                 = 1 << 30
+)
+
+var armFamily = regexp.MustCompile(`^(a1|[a-z]+[0-9]+g[a-z]*)$`)
+
+// NodeResources are the CPU (in cores) and memory (in bytes) requested by the pods
+// running in a node and the node allocatable resources.
+type NodeResources struct {
+	CPURequests       float64
+	CPUAllocatable    float64
+	MemoryRequests    float64
+	MemoryAllocatable float64
+}
+
+// Pool is a worker pool and the names of its nodes.
+type Pool struct {
+	Index       int32
+	MachineType string
+	Replicas    int32
+	Nodes       []string
+}
+
+type machine struct {
+	instanceType string
+	vcpus        float64
+	memory       float64
+	price        float64
+}
+
+// Recommend computes the right-sizing recommendation of the pool from the resources of
+// its nodes. The recommended machine type is the cheapest machine type of the catalog,
+// available in the region and with the same architecture, that fits the pool requests
+// keeping the number of replicas.
+func Recommend(pool Pool, nodes map[string]NodeResources, catalog []metadatav1alpha1.AWSMachineSpec, region string) appv1alpha1.WorkerRecommendation {
+	rec := appv1alpha1.WorkerRecommendation{
+		Pool:                   pool.Index,
+		MachineType:            pool.MachineType,
+		Replicas:               pool.Replicas,
+		RecommendedMachineType: pool.MachineType,
+		RecommendedReplicas:    pool.Replicas,
+	}
+	var total NodeResources
+	count := 0
+	for _, name := range pool.Nodes {
+		n, ok := nodes[name]
+		if !ok {
+			continue
+		}
+		total.CPURequests += n.CPURequests
+		total.CPUAllocatable += n.CPUAllocatable
+		total.MemoryRequests += n.MemoryRequests
+		total.MemoryAllocatable += n.MemoryAllocatable
+		count++
+	}
+	if count == 0 || total.CPUAllocatable == 0 || total.MemoryAllocatable == 0 {
+		rec.Reason = "No metrics found for the pool nodes"
+		return rec
+	}
+	cpuRatio := total.CPURequests / total.CPUAllocatable
+	memRatio := total.MemoryRequests / total.MemoryAllocatable
+	rec.CPURequestsRatio = formatRatio(cpuRatio)
+	rec.MemoryRequestsRatio = formatRatio(memRatio)
+	ratio := math.Max(cpuRatio, memRatio)
+	switch {
+	case ratio > highRequestsRatio:
+		rec.Reason = "Pool requests are above 80% of the allocatable resources"
+	case ratio < lowRequestsRatio:
+		rec.Reason = "Pool requests are below 40% of the allocatable resources"
+	default:
+		rec.Reason = "Pool is right sized"
+		return rec
+	}
+
+	neededCPU := total.CPURequests / targetRequestsRatio
+	neededMem := total.MemoryRequests / targetRequestsRatio
+	nodeCPU := total.CPUAllocatable / float64(count)
+	nodeMem := total.MemoryAllocatable / float64(count)
+	replicas := math.Max(math.Ceil(neededCPU/nodeCPU), math.Ceil(neededMem/nodeMem))
+	rec.RecommendedReplicas = int32(math.Max(replicas, 1))
+
+	machines := machinesInRegion(catalog, region)
+	current, ok := machines[pool.MachineType]
+	if !ok || pool.Replicas < 1 {
+		return rec
+	}
+	// fraction of the machine resources left allocatable by the system reservations
+	cpuFraction := nodeCPU / current.vcpus
+	memFraction := nodeMem / (current.memory * gib)
+	candidates := make([]machine, 0)
+	for _, m := range machines {
+		if isARM(m.instanceType) != isARM(current.instanceType) || m.price == 0 {
+			continue
+		}
+		if m.vcpus*cpuFraction*float64(pool.Replicas) < neededCPU {
+			continue
+		}
+		if m.memory*gib*memFraction*float64(pool.Replicas) < neededMem {
+			continue
+		}
+		candidates = append(candidates, m)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].price == candidates[j].price {
+			return candidates[i].instanceType < candidates[j].instanceType
+		}
+		return candidates[i].price < candidates[j].price
+	})
+	if len(candidates) > 0 {
+		rec.RecommendedMachineType = candidates[0].instanceType
+	}
+	return rec
+}
+
+// machinesInRegion returns the general purpose machines, without accelerators,
+// available in the region indexed by instance type.
+func machinesInRegion(catalog []metadatav1alpha1.AWSMachineSpec, region string) map[string]machine {
+	machines := make(map[string]machine)
+	for _, spec := range catalog {
+		if spec.AcceleratorManufacturer != "" {
+			continue
+		}
+		available := false
+		for _, zone := range spec.AvailabilityZones {
+			available = available || strings.HasPrefix(zone, region)
+		}
+		if !available {
+			continue
+		}
+		vcpus, err := strconv.ParseFloat(spec.Vcpus, 64)
+		if err != nil {
+			continue
+		}
+		memory, err := strconv.ParseFloat(spec.Memory, 64)
+		if err != nil {
+			continue
+		}
+		price, _ := strconv.ParseFloat(spec.PricePerHour, 64)
+		machines[spec.InstanceType] = machine{
+			instanceType: spec.InstanceType,
+			vcpus:        vcpus,
+			memory:       memory,
+			price:        price,
+		}
+	}
+	return machines
+}
+
+func isARM(instanceType string) bool {
+	family := strings.Split(instanceType, ".")[0]
+	return armFamily.MatchString(family)
+}
+
+func formatRatio(r float64) string {
+	return strconv.FormatFloat(r, 'f', 2, 64)
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	appv1alpha1 "github.com/getupio-undistro/undistro/apis/app/v1alpha1"
+	metadatav1alpha1 "github.com/getupio-undistro/undistro/apis/metadata/v1alpha1"
+)
+
+// fakePrometheus answers the node resources queries with the values of two nodes
+func fakePrometheus(t *testing.T) *httptest.Server {
+	results := map[string]string{
+		cpuRequestsQuery:       `[{"metric":{"node":"node-a"},"value":[1634558400,"0.5"]},{"metric":{"node":"node-b"},"value":[1634558400,"0.3"]}]`,
+		memoryRequestsQuery:    `[{"metric":{"node":"node-a"},"value":[1634558400,"1073741824"]},{"metric":{"node":"node-b"},"value":[1634558400,"536870912"]}]`,
+		cpuAllocatableQuery:    `[{"metric":{"node":"node-a"},"value":[1634558400,"1.93"]},{"metric":{"node":"node-b"},"value":[1634558400,"1.93"]}]`,
+		memoryAllocatableQuery: `[{"metric":{"node":"node-a"},"value":[1634558400,"7543554048"]},{"metric":{"node":"node-b"},"value":[1634558400,"7543554048"]}]`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			http.NotFound(w, r)
+			return
+		}
+		result, ok := results[r.URL.Query().Get("query")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"unknown query"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":%s}}`, result)
+	}))
+}
+
+func TestPrometheus_NodeResources(t *testing.T) {
+	srv := fakePrometheus(t)
+	defer srv.Close()
+	p := NewPrometheus(srv.Client(), srv.URL)
+	got, err := p.NodeResources(context.Background())
+	if err != nil {
+		t.Fatalf("NodeResources() error = %v", err)
+	}
+	want := map[string]NodeResources{
+		"node-a": {CPURequests: 0.5, CPUAllocatable: 1.93, MemoryRequests: 1073741824, MemoryAllocatable: 7543554048},
+		"node-b": {CPURequests: 0.3, CPUAllocatable: 1.93, MemoryRequests: 536870912, MemoryAllocatable: 7543554048},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NodeResources() = %v, want %v", got, want)
+	}
+	_, err = p.Query(context.Background(), "up")
+	if err == nil || !strings.Contains(err.Error(), "unknown query") {
+		t.Errorf("Query() error = %v, want unknown query", err)
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	zones := []string{"us-east-1a"}
+	catalog := []metadatav1alpha1.AWSMachineSpec{
+		{InstanceType: "t3.medium", Vcpus: "2", Memory: "4", PricePerHour: "0.0416", AvailabilityZones: zones},
+		{InstanceType: "t3.large", Vcpus: "2", Memory: "8", PricePerHour: "0.0832", AvailabilityZones: zones},
+		{InstanceType: "m5.large", Vcpus: "2", Memory: "8", PricePerHour: "0.096", AvailabilityZones: zones},
+		{InstanceType: "m5.xlarge", Vcpus: "4", Memory: "16", PricePerHour: "0.192", AvailabilityZones: zones},
+		{InstanceType: "m6g.medium", Vcpus: "1", Memory: "4", PricePerHour: "0.0385", AvailabilityZones: zones},
+		{InstanceType: "g4dn.xlarge", Vcpus: "4", Memory: "16", PricePerHour: "0.526", AvailabilityZones: zones, AcceleratorManufacturer: "nvidia"},
+		{InstanceType: "t3.small", Vcpus: "2", Memory: "2", PricePerHour: "0.0208", AvailabilityZones: []string{"sa-east-1a"}},
+	}
+	srv := fakePrometheus(t)
+	defer srv.Close()
+	nodes, err := NewPrometheus(srv.Client(), srv.URL).NodeResources(context.Background())
+	if err != nil {
+		t.Fatalf("NodeResources() error = %v", err)
+	}
+	tests := []struct {
+		name  string
+		pool  Pool
+		nodes map[string]NodeResources
+		want  appv1alpha1.WorkerRecommendation
+	}{
+		{
+			name: "oversized pool",
+			pool: Pool{Index: 0, MachineType: "m5.large", Replicas: 2, Nodes: []string{"node-a", "node-b"}},
+			want: appv1alpha1.WorkerRecommendation{
+				Pool:                   0,
+				MachineType:            "m5.large",
+				Replicas:               2,
+				CPURequestsRatio:       "0.21",
+				MemoryRequestsRatio:    "0.11",
+				RecommendedMachineType: "t3.medium",
+				RecommendedReplicas:    1,
+				Reason:                 "Pool requests are below 40% of the allocatable resources",
+			},
+		},
+		{
+			name: "undersized pool",
+			pool: Pool{Index: 1, MachineType: "m5.large", Replicas: 1, Nodes: []string{"node-a"}},
+			nodes: map[string]NodeResources{
+				"node-a": {CPURequests: 1.8, CPUAllocatable: 1.93, MemoryRequests: 2 * gib, MemoryAllocatable: 7 * gib},
+			},
+			want: appv1alpha1.WorkerRecommendation{
+				Pool:                   1,
+				MachineType:            "m5.large",
+				Replicas:               1,
+				CPURequestsRatio:       "0.93",
+				MemoryRequestsRatio:    "0.29",
+				RecommendedMachineType: "m5.xlarge",
+				RecommendedReplicas:    2,
+				Reason:                 "Pool requests are above 80% of the allocatable resources",
+			},
+		},
+		{
+			name: "right sized pool",
+			pool: Pool{Index: 0, MachineType: "t3.large", Replicas: 1, Nodes: []string{"node-a"}},
+			nodes: map[string]NodeResources{
+				"node-a": {CPURequests: 1.2, CPUAllocatable: 1.93, MemoryRequests: 2 * gib, MemoryAllocatable: 7 * gib},
+			},
+			want: appv1alpha1.WorkerRecommendation{
+				Pool:                   0,
+				MachineType:            "t3.large",
+				Replicas:               1,
+				CPURequestsRatio:       "0.62",
+				MemoryRequestsRatio:    "0.29",
+				RecommendedMachineType: "t3.large",
+				RecommendedReplicas:    1,
+				Reason:                 "Pool is right sized",
+			},
+		},
+		{
+			name: "pool without metrics",
+			pool: Pool{Index: 2, MachineType: "t3.large", Replicas: 3, Nodes: []string{"node-c"}},
+			want: appv1alpha1.WorkerRecommendation{
+				Pool:                   2,
+				MachineType:            "t3.large",
+				Replicas:               3,
+				RecommendedMachineType: "t3.large",
+				RecommendedReplicas:    3,
+				Reason:                 "No metrics found for the pool nodes",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := nodes
+			if tt.nodes != nil {
+				n = tt.nodes
+			}
+			if got := Recommend(tt.pool, n, catalog, "us-east-1"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Recommend() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// Media types of the artifacts UnDistro stores in registries.
+// Charts use the same media types as helm, so they can be pulled by helm too.
+const (
+	ChartConfigMediaType = "application/vnd.cncf.helm.config.v1+json"
+	ChartLayerMediaType  = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	FileConfigMediaType  = "application/vnd.undistro.file.config.v1+json"
+	FileLayerMediaType   = "application/vnd.undistro.file.v1"
+)
+
+// PushArtifact stores content as the single layer of an OCI artifact tagged as ref.
+func (c *Client) PushArtifact(ctx context.Context, ref Reference, configMediaType string, config []byte, layerMediaType string, content []byte) error {
+	m := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Config: ocispec.Descriptor{
+			MediaType: configMediaType,
+			Digest:    digest.FromBytes(config),
+			Size:      int64(len(config)),
+		},
+		Layers: []ocispec.Descriptor{
+			{
+				MediaType: layerMediaType,
+				Digest:    digest.FromBytes(content),
+				Size:      int64(len(content)),
+			},
+		},
+	}
+	err := c.PushBlob(ctx, ref, m.Config, bytes.NewReader(config))
+	if err != nil {
+		return err
+	}
+	err = c.PushBlob(ctx, ref, m.Layers[0], bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	byt, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.PushManifest(ctx, ref, ocispec.MediaTypeImageManifest, byt)
+}
+
+// PullArtifact returns the content of the first layer of the artifact ref points to.
+func (c *Client) PullArtifact(ctx context.Context, ref Reference) ([]byte, error) {
+	_, byt, err := c.Manifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	m := Manifest{}
+	err = json.Unmarshal(byt, &m)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Layers) == 0 {
+		return nil, errors.Errorf("%s has no layers", ref)
+	}
+	rc, err := c.Blob(ctx, ref, m.Layers[0].Digest)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	if digest.FromBytes(content) != m.Layers[0].Digest {
+		return nil, errors.Errorf("content of %s does not match its digest", ref)
+	}
+	return content, nil
+}
@@ -0,0 +1,348 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const (
+	DockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	DockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// ErrNotFound is returned when a manifest or blob does not exist.
+var ErrNotFound = errors.New("not found")
+
+var manifestMediaTypes = []string{
+	ocispec.MediaTypeImageManifest,
+	ocispec.MediaTypeImageIndex,
+	DockerManifest,
+	DockerManifestList,
+}
+
+// Manifest holds the fields shared by image manifests and indexes,
+// both in the OCI and in the docker formats.
+type Manifest struct {
+	MediaType string               `json:"mediaType,omitempty"`
+	Config    *ocispec.Descriptor  `json:"config,omitempty"`
+	Layers    []ocispec.Descriptor `json:"layers,omitempty"`
+	Manifests []ocispec.Descriptor `json:"manifests,omitempty"`
+}
+
+// IsIndex reports if the media type is an index of manifests.
+func IsIndex(mediaType string) bool {
+	return mediaType == ocispec.MediaTypeImageIndex || mediaType == DockerManifestList
+}
+
+// Client talks to registries implementing the distribution API v2.
+// Registries answering plain HTTP are used over HTTP, which is what local
+// registries of disconnected sites usually do.
+type Client struct {
+	HTTPClient *http.Client
+	// Username and Password are sent to every registry asking for credentials.
+	Username string
+	Password string
+
+	mu        sync.Mutex
+	tokens    map[string]string
+	plainHTTP map[string]bool
+}
+
+// NewClient returns an anonymous client.
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: http.DefaultClient,
+		tokens:     make(map[string]string),
+		plainHTTP:  make(map[string]bool),
+	}
+}
+
+// Manifest returns the descriptor and the content of the manifest ref points to.
+func (c *Client) Manifest(ctx context.Context, ref Reference) (ocispec.Descriptor, []byte, error) {
+	h := http.Header{}
+	h.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	resp, err := c.do(ctx, http.MethodGet, ref, fmt.Sprintf("/v2/%s/manifests/%s", ref.Repository, ref.identifier()), h, nil)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ocispec.Descriptor{}, nil, err
+	}
+	desc := ocispec.Descriptor{
+		MediaType: resp.Header.Get("Content-Type"),
+		Digest:    digest.FromBytes(body),
+		Size:      int64(len(body)),
+	}
+	if ref.Digest != "" && ref.Digest != desc.Digest {
+		return ocispec.Descriptor{}, nil, errors.Errorf("manifest of %s has digest %s", ref, desc.Digest)
+	}
+	if desc.MediaType == "" || desc.MediaType == "application/json" || desc.MediaType == "text/plain" {
+		m := Manifest{}
+		err = json.Unmarshal(body, &m)
+		if err != nil {
+			return ocispec.Descriptor{}, nil, err
+		}
+		desc.MediaType = m.MediaType
+	}
+	return desc, body, nil
+}
+
+// PushManifest uploads a manifest tagged as ref.
+func (c *Client) PushManifest(ctx context.Context, ref Reference, mediaType string, body []byte) error {
+	h := http.Header{}
+	h.Set("Content-Type", mediaType)
+	resp, err := c.do(ctx, http.MethodPut, ref, fmt.Sprintf("/v2/%s/manifests/%s", ref.Repository, ref.identifier()), h, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Blob returns the content of the blob with the given digest in ref's repository.
+func (c *Client) Blob(ctx context.Context, ref Reference, dgst digest.Digest) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, ref, fmt.Sprintf("/v2/%s/blobs/%s", ref.Repository, dgst), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// HasBlob reports if ref's repository already has the blob.
+func (c *Client) HasBlob(ctx context.Context, ref Reference, dgst digest.Digest) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, ref, fmt.Sprintf("/v2/%s/blobs/%s", ref.Repository, dgst), nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, resp.Body.Close()
+}
+
+// PushBlob uploads the blob described by desc in a single request,
+// blobs already in the repository are skipped.
+func (c *Client) PushBlob(ctx context.Context, ref Reference, desc ocispec.Descriptor, r io.ReadSeeker) error {
+	ok, err := c.HasBlob(ctx, ref, desc.Digest)
+	if err != nil || ok {
+		return err
+	}
+	resp, err := c.do(ctx, http.MethodPost, ref, fmt.Sprintf("/v2/%s/blobs/uploads/", ref.Repository), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	loc, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return err
+	}
+	q := loc.Query()
+	q.Set("digest", desc.Digest.String())
+	loc.RawQuery = q.Encode()
+	h := http.Header{}
+	h.Set("Content-Type", "application/octet-stream")
+	resp, err = c.do(ctx, http.MethodPut, ref, loc.String(), h, r)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// do sends a request to the registry of ref, target is either a path or
+// an absolute URL returned by the registry. Bearer tokens are requested
+// when the registry challenges the client and cached per repository.
+func (c *Client) do(ctx context.Context, method string, ref Reference, target string, h http.Header, body io.ReadSeeker) (*http.Response, error) {
+	resp, err := c.send(ctx, method, ref, target, h, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		err = c.authorize(ctx, ref, challenge)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.send(ctx, method, ref, target, h, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.Wrapf(ErrNotFound, "%s %s", method, ref.Name())
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, errors.Errorf("%s %s: unexpected status %s: %s", method, ref.Name(), resp.Status, strings.TrimSpace(string(msg)))
+}
+
+func (c *Client) send(ctx context.Context, method string, ref Reference, target string, h http.Header, body io.ReadSeeker) (*http.Response, error) {
+	resp, err := c.sendOnce(ctx, method, ref, target, h, body)
+	if err != nil && strings.Contains(err.Error(), "server gave HTTP response to HTTPS client") {
+		c.mu.Lock()
+		c.plainHTTP[ref.Registry] = true
+		c.mu.Unlock()
+		resp, err = c.sendOnce(ctx, method, ref, target, h, body)
+	}
+	return resp, err
+}
+
+func (c *Client) sendOnce(ctx context.Context, method string, ref Reference, target string, h http.Header, body io.ReadSeeker) (*http.Response, error) {
+	u := target
+	if strings.HasPrefix(target, "/") {
+		u = c.baseURL(ref.Registry) + target
+	}
+	var r io.Reader
+	if body != nil {
+		_, err := body.Seek(0, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+		r = body
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h {
+		req.Header[k] = v
+	}
+	c.mu.Lock()
+	token := c.tokens[ref.Name()]
+	c.mu.Unlock()
+	switch {
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	return c.HTTPClient.Do(req)
+}
+
+func (c *Client) baseURL(registry string) string {
+	host := registry
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.plainHTTP[registry] {
+		return "http://" + host
+	}
+	return "https://" + host
+}
+
+// authorize gets a bearer token answering the challenge of the registry.
+func (c *Client) authorize(ctx context.Context, ref Reference, challenge string) error {
+	scheme, params := parseChallenge(challenge)
+	if !strings.EqualFold(scheme, "bearer") {
+		return errors.Errorf("%s: unauthorized", ref.Name())
+	}
+	u, err := url.Parse(params["realm"])
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		q.Set("scope", params["scope"])
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s: token request failed with status %s", ref.Name(), resp.Status)
+	}
+	t := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&t)
+	if err != nil {
+		return err
+	}
+	if t.Token == "" {
+		t.Token = t.AccessToken
+	}
+	c.mu.Lock()
+	c.tokens[ref.Name()] = t.Token
+	c.mu.Unlock()
+	return nil
+}
+
+// parseChallenge parses a WWW-Authenticate header such as
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io".
+func parseChallenge(h string) (string, map[string]string) {
+	params := make(map[string]string)
+	h = strings.TrimSpace(h)
+	i := strings.Index(h, " ")
+	if i < 0 {
+		return h, params
+	}
+	scheme, rest := h[:i], h[i+1:]
+	for {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		k, v := strings.ToLower(strings.TrimSpace(rest[:eq])), rest[eq+1:]
+		if strings.HasPrefix(v, `"`) {
+			end := strings.Index(v[1:], `"`)
+			if end < 0 {
+				break
+			}
+			params[k], rest = v[1:end+1], v[end+2:]
+			continue
+		}
+		end := strings.Index(v, ",")
+		if end < 0 {
+			params[k] = v
+			break
+		}
+		params[k], rest = v[:end], v[end+1:]
+	}
+	return scheme, params
+}
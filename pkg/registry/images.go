@@ -0,0 +1,59 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package registry
+
+import (
+	"regexp"
+	"sort"
+)
+
+var imageLine = regexp.MustCompile(`(?m)^(\s*(?:-\s+)?image:\s*)(["']?)([^"'\s#]+)(["']?)`)
+
+// FindImages returns the sorted and unique images referenced
+// by the image fields of the given YAML manifests.
+func FindImages(manifests []byte) []string {
+	seen := make(map[string]bool)
+	images := make([]string, 0)
+	for _, m := range imageLine.FindAllSubmatch(manifests, -1) {
+		ref, err := ParseReference(string(m[3]))
+		if err != nil {
+			continue
+		}
+		s := ref.String()
+		if !seen[s] {
+			seen[s] = true
+			images = append(images, s)
+		}
+	}
+	sort.Strings(images)
+	return images
+}
+
+// RelocateImages rewrites the image fields of the given YAML manifests
+// to pull from registry, see Relocate.
+func RelocateImages(manifests []byte, registry string) []byte {
+	return imageLine.ReplaceAllFunc(manifests, func(line []byte) []byte {
+		m := imageLine.FindSubmatch(line)
+		image, err := Relocate(string(m[3]), registry)
+		if err != nil {
+			return line
+		}
+		res := append([]byte{}, m[1]...)
+		res = append(res, m[2]...)
+		res = append(res, image...)
+		return append(res, m[4]...)
+	})
+}
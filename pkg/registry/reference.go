@@ -0,0 +1,99 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package registry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// Reference identifies a manifest in a registry.
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     digest.Digest
+}
+
+// ParseReference parses an image reference using the same normalization as
+// docker, so nginx:1.21 is read as docker.io/library/nginx:1.21.
+func ParseReference(s string) (Reference, error) {
+	named, err := reference.ParseNormalizedNamed(s)
+	if err != nil {
+		return Reference{}, errors.Wrapf(err, "invalid reference %q", s)
+	}
+	ref := Reference{
+		Registry:   reference.Domain(named),
+		Repository: reference.Path(named),
+	}
+	if t, ok := named.(reference.Tagged); ok {
+		ref.Tag = t.Tag()
+	}
+	if d, ok := named.(reference.Digested); ok {
+		ref.Digest = d.Digest()
+	}
+	return ref, nil
+}
+
+// Name returns the reference without tag and digest.
+func (r Reference) Name() string {
+	return fmt.Sprintf("%s/%s", r.Registry, r.Repository)
+}
+
+// String returns the full reference.
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s = fmt.Sprintf("%s:%s", s, r.Tag)
+	}
+	if r.Digest != "" {
+		s = fmt.Sprintf("%s@%s", s, r.Digest)
+	}
+	return s
+}
+
+// identifier is the tag or digest used to address the manifest,
+// digests win because they are immutable and references without
+// both point to the latest tag.
+func (r Reference) identifier() string {
+	if r.Digest != "" {
+		return r.Digest.String()
+	}
+	if r.Tag == "" {
+		return "latest"
+	}
+	return r.Tag
+}
+
+// Relocate moves image to registry keeping its repository path, tag and digest,
+// so quay.io/jetstack/cert-manager-controller:v1.5.3 relocated to
+// registry.local:5000 is registry.local:5000/jetstack/cert-manager-controller:v1.5.3.
+func Relocate(image, registry string) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	registry = strings.TrimSuffix(registry, "/")
+	if strings.HasPrefix(ref.Name(), registry+"/") {
+		return ref.String(), nil
+	}
+	ref.Registry = registry
+	return ref.String(), nil
+}
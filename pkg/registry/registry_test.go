@@ -0,0 +1,114 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/getupio-undistro/undistro/pkg/registry/registrytest"
+)
+
+func TestRelocate(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"quay.io/jetstack/cert-manager-controller:v1.5.3", "registry.local:5000/jetstack/cert-manager-controller:v1.5.3"},
+		{"nginx", "registry.local:5000/library/nginx"},
+		{"registry.undistro.io/k8s", "registry.local:5000/k8s"},
+		{"k8s.gcr.io/ingress-nginx/controller:v1.0.4@sha256:545cff00370f28363dad31e3b59a94ba377854d3a11f18988f5f9e56841ef9ef", "registry.local:5000/ingress-nginx/controller:v1.0.4@sha256:545cff00370f28363dad31e3b59a94ba377854d3a11f18988f5f9e56841ef9ef"},
+		{"registry.local:5000/library/undistro:v0.37.2", "registry.local:5000/library/undistro:v0.37.2"},
+	}
+	for _, tt := range tests {
+		got, err := Relocate(tt.image, "registry.local:5000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Relocate(%s) = %s, want %s", tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestImages(t *testing.T) {
+	manifests := []byte(`
+spec:
+  containers:
+  - name: manager
+    image: "registry.undistro.io/library/undistro:v0.37.2"
+  - image: quay.io/jetstack/cert-manager-controller:v1.5.3 # controller
+    name: cert-manager
+  - name: proxy
+    image: registry.undistro.io/library/undistro:v0.37.2
+    imagePullPolicy: IfNotPresent
+`)
+	want := []string{"quay.io/jetstack/cert-manager-controller:v1.5.3", "registry.undistro.io/library/undistro:v0.37.2"}
+	if got := FindImages(manifests); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindImages() = %v, want %v", got, want)
+	}
+	want = []string{"registry.local/jetstack/cert-manager-controller:v1.5.3", "registry.local/library/undistro:v0.37.2"}
+	relocated := RelocateImages(manifests, "registry.local")
+	if got := FindImages(relocated); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindImages(RelocateImages()) = %v, want %v", got, want)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`)
+	want := map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull",
+	}
+	if scheme != "Bearer" || !reflect.DeepEqual(params, want) {
+		t.Errorf("parseChallenge() = %s %v, want Bearer %v", scheme, params, want)
+	}
+}
+
+func TestArtifact(t *testing.T) {
+	srv, _ := registrytest.NewServer()
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	c := NewClient()
+	ref, err := ParseReference(u.Host + "/metadata/aws.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.PullArtifact(ctx, ref)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("PullArtifact() error = %v, want ErrNotFound", err)
+	}
+	content := []byte("kind: AWSMachine\n")
+	err = c.PushArtifact(ctx, ref, FileConfigMediaType, []byte("{}"), FileLayerMediaType, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.PullArtifact(ctx, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("PullArtifact() = %q, want %q", got, content)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2020-2021 The UnDistro authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package registrytest provides an in-memory registry for tests.
+package registrytest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+)
+
+type manifest struct {
+	mediaType string
+	body      []byte
+}
+
+// Registry implements the parts of the distribution API v2 used by UnDistro.
+type Registry struct {
+	mu        sync.Mutex
+	blobs     map[digest.Digest][]byte
+	manifests map[string]manifest
+	uploads   int
+}
+
+// NewServer starts a plain HTTP server backed by a new Registry.
+// The host of the server URL is the registry address.
+func NewServer() (*httptest.Server, *Registry) {
+	r := &Registry{
+		blobs:     make(map[digest.Digest][]byte),
+		manifests: make(map[string]manifest),
+	}
+	return httptest.NewServer(r), r
+}
+
+// Manifest returns the manifest of repo tagged or with digest ref.
+func (r *Registry) Manifest(repo, ref string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.manifests[repo+"@"+ref]
+	return m.body, ok
+}
+
+// AddManifest stores a manifest under tag and under its digest.
+func (r *Registry) AddManifest(repo, tag, mediaType string, body []byte) digest.Digest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := digest.FromBytes(body)
+	m := manifest{mediaType: mediaType, body: body}
+	r.manifests[repo+"@"+d.String()] = m
+	if tag != "" {
+		r.manifests[repo+"@"+tag] = m
+	}
+	return d
+}
+
+// AddBlob stores a blob and returns its digest.
+func (r *Registry) AddBlob(body []byte) digest.Digest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := digest.FromBytes(body)
+	r.blobs[d] = body
+	return d
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if i := strings.LastIndex(p, "/manifests/"); i > 0 {
+		r.serveManifest(w, req, p[:i], p[i+len("/manifests/"):])
+		return
+	}
+	if i := strings.LastIndex(p, "/blobs/uploads/"); i > 0 {
+		r.serveUpload(w, req, p[:i])
+		return
+	}
+	if i := strings.LastIndex(p, "/blobs/"); i > 0 {
+		r.serveBlob(w, req, digest.Digest(p[i+len("/blobs/"):]))
+		return
+	}
+	http.NotFound(w, req)
+}
+
+func (r *Registry) serveManifest(w http.ResponseWriter, req *http.Request, repo, ref string) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		r.mu.Lock()
+		m, ok := r.manifests[repo+"@"+ref]
+		r.mu.Unlock()
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", m.mediaType)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(m.body).String())
+		_, _ = w.Write(m.body)
+	case http.MethodPut:
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tag := ref
+		if _, err := digest.Parse(ref); err == nil {
+			tag = ""
+		}
+		d := r.AddManifest(repo, tag, req.Header.Get("Content-Type"), body)
+		w.Header().Set("Docker-Content-Digest", d.String())
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *Registry) serveUpload(w http.ResponseWriter, req *http.Request, repo string) {
+	switch req.Method {
+	case http.MethodPost:
+		r.mu.Lock()
+		r.uploads++
+		id := r.uploads
+		r.mu.Unlock()
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%d", repo, id))
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPut:
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if d := r.AddBlob(body); d.String() != req.URL.Query().Get("digest") {
+			http.Error(w, "digest mismatch", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *Registry) serveBlob(w http.ResponseWriter, req *http.Request, d digest.Digest) {
+	r.mu.Lock()
+	body, ok := r.blobs[d]
+	r.mu.Unlock()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	if req.Method == http.MethodGet {
+		_, _ = w.Write(body)
+	}
+}
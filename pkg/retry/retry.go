@@ -16,6 +16,7 @@ limitations under the License.
 package retry
 
 import (
+	"context"
 	"time"
 
 	"github.com/pkg/errors"
@@ -37,9 +38,14 @@ func NewBackoff() wait.Backoff {
 
 // WithExponentialBackoff repeats an operation until it passes or the exponential backoff times out.
 func WithExponentialBackoff(opts wait.Backoff, operation func() error) error {
+	return WithExponentialBackoffContext(context.Background(), opts, operation)
+}
+
+// WithExponentialBackoffContext is WithExponentialBackoff stopping when the context is done.
+func WithExponentialBackoffContext(ctx context.Context, opts wait.Backoff, operation func() error) error {
 	log := log.Log
 	i := 0
-	err := wait.ExponentialBackoff(opts, func() (bool, error) {
+	err := wait.ExponentialBackoffWithContext(ctx, opts, func() (bool, error) {
 		i++
 		if err := operation(); err != nil {
 			if i < opts.Steps {
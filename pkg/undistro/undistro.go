@@ -32,6 +32,7 @@ const (
 	Namespace           = "undistro-system"
 	MgmtClusterName     = "undistro"
 	DefaultRepo         = "https://registry.undistro.io/chartrepo/library"
+	MetadataURL         = "https://undistro.io/resources/metadata"
 	loginAudienceSecret = "undistro-login-audience"
 )
 
@@ -85,8 +86,6 @@ func GetRequestAudience() string {
 
 const LocalCluster = "undistro"
 
-var KindCmdDestroy = `kind delete cluster --name "%s"`
-
 var KindCfg = `kind: Cluster
 apiVersion: kind.x-k8s.io/v1alpha4
 networking:
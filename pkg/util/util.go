@@ -50,13 +50,30 @@ var chartNameRegex *regexp.Regexp
 
 type LocalClusterType int8
 
+// MinikubeProfileLabel is the label of minikube nodes with the name of their profile.
+const MinikubeProfileLabel = "minikube.k8s.io/name"
+
 const (
 	NonLocal LocalClusterType = iota
 	NodeRetrieveError
 	Kind
 	Minikube
+	K3d
 )
 
+// String returns the name of the tool creating the local cluster.
+func (t LocalClusterType) String() string {
+	switch t {
+	case Kind:
+		return "kind"
+	case Minikube:
+		return "minikube"
+	case K3d:
+		return "k3d"
+	}
+	return ""
+}
+
 func init() {
 	chartNameRegex = regexp.MustCompile("[a-z]+-?[a-z]{2,}")
 }
@@ -262,7 +279,8 @@ func RemoveDuplicateTaints(taints []corev1.Taint) []corev1.Taint {
 	return res
 }
 
-//// Asserts whether the current cluster is local, and the kind of local cluster. Each type is mapped to the enum <LocalClusterType>.
+// IsLocalCluster asserts whether the current cluster is local, and the kind of local cluster.
+// Each type is mapped to the enum <LocalClusterType>.
 func IsLocalCluster(ctx context.Context, c client.Client) (LocalClusterType, error) {
 	nodes := corev1.NodeList{
 		TypeMeta: metav1.TypeMeta{},
@@ -273,26 +291,40 @@ func IsLocalCluster(ctx context.Context, c client.Client) (LocalClusterType, err
 	if err != nil {
 		return NodeRetrieveError, err
 	}
-	var cTyp int8
 	for _, node := range nodes.Items {
-		for _, image := range node.Status.Images {
-			for _, name := range image.Names {
-				if strings.Contains(name, "kindnet") {
-					cTyp++
-				} else if strings.Contains(name, "minikube") {
-					cTyp++
-				}
-			}
+		typ := NodeLocalClusterType(node)
+		if typ != NonLocal {
+			return typ, nil
 		}
 	}
-	if cTyp == 1 {
-		return Kind, nil
-	} else if cTyp > 1 {
-		return Minikube, nil
-	}
 	return NonLocal, nil
 }
 
+// NodeLocalClusterType returns the kind of local cluster the node belongs to.
+func NodeLocalClusterType(node corev1.Node) LocalClusterType {
+	switch {
+	case strings.HasPrefix(node.Spec.ProviderID, "kind://"):
+		return Kind
+	case node.Labels[MinikubeProfileLabel] != "":
+		return Minikube
+	// k3s clusters are local when their nodes are k3d containers
+	case strings.HasPrefix(node.Spec.ProviderID, "k3s://") && strings.HasPrefix(node.Name, "k3d-"):
+		return K3d
+	}
+	// older kind and minikube versions are recognized by their images
+	for _, image := range node.Status.Images {
+		for _, name := range image.Names {
+			if strings.Contains(name, "kindnet") {
+				return Kind
+			}
+			if strings.Contains(name, "minikube") {
+				return Minikube
+			}
+		}
+	}
+	return NonLocal
+}
+
 func ChartNameByFile(name string) string {
 	// just support `chart-name` format
 	// `chart-name-test` format will fail
@@ -24,6 +24,7 @@ import (
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/release"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestMergeMaps(t *testing.T) {
@@ -362,3 +363,67 @@ func TestChartNameByFile(t *testing.T) {
 		})
 	}
 }
+
+func TestNodeLocalClusterType(t *testing.T) {
+	tests := []struct {
+		name string
+		node corev1.Node
+		want LocalClusterType
+	}{
+		{
+			name: "kind",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "undistro-control-plane"},
+				Spec:       corev1.NodeSpec{ProviderID: "kind://docker/undistro/undistro-control-plane"},
+			},
+			want: Kind,
+		},
+		{
+			name: "old kind",
+			node: corev1.Node{
+				Status: corev1.NodeStatus{Images: []corev1.ContainerImage{
+					{Names: []string{"docker.io/kindest/kindnetd@sha256:060b", "docker.io/kindest/kindnetd:v20210326-1e038dc5"}},
+				}},
+			},
+			want: Kind,
+		},
+		{
+			name: "minikube",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "undistro", Labels: map[string]string{MinikubeProfileLabel: "undistro"}},
+			},
+			want: Minikube,
+		},
+		{
+			name: "k3d",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "k3d-undistro-server-0"},
+				Spec:       corev1.NodeSpec{ProviderID: "k3s://k3d-undistro-server-0"},
+			},
+			want: K3d,
+		},
+		{
+			name: "k3s",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "edge-1"},
+				Spec:       corev1.NodeSpec{ProviderID: "k3s://edge-1"},
+			},
+			want: NonLocal,
+		},
+		{
+			name: "aws",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "ip-10-0-1-10.ec2.internal"},
+				Spec:       corev1.NodeSpec{ProviderID: "aws:///us-east-1a/i-0123456789abcdef0"},
+			},
+			want: NonLocal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NodeLocalClusterType(tt.node); got != tt.want {
+				t.Errorf("NodeLocalClusterType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}